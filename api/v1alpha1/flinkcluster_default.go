@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -26,6 +27,7 @@ func _SetDefault(cluster *FlinkCluster) {
 	_SetJobManagerDefault(&cluster.Spec.JobManagerSpec)
 	_SetTaskManagerDefault(&cluster.Spec.TaskManagerSpec)
 	_SetJobDefault(cluster.Spec.JobSpec)
+	_SetMonitoringDefault(cluster.Spec.Monitoring)
 }
 
 func _SetImageDefault(imageSpec *ImageSpec) {
@@ -58,6 +60,10 @@ func _SetJobManagerDefault(jmSpec *JobManagerSpec) {
 		jmSpec.Ports.UI = new(int32)
 		*jmSpec.Ports.UI = 8081
 	}
+	if jmSpec.Ingress != nil && jmSpec.Ingress.Path == nil {
+		jmSpec.Ingress.Path = new(string)
+		*jmSpec.Ingress.Path = "/"
+	}
 }
 
 func _SetTaskManagerDefault(tmSpec *TaskManagerSpec) {
@@ -73,12 +79,25 @@ func _SetTaskManagerDefault(tmSpec *TaskManagerSpec) {
 		tmSpec.Ports.Query = new(int32)
 		*tmSpec.Ports.Query = 6125
 	}
+	if tmSpec.DeploymentType == nil {
+		tmSpec.DeploymentType = new(string)
+		*tmSpec.DeploymentType = TaskManagerDeploymentType.Deployment
+	}
+	if *tmSpec.DeploymentType == TaskManagerDeploymentType.StatefulSet &&
+		tmSpec.PodManagementPolicy == nil {
+		tmSpec.PodManagementPolicy = new(appsv1.PodManagementPolicyType)
+		*tmSpec.PodManagementPolicy = appsv1.ParallelPodManagement
+	}
 }
 
 func _SetJobDefault(jobSpec *JobSpec) {
 	if jobSpec == nil {
 		return
 	}
+	if jobSpec.Mode == nil {
+		jobSpec.Mode = new(string)
+		*jobSpec.Mode = JobMode.Client
+	}
 	if jobSpec.AllowNonRestoredState == nil {
 		jobSpec.AllowNonRestoredState = new(bool)
 		*jobSpec.AllowNonRestoredState = false
@@ -95,4 +114,49 @@ func _SetJobDefault(jobSpec *JobSpec) {
 		jobSpec.RestartPolicy = new(corev1.RestartPolicy)
 		*jobSpec.RestartPolicy = corev1.RestartPolicyOnFailure
 	}
+	if *jobSpec.RestartPolicy == corev1.RestartPolicy(
+		JobRestartPolicy.FromSavepointOnFailure) {
+		if jobSpec.MaxRestartAttempts == nil {
+			jobSpec.MaxRestartAttempts = new(int32)
+			*jobSpec.MaxRestartAttempts = 3
+		}
+		if jobSpec.RestartBackoffSeconds == nil {
+			jobSpec.RestartBackoffSeconds = new(int32)
+			*jobSpec.RestartBackoffSeconds = 10
+		}
+	}
+	if jobSpec.TakeSavepointOnDelete != nil && *jobSpec.TakeSavepointOnDelete &&
+		jobSpec.SavepointOnDeleteTimeoutSeconds == nil {
+		jobSpec.SavepointOnDeleteTimeoutSeconds = new(int32)
+		*jobSpec.SavepointOnDeleteTimeoutSeconds = 600
+	}
+	_SetJobAutoscalerDefault(jobSpec.Autoscaler)
+}
+
+func _SetJobAutoscalerDefault(autoscalerSpec *JobAutoscalerSpec) {
+	if autoscalerSpec == nil {
+		return
+	}
+	if autoscalerSpec.MinParallelism == nil {
+		autoscalerSpec.MinParallelism = new(int32)
+		*autoscalerSpec.MinParallelism = 1
+	}
+	if autoscalerSpec.TargetUtilization == nil {
+		autoscalerSpec.TargetUtilization = new(int32)
+		*autoscalerSpec.TargetUtilization = 70
+	}
+	if autoscalerSpec.StabilizationWindowSeconds == nil {
+		autoscalerSpec.StabilizationWindowSeconds = new(int32)
+		*autoscalerSpec.StabilizationWindowSeconds = 300
+	}
+}
+
+func _SetMonitoringDefault(monitoringSpec *MonitoringSpec) {
+	if monitoringSpec == nil {
+		return
+	}
+	if monitoringSpec.Port == nil {
+		monitoringSpec.Port = new(int32)
+		*monitoringSpec.Port = 9249
+	}
 }
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"gotest.tools/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -37,6 +38,8 @@ func TestSetDefault(t *testing.T) {
 	var defaultTmDataPort = int32(6121)
 	var defaultTmRPCPort = int32(6122)
 	var defaultTmQueryPort = int32(6125)
+	var defaultTmDeploymentType = "Deployment"
+	var defaultJobMode = "Client"
 	var defaultJobAllowNonRestoredState = false
 	var defaultJobParallelism = int32(1)
 	var defaultJobNoLoggingToStdout = false
@@ -71,10 +74,12 @@ func TestSetDefault(t *testing.T) {
 					RPC:   &defaultTmRPCPort,
 					Query: &defaultTmQueryPort,
 				},
-				Resources: corev1.ResourceRequirements{},
-				Volumes:   nil,
+				Resources:      corev1.ResourceRequirements{},
+				Volumes:        nil,
+				DeploymentType: &defaultTmDeploymentType,
 			},
 			JobSpec: &JobSpec{
+				Mode:                  &defaultJobMode,
 				AllowNonRestoredState: &defaultJobAllowNonRestoredState,
 				Parallelism:           &defaultJobParallelism,
 				NoLoggingToStdout:     &defaultJobNoLoggingToStdout,
@@ -99,6 +104,9 @@ func TestSetNonDefault(t *testing.T) {
 	var tmDataPort = int32(8121)
 	var tmRPCPort = int32(8122)
 	var tmQueryPort = int32(8125)
+	var tmDeploymentType = "StatefulSet"
+	var tmPodManagementPolicy = appsv1.OrderedReadyPodManagement
+	var jobMode = "Application"
 	var jobAllowNonRestoredState = true
 	var jobParallelism = int32(2)
 	var jobNoLoggingToStdout = true
@@ -132,10 +140,13 @@ func TestSetNonDefault(t *testing.T) {
 					RPC:   &tmRPCPort,
 					Query: &tmQueryPort,
 				},
-				Resources: corev1.ResourceRequirements{},
-				Volumes:   nil,
+				Resources:           corev1.ResourceRequirements{},
+				Volumes:             nil,
+				DeploymentType:      &tmDeploymentType,
+				PodManagementPolicy: &tmPodManagementPolicy,
 			},
 			JobSpec: &JobSpec{
+				Mode:                  &jobMode,
 				AllowNonRestoredState: &jobAllowNonRestoredState,
 				Parallelism:           &jobParallelism,
 				NoLoggingToStdout:     &jobNoLoggingToStdout,
@@ -178,10 +189,13 @@ func TestSetNonDefault(t *testing.T) {
 					RPC:   &tmRPCPort,
 					Query: &tmQueryPort,
 				},
-				Resources: corev1.ResourceRequirements{},
-				Volumes:   nil,
+				Resources:           corev1.ResourceRequirements{},
+				Volumes:             nil,
+				DeploymentType:      &tmDeploymentType,
+				PodManagementPolicy: &tmPodManagementPolicy,
 			},
 			JobSpec: &JobSpec{
+				Mode:                  &jobMode,
 				AllowNonRestoredState: &jobAllowNonRestoredState,
 				Parallelism:           &jobParallelism,
 				NoLoggingToStdout:     &jobNoLoggingToStdout,
@@ -195,3 +209,86 @@ func TestSetNonDefault(t *testing.T) {
 
 	assert.DeepEqual(t, cluster, expectedCluster)
 }
+
+// Tests the path of the JobManager ingress is defaulted only when the ingress
+// is specified.
+func TestSetJobManagerIngressDefault(t *testing.T) {
+	var cluster = FlinkCluster{}
+	_SetDefault(&cluster)
+	assert.Assert(t, cluster.Spec.JobManagerSpec.Ingress == nil)
+
+	cluster.Spec.JobManagerSpec.Ingress = &JobManagerIngressSpec{}
+	_SetDefault(&cluster)
+	assert.Equal(t, *cluster.Spec.JobManagerSpec.Ingress.Path, "/")
+}
+
+// Tests the metrics port is defaulted only when monitoring is specified.
+func TestSetMonitoringDefault(t *testing.T) {
+	var cluster = FlinkCluster{}
+	_SetDefault(&cluster)
+	assert.Assert(t, cluster.Spec.Monitoring == nil)
+
+	cluster.Spec.Monitoring = &MonitoringSpec{}
+	_SetDefault(&cluster)
+	assert.Equal(t, *cluster.Spec.Monitoring.Port, int32(9249))
+}
+
+// Tests the max attempts and the backoff of restarts are defaulted only for the
+// FromSavepointOnFailure restart policy.
+func TestSetRestartDefault(t *testing.T) {
+	var cluster = FlinkCluster{Spec: FlinkClusterSpec{JobSpec: &JobSpec{}}}
+	_SetDefault(&cluster)
+	assert.Assert(t, cluster.Spec.JobSpec.MaxRestartAttempts == nil)
+	assert.Assert(t, cluster.Spec.JobSpec.RestartBackoffSeconds == nil)
+
+	var restartPolicy = corev1.RestartPolicy(
+		JobRestartPolicy.FromSavepointOnFailure)
+	cluster.Spec.JobSpec.RestartPolicy = &restartPolicy
+	_SetDefault(&cluster)
+	assert.Equal(t, *cluster.Spec.JobSpec.MaxRestartAttempts, int32(3))
+	assert.Equal(t, *cluster.Spec.JobSpec.RestartBackoffSeconds, int32(10))
+}
+
+// Tests the timeout of the savepoint on deletion is defaulted only when the
+// savepoint is enabled.
+func TestSetSavepointOnDeleteTimeoutDefault(t *testing.T) {
+	var cluster = FlinkCluster{Spec: FlinkClusterSpec{JobSpec: &JobSpec{}}}
+	_SetDefault(&cluster)
+	assert.Assert(t, cluster.Spec.JobSpec.SavepointOnDeleteTimeoutSeconds == nil)
+
+	var takeSavepointOnDelete = true
+	cluster.Spec.JobSpec.TakeSavepointOnDelete = &takeSavepointOnDelete
+	_SetDefault(&cluster)
+	assert.Equal(
+		t, *cluster.Spec.JobSpec.SavepointOnDeleteTimeoutSeconds, int32(600))
+}
+
+// Tests the options of the autoscaler are defaulted only when the autoscaler is
+// specified.
+func TestSetJobAutoscalerDefault(t *testing.T) {
+	var cluster = FlinkCluster{Spec: FlinkClusterSpec{JobSpec: &JobSpec{}}}
+	_SetDefault(&cluster)
+	assert.Assert(t, cluster.Spec.JobSpec.Autoscaler == nil)
+
+	cluster.Spec.JobSpec.Autoscaler = &JobAutoscalerSpec{MaxParallelism: 8}
+	_SetDefault(&cluster)
+	var autoscalerSpec = cluster.Spec.JobSpec.Autoscaler
+	assert.Equal(t, *autoscalerSpec.MinParallelism, int32(1))
+	assert.Equal(t, *autoscalerSpec.TargetUtilization, int32(70))
+	assert.Equal(t, *autoscalerSpec.StabilizationWindowSeconds, int32(300))
+}
+
+// Tests the pod management policy is defaulted only for StatefulSet.
+func TestSetPodManagementPolicyDefault(t *testing.T) {
+	var cluster = FlinkCluster{}
+	_SetDefault(&cluster)
+	assert.Assert(t, cluster.Spec.TaskManagerSpec.PodManagementPolicy == nil)
+
+	var deploymentType = TaskManagerDeploymentType.StatefulSet
+	cluster.Spec.TaskManagerSpec.DeploymentType = &deploymentType
+	_SetDefault(&cluster)
+	assert.Equal(
+		t,
+		*cluster.Spec.TaskManagerSpec.PodManagementPolicy,
+		appsv1.ParallelPodManagement)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -28,12 +29,14 @@ var ClusterState = struct {
 	Reconciling string
 	Stopping    string
 	Stopped     string
+	Failed      string
 }{
 	Creating:    "Creating",
 	Running:     "Running",
 	Reconciling: "Reconciling",
 	Stopping:    "Stopping",
 	Stopped:     "Stopped",
+	Failed:      "Failed",
 }
 
 // ClusterComponentState defines states for a cluster component.
@@ -47,28 +50,73 @@ var ClusterComponentState = struct {
 	Deleted:  "Deleted",
 }
 
+// ClusterConditionType defines types of the conditions of a cluster.
+var ClusterConditionType = struct {
+	Ready             string
+	JobManagerReady   string
+	TaskManagersReady string
+	JobRunning        string
+	Progressing       string
+	Degraded          string
+	ReconcileError    string
+}{
+	Ready:             "Ready",
+	JobManagerReady:   "JobManagerReady",
+	TaskManagersReady: "TaskManagersReady",
+	JobRunning:        "JobRunning",
+	Progressing:       "Progressing",
+	Degraded:          "Degraded",
+	ReconcileError:    "ReconcileError",
+}
+
 // JobState defines states for a Flink job.
 var JobState = struct {
-	Pending   string
-	Running   string
-	Succeeded string
-	Failed    string
-	Unknown   string
+	Pending    string
+	Running    string
+	Failing    string
+	Restarting string
+	Succeeded  string
+	Failed     string
+	Cancelled  string
+	Unknown    string
+}{
+	Pending:    "Pending",
+	Running:    "Running",
+	Failing:    "Failing",
+	Restarting: "Restarting",
+	Succeeded:  "Succeeded",
+	Failed:     "Failed",
+	Cancelled:  "Cancelled",
+	Unknown:    "Unknown",
+}
+
+// JobUpgradeState defines states for the upgrade of a Flink job.
+var JobUpgradeState = struct {
+	TakingSavepoint string
+	Resubmitting    string
 }{
-	Pending:   "Pending",
-	Running:   "Running",
-	Succeeded: "Succeeded",
-	Failed:    "Failed",
-	Unknown:   "Unknown",
+	TakingSavepoint: "TakingSavepoint",
+	Resubmitting:    "Resubmitting",
+}
+
+// JobMode defines how the job of a job cluster is run.
+var JobMode = struct {
+	Client      string
+	Application string
+}{
+	Client:      "Client",
+	Application: "Application",
 }
 
 // JobRestartPolicy defines the policy for job restart.
 var JobRestartPolicy = struct {
-	OnFailure string
-	Never     string
+	OnFailure              string
+	Never                  string
+	FromSavepointOnFailure string
 }{
-	OnFailure: "OnFailure",
-	Never:     "Never",
+	OnFailure:              "OnFailure",
+	Never:                  "Never",
+	FromSavepointOnFailure: "FromSavepointOnFailure",
 }
 
 // AccessScope defines the access scope of JobManager service.
@@ -82,6 +130,36 @@ var AccessScope = struct {
 	External: "External",
 }
 
+// HighAvailabilityMode defines the services used by JobManagers for leader
+// election and recovery.
+var HighAvailabilityMode = struct {
+	Kubernetes string
+	ZooKeeper  string
+}{
+	Kubernetes: "Kubernetes",
+	ZooKeeper:  "ZooKeeper",
+}
+
+// TaskManagerDeploymentType defines the kind of the controller of TaskManager
+// pods.
+var TaskManagerDeploymentType = struct {
+	Deployment  string
+	StatefulSet string
+}{
+	Deployment:  "Deployment",
+	StatefulSet: "StatefulSet",
+}
+
+// MonitorKind defines the kind of the Prometheus Operator object which selects
+// the metrics endpoints of a cluster for Prometheus.
+var MonitorKind = struct {
+	PodMonitor     string
+	ServiceMonitor string
+}{
+	PodMonitor:     "PodMonitor",
+	ServiceMonitor: "ServiceMonitor",
+}
+
 // ImageSpec defines Flink image of JobManager and TaskManager containers.
 type ImageSpec struct {
 	// Flink image name.
@@ -110,6 +188,29 @@ type JobManagerPorts struct {
 	UI *int32 `json:"ui,omitempty"`
 }
 
+// JobManagerIngressSpec defines the ingress of the JobManager web UI.
+type JobManagerIngressSpec struct {
+	// Host format of the ingress, in which "{{$clusterName}}" and
+	// "{{$clusterNamespace}}" are replaced with the name and namespace of the
+	// cluster, e.g., "{{$clusterName}}.example.com". If omitted, the ingress
+	// matches all hosts.
+	HostFormat *string `json:"hostFormat,omitempty"`
+
+	// Path of the web UI, default: "/".
+	Path *string `json:"path,omitempty"`
+
+	// Ingress class, which selects the ingress controller through the
+	// "kubernetes.io/ingress.class" annotation.
+	IngressClass *string `json:"ingressClass,omitempty"`
+
+	// Name of the secret with the TLS certificate of the host, TLS is enabled
+	// if specified.
+	TLSSecretName *string `json:"tlsSecretName,omitempty"`
+
+	// Annotations of the ingress, e.g., for the ingress controller.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
 // JobManagerSpec defines properties of JobManager.
 type JobManagerSpec struct {
 	// The number of replicas.
@@ -121,9 +222,11 @@ type JobManagerSpec struct {
 	// Ports.
 	Ports JobManagerPorts `json:"ports,omitempty"`
 
+	// Optional ingress of the web UI, in addition to the JobManager service.
+	Ingress *JobManagerIngressSpec `json:"ingress,omitempty"`
+
 	// Compute resources required by each JobManager container.
 	// If omitted, a default value will be used.
-	// Cannot be updated.
 	// More info: https://kubernetes.io/docs/concepts/configuration/manage-compute-resources-container/
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 
@@ -137,6 +240,11 @@ type JobManagerSpec struct {
 	// scheduled on that node.
 	// More info: https://kubernetes.io/docs/concepts/configuration/assign-pod-node/
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Pod template which is strategically merged onto the JobManager pod
+	// template generated by the operator, e.g., for affinity, tolerations,
+	// probes or extra labels. The main container is named "jobmanager".
+	PodTemplate *corev1.PodTemplateSpec `json:"podTemplate,omitempty"`
 }
 
 // TaskManagerPorts defines ports of TaskManager.
@@ -161,7 +269,6 @@ type TaskManagerSpec struct {
 
 	// Compute resources required by each TaskManager container.
 	// If omitted, a default value will be used.
-	// Cannot be updated.
 	// More info: https://kubernetes.io/docs/concepts/configuration/manage-compute-resources-container/
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 
@@ -179,22 +286,201 @@ type TaskManagerSpec struct {
 	// Sidecar containers running alongside with the TaskManager container in the
 	// pod.
 	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+
+	// Pod template which is strategically merged onto the TaskManager pod
+	// template generated by the operator, e.g., for affinity, tolerations,
+	// probes or extra labels. The main container is named "taskmanager".
+	PodTemplate *corev1.PodTemplateSpec `json:"podTemplate,omitempty"`
+
+	// The kind of the controller of TaskManager pods, enum("Deployment",
+	// "StatefulSet"), default: "Deployment". With "StatefulSet", each pod keeps
+	// its hostname and persistent volumes across restarts, e.g., for the local
+	// state of RocksDB and local recovery.
+	DeploymentType *string `json:"deploymentType,omitempty"`
+
+	// Claims of the persistent volumes of each TaskManager pod, only for
+	// "StatefulSet". The volumes are mounted with Mounts by the names of the
+	// claims.
+	VolumeClaimTemplates []corev1.PersistentVolumeClaim `json:"volumeClaimTemplates,omitempty"`
+
+	// How the pods of the StatefulSet are created and deleted,
+	// enum("OrderedReady", "Parallel"), default: "Parallel", only for
+	// "StatefulSet".
+	PodManagementPolicy *appsv1.PodManagementPolicyType `json:"podManagementPolicy,omitempty"`
+}
+
+// TaskManagerPoolSpec defines a pool of TaskManagers which differ from the
+// TaskManagers of TaskManagerSpec in their number, resources, placement and
+// task slots, e.g., high-memory TaskManagers on a dedicated node pool. The
+// other fields of TaskManagerSpec, e.g., the ports, volumes and pod template,
+// are shared by the pools.
+type TaskManagerPoolSpec struct {
+	// The name of the pool, unique in the cluster. The deployment of the pool
+	// is named "<cluster name>-taskmanager-<pool name>".
+	Name string `json:"name"`
+
+	// The number of replicas.
+	Replicas int32 `json:"replicas"`
+
+	// Compute resources required by each TaskManager container of the pool,
+	// default: the resources of TaskManagerSpec.
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Selector which must match a node's labels for the TaskManager pods of
+	// the pool to be scheduled on that node, default: the node selector of
+	// TaskManagerSpec.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations of the TaskManager pods of the pool, e.g., for the taints
+	// of a dedicated node pool.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// The number of task slots of each TaskManager of the pool, default:
+	// "taskmanager.numberOfTaskSlots" in the Flink properties.
+	TaskSlots *int32 `json:"taskSlots,omitempty"`
+}
+
+// HighAvailabilitySpec defines the high availability services of JobManager.
+type HighAvailabilitySpec struct {
+	// HA services, enum("Kubernetes", "ZooKeeper"). Kubernetes HA stores the
+	// leader information in ConfigMaps, it requires Flink 1.12 or later.
+	Mode string `json:"mode"`
+
+	// The file system path where JobManager metadata is persisted for
+	// recovery, e.g., gs://my-bucket/flink/ha.
+	StorageDir string `json:"storageDir"`
+
+	// The ID which isolates the HA data of the cluster from other clusters
+	// sharing the same storage dir or ZooKeeper quorum, default:
+	// <namespace>-<name>.
+	ClusterID *string `json:"clusterID,omitempty"`
+
+	// ZooKeeper quorum, e.g., "zk-0.zk:2181,zk-1.zk:2181", required for
+	// ZooKeeper HA.
+	ZooKeeperQuorum *string `json:"zooKeeperQuorum,omitempty"`
+}
+
+// MonitoringSpec defines the Prometheus monitoring of a cluster. The Flink
+// Prometheus reporter is enabled on JobManager and TaskManagers, which requires
+// flink-metrics-prometheus in /opt/flink/lib of the image.
+type MonitoringSpec struct {
+	// The port of the Prometheus reporter, which is exposed as the "metrics"
+	// port of the JobManager and TaskManager containers and the JobManager
+	// service, default: 9249.
+	Port *int32 `json:"port,omitempty"`
+
+	// The kind of the Prometheus Operator object created for the cluster,
+	// enum("PodMonitor", "ServiceMonitor"). A PodMonitor selects both
+	// JobManager and TaskManagers, while a ServiceMonitor only selects the
+	// JobManager service, so the metrics of TaskManagers are not scraped
+	// through it. No object is created if it is not specified, e.g.,
+	// when the Prometheus Operator is not installed.
+	Monitor *string `json:"monitor,omitempty"`
+
+	// Labels of the monitor object, e.g., to be selected by the Prometheus
+	// instance.
+	MonitorLabels map[string]string `json:"monitorLabels,omitempty"`
+
+	// The interval between scrapes of the monitor object, e.g., "30s",
+	// default: the scrape interval of Prometheus.
+	ScrapeInterval *string `json:"scrapeInterval,omitempty"`
+}
+
+// SQLScriptSpec defines the source of the SQL script of a job, either a key of
+// a ConfigMap or the inline text.
+type SQLScriptSpec struct {
+	// The key of the ConfigMap which holds the script, the ConfigMap must be in
+	// the namespace of the cluster.
+	ConfigMap *corev1.ConfigMapKeySelector `json:"configMap,omitempty"`
+
+	// The text of the script.
+	Inline *string `json:"inline,omitempty"`
+}
+
+// ArtifactSpec defines a remote file of a job, which is fetched by the
+// operator before the job is run.
+type ArtifactSpec struct {
+	// URI of the file, "http://", "https://", "gs://", "s3://", or
+	// "pvc://<claim name>/<path>" for a file in a PersistentVolumeClaim.
+	URI string `json:"uri"`
+
+	// SHA-256 checksum of the file in hex, which is verified once the file is
+	// fetched.
+	SHA256 *string `json:"sha256,omitempty"`
+}
+
+// ArtifactFetcherSpec defines the init container which fetches the remote
+// files of a job.
+type ArtifactFetcherSpec struct {
+	// Image of the init container, which must have `curl`, `gsutil` and
+	// `sha256sum`, default: "google/cloud-sdk:slim".
+	Image *string `json:"image,omitempty"`
+
+	// Name of the Secret with the credentials of the files, its keys are set
+	// as env variables of the init container: AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY for "s3://", GOOGLE_SERVICE_ACCOUNT_KEY for
+	// "gs://", and HTTP_AUTHORIZATION for the Authorization header of
+	// "http(s)://".
+	CredentialsSecret *string `json:"credentialsSecret,omitempty"`
 }
 
 // JobSpec defines properties of a Flink job.
 type JobSpec struct {
-	// JAR file of the job.
-	JarFile string `json:"jarFile"`
-
-	// Fully qualified Java class name of the job.
+	// How the job is run, "Client" or "Application", default: "Client". In
+	// "Client" mode, the job is submitted to JobManager by a separate
+	// Kubernetes job. In "Application" mode, the job is run inside JobManager,
+	// the JAR file must be in /opt/flink/usrlib of the image or mounted there,
+	// or a remote URI, and the restart policy is ignored.
+	Mode *string `json:"mode,omitempty"`
+
+	// JAR file of the job. Exactly one of JarFile, PyFile, PyModule and
+	// SQLScript must be set as the entry point of the job. Remote URIs are
+	// fetched by the operator, see ArtifactSpec for the supported schemes.
+	JarFile string `json:"jarFile,omitempty"`
+
+	// SHA-256 checksum of the remote JAR file in hex.
+	JarFileSHA256 *string `json:"jarFileSHA256,omitempty"`
+
+	// Additional remote files of the job, e.g., dependency JARs, which are
+	// fetched into /opt/flink/usrlib with the JAR file of the job, only in
+	// "Application" mode.
+	Artifacts []ArtifactSpec `json:"artifacts,omitempty"`
+
+	// The init container which fetches the remote files of the job.
+	ArtifactFetcher *ArtifactFetcherSpec `json:"artifactFetcher,omitempty"`
+
+	// Fully qualified Java class name of the job, only for JAR files.
 	ClassName *string `json:"className,omitempty"`
 
-	// Args of the job.
+	// Python file of the job, e.g., /opt/flink/job/word_count.py.
+	PyFile *string `json:"pyFile,omitempty"`
+
+	// Python module of the job, which is looked up in PyFiles.
+	PyModule *string `json:"pyModule,omitempty"`
+
+	// Additional Python files of the job, e.g., .py, .zip or .whl files, which
+	// are added to the PYTHONPATH of the job.
+	PyFiles []string `json:"pyFiles,omitempty"`
+
+	// requirements.txt of the Python dependencies of the job, which are
+	// installed by Flink before the job is run.
+	PyRequirements *string `json:"pyRequirements,omitempty"`
+
+	// SQL script of the job, which is run by the Flink SQL client.
+	SQLScript *SQLScriptSpec `json:"sqlScript,omitempty"`
+
+	// Args of the job. For SQL scripts, they are the options of the SQL
+	// client.
 	Args []string `json:"args,omitempty"`
 
 	// Savepoint where to restore the job from (e.g., gs://my-savepoint/1234).
 	Savepoint *string `json:"savepoint,omitempty"`
 
+	// Savepoints dir where to store savepoints of the job taken by the
+	// operator, e.g., before upgrading the job. If omitted,
+	// `state.savepoints.dir` in Flink properties will be used.
+	SavepointsDir *string `json:"savepointsDir,omitempty"`
+
 	// Allow non-restored state, default: false.
 	AllowNonRestoredState *bool `json:"allowNonRestoredState,omitempty"`
 
@@ -204,14 +490,65 @@ type JobSpec struct {
 	// No logging output to STDOUT, default: false.
 	NoLoggingToStdout *bool `json:"noLoggingToStdout,omitempty"`
 
-	// Restart policy, "OnFailure" or "Never", default: "OnFailure".
+	// Restart policy, "OnFailure", "Never" or "FromSavepointOnFailure",
+	// default: "OnFailure". With "FromSavepointOnFailure", the operator
+	// resubmits the failed job from its latest checkpoint or savepoint, only
+	// for job clusters.
 	RestartPolicy *corev1.RestartPolicy `json:"restartPolicy"`
 
+	// The max number of consecutive restarts with "FromSavepointOnFailure",
+	// after which the job is left failed, default: 3.
+	MaxRestartAttempts *int32 `json:"maxRestartAttempts,omitempty"`
+
+	// The delay of the first restart with "FromSavepointOnFailure" after the
+	// job fails, doubled for each following restart, default: 10.
+	RestartBackoffSeconds *int32 `json:"restartBackoffSeconds,omitempty"`
+
+	// Cancel the job with a savepoint before the cluster is deleted, default:
+	// false. Only for job clusters, the deletion is blocked by a finalizer
+	// until the savepoint completes or times out.
+	TakeSavepointOnDelete *bool `json:"takeSavepointOnDelete,omitempty"`
+
+	// The timeout of the savepoint on deletion, after which the cluster is
+	// deleted without it, default: 600.
+	SavepointOnDeleteTimeoutSeconds *int32 `json:"savepointOnDeleteTimeoutSeconds,omitempty"`
+
 	// Volumes in the Job pod.
 	Volumes []corev1.Volume `json:"volumes,omitempty"`
 
 	// Volume mounts in the Job container.
 	Mounts []corev1.VolumeMount `json:"mounts,omitempty"`
+
+	// Pod template which is strategically merged onto the pod template of the
+	// job submitter generated by the operator. The main container is named
+	// "main". Not supported in "Application" mode, where the job is run by
+	// JobManager.
+	PodTemplate *corev1.PodTemplateSpec `json:"podTemplate,omitempty"`
+
+	// Autoscaler which rescales the job based on the metrics of its vertices,
+	// only for job clusters. With the autoscaler, the parallelism in the job
+	// spec is the initial parallelism, and the replicas of TaskManager are
+	// derived from the parallelism of the job.
+	Autoscaler *JobAutoscalerSpec `json:"autoscaler,omitempty"`
+}
+
+// JobAutoscalerSpec defines the autoscaler of a job, which rescales the job by
+// taking a savepoint and resubmitting the job from it with a new parallelism
+// when the utilization of its vertices leaves the target.
+type JobAutoscalerSpec struct {
+	// The min parallelism of the job, default: 1.
+	MinParallelism *int32 `json:"minParallelism,omitempty"`
+
+	// The max parallelism of the job.
+	MaxParallelism int32 `json:"maxParallelism"`
+
+	// The target utilization of the busiest vertex in percent, i.e., the
+	// share of time it is busy processing records, default: 70.
+	TargetUtilization *int32 `json:"targetUtilization,omitempty"`
+
+	// The min time between the start of the job or the last rescale and the
+	// next rescale, default: 300.
+	StabilizationWindowSeconds *int32 `json:"stabilizationWindowSeconds,omitempty"`
 }
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -231,17 +568,35 @@ type FlinkClusterSpec struct {
 	// Flink TaskManager spec.
 	TaskManagerSpec TaskManagerSpec `json:"taskManager"`
 
+	// Optional pools of TaskManagers which run alongside the TaskManagers of
+	// TaskManagerSpec, each in its own deployment.
+	TaskManagerPools []TaskManagerPoolSpec `json:"taskManagerPools,omitempty"`
+
 	// Optional job spec. If specified, this cluster is an ephemeral Job
 	// Cluster, which will be automatically terminated after the job finishes;
 	// otherwise, it is a long-running Session Cluster.
 	JobSpec *JobSpec `json:"job,omitempty"`
 
-	// Flink properties which are appened to flink-conf.yaml of the image.
+	// Optional high availability spec of JobManager. It is required for more
+	// than 1 JobManager replica.
+	HighAvailability *HighAvailabilitySpec `json:"highAvailability,omitempty"`
+
+	// Flink properties which override the properties generated by the
+	// operator in flink-conf.yaml.
 	FlinkProperties map[string]string `json:"flinkProperties,omitempty"`
 
+	// Logging config files keyed by the file name, e.g.,
+	// "log4j-console.properties" and "logback-console.xml". They are mounted
+	// into the conf directory of Flink along with flink-conf.yaml, and replace
+	// the files of the same name in the image.
+	LogConfig map[string]string `json:"logConfig,omitempty"`
+
 	// Environment variables shared by all JobManager, TaskManager and job
 	// containers.
 	EnvVars []corev1.EnvVar `json:"envVars,omitempty"`
+
+	// Optional Prometheus monitoring spec of the cluster.
+	Monitoring *MonitoringSpec `json:"monitoring,omitempty"`
 }
 
 // FlinkClusterComponentState defines the observed state of a component
@@ -263,23 +618,158 @@ type FlinkClusterComponentsStatus struct {
 	// The state of JobManager service.
 	JobManagerService FlinkClusterComponentState `json:"jobManagerService"`
 
+	// The status of JobManager ingress, available only when the ingress is
+	// specified.
+	JobManagerIngress *JobManagerIngressStatus `json:"jobManagerIngress,omitempty"`
+
 	// The state of TaskManager deployment.
 	TaskManagerDeployment FlinkClusterComponentState `json:"taskManagerDeployment"`
 
+	// The status of each TaskManager pool, available only when the pools are
+	// specified.
+	TaskManagerPools []TaskManagerPoolStatus `json:"taskManagerPools,omitempty"`
+
 	// The status of the job, available only when JobSpec is provided.
 	Job *JobStatus `json:"job,omitempty"`
+
+	// The current leader of JobManagers, available only when Kubernetes HA is
+	// enabled.
+	JobManagerLeader *JobManagerLeaderStatus `json:"jobManagerLeader,omitempty"`
+}
+
+// JobManagerIngressStatus defines the status of the JobManager ingress.
+type JobManagerIngressStatus struct {
+	// The resource name of the ingress.
+	Name string `json:"name"`
+
+	// The state of the ingress, it is ready once the ingress controller has
+	// assigned an address to it.
+	State string `json:"state"`
+
+	// The URLs of the web UI.
+	URLs []string `json:"urls,omitempty"`
+}
+
+// TaskManagerPoolStatus defines the observed status of a TaskManager pool.
+type TaskManagerPoolStatus struct {
+	// The name of the pool.
+	Name string `json:"name"`
+
+	// The resource name of the deployment of the pool.
+	Deployment string `json:"deployment"`
+
+	// The state of the deployment of the pool.
+	State string `json:"state"`
+
+	// The number of TaskManager pods of the pool.
+	Replicas int32 `json:"replicas"`
+
+	// The number of ready TaskManager pods of the pool.
+	ReadyReplicas int32 `json:"readyReplicas"`
+}
+
+// JobManagerLeaderStatus defines the observed leader of JobManagers.
+type JobManagerLeaderStatus struct {
+	// The name of the JobManager pod which is the leader, empty if the pod is
+	// not found.
+	PodName string `json:"podName,omitempty"`
+
+	// The address of the REST endpoint of the leader.
+	Address string `json:"address"`
 }
 
 // JobStatus defines the status of a job.
 type JobStatus struct {
-	// The name of the Kubernetes job resource.
+	// The name of the Kubernetes job resource, or the JobManager deployment in
+	// "Application" mode.
 	Name string `json:"name"`
 
 	// The ID of the Flink job.
 	ID string `json:"id"`
 
-	// The state of the Kubernetes job.
+	// The state of the job, derived from the state of the Flink job when it is
+	// available through the Flink REST API, otherwise from the state of the
+	// Kubernetes job which submits the Flink job.
 	State string `json:"state"`
+
+	// The state of the Flink job reported by the Flink REST API, e.g.,
+	// "RUNNING", "RESTARTING", "CANCELED".
+	FlinkJobState string `json:"flinkJobState,omitempty"`
+
+	// The state of the Kubernetes job which submits the Flink job,
+	// enum("Pending", "Running", "Succeeded", "Failed"), empty in
+	// "Application" mode.
+	SubmitterState string `json:"submitterState,omitempty"`
+
+	// The state of the ongoing upgrade of the job, enum("TakingSavepoint",
+	// "Resubmitting"), empty if there is no upgrade in progress.
+	UpgradeState string `json:"upgradeState,omitempty"`
+
+	// The trigger ID of the savepoint in progress.
+	SavepointTriggerID string `json:"savepointTriggerID,omitempty"`
+
+	// The location of the last savepoint taken by the operator.
+	SavepointLocation string `json:"savepointLocation,omitempty"`
+
+	// The savepoint the current job was submitted from. It takes precedence
+	// over the savepoint in the job spec once the job has been upgraded or
+	// restarted.
+	FromSavepoint string `json:"fromSavepoint,omitempty"`
+
+	// The savepoint in the job spec when the job was last submitted. The
+	// savepoint in the job spec takes precedence over FromSavepoint again once
+	// it is changed.
+	SpecSavepoint *string `json:"specSavepoint,omitempty"`
+
+	// The number of times the job has been restarted by the operator with the
+	// "FromSavepointOnFailure" restart policy, reset once the job has been
+	// running for 10 minutes since the last restart.
+	RestartCount int32 `json:"restartCount,omitempty"`
+
+	// The last time the job was restarted by the operator.
+	LastRestartTime string `json:"lastRestartTime,omitempty"`
+
+	// The parallelism of the job decided by the autoscaler. It takes
+	// precedence over the parallelism in the job spec.
+	Parallelism int32 `json:"parallelism,omitempty"`
+
+	// The last time the job was rescaled by the autoscaler.
+	LastRescaleTime string `json:"lastRescaleTime,omitempty"`
+}
+
+// TaskManagerStatus defines the observed scale of TaskManagers, which is
+// exposed through the scale subresource of FlinkCluster.
+type TaskManagerStatus struct {
+	// The number of TaskManager pods.
+	Replicas int32 `json:"replicas"`
+
+	// The label selector of TaskManager pods in string form, e.g., for
+	// HorizontalPodAutoscalers to find the pods.
+	Selector string `json:"selector"`
+}
+
+// FlinkClusterCondition defines an aspect of the observed state of a
+// FlinkCluster, in the same form as the conditions of Kubernetes resources.
+type FlinkClusterCondition struct {
+	// The type of the condition, enum("Ready", "JobManagerReady",
+	// "TaskManagersReady", "JobRunning", "Progressing", "Degraded",
+	// "ReconcileError").
+	Type string `json:"type"`
+
+	// The status of the condition, enum("True", "False", "Unknown").
+	Status corev1.ConditionStatus `json:"status"`
+
+	// The generation of the cluster spec the condition was derived from.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// The last time the status of the condition changed.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// A machine-readable reason for the last transition, in CamelCase.
+	Reason string `json:"reason,omitempty"`
+
+	// A human-readable message with details about the last transition.
+	Message string `json:"message,omitempty"`
 }
 
 // FlinkClusterStatus defines the observed state of FlinkCluster
@@ -293,11 +783,22 @@ type FlinkClusterStatus struct {
 	// The status of the components.
 	Components FlinkClusterComponentsStatus `json:"components"`
 
+	// The scale status of TaskManagers.
+	TaskManager *TaskManagerStatus `json:"taskManager,omitempty"`
+
+	// The conditions of the cluster.
+	Conditions []FlinkClusterCondition `json:"conditions,omitempty"`
+
+	// The generation of the cluster spec the status was derived from.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// Last update timestamp for this status.
 	LastUpdateTime string `json:"lastUpdateTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.taskManager.replicas,statuspath=.status.taskManager.replicas,selectorpath=.status.taskManager.selector
 
 // FlinkCluster is the Schema for the flinkclusters API
 type FlinkCluster struct {
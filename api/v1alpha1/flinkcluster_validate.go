@@ -17,16 +17,946 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"errors"
+	"fmt"
+	"net/url"
+	"path"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
+// The directory in the image which Flink loads the job from in Application
+// mode.
+const userLibDir = "/opt/flink/usrlib"
+
+// The schemes of the remote files of a job, which are fetched by the operator.
+var artifactSchemes = []string{"http", "https", "gs", "s3", "pvc"}
+
+var sha256Regexp = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// The format of durations in the Prometheus Operator objects, e.g., "30s".
+var prometheusDurationRegexp = regexp.MustCompile(
+	`^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$`)
+
+// Validates create request.
+//
+// The spec is validated after the defaults have been set by the mutating
+// webhook, the errors of all fields are reported at once.
+func _ValidateCreate(cluster *FlinkCluster) error {
+	return _ValidateSpec(nil, &cluster.Spec, field.NewPath("spec")).ToAggregate()
+}
+
+// Validates the spec on create, or the parts of the spec which have been
+// changed on update, along with the parts they depend on, so existing clusters
+// which do not pass newer rules can still be updated.
+func _ValidateSpec(
+	old *FlinkClusterSpec, spec *FlinkClusterSpec, path *field.Path) field.ErrorList {
+	var isCreate = old == nil
+	if isCreate {
+		old = &FlinkClusterSpec{}
+	}
+	var changed = func(oldValue interface{}, newValue interface{}) bool {
+		return isCreate || !reflect.DeepEqual(oldValue, newValue)
+	}
+
+	var allErrs field.ErrorList
+	if changed(old.ImageSpec, spec.ImageSpec) {
+		allErrs = append(
+			allErrs, _ValidateImage(&spec.ImageSpec, path.Child("image"))...)
+	}
+	if changed(old.JobManagerSpec, spec.JobManagerSpec) ||
+		changed(old.HighAvailability, spec.HighAvailability) {
+		allErrs = append(
+			allErrs,
+			_ValidateJobManager(
+				&spec.JobManagerSpec,
+				spec.HighAvailability != nil,
+				path.Child("jobManager"))...)
+	}
+	if changed(old.TaskManagerSpec, spec.TaskManagerSpec) {
+		allErrs = append(
+			allErrs,
+			_ValidateTaskManager(&spec.TaskManagerSpec, path.Child("taskManager"))...)
+	}
+	if len(spec.TaskManagerPools) > 0 &&
+		(changed(old.TaskManagerPools, spec.TaskManagerPools) ||
+			changed(old.TaskManagerSpec, spec.TaskManagerSpec) ||
+			changed(old.JobSpec, spec.JobSpec)) {
+		allErrs = append(
+			allErrs,
+			_ValidateTaskManagerPools(spec, path.Child("taskManagerPools"))...)
+	}
+	if spec.JobSpec != nil && changed(old.JobSpec, spec.JobSpec) {
+		allErrs = append(
+			allErrs, _ValidateJob(spec.JobSpec, path.Child("job"))...)
+	}
+	if spec.HighAvailability != nil &&
+		changed(old.HighAvailability, spec.HighAvailability) {
+		allErrs = append(
+			allErrs,
+			_ValidateHighAvailability(
+				spec.HighAvailability, path.Child("highAvailability"))...)
+	}
+	if changed(old.LogConfig, spec.LogConfig) {
+		allErrs = append(
+			allErrs, _ValidateLogConfig(spec.LogConfig, path.Child("logConfig"))...)
+	}
+	if spec.Monitoring != nil &&
+		(changed(old.Monitoring, spec.Monitoring) ||
+			changed(old.JobManagerSpec.Ports, spec.JobManagerSpec.Ports) ||
+			changed(old.TaskManagerSpec.Ports, spec.TaskManagerSpec.Ports)) {
+		allErrs = append(
+			allErrs, _ValidateMonitoring(spec, path.Child("monitoring"))...)
+	}
+	return allErrs
+}
+
+// The metrics port is added to the JobManager and TaskManager containers, so
+// it must not conflict with their other ports.
+func _ValidateMonitoring(
+	spec *FlinkClusterSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	var monitoringSpec = spec.Monitoring
+	var portPath = path.Child("port")
+	allErrs = append(allErrs, _ValidatePorts(map[string]*int32{
+		"port": monitoringSpec.Port,
+	}, path)...)
+	if len(allErrs) == 0 && monitoringSpec.Port != nil {
+		var jmPorts = spec.JobManagerSpec.Ports
+		var tmPorts = spec.TaskManagerSpec.Ports
+		var otherPorts = map[string]*int32{
+			"jobManager.ports.rpc":    jmPorts.RPC,
+			"jobManager.ports.blob":   jmPorts.Blob,
+			"jobManager.ports.query":  jmPorts.Query,
+			"jobManager.ports.ui":     jmPorts.UI,
+			"taskManager.ports.data":  tmPorts.Data,
+			"taskManager.ports.rpc":   tmPorts.RPC,
+			"taskManager.ports.query": tmPorts.Query,
+		}
+		for _, name := range _SortedKeys(otherPorts) {
+			var otherPort = otherPorts[name]
+			if otherPort != nil && *otherPort == *monitoringSpec.Port {
+				allErrs = append(allErrs, field.Invalid(
+					portPath,
+					*monitoringSpec.Port,
+					fmt.Sprintf("conflicts with spec.%v", name)))
+			}
+		}
+	}
+	if monitoringSpec.Monitor != nil {
+		switch *monitoringSpec.Monitor {
+		case MonitorKind.PodMonitor, MonitorKind.ServiceMonitor:
+		default:
+			allErrs = append(allErrs, field.NotSupported(
+				path.Child("monitor"),
+				*monitoringSpec.Monitor,
+				[]string{MonitorKind.PodMonitor, MonitorKind.ServiceMonitor}))
+		}
+	}
+	allErrs = append(allErrs, metav1validation.ValidateLabels(
+		monitoringSpec.MonitorLabels, path.Child("monitorLabels"))...)
+	if monitoringSpec.ScrapeInterval != nil &&
+		!prometheusDurationRegexp.MatchString(*monitoringSpec.ScrapeInterval) {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("scrapeInterval"),
+			*monitoringSpec.ScrapeInterval,
+			"must be a duration, e.g., 30s"))
+	}
+	return allErrs
+}
+
+// The log config files are mounted into the conf directory of Flink, so the
+// keys must be valid file names, and flink-conf.yaml is reserved for the
+// generated properties.
+func _ValidateLogConfig(
+	logConfig map[string]string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	var fileNames = []string{}
+	for fileName := range logConfig {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	for _, fileName := range fileNames {
+		if fileName == "flink-conf.yaml" {
+			allErrs = append(allErrs, field.Invalid(
+				path.Key(fileName),
+				fileName,
+				"flink-conf.yaml is generated from flinkProperties"))
+			continue
+		}
+		for _, msg := range validation.IsConfigMapKey(fileName) {
+			allErrs = append(
+				allErrs, field.Invalid(path.Key(fileName), fileName, msg))
+		}
+	}
+	return allErrs
+}
+
+func _ValidateImage(imageSpec *ImageSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(imageSpec.Name) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("name"), ""))
+	}
+	switch imageSpec.PullPolicy {
+	case "", corev1.PullAlways, corev1.PullNever, corev1.PullIfNotPresent:
+	default:
+		allErrs = append(allErrs, field.NotSupported(
+			path.Child("pullPolicy"),
+			string(imageSpec.PullPolicy),
+			[]string{
+				string(corev1.PullAlways),
+				string(corev1.PullNever),
+				string(corev1.PullIfNotPresent),
+			}))
+	}
+	return allErrs
+}
+
+func _ValidateJobManager(
+	jmSpec *JobManagerSpec,
+	highAvailability bool,
+	path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if jmSpec.Replicas != nil {
+		if *jmSpec.Replicas < 1 {
+			allErrs = append(allErrs, field.Invalid(
+				path.Child("replicas"), *jmSpec.Replicas, "must be at least 1"))
+		} else if *jmSpec.Replicas > 1 && !highAvailability {
+			allErrs = append(allErrs, field.Invalid(
+				path.Child("replicas"),
+				*jmSpec.Replicas,
+				"only 1 JobManager replica is supported without high availability"))
+		}
+	}
+	switch jmSpec.AccessScope {
+	case AccessScope.Cluster, AccessScope.VPC, AccessScope.External:
+	default:
+		allErrs = append(allErrs, field.NotSupported(
+			path.Child("accessScope"),
+			jmSpec.AccessScope,
+			[]string{AccessScope.Cluster, AccessScope.VPC, AccessScope.External}))
+	}
+	var portsPath = path.Child("ports")
+	allErrs = append(allErrs, _ValidatePorts(map[string]*int32{
+		"rpc":   jmSpec.Ports.RPC,
+		"blob":  jmSpec.Ports.Blob,
+		"query": jmSpec.Ports.Query,
+		"ui":    jmSpec.Ports.UI,
+	}, portsPath)...)
+	if jmSpec.Ingress != nil {
+		allErrs = append(
+			allErrs,
+			_ValidateJobManagerIngress(jmSpec.Ingress, path.Child("ingress"))...)
+	}
+	allErrs = append(allErrs, _ValidatePodTemplate(
+		jmSpec.PodTemplate, path.Child("podTemplate"))...)
+	return allErrs
+}
+
+func _ValidateJobManagerIngress(
+	ingressSpec *JobManagerIngressSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if ingressSpec.Path != nil && !strings.HasPrefix(*ingressSpec.Path, "/") {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("path"), *ingressSpec.Path, "must start with /"))
+	}
+	if ingressSpec.TLSSecretName != nil && len(*ingressSpec.TLSSecretName) == 0 {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("tlsSecretName"),
+			*ingressSpec.TLSSecretName,
+			"must not be empty"))
+	}
+	return allErrs
+}
+
+func _ValidateTaskManager(
+	tmSpec *TaskManagerSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if tmSpec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("replicas"), tmSpec.Replicas, "must be non-negative"))
+	}
+	var portsPath = path.Child("ports")
+	allErrs = append(allErrs, _ValidatePorts(map[string]*int32{
+		"data":  tmSpec.Ports.Data,
+		"rpc":   tmSpec.Ports.RPC,
+		"query": tmSpec.Ports.Query,
+	}, portsPath)...)
+	allErrs = append(allErrs, _ValidatePodTemplate(
+		tmSpec.PodTemplate, path.Child("podTemplate"))...)
+	allErrs = append(allErrs, _ValidateTaskManagerDeploymentType(tmSpec, path)...)
+	return allErrs
+}
+
+// The pools are run as deployments along with the TaskManager deployment, and
+// their task slots are not accounted for by the autoscaler.
+func _ValidateTaskManagerPools(
+	spec *FlinkClusterSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if _GetTaskManagerDeploymentType(&spec.TaskManagerSpec) !=
+		TaskManagerDeploymentType.Deployment {
+		allErrs = append(allErrs, field.Forbidden(
+			path, "only supported for TaskManagers run as a Deployment"))
+	}
+	if spec.JobSpec != nil && spec.JobSpec.Autoscaler != nil {
+		allErrs = append(allErrs, field.Forbidden(
+			path, "not supported with the job autoscaler"))
+	}
+	var poolNames = map[string]bool{}
+	for i, pool := range spec.TaskManagerPools {
+		var poolPath = path.Index(i)
+		if len(pool.Name) == 0 {
+			allErrs = append(allErrs, field.Required(poolPath.Child("name"), ""))
+		} else if poolNames[pool.Name] {
+			allErrs = append(allErrs, field.Duplicate(
+				poolPath.Child("name"), pool.Name))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(pool.Name) {
+				allErrs = append(allErrs, field.Invalid(
+					poolPath.Child("name"), pool.Name, msg))
+			}
+		}
+		poolNames[pool.Name] = true
+		if pool.Replicas < 0 {
+			allErrs = append(allErrs, field.Invalid(
+				poolPath.Child("replicas"), pool.Replicas, "must be non-negative"))
+		}
+		if pool.TaskSlots != nil && *pool.TaskSlots < 1 {
+			allErrs = append(allErrs, field.Invalid(
+				poolPath.Child("taskSlots"), *pool.TaskSlots, "must be at least 1"))
+		}
+	}
+	return allErrs
+}
+
+func _ValidateTaskManagerDeploymentType(
+	tmSpec *TaskManagerSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	var deploymentType = _GetTaskManagerDeploymentType(tmSpec)
+	switch deploymentType {
+	case TaskManagerDeploymentType.Deployment,
+		TaskManagerDeploymentType.StatefulSet:
+	default:
+		allErrs = append(allErrs, field.NotSupported(
+			path.Child("deploymentType"),
+			deploymentType,
+			[]string{
+				TaskManagerDeploymentType.Deployment,
+				TaskManagerDeploymentType.StatefulSet,
+			}))
+		return allErrs
+	}
+	if deploymentType != TaskManagerDeploymentType.StatefulSet {
+		if len(tmSpec.VolumeClaimTemplates) > 0 {
+			allErrs = append(allErrs, field.Forbidden(
+				path.Child("volumeClaimTemplates"), "only supported for StatefulSet"))
+		}
+		if tmSpec.PodManagementPolicy != nil {
+			allErrs = append(allErrs, field.Forbidden(
+				path.Child("podManagementPolicy"), "only supported for StatefulSet"))
+		}
+		return allErrs
+	}
+	for i, claim := range tmSpec.VolumeClaimTemplates {
+		if len(claim.ObjectMeta.Name) == 0 {
+			allErrs = append(allErrs, field.Required(
+				path.Child("volumeClaimTemplates").Index(i).Child(
+					"metadata", "name"), ""))
+		}
+	}
+	if tmSpec.PodManagementPolicy != nil {
+		switch *tmSpec.PodManagementPolicy {
+		case appsv1.OrderedReadyPodManagement, appsv1.ParallelPodManagement:
+		default:
+			allErrs = append(allErrs, field.NotSupported(
+				path.Child("podManagementPolicy"),
+				string(*tmSpec.PodManagementPolicy),
+				[]string{
+					string(appsv1.OrderedReadyPodManagement),
+					string(appsv1.ParallelPodManagement),
+				}))
+		}
+	}
+	return allErrs
+}
+
+// The containers of the pod template are merged with the containers generated
+// by the operator by their names, so the names are required.
+func _ValidatePodTemplate(
+	podTemplate *corev1.PodTemplateSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if podTemplate == nil {
+		return allErrs
+	}
+	var specPath = path.Child("spec")
+	for i, container := range podTemplate.Spec.InitContainers {
+		if len(container.Name) == 0 {
+			allErrs = append(allErrs, field.Required(
+				specPath.Child("initContainers").Index(i).Child("name"), ""))
+		}
+	}
+	for i, container := range podTemplate.Spec.Containers {
+		if len(container.Name) == 0 {
+			allErrs = append(allErrs, field.Required(
+				specPath.Child("containers").Index(i).Child("name"), ""))
+		}
+	}
+	return allErrs
+}
+
+func _ValidateJob(jobSpec *JobSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	var isApplicationMode = false
+	if jobSpec.Mode != nil {
+		switch *jobSpec.Mode {
+		case JobMode.Client:
+		case JobMode.Application:
+			isApplicationMode = true
+		default:
+			allErrs = append(allErrs, field.NotSupported(
+				path.Child("mode"),
+				*jobSpec.Mode,
+				[]string{JobMode.Client, JobMode.Application}))
+		}
+	}
+	allErrs = append(allErrs, _ValidateJobEntryPoint(jobSpec, path)...)
+	allErrs = append(allErrs, _ValidateJobArtifacts(jobSpec, path)...)
+	if !isApplicationMode && len(jobSpec.Artifacts) > 0 {
+		// The submitter only passes the JAR file of the job to Flink, the other
+		// files are not available to JobManager and TaskManagers.
+		allErrs = append(allErrs, field.Forbidden(
+			path.Child("artifacts"), "only supported in Application mode"))
+	}
+	if isApplicationMode && len(jobSpec.JarFile) > 0 &&
+		!_IsRemoteArtifact(jobSpec.JarFile) &&
+		!strings.HasPrefix(jobSpec.JarFile, userLibDir+"/") {
+		// Flink only loads the job from the usrlib directory in Application
+		// mode, which remote files are fetched into.
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("jarFile"),
+			jobSpec.JarFile,
+			fmt.Sprintf(
+				"must be in %v or a remote URI in Application mode", userLibDir)))
+	}
+	if isApplicationMode && len(jobSpec.JarFile) == 0 {
+		for _, name := range _GetJobEntryPoints(jobSpec) {
+			allErrs = append(allErrs, field.Forbidden(
+				path.Child(name), "only JAR files are supported in Application mode"))
+		}
+	}
+	if isApplicationMode && jobSpec.PodTemplate != nil {
+		allErrs = append(allErrs, field.Forbidden(
+			path.Child("podTemplate"),
+			"not supported in Application mode, the job is run by JobManager"))
+	}
+	allErrs = append(allErrs, _ValidatePodTemplate(
+		jobSpec.PodTemplate, path.Child("podTemplate"))...)
+	if jobSpec.Parallelism != nil && *jobSpec.Parallelism < 1 {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("parallelism"), *jobSpec.Parallelism, "must be at least 1"))
+	}
+	if jobSpec.SavepointOnDeleteTimeoutSeconds != nil &&
+		*jobSpec.SavepointOnDeleteTimeoutSeconds < 1 {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("savepointOnDeleteTimeoutSeconds"),
+			*jobSpec.SavepointOnDeleteTimeoutSeconds,
+			"must be at least 1"))
+	}
+	if jobSpec.RestartPolicy != nil {
+		switch *jobSpec.RestartPolicy {
+		case corev1.RestartPolicyOnFailure, corev1.RestartPolicyNever,
+			corev1.RestartPolicy(JobRestartPolicy.FromSavepointOnFailure):
+		default:
+			allErrs = append(allErrs, field.NotSupported(
+				path.Child("restartPolicy"),
+				string(*jobSpec.RestartPolicy),
+				[]string{
+					string(corev1.RestartPolicyOnFailure),
+					string(corev1.RestartPolicyNever),
+					JobRestartPolicy.FromSavepointOnFailure,
+				}))
+		}
+	}
+	if isApplicationMode && jobSpec.RestartPolicy != nil &&
+		string(*jobSpec.RestartPolicy) == JobRestartPolicy.FromSavepointOnFailure {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("restartPolicy"),
+			string(*jobSpec.RestartPolicy),
+			"not supported in Application mode"))
+	}
+	if jobSpec.MaxRestartAttempts != nil && *jobSpec.MaxRestartAttempts < 1 {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("maxRestartAttempts"),
+			*jobSpec.MaxRestartAttempts,
+			"must be at least 1"))
+	}
+	if jobSpec.RestartBackoffSeconds != nil &&
+		*jobSpec.RestartBackoffSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("restartBackoffSeconds"),
+			*jobSpec.RestartBackoffSeconds,
+			"must be non-negative"))
+	}
+	allErrs = append(allErrs, _ValidateJobAutoscaler(
+		jobSpec.Autoscaler, path.Child("autoscaler"))...)
+	return allErrs
+}
+
+func _ValidateJobAutoscaler(
+	autoscalerSpec *JobAutoscalerSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if autoscalerSpec == nil {
+		return allErrs
+	}
+	var minParallelism int32 = 1
+	if autoscalerSpec.MinParallelism != nil {
+		minParallelism = *autoscalerSpec.MinParallelism
+		if minParallelism < 1 {
+			allErrs = append(allErrs, field.Invalid(
+				path.Child("minParallelism"), minParallelism, "must be at least 1"))
+		}
+	}
+	if autoscalerSpec.MaxParallelism < minParallelism {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("maxParallelism"),
+			autoscalerSpec.MaxParallelism,
+			"must be at least minParallelism"))
+	}
+	if autoscalerSpec.TargetUtilization != nil &&
+		(*autoscalerSpec.TargetUtilization < 1 ||
+			*autoscalerSpec.TargetUtilization > 100) {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("targetUtilization"),
+			*autoscalerSpec.TargetUtilization,
+			"must be between 1 and 100"))
+	}
+	if autoscalerSpec.StabilizationWindowSeconds != nil &&
+		*autoscalerSpec.StabilizationWindowSeconds < 1 {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("stabilizationWindowSeconds"),
+			*autoscalerSpec.StabilizationWindowSeconds,
+			"must be at least 1"))
+	}
+	return allErrs
+}
+
+// Validates exactly one entry point of the job is set, along with the options
+// which only apply to some kinds of entry points.
+func _ValidateJobEntryPoint(jobSpec *JobSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	var entryPoints = _GetJobEntryPoints(jobSpec)
+	if len(entryPoints) == 0 {
+		allErrs = append(allErrs, field.Required(
+			path.Child("jarFile"),
+			"one of jarFile, pyFile, pyModule and sqlScript is required"))
+	}
+	if len(entryPoints) > 1 {
+		for _, name := range entryPoints[1:] {
+			allErrs = append(allErrs, field.Forbidden(
+				path.Child(name),
+				fmt.Sprintf("%v is already set as the entry point", entryPoints[0])))
+		}
+	}
+
+	var isPython = jobSpec.PyFile != nil || jobSpec.PyModule != nil
+	if jobSpec.ClassName != nil && len(jobSpec.JarFile) == 0 {
+		allErrs = append(allErrs, field.Forbidden(
+			path.Child("className"), "only supported for JAR files"))
+	}
+	if jobSpec.PyModule != nil && len(jobSpec.PyFiles) == 0 {
+		allErrs = append(allErrs, field.Required(
+			path.Child("pyFiles"), "the files of pyModule are required"))
+	}
+	if len(jobSpec.PyFiles) > 0 && !isPython {
+		allErrs = append(allErrs, field.Forbidden(
+			path.Child("pyFiles"), "only supported for Python jobs"))
+	}
+	if jobSpec.PyRequirements != nil && !isPython {
+		allErrs = append(allErrs, field.Forbidden(
+			path.Child("pyRequirements"), "only supported for Python jobs"))
+	}
+
+	var sqlScript = jobSpec.SQLScript
+	if sqlScript != nil {
+		var sqlScriptPath = path.Child("sqlScript")
+		switch {
+		case sqlScript.ConfigMap == nil && sqlScript.Inline == nil:
+			allErrs = append(allErrs, field.Required(
+				sqlScriptPath, "one of configMap and inline is required"))
+		case sqlScript.ConfigMap != nil && sqlScript.Inline != nil:
+			allErrs = append(allErrs, field.Forbidden(
+				sqlScriptPath.Child("inline"), "configMap is already set"))
+		case sqlScript.ConfigMap != nil:
+			if len(sqlScript.ConfigMap.Name) == 0 {
+				allErrs = append(allErrs, field.Required(
+					sqlScriptPath.Child("configMap", "name"), ""))
+			}
+			if len(sqlScript.ConfigMap.Key) == 0 {
+				allErrs = append(allErrs, field.Required(
+					sqlScriptPath.Child("configMap", "key"), ""))
+			}
+		}
+	}
+	return allErrs
+}
+
+// Validates the remote files of the job, which are fetched into the same
+// directory, so their file names must be unique.
+func _ValidateJobArtifacts(jobSpec *JobSpec, jobPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	var fileNames = map[string]bool{}
+	var validateArtifact = func(
+		uri string, sha256 *string, uriPath *field.Path, sha256Path *field.Path) {
+		var parsed, err = url.Parse(uri)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(uriPath, uri, err.Error()))
+			return
+		}
+		var fileName = path.Base(parsed.Path)
+		switch {
+		case !_ContainsString(artifactSchemes, parsed.Scheme):
+			allErrs = append(allErrs, field.NotSupported(
+				uriPath, parsed.Scheme+"://", artifactSchemes))
+		case len(parsed.Host) == 0 || fileName == "/" || fileName == ".":
+			allErrs = append(allErrs, field.Invalid(
+				uriPath, uri, "must have a host and a file path"))
+		case fileNames[fileName]:
+			allErrs = append(allErrs, field.Duplicate(uriPath, fileName))
+		default:
+			fileNames[fileName] = true
+		}
+		if sha256 != nil && !sha256Regexp.MatchString(*sha256) {
+			allErrs = append(allErrs, field.Invalid(
+				sha256Path, *sha256, "must be 64 hex digits"))
+		}
+	}
+
+	if _IsRemoteArtifact(jobSpec.JarFile) {
+		validateArtifact(
+			jobSpec.JarFile,
+			jobSpec.JarFileSHA256,
+			jobPath.Child("jarFile"),
+			jobPath.Child("jarFileSHA256"))
+	} else if jobSpec.JarFileSHA256 != nil {
+		allErrs = append(allErrs, field.Forbidden(
+			jobPath.Child("jarFileSHA256"), "only supported for remote JAR files"))
+	}
+	for i, artifact := range jobSpec.Artifacts {
+		var artifactPath = jobPath.Child("artifacts").Index(i)
+		validateArtifact(
+			artifact.URI,
+			artifact.SHA256,
+			artifactPath.Child("uri"),
+			artifactPath.Child("sha256"))
+	}
+	return allErrs
+}
+
+// Checks whether the file is fetched by the operator.
+func _IsRemoteArtifact(uri string) bool {
+	return strings.Contains(uri, "://")
+}
+
+func _ContainsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the names of the entry points set in the job spec.
+func _GetJobEntryPoints(jobSpec *JobSpec) []string {
+	var entryPoints = []string{}
+	if len(jobSpec.JarFile) > 0 {
+		entryPoints = append(entryPoints, "jarFile")
+	}
+	if jobSpec.PyFile != nil {
+		entryPoints = append(entryPoints, "pyFile")
+	}
+	if jobSpec.PyModule != nil {
+		entryPoints = append(entryPoints, "pyModule")
+	}
+	if jobSpec.SQLScript != nil {
+		entryPoints = append(entryPoints, "sqlScript")
+	}
+	return entryPoints
+}
+
+func _ValidateHighAvailability(
+	haSpec *HighAvailabilitySpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch haSpec.Mode {
+	case HighAvailabilityMode.Kubernetes:
+	case HighAvailabilityMode.ZooKeeper:
+		if haSpec.ZooKeeperQuorum == nil || len(*haSpec.ZooKeeperQuorum) == 0 {
+			allErrs = append(
+				allErrs, field.Required(path.Child("zooKeeperQuorum"), ""))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(
+			path.Child("mode"),
+			haSpec.Mode,
+			[]string{
+				HighAvailabilityMode.Kubernetes, HighAvailabilityMode.ZooKeeper,
+			}))
+	}
+	if len(haSpec.StorageDir) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("storageDir"), ""))
+	}
+	if haSpec.ClusterID != nil && len(*haSpec.ClusterID) == 0 {
+		allErrs = append(allErrs, field.Invalid(
+			path.Child("clusterID"), *haSpec.ClusterID, "must not be empty"))
+	}
+	return allErrs
+}
+
+// Validates the ports of a component, which are keyed by their field names.
+// Each port must be in the valid range and used only once in the component.
+func _ValidatePorts(ports map[string]*int32, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	var portNames = map[int32]string{}
+	// Iterate in a fixed order, so the errors are deterministic.
+	for _, name := range _SortedKeys(ports) {
+		var port = ports[name]
+		if port == nil {
+			continue
+		}
+		if *port < 1 || *port > 65535 {
+			allErrs = append(allErrs, field.Invalid(
+				path.Child(name), *port, "must be between 1 and 65535, inclusive"))
+			continue
+		}
+		if otherName, ok := portNames[*port]; ok {
+			allErrs = append(allErrs, field.Invalid(
+				path.Child(name),
+				*port,
+				fmt.Sprintf("conflicts with %v", path.Child(otherName))))
+			continue
+		}
+		portNames[*port] = name
+	}
+	return allErrs
+}
+
+func _SortedKeys(m map[string]*int32) []string {
+	var keys = []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // Validates update request.
+//
+// Updates which leave the spec unchanged, e.g., the operator adding or
+// removing its finalizer, are always allowed, and so is any update of a
+// cluster being deleted. Otherwise the changes must be allowed in place, and
+// the changed fields must be valid.
+func _ValidateUpdateRequest(old *FlinkCluster, new *FlinkCluster) error {
+	if new.ObjectMeta.DeletionTimestamp != nil ||
+		reflect.DeepEqual(old.Spec, new.Spec) {
+		return nil
+	}
+	var err = _ValidateUpdate(old, new)
+	if err != nil {
+		return err
+	}
+	return _ValidateSpec(
+		&old.Spec, &new.Spec, field.NewPath("spec")).ToAggregate()
+}
+
+// Validates the changes of update request.
+//
+// Changes which can be applied to the running cluster by updating its
+// components in place (image, replicas of TaskManager, resources, Flink
+// properties, environment variables and the job) are allowed, other changes
+// are rejected with the reason for each field. The status is managed by the
+// operator through the status subresource, it cannot be changed by users.
 func _ValidateUpdate(old *FlinkCluster, new *FlinkCluster) error {
-	if !reflect.DeepEqual(new.Spec, old.Spec) {
-		return errors.New(
-			"updating FlinkCluster spec is not allowed," +
-				" please delete the resouce and recreate")
+	var allErrs field.ErrorList
+	var specPath = field.NewPath("spec")
+
+	allErrs = _AppendIfChanged(
+		allErrs, field.NewPath("status"), old.Status, new.Status,
+		"the status is managed by the operator")
+
+	allErrs = append(
+		allErrs,
+		_ValidateJobManagerUpdate(
+			&old.Spec.JobManagerSpec,
+			&new.Spec.JobManagerSpec,
+			specPath.Child("jobManager"))...)
+	allErrs = append(
+		allErrs,
+		_ValidateTaskManagerUpdate(
+			&old.Spec.TaskManagerSpec,
+			&new.Spec.TaskManagerSpec,
+			specPath.Child("taskManager"))...)
+	allErrs = append(
+		allErrs,
+		_ValidateTaskManagerPoolsUpdate(
+			old.Spec.TaskManagerPools,
+			new.Spec.TaskManagerPools,
+			specPath.Child("taskManagerPools"))...)
+	allErrs = append(
+		allErrs,
+		_ValidateJobUpdate(
+			old.Spec.JobSpec, new.Spec.JobSpec, specPath.Child("job"))...)
+	allErrs = _AppendIfChanged(
+		allErrs,
+		specPath.Child("highAvailability"),
+		old.Spec.HighAvailability,
+		new.Spec.HighAvailability,
+		"high availability cannot be enabled, disabled or changed in place")
+
+	return allErrs.ToAggregate()
+}
+
+func _ValidateJobManagerUpdate(
+	old *JobManagerSpec, new *JobManagerSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("replicas"), old.Replicas, new.Replicas,
+		"the number of JobManager replicas cannot be updated")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("accessScope"), old.AccessScope, new.AccessScope,
+		"the type of the JobManager service cannot be updated in place")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("ports"), old.Ports, new.Ports,
+		"the ports are used by other running components to reach JobManager")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("volumes"), old.Volumes, new.Volumes,
+		"volumes of JobManager cannot be updated")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("mounts"), old.Mounts, new.Mounts,
+		"volume mounts of JobManager cannot be updated")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("nodeSelector"), old.NodeSelector, new.NodeSelector,
+		"the node selector of JobManager cannot be updated")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("podTemplate"), old.PodTemplate, new.PodTemplate,
+		"the pod template of JobManager cannot be updated")
+	return allErrs
+}
+
+func _ValidateTaskManagerUpdate(
+	old *TaskManagerSpec, new *TaskManagerSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("ports"), old.Ports, new.Ports,
+		"the ports of TaskManager cannot be updated")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("volumes"), old.Volumes, new.Volumes,
+		"volumes of TaskManager cannot be updated")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("mounts"), old.Mounts, new.Mounts,
+		"volume mounts of TaskManager cannot be updated")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("nodeSelector"), old.NodeSelector, new.NodeSelector,
+		"the node selector of TaskManager cannot be updated")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("sidecars"), old.Sidecars, new.Sidecars,
+		"sidecars of TaskManager cannot be updated")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("podTemplate"), old.PodTemplate, new.PodTemplate,
+		"the pod template of TaskManager cannot be updated")
+	// Clusters created before the deployment type was introduced run
+	// TaskManagers as a Deployment.
+	if _GetTaskManagerDeploymentType(old) != _GetTaskManagerDeploymentType(new) {
+		allErrs = append(allErrs, field.Forbidden(
+			path.Child("deploymentType"),
+			"the deployment type of TaskManager cannot be updated in place"))
+	}
+	// The claims and the pod management policy of a StatefulSet are immutable.
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("volumeClaimTemplates"),
+		old.VolumeClaimTemplates, new.VolumeClaimTemplates,
+		"volume claim templates of TaskManager cannot be updated")
+	allErrs = _AppendIfChanged(
+		allErrs, path.Child("podManagementPolicy"),
+		old.PodManagementPolicy, new.PodManagementPolicy,
+		"the pod management policy of TaskManager cannot be updated")
+	return allErrs
+}
+
+// Pools can be added, removed and resized in place, but the placement of the
+// pods of an existing pool cannot be changed.
+func _ValidateTaskManagerPoolsUpdate(
+	old []TaskManagerPoolSpec,
+	new []TaskManagerPoolSpec,
+	path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, newPool := range new {
+		for _, oldPool := range old {
+			if oldPool.Name != newPool.Name {
+				continue
+			}
+			allErrs = _AppendIfChanged(
+				allErrs, path.Index(i).Child("nodeSelector"),
+				oldPool.NodeSelector, newPool.NodeSelector,
+				"the node selector of a TaskManager pool cannot be updated")
+			allErrs = _AppendIfChanged(
+				allErrs, path.Index(i).Child("tolerations"),
+				oldPool.Tolerations, newPool.Tolerations,
+				"tolerations of a TaskManager pool cannot be updated")
+		}
+	}
+	return allErrs
+}
+
+func _ValidateJobUpdate(
+	old *JobSpec, new *JobSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if (old == nil) != (new == nil) {
+		allErrs = append(allErrs, field.Forbidden(
+			path,
+			"a session cluster cannot be converted to a job cluster or vice versa"))
+		return allErrs
+	}
+	// Clusters created before the mode was introduced run in Client mode.
+	if old != nil && _GetJobMode(old) != _GetJobMode(new) {
+		allErrs = append(allErrs, field.Forbidden(
+			path.Child("mode"), "the mode of the job cannot be updated in place"))
+	}
+	// Other changes of the job spec are applied by taking a savepoint of the
+	// running job and resubmitting it from the savepoint.
+	return allErrs
+}
+
+func _GetTaskManagerDeploymentType(tmSpec *TaskManagerSpec) string {
+	if tmSpec.DeploymentType == nil {
+		return TaskManagerDeploymentType.Deployment
+	}
+	return *tmSpec.DeploymentType
+}
+
+func _GetJobMode(jobSpec *JobSpec) string {
+	if jobSpec.Mode == nil {
+		return JobMode.Client
+	}
+	return *jobSpec.Mode
+}
+
+// Appends a Forbidden error with the reason to the list if the value of the
+// field has been changed.
+func _AppendIfChanged(
+	allErrs field.ErrorList,
+	path *field.Path,
+	old interface{},
+	new interface{},
+	reason string) field.ErrorList {
+	if !reflect.DeepEqual(old, new) {
+		allErrs = append(allErrs, field.Forbidden(path, reason))
 	}
-	return nil
+	return allErrs
 }
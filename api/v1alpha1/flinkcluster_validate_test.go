@@ -17,25 +17,649 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"strings"
 	"testing"
 
 	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// Tests updating status is allowed.
-func TestUpdateStatusAllowed(t *testing.T) {
+// Tests updating status through the main resource is not allowed.
+func TestUpdateStatusNotAllowed(t *testing.T) {
 	var oldCluster = FlinkCluster{Status: FlinkClusterStatus{State: "NoReady"}}
 	var newCluster = FlinkCluster{Status: FlinkClusterStatus{State: "Running"}}
 	var err = _ValidateUpdate(&oldCluster, &newCluster)
-	assert.NilError(t, err, "updating status failed unexpectedly")
+	assert.Error(
+		t, err, "status: Forbidden: the status is managed by the operator")
 }
 
-// Tests updating spec is not allowed.
+// Tests updating spec fields which can be applied in place is allowed.
+func TestUpdateSpecAllowed(t *testing.T) {
+	var oldCluster = FlinkCluster{
+		Spec: FlinkClusterSpec{
+			ImageSpec:       ImageSpec{Name: "flink:1.8.1"},
+			TaskManagerSpec: TaskManagerSpec{Replicas: 1},
+			FlinkProperties: map[string]string{"taskmanager.numberOfTaskSlots": "1"},
+		},
+	}
+	var newCluster = FlinkCluster{
+		Spec: FlinkClusterSpec{
+			ImageSpec:       ImageSpec{Name: "flink:1.8.2"},
+			TaskManagerSpec: TaskManagerSpec{Replicas: 3},
+			FlinkProperties: map[string]string{"taskmanager.numberOfTaskSlots": "2"},
+			EnvVars:         []corev1.EnvVar{{Name: "FOO", Value: "bar"}},
+			TaskManagerPools: []TaskManagerPoolSpec{
+				{Name: "highmem", Replicas: 2},
+			},
+		},
+	}
+	var err = _ValidateUpdate(&oldCluster, &newCluster)
+	assert.NilError(t, err, "updating spec failed unexpectedly")
+}
+
+// Tests updating spec fields which cannot be applied in place is not allowed.
 func TestUpdateSpecNotAllowed(t *testing.T) {
-	var oldCluster = FlinkCluster{Spec: FlinkClusterSpec{ImageSpec: ImageSpec{Name: "flink:1.8.1"}}}
-	var newCluster = FlinkCluster{Spec: FlinkClusterSpec{ImageSpec: ImageSpec{Name: "flink:1.9.0"}}}
+	var oldCluster = FlinkCluster{
+		Spec: FlinkClusterSpec{
+			JobManagerSpec: JobManagerSpec{AccessScope: AccessScope.Cluster},
+		},
+	}
+	var newCluster = FlinkCluster{
+		Spec: FlinkClusterSpec{
+			JobManagerSpec: JobManagerSpec{AccessScope: AccessScope.External},
+		},
+	}
 	var err = _ValidateUpdate(&oldCluster, &newCluster)
-	var expectedErr = "updating FlinkCluster spec is not allowed," +
-		" please delete the resouce and recreate"
+	var expectedErr = "spec.jobManager.accessScope: Forbidden: " +
+		"the type of the JobManager service cannot be updated in place"
+	assert.Equal(t, err.Error(), expectedErr)
+
+	newCluster = oldCluster
+	newCluster.Spec.HighAvailability = &HighAvailabilitySpec{
+		Mode:       HighAvailabilityMode.Kubernetes,
+		StorageDir: "gs://my-bucket/flink/ha",
+	}
+	err = _ValidateUpdate(&oldCluster, &newCluster)
+	expectedErr = "spec.highAvailability: Forbidden: " +
+		"high availability cannot be enabled, disabled or changed in place"
 	assert.Equal(t, err.Error(), expectedErr)
+
+	newCluster = oldCluster
+	newCluster.Spec.TaskManagerSpec.PodTemplate = &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{PriorityClassName: "high-priority"},
+	}
+	err = _ValidateUpdate(&oldCluster, &newCluster)
+	expectedErr = "spec.taskManager.podTemplate: Forbidden: " +
+		"the pod template of TaskManager cannot be updated"
+	assert.Equal(t, err.Error(), expectedErr)
+
+	newCluster = oldCluster
+	var deploymentType = TaskManagerDeploymentType.StatefulSet
+	newCluster.Spec.TaskManagerSpec.DeploymentType = &deploymentType
+	err = _ValidateUpdate(&oldCluster, &newCluster)
+	expectedErr = "spec.taskManager.deploymentType: Forbidden: " +
+		"the deployment type of TaskManager cannot be updated in place"
+	assert.Equal(t, err.Error(), expectedErr)
+
+	oldCluster.Spec.TaskManagerPools = []TaskManagerPoolSpec{
+		{Name: "highmem", Replicas: 1},
+	}
+	newCluster = oldCluster
+	newCluster.Spec.TaskManagerPools = []TaskManagerPoolSpec{
+		{
+			Name:         "highmem",
+			Replicas:     2,
+			NodeSelector: map[string]string{"pool": "highmem"},
+		},
+	}
+	err = _ValidateUpdate(&oldCluster, &newCluster)
+	expectedErr = "spec.taskManagerPools[0].nodeSelector: Forbidden: " +
+		"the node selector of a TaskManager pool cannot be updated"
+	assert.Equal(t, err.Error(), expectedErr)
+}
+
+// Tests updating the job of a job cluster is allowed.
+func TestUpdateJobSpecAllowed(t *testing.T) {
+	var oldCluster = FlinkCluster{
+		Spec: FlinkClusterSpec{JobSpec: &JobSpec{JarFile: "job-v1.jar"}},
+	}
+	var newCluster = FlinkCluster{
+		Spec: FlinkClusterSpec{
+			JobSpec: &JobSpec{JarFile: "job-v2.jar", Args: []string{"--foo"}},
+		},
+	}
+	var err = _ValidateUpdate(&oldCluster, &newCluster)
+	assert.NilError(t, err, "updating job spec failed unexpectedly")
+}
+
+// Tests the mode of the job cannot be updated, clusters created without the mode
+// are in Client mode.
+func TestUpdateJobModeNotAllowed(t *testing.T) {
+	var clientMode = JobMode.Client
+	var applicationMode = JobMode.Application
+	var oldCluster = FlinkCluster{
+		Spec: FlinkClusterSpec{JobSpec: &JobSpec{JarFile: "job.jar"}},
+	}
+	var newCluster = FlinkCluster{
+		Spec: FlinkClusterSpec{
+			JobSpec: &JobSpec{JarFile: "job.jar", Mode: &clientMode},
+		},
+	}
+	var err = _ValidateUpdate(&oldCluster, &newCluster)
+	assert.NilError(t, err, "defaulting the job mode failed unexpectedly")
+
+	newCluster.Spec.JobSpec.Mode = &applicationMode
+	err = _ValidateUpdate(&oldCluster, &newCluster)
+	assert.Error(t, err, "spec.job.mode: Forbidden: "+
+		"the mode of the job cannot be updated in place")
+}
+
+// Tests converting a session cluster to a job cluster is not allowed.
+func TestUpdateJobSpecNotAllowed(t *testing.T) {
+	var oldCluster = FlinkCluster{Spec: FlinkClusterSpec{}}
+	var newCluster = FlinkCluster{
+		Spec: FlinkClusterSpec{JobSpec: &JobSpec{JarFile: "job.jar"}},
+	}
+	var err = _ValidateUpdate(&oldCluster, &newCluster)
+	var expectedErr = "spec.job: Forbidden: " +
+		"a session cluster cannot be converted to a job cluster or vice versa"
+	assert.Equal(t, err.Error(), expectedErr)
+}
+
+// Tests clusters which do not pass the create rules can still be updated, as
+// long as the changed fields are valid.
+func TestValidateUpdateRequest(t *testing.T) {
+	var oldCluster = newTestValidCluster()
+	oldCluster.ObjectMeta.Finalizers = []string{"flinkoperator.k8s.io/savepoint"}
+	oldCluster.Spec.LogConfig = map[string]string{
+		"flink-conf.yaml": "parallelism.default: 2",
+	}
+
+	// Removing the finalizer of a cluster being deleted.
+	var newCluster = oldCluster.DeepCopy()
+	newCluster.ObjectMeta.DeletionTimestamp = &metav1.Time{}
+	newCluster.ObjectMeta.Finalizers = nil
+	var err = _ValidateUpdateRequest(oldCluster, newCluster)
+	assert.NilError(t, err, "removing the finalizer failed unexpectedly")
+
+	// Updating the metadata only.
+	newCluster = oldCluster.DeepCopy()
+	newCluster.ObjectMeta.Labels = map[string]string{"team": "data"}
+	err = _ValidateUpdateRequest(oldCluster, newCluster)
+	assert.NilError(t, err, "updating the labels failed unexpectedly")
+
+	// Updating the fields which pass validation.
+	newCluster = oldCluster.DeepCopy()
+	newCluster.Spec.TaskManagerSpec.Replicas = 3
+	err = _ValidateUpdateRequest(oldCluster, newCluster)
+	assert.NilError(t, err, "updating the replicas failed unexpectedly")
+
+	// Updating the fields which do not pass validation.
+	newCluster = oldCluster.DeepCopy()
+	newCluster.Spec.TaskManagerSpec.Replicas = -1
+	err = _ValidateUpdateRequest(oldCluster, newCluster)
+	assert.Error(t, err, "spec.taskManager.replicas: Invalid value: -1: "+
+		"must be non-negative")
+}
+
+func newTestValidCluster() *FlinkCluster {
+	var cluster = FlinkCluster{
+		Spec: FlinkClusterSpec{
+			ImageSpec:       ImageSpec{Name: "flink:1.8.1"},
+			TaskManagerSpec: TaskManagerSpec{Replicas: 1},
+			JobSpec:         &JobSpec{JarFile: "./examples/batch/WordCount.jar"},
+		},
+	}
+	_SetDefault(&cluster)
+	return &cluster
+}
+
+// Tests a valid cluster passes validation.
+func TestValidateCreateValid(t *testing.T) {
+	var err = _ValidateCreate(newTestValidCluster())
+	assert.NilError(t, err, "validating cluster failed unexpectedly")
+}
+
+// Tests multiple JobManager replicas are allowed with high availability.
+func TestValidateCreateHighAvailability(t *testing.T) {
+	var cluster = newTestValidCluster()
+	var replicas int32 = 2
+	cluster.Spec.JobManagerSpec.Replicas = &replicas
+	cluster.Spec.HighAvailability = &HighAvailabilitySpec{
+		Mode:       HighAvailabilityMode.Kubernetes,
+		StorageDir: "gs://my-bucket/flink/ha",
+	}
+	var err = _ValidateCreate(cluster)
+	assert.NilError(t, err, "validating HA cluster failed unexpectedly")
+}
+
+// Tests invalid fields are rejected with the reason for each field.
+func TestValidateCreateInvalid(t *testing.T) {
+	var int32Ptr = func(i int32) *int32 { return &i }
+	var testCases = []struct {
+		name        string
+		update      func(cluster *FlinkCluster)
+		expectedErr string
+	}{
+		{
+			name: "missing image name",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.ImageSpec.Name = ""
+			},
+			expectedErr: "spec.image.name: Required value",
+		},
+		{
+			name: "unknown pull policy",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.ImageSpec.PullPolicy = "Sometimes"
+			},
+			expectedErr: `spec.image.pullPolicy: Unsupported value: "Sometimes": ` +
+				`supported values: "Always", "Never", "IfNotPresent"`,
+		},
+		{
+			name: "multiple JobManager replicas",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobManagerSpec.Replicas = int32Ptr(2)
+			},
+			expectedErr: "spec.jobManager.replicas: Invalid value: 2: only 1 " +
+				"JobManager replica is supported without high availability",
+		},
+		{
+			name: "zero JobManager replicas",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobManagerSpec.Replicas = int32Ptr(0)
+			},
+			expectedErr: "spec.jobManager.replicas: Invalid value: 0: " +
+				"must be at least 1",
+		},
+		{
+			name: "unknown high availability mode",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.HighAvailability = &HighAvailabilitySpec{
+					Mode:       "Etcd",
+					StorageDir: "gs://my-bucket/flink/ha",
+				}
+			},
+			expectedErr: `spec.highAvailability.mode: Unsupported value: ` +
+				`"Etcd": supported values: "Kubernetes", "ZooKeeper"`,
+		},
+		{
+			name: "missing ZooKeeper quorum and HA storage dir",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.HighAvailability = &HighAvailabilitySpec{
+					Mode: HighAvailabilityMode.ZooKeeper,
+				}
+			},
+			expectedErr: "[spec.highAvailability.zooKeeperQuorum: Required value, " +
+				"spec.highAvailability.storageDir: Required value]",
+		},
+		{
+			name: "unknown access scope",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobManagerSpec.AccessScope = "Public"
+			},
+			expectedErr: `spec.jobManager.accessScope: Unsupported value: ` +
+				`"Public": supported values: "Cluster", "VPC", "External"`,
+		},
+		{
+			name: "port out of range",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobManagerSpec.Ports.UI = int32Ptr(80800)
+			},
+			expectedErr: "spec.jobManager.ports.ui: Invalid value: 80800: " +
+				"must be between 1 and 65535, inclusive",
+		},
+		{
+			name: "duplicate JobManager ports",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobManagerSpec.Ports.UI = int32Ptr(6123)
+			},
+			expectedErr: "spec.jobManager.ports.ui: Invalid value: 6123: " +
+				"conflicts with spec.jobManager.ports.rpc",
+		},
+		{
+			name: "relative ingress path",
+			update: func(cluster *FlinkCluster) {
+				var path = "flink"
+				cluster.Spec.JobManagerSpec.Ingress = &JobManagerIngressSpec{
+					Path: &path,
+				}
+			},
+			expectedErr: `spec.jobManager.ingress.path: Invalid value: "flink": ` +
+				"must start with /",
+		},
+		{
+			name: "duplicate TaskManager ports",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.TaskManagerSpec.Ports.Query = int32Ptr(6121)
+			},
+			expectedErr: "spec.taskManager.ports.query: Invalid value: 6121: " +
+				"conflicts with spec.taskManager.ports.data",
+		},
+		{
+			name: "negative TaskManager replicas",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.TaskManagerSpec.Replicas = -1
+			},
+			expectedErr: "spec.taskManager.replicas: Invalid value: -1: " +
+				"must be non-negative",
+		},
+		{
+			name: "empty JAR file",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobSpec.JarFile = ""
+			},
+			expectedErr: "spec.job.jarFile: Required value: " +
+				"one of jarFile, pyFile, pyModule and sqlScript is required",
+		},
+		{
+			name: "multiple entry points",
+			update: func(cluster *FlinkCluster) {
+				var pyFile = "/opt/flink/job/word_count.py"
+				cluster.Spec.JobSpec.PyFile = &pyFile
+			},
+			expectedErr: "spec.job.pyFile: Forbidden: " +
+				"jarFile is already set as the entry point",
+		},
+		{
+			name: "Python module without files",
+			update: func(cluster *FlinkCluster) {
+				var pyModule = "word_count"
+				cluster.Spec.JobSpec.JarFile = ""
+				cluster.Spec.JobSpec.PyModule = &pyModule
+			},
+			expectedErr: "spec.job.pyFiles: Required value: " +
+				"the files of pyModule are required",
+		},
+		{
+			name: "Python files for JAR file",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobSpec.PyFiles = []string{"/opt/flink/job/udfs.zip"}
+			},
+			expectedErr: "spec.job.pyFiles: Forbidden: " +
+				"only supported for Python jobs",
+		},
+		{
+			name: "SQL script without source",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobSpec.JarFile = ""
+				cluster.Spec.JobSpec.SQLScript = &SQLScriptSpec{}
+			},
+			expectedErr: "spec.job.sqlScript: Required value: " +
+				"one of configMap and inline is required",
+		},
+		{
+			name: "SQL script in Application mode",
+			update: func(cluster *FlinkCluster) {
+				var mode = JobMode.Application
+				var script = "SELECT 1;"
+				cluster.Spec.JobSpec.Mode = &mode
+				cluster.Spec.JobSpec.JarFile = ""
+				cluster.Spec.JobSpec.SQLScript = &SQLScriptSpec{Inline: &script}
+			},
+			expectedErr: "spec.job.sqlScript: Forbidden: " +
+				"only JAR files are supported in Application mode",
+		},
+		{
+			name: "unknown job mode",
+			update: func(cluster *FlinkCluster) {
+				var mode = "Session"
+				cluster.Spec.JobSpec.Mode = &mode
+			},
+			expectedErr: `spec.job.mode: Unsupported value: "Session": ` +
+				`supported values: "Client", "Application"`,
+		},
+		{
+			name: "JAR file outside usrlib in Application mode",
+			update: func(cluster *FlinkCluster) {
+				var mode = JobMode.Application
+				cluster.Spec.JobSpec.Mode = &mode
+			},
+			expectedErr: "spec.job.jarFile: Invalid value: " +
+				`"./examples/batch/WordCount.jar": ` +
+				"must be in /opt/flink/usrlib or a remote URI in Application mode",
+		},
+		{
+			name: "unsupported artifact scheme",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobSpec.JarFile = "hdfs://namenode/jobs/WordCount.jar"
+			},
+			expectedErr: `spec.job.jarFile: Unsupported value: "hdfs://": ` +
+				`supported values: "http", "https", "gs", "s3", "pvc"`,
+		},
+		{
+			name: "invalid artifact checksum",
+			update: func(cluster *FlinkCluster) {
+				var checksum = "abc"
+				var mode = JobMode.Application
+				cluster.Spec.JobSpec.Mode = &mode
+				cluster.Spec.JobSpec.JarFile = "gs://my-bucket/jobs/job.jar"
+				cluster.Spec.JobSpec.Artifacts = []ArtifactSpec{
+					{URI: "gs://my-bucket/jobs/deps.jar", SHA256: &checksum},
+				}
+			},
+			expectedErr: `spec.job.artifacts[0].sha256: Invalid value: "abc": ` +
+				"must be 64 hex digits",
+		},
+		{
+			name: "duplicate artifact file name",
+			update: func(cluster *FlinkCluster) {
+				var mode = JobMode.Application
+				cluster.Spec.JobSpec.Mode = &mode
+				cluster.Spec.JobSpec.JarFile = "https://example.com/jobs/job.jar?v=1"
+				cluster.Spec.JobSpec.Artifacts = []ArtifactSpec{
+					{URI: "pvc://my-claim/jobs/job.jar"},
+				}
+			},
+			expectedErr: `spec.job.artifacts[0].uri: Duplicate value: "job.jar"`,
+		},
+		{
+			name: "artifacts in Client mode",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobSpec.JarFile = "gs://my-bucket/jobs/job.jar"
+				cluster.Spec.JobSpec.Artifacts = []ArtifactSpec{
+					{URI: "gs://my-bucket/jobs/deps.jar"},
+				}
+			},
+			expectedErr: "spec.job.artifacts: Forbidden: " +
+				"only supported in Application mode",
+		},
+		{
+			name: "checksum of local JAR file",
+			update: func(cluster *FlinkCluster) {
+				var checksum = strings.Repeat("0", 64)
+				cluster.Spec.JobSpec.JarFileSHA256 = &checksum
+			},
+			expectedErr: "spec.job.jarFileSHA256: Forbidden: " +
+				"only supported for remote JAR files",
+		},
+		{
+			name: "zero parallelism",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobSpec.Parallelism = int32Ptr(0)
+			},
+			expectedErr: "spec.job.parallelism: Invalid value: 0: " +
+				"must be at least 1",
+		},
+		{
+			name: "zero savepoint timeout on deletion",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobSpec.SavepointOnDeleteTimeoutSeconds = int32Ptr(0)
+			},
+			expectedErr: "spec.job.savepointOnDeleteTimeoutSeconds: " +
+				"Invalid value: 0: must be at least 1",
+		},
+		{
+			name: "unknown restart policy",
+			update: func(cluster *FlinkCluster) {
+				var restartPolicy = corev1.RestartPolicyAlways
+				cluster.Spec.JobSpec.RestartPolicy = &restartPolicy
+			},
+			expectedErr: `spec.job.restartPolicy: Unsupported value: "Always": ` +
+				`supported values: "OnFailure", "Never", "FromSavepointOnFailure"`,
+		},
+		{
+			name: "zero max restart attempts",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobSpec.MaxRestartAttempts = int32Ptr(0)
+			},
+			expectedErr: "spec.job.maxRestartAttempts: Invalid value: 0: " +
+				"must be at least 1",
+		},
+		{
+			name: "log config overriding flink-conf.yaml",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.LogConfig = map[string]string{
+					"flink-conf.yaml":          "parallelism.default: 2",
+					"log4j-console.properties": "log4j.rootLogger=INFO, console",
+				}
+			},
+			expectedErr: `spec.logConfig[flink-conf.yaml]: Invalid value: ` +
+				`"flink-conf.yaml": flink-conf.yaml is generated from flinkProperties`,
+		},
+		{
+			name: "metrics port conflicting with TaskManager port",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.Monitoring = &MonitoringSpec{Port: int32Ptr(6121)}
+			},
+			expectedErr: "spec.monitoring.port: Invalid value: 6121: " +
+				"conflicts with spec.taskManager.ports.data",
+		},
+		{
+			name: "unknown monitor kind",
+			update: func(cluster *FlinkCluster) {
+				var monitor = "Probe"
+				cluster.Spec.Monitoring = &MonitoringSpec{
+					Port:    int32Ptr(9249),
+					Monitor: &monitor,
+				}
+			},
+			expectedErr: `spec.monitoring.monitor: Unsupported value: "Probe": ` +
+				`supported values: "PodMonitor", "ServiceMonitor"`,
+		},
+		{
+			name: "invalid scrape interval",
+			update: func(cluster *FlinkCluster) {
+				var interval = "30"
+				cluster.Spec.Monitoring = &MonitoringSpec{
+					Port:           int32Ptr(9249),
+					ScrapeInterval: &interval,
+				}
+			},
+			expectedErr: `spec.monitoring.scrapeInterval: Invalid value: "30": ` +
+				"must be a duration, e.g., 30s",
+		},
+		{
+			name: "pod template container without name",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.TaskManagerSpec.PodTemplate = &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Image: "fluentd"}},
+					},
+				}
+			},
+			expectedErr: "spec.taskManager.podTemplate.spec.containers[0].name: " +
+				"Required value",
+		},
+		{
+			name: "job pod template in Application mode",
+			update: func(cluster *FlinkCluster) {
+				var mode = JobMode.Application
+				cluster.Spec.JobSpec.Mode = &mode
+				cluster.Spec.JobSpec.JarFile = "/opt/flink/usrlib/job.jar"
+				cluster.Spec.JobSpec.PodTemplate = &corev1.PodTemplateSpec{}
+			},
+			expectedErr: "spec.job.podTemplate: Forbidden: " +
+				"not supported in Application mode, the job is run by JobManager",
+		},
+		{
+			name: "volume claim templates of Deployment",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.TaskManagerSpec.VolumeClaimTemplates =
+					[]corev1.PersistentVolumeClaim{
+						{ObjectMeta: metav1.ObjectMeta{Name: "state"}},
+					}
+			},
+			expectedErr: "spec.taskManager.volumeClaimTemplates: Forbidden: " +
+				"only supported for StatefulSet",
+		},
+		{
+			name: "volume claim template without name",
+			update: func(cluster *FlinkCluster) {
+				var deploymentType = TaskManagerDeploymentType.StatefulSet
+				cluster.Spec.TaskManagerSpec.DeploymentType = &deploymentType
+				cluster.Spec.TaskManagerSpec.VolumeClaimTemplates =
+					[]corev1.PersistentVolumeClaim{{}}
+			},
+			expectedErr: "spec.taskManager.volumeClaimTemplates[0].metadata.name: " +
+				"Required value",
+		},
+		{
+			name: "duplicate TaskManager pool name",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.TaskManagerPools = []TaskManagerPoolSpec{
+					{Name: "highmem", Replicas: 1},
+					{Name: "highmem", Replicas: 2},
+				}
+			},
+			expectedErr: `spec.taskManagerPools[1].name: Duplicate value: "highmem"`,
+		},
+		{
+			name: "TaskManager pools with autoscaler",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.TaskManagerPools = []TaskManagerPoolSpec{
+					{Name: "highmem", Replicas: 1, TaskSlots: int32Ptr(0)},
+				}
+				cluster.Spec.JobSpec.Autoscaler = &JobAutoscalerSpec{
+					MinParallelism:    int32Ptr(1),
+					MaxParallelism:    8,
+					TargetUtilization: int32Ptr(70),
+				}
+			},
+			expectedErr: "[spec.taskManagerPools: Forbidden: " +
+				"not supported with the job autoscaler, " +
+				"spec.taskManagerPools[0].taskSlots: Invalid value: 0: " +
+				"must be at least 1]",
+		},
+		{
+			name: "autoscaler max parallelism below min",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobSpec.Autoscaler = &JobAutoscalerSpec{
+					MinParallelism: int32Ptr(4),
+					MaxParallelism: 2,
+				}
+			},
+			expectedErr: "spec.job.autoscaler.maxParallelism: Invalid value: 2: " +
+				"must be at least minParallelism",
+		},
+		{
+			name: "autoscaler target utilization out of range",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.JobSpec.Autoscaler = &JobAutoscalerSpec{
+					MaxParallelism:    8,
+					TargetUtilization: int32Ptr(120),
+				}
+			},
+			expectedErr: "spec.job.autoscaler.targetUtilization: " +
+				"Invalid value: 120: must be between 1 and 100",
+		},
+		{
+			name: "multiple errors",
+			update: func(cluster *FlinkCluster) {
+				cluster.Spec.ImageSpec.Name = ""
+				cluster.Spec.JobSpec.JarFile = ""
+			},
+			expectedErr: "[spec.image.name: Required value, " +
+				"spec.job.jarFile: Required value: " +
+				"one of jarFile, pyFile, pyModule and sqlScript is required]",
+		},
+	}
+
+	for _, testCase := range testCases {
+		var cluster = newTestValidCluster()
+		testCase.update(cluster)
+		var err = _ValidateCreate(cluster)
+		assert.Error(t, err, testCase.expectedErr, testCase.name)
+	}
 }
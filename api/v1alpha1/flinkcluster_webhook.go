@@ -71,9 +71,7 @@ var _ webhook.Validator = &FlinkCluster{}
 // for the type.
 func (cluster *FlinkCluster) ValidateCreate() error {
 	flinkclusterlog.Info("validate create", "name", cluster.Name)
-
-	// TODO
-	return nil
+	return _ValidateCreate(cluster)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered
@@ -81,7 +79,7 @@ func (cluster *FlinkCluster) ValidateCreate() error {
 func (cluster *FlinkCluster) ValidateUpdate(old runtime.Object) error {
 	flinkclusterlog.Info("Validate update", "name", cluster.Name)
 	var oldCluster = old.(*FlinkCluster)
-	return _ValidateUpdate(oldCluster, cluster)
+	return _ValidateUpdateRequest(oldCluster, cluster)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered
@@ -0,0 +1,89 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FlinkJobSpec defines the desired state of FlinkJob.
+type FlinkJobSpec struct {
+	// The name of the session FlinkCluster in the same namespace, which the
+	// job is submitted to.
+	ClusterName string `json:"clusterName"`
+
+	// The job to submit, the image of the cluster is used for submitting it.
+	// `savepointsDir` is not used.
+	Job JobSpec `json:"job"`
+}
+
+// FlinkJobStatus defines the observed state of FlinkJob.
+type FlinkJobStatus struct {
+	// The state of the job, derived from the state of the Flink job when it
+	// is available through the Flink REST API, otherwise from the state of the
+	// submitter.
+	State string `json:"state"`
+
+	// The ID of the Flink job.
+	ID string `json:"id,omitempty"`
+
+	// The state of the Flink job reported by the Flink REST API, e.g.,
+	// "RUNNING", "RESTARTING", "CANCELED".
+	FlinkJobState string `json:"flinkJobState,omitempty"`
+
+	// The name of the Kubernetes job which submits the Flink job.
+	SubmitterName string `json:"submitterName,omitempty"`
+
+	// The state of the Kubernetes job which submits the Flink job,
+	// enum("Pending", "Running", "Succeeded", "Failed").
+	SubmitterState string `json:"submitterState,omitempty"`
+
+	// The reason why the job could not be submitted or tracked.
+	FailureReason string `json:"failureReason,omitempty"`
+
+	// Last update timestamp for this status.
+	LastUpdateTime string `json:"lastUpdateTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.clusterName"
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+// +kubebuilder:printcolumn:name="Job ID",type="string",JSONPath=".status.id"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// FlinkJob is the Schema for the flinkjobs API
+type FlinkJob struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FlinkJobSpec   `json:"spec"`
+	Status FlinkJobStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// FlinkJobList contains a list of FlinkJob
+type FlinkJobList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FlinkJob `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FlinkJob{}, &FlinkJobList{})
+}
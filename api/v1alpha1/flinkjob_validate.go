@@ -0,0 +1,74 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validates create request of FlinkJob.
+func _ValidateFlinkJobCreate(job *FlinkJob) error {
+	var allErrs field.ErrorList
+	var specPath = field.NewPath("spec")
+	if len(job.Spec.ClusterName) == 0 {
+		allErrs = append(
+			allErrs, field.Required(specPath.Child("clusterName"), ""))
+	}
+	allErrs = append(
+		allErrs, _ValidateJob(&job.Spec.Job, specPath.Child("job"))...)
+	if _GetJobMode(&job.Spec.Job) == JobMode.Application {
+		allErrs = append(allErrs, field.Invalid(
+			specPath.Child("job", "mode"),
+			JobMode.Application,
+			"only supported for job clusters"))
+	}
+	// The submitter exits once the job is submitted, so the operator does not
+	// watch the job for failures.
+	var restartPolicy = job.Spec.Job.RestartPolicy
+	if restartPolicy != nil && string(*restartPolicy) ==
+		JobRestartPolicy.FromSavepointOnFailure {
+		allErrs = append(allErrs, field.Invalid(
+			specPath.Child("job", "restartPolicy"),
+			string(*restartPolicy),
+			"only supported for job clusters"))
+	}
+	// The job is rescaled with the TaskManagers of its cluster, which are
+	// shared with the other jobs in a session cluster.
+	if job.Spec.Job.Autoscaler != nil {
+		allErrs = append(allErrs, field.Forbidden(
+			specPath.Child("job", "autoscaler"),
+			"only supported for job clusters"))
+	}
+	return allErrs.ToAggregate()
+}
+
+// Validates update request of FlinkJob.
+//
+// The job has been submitted once the FlinkJob is created, so the spec cannot
+// be updated, the FlinkJob needs to be deleted and recreated instead.
+func _ValidateFlinkJobUpdate(old *FlinkJob, new *FlinkJob) error {
+	var allErrs field.ErrorList
+	var specPath = field.NewPath("spec")
+	allErrs = _AppendIfChanged(
+		allErrs, specPath.Child("clusterName"),
+		old.Spec.ClusterName, new.Spec.ClusterName,
+		"the job cannot be moved to another cluster")
+	allErrs = _AppendIfChanged(
+		allErrs, specPath.Child("job"), old.Spec.Job, new.Spec.Job,
+		"the submitted job cannot be updated, delete and recreate the FlinkJob instead")
+	return allErrs.ToAggregate()
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Tests the cluster name and the entry point of the job are required.
+func TestFlinkJobCreateRequiredFields(t *testing.T) {
+	var job = FlinkJob{}
+	var err = _ValidateFlinkJobCreate(&job)
+	var expectedErr = "[spec.clusterName: Required value, " +
+		"spec.job.jarFile: Required value: " +
+		"one of jarFile, pyFile, pyModule and sqlScript is required]"
+	assert.Equal(t, err.Error(), expectedErr)
+
+	job.Spec.ClusterName = "mysessioncluster"
+	job.Spec.Job.JarFile = "./examples/streaming/WordCount.jar"
+	err = _ValidateFlinkJobCreate(&job)
+	assert.NilError(t, err, "validating FlinkJob failed unexpectedly")
+}
+
+// Tests restarting from savepoints is rejected for jobs in session clusters.
+func TestFlinkJobCreateRestartFromSavepoint(t *testing.T) {
+	var restartPolicy = corev1.RestartPolicy(
+		JobRestartPolicy.FromSavepointOnFailure)
+	var job = FlinkJob{
+		Spec: FlinkJobSpec{
+			ClusterName: "mysessioncluster",
+			Job: JobSpec{
+				JarFile:       "./examples/streaming/WordCount.jar",
+				RestartPolicy: &restartPolicy,
+			},
+		},
+	}
+	var err = _ValidateFlinkJobCreate(&job)
+	var expectedErr = `spec.job.restartPolicy: Invalid value: ` +
+		`"FromSavepointOnFailure": only supported for job clusters`
+	assert.Error(t, err, expectedErr)
+}
+
+// Tests the autoscaler is rejected for jobs in session clusters.
+func TestFlinkJobCreateAutoscaler(t *testing.T) {
+	var job = FlinkJob{
+		Spec: FlinkJobSpec{
+			ClusterName: "mysessioncluster",
+			Job: JobSpec{
+				JarFile:    "./examples/streaming/WordCount.jar",
+				Autoscaler: &JobAutoscalerSpec{MaxParallelism: 8},
+			},
+		},
+	}
+	var err = _ValidateFlinkJobCreate(&job)
+	var expectedErr = "spec.job.autoscaler: Forbidden: " +
+		"only supported for job clusters"
+	assert.Error(t, err, expectedErr)
+}
+
+// Tests the spec of a submitted FlinkJob cannot be updated.
+func TestFlinkJobUpdateNotAllowed(t *testing.T) {
+	var oldJob = FlinkJob{
+		Spec: FlinkJobSpec{
+			ClusterName: "mysessioncluster",
+			Job:         JobSpec{JarFile: "job-v1.jar"},
+		},
+	}
+	var newJob = FlinkJob{
+		Spec: FlinkJobSpec{
+			ClusterName: "mysessioncluster",
+			Job:         JobSpec{JarFile: "job-v2.jar"},
+		},
+	}
+	var err = _ValidateFlinkJobUpdate(&oldJob, &newJob)
+	var expectedErr = "spec.job: Forbidden: the submitted job cannot be " +
+		"updated, delete and recreate the FlinkJob instead"
+	assert.Equal(t, err.Error(), expectedErr)
+
+	newJob.Spec.Job.JarFile = "job-v1.jar"
+	newJob.Status.State = JobState.Running
+	err = _ValidateFlinkJobUpdate(&oldJob, &newJob)
+	assert.NilError(t, err, "updating status failed unexpectedly")
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+var flinkjoblog = logf.Log.WithName("flinkjob-webhook")
+
+// SetupWebhookWithManager adds webhook for FlinkJob.
+func (job *FlinkJob) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(job).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-flinkoperator-k8s-io-v1alpha1-flinkjob,mutating=true,failurePolicy=fail,groups=flinkoperator.k8s.io,resources=flinkjobs,verbs=create;update,versions=v1alpha1,name=mflinkjob.flinkoperator.k8s.io
+
+var _ webhook.Defaulter = &FlinkJob{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the
+// type.
+func (job *FlinkJob) Default() {
+	flinkjoblog.Info("default", "name", job.Name, "original", *job)
+	_SetJobDefault(&job.Spec.Job)
+	flinkjoblog.Info("default", "name", job.Name, "augmented", *job)
+}
+
+// +kubebuilder:webhook:path=/validate-flinkoperator-k8s-io-v1alpha1-flinkjob,mutating=false,failurePolicy=fail,groups=flinkoperator.k8s.io,resources=flinkjobs,verbs=create;update,versions=v1alpha1,name=vflinkjob.flinkoperator.k8s.io
+
+var _ webhook.Validator = &FlinkJob{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered
+// for the type.
+func (job *FlinkJob) ValidateCreate() error {
+	flinkjoblog.Info("validate create", "name", job.Name)
+	return _ValidateFlinkJobCreate(job)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered
+// for the type.
+func (job *FlinkJob) ValidateUpdate(old runtime.Object) error {
+	flinkjoblog.Info("validate update", "name", job.Name)
+	var oldJob = old.(*FlinkJob)
+	return _ValidateFlinkJobUpdate(oldJob, job)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered
+// for the type.
+func (job *FlinkJob) ValidateDelete() error {
+	flinkjoblog.Info("validate delete", "name", job.Name)
+	return nil
+}
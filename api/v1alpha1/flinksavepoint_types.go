@@ -0,0 +1,101 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SavepointState defines states for a savepoint.
+var SavepointState = struct {
+	Pending    string
+	InProgress string
+	Succeeded  string
+	Failed     string
+}{
+	Pending:    "Pending",
+	InProgress: "InProgress",
+	Succeeded:  "Succeeded",
+	Failed:     "Failed",
+}
+
+// FlinkSavepointSpec defines the desired state of FlinkSavepoint. The spec is
+// only read when the savepoint is triggered, later updates are ignored.
+type FlinkSavepointSpec struct {
+	// The name of the FlinkCluster in the same namespace, a savepoint is taken
+	// for its running job.
+	ClusterName string `json:"clusterName"`
+
+	// Savepoint target directory, optional. If omitted, the savepoints dir of
+	// the job spec is used, then `state.savepoints.dir` in the Flink
+	// properties of the cluster.
+	SavepointsDir *string `json:"savepointsDir,omitempty"`
+}
+
+// FlinkSavepointStatus defines the observed state of FlinkSavepoint.
+type FlinkSavepointStatus struct {
+	// The state of the savepoint, enum("Pending", "InProgress", "Succeeded",
+	// "Failed").
+	State string `json:"state"`
+
+	// The ID of the Flink job the savepoint is taken for.
+	JobID string `json:"jobID,omitempty"`
+
+	// The trigger ID of the savepoint returned by the Flink REST API.
+	TriggerID string `json:"triggerID,omitempty"`
+
+	// The time when the savepoint was triggered, in RFC3339 format.
+	TriggerTime string `json:"triggerTime,omitempty"`
+
+	// The location of the savepoint, available when it has succeeded.
+	Location string `json:"location,omitempty"`
+
+	// The time when the savepoint completed, in RFC3339 format.
+	Timestamp string `json:"timestamp,omitempty"`
+
+	// The reason of the failure, available when it has failed.
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.clusterName"
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+// +kubebuilder:printcolumn:name="Location",type="string",JSONPath=".status.location"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// FlinkSavepoint is the Schema for the flinksavepoints API
+type FlinkSavepoint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FlinkSavepointSpec   `json:"spec"`
+	Status FlinkSavepointStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// FlinkSavepointList contains a list of FlinkSavepoint
+type FlinkSavepointList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FlinkSavepoint `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FlinkSavepoint{}, &FlinkSavepointList{})
+}
@@ -21,10 +21,56 @@ limitations under the License.
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArtifactFetcherSpec) DeepCopyInto(out *ArtifactFetcherSpec) {
+	*out = *in
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(string)
+		**out = **in
+	}
+	if in.CredentialsSecret != nil {
+		in, out := &in.CredentialsSecret, &out.CredentialsSecret
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArtifactFetcherSpec.
+func (in *ArtifactFetcherSpec) DeepCopy() *ArtifactFetcherSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ArtifactFetcherSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArtifactSpec) DeepCopyInto(out *ArtifactSpec) {
+	*out = *in
+	if in.SHA256 != nil {
+		in, out := &in.SHA256, &out.SHA256
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArtifactSpec.
+func (in *ArtifactSpec) DeepCopy() *ArtifactSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ArtifactSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FlinkCluster) DeepCopyInto(out *FlinkCluster) {
 	*out = *in
@@ -72,10 +118,25 @@ func (in *FlinkClusterComponentsStatus) DeepCopyInto(out *FlinkClusterComponents
 	*out = *in
 	out.JobManagerDeployment = in.JobManagerDeployment
 	out.JobManagerService = in.JobManagerService
+	if in.JobManagerIngress != nil {
+		in, out := &in.JobManagerIngress, &out.JobManagerIngress
+		*out = new(JobManagerIngressStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	out.TaskManagerDeployment = in.TaskManagerDeployment
+	if in.TaskManagerPools != nil {
+		in, out := &in.TaskManagerPools, &out.TaskManagerPools
+		*out = make([]TaskManagerPoolStatus, len(*in))
+		copy(*out, *in)
+	}
 	if in.Job != nil {
 		in, out := &in.Job, &out.Job
 		*out = new(JobStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobManagerLeader != nil {
+		in, out := &in.JobManagerLeader, &out.JobManagerLeader
+		*out = new(JobManagerLeaderStatus)
 		**out = **in
 	}
 }
@@ -90,6 +151,22 @@ func (in *FlinkClusterComponentsStatus) DeepCopy() *FlinkClusterComponentsStatus
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlinkClusterCondition) DeepCopyInto(out *FlinkClusterCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlinkClusterCondition.
+func (in *FlinkClusterCondition) DeepCopy() *FlinkClusterCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(FlinkClusterCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FlinkClusterList) DeepCopyInto(out *FlinkClusterList) {
 	*out = *in
@@ -128,11 +205,23 @@ func (in *FlinkClusterSpec) DeepCopyInto(out *FlinkClusterSpec) {
 	in.ImageSpec.DeepCopyInto(&out.ImageSpec)
 	in.JobManagerSpec.DeepCopyInto(&out.JobManagerSpec)
 	in.TaskManagerSpec.DeepCopyInto(&out.TaskManagerSpec)
+	if in.TaskManagerPools != nil {
+		in, out := &in.TaskManagerPools, &out.TaskManagerPools
+		*out = make([]TaskManagerPoolSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.JobSpec != nil {
 		in, out := &in.JobSpec, &out.JobSpec
 		*out = new(JobSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.HighAvailability != nil {
+		in, out := &in.HighAvailability, &out.HighAvailability
+		*out = new(HighAvailabilitySpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.FlinkProperties != nil {
 		in, out := &in.FlinkProperties, &out.FlinkProperties
 		*out = make(map[string]string, len(*in))
@@ -140,6 +229,13 @@ func (in *FlinkClusterSpec) DeepCopyInto(out *FlinkClusterSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.LogConfig != nil {
+		in, out := &in.LogConfig, &out.LogConfig
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.EnvVars != nil {
 		in, out := &in.EnvVars, &out.EnvVars
 		*out = make([]v1.EnvVar, len(*in))
@@ -147,6 +243,11 @@ func (in *FlinkClusterSpec) DeepCopyInto(out *FlinkClusterSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Monitoring != nil {
+		in, out := &in.Monitoring, &out.Monitoring
+		*out = new(MonitoringSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlinkClusterSpec.
@@ -163,6 +264,18 @@ func (in *FlinkClusterSpec) DeepCopy() *FlinkClusterSpec {
 func (in *FlinkClusterStatus) DeepCopyInto(out *FlinkClusterStatus) {
 	*out = *in
 	in.Components.DeepCopyInto(&out.Components)
+	if in.TaskManager != nil {
+		in, out := &in.TaskManager, &out.TaskManager
+		*out = new(TaskManagerStatus)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]FlinkClusterCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlinkClusterStatus.
@@ -175,6 +288,215 @@ func (in *FlinkClusterStatus) DeepCopy() *FlinkClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlinkJob) DeepCopyInto(out *FlinkJob) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlinkJob.
+func (in *FlinkJob) DeepCopy() *FlinkJob {
+	if in == nil {
+		return nil
+	}
+	out := new(FlinkJob)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlinkJob) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlinkJobList) DeepCopyInto(out *FlinkJobList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FlinkJob, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlinkJobList.
+func (in *FlinkJobList) DeepCopy() *FlinkJobList {
+	if in == nil {
+		return nil
+	}
+	out := new(FlinkJobList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlinkJobList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlinkJobSpec) DeepCopyInto(out *FlinkJobSpec) {
+	*out = *in
+	in.Job.DeepCopyInto(&out.Job)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlinkJobSpec.
+func (in *FlinkJobSpec) DeepCopy() *FlinkJobSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FlinkJobSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlinkJobStatus) DeepCopyInto(out *FlinkJobStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlinkJobStatus.
+func (in *FlinkJobStatus) DeepCopy() *FlinkJobStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FlinkJobStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlinkSavepoint) DeepCopyInto(out *FlinkSavepoint) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlinkSavepoint.
+func (in *FlinkSavepoint) DeepCopy() *FlinkSavepoint {
+	if in == nil {
+		return nil
+	}
+	out := new(FlinkSavepoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlinkSavepoint) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlinkSavepointList) DeepCopyInto(out *FlinkSavepointList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FlinkSavepoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlinkSavepointList.
+func (in *FlinkSavepointList) DeepCopy() *FlinkSavepointList {
+	if in == nil {
+		return nil
+	}
+	out := new(FlinkSavepointList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlinkSavepointList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlinkSavepointSpec) DeepCopyInto(out *FlinkSavepointSpec) {
+	*out = *in
+	if in.SavepointsDir != nil {
+		in, out := &in.SavepointsDir, &out.SavepointsDir
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlinkSavepointSpec.
+func (in *FlinkSavepointSpec) DeepCopy() *FlinkSavepointSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FlinkSavepointSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlinkSavepointStatus) DeepCopyInto(out *FlinkSavepointStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlinkSavepointStatus.
+func (in *FlinkSavepointStatus) DeepCopy() *FlinkSavepointStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FlinkSavepointStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HighAvailabilitySpec) DeepCopyInto(out *HighAvailabilitySpec) {
+	*out = *in
+	if in.ClusterID != nil {
+		in, out := &in.ClusterID, &out.ClusterID
+		*out = new(string)
+		**out = **in
+	}
+	if in.ZooKeeperQuorum != nil {
+		in, out := &in.ZooKeeperQuorum, &out.ZooKeeperQuorum
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HighAvailabilitySpec.
+func (in *HighAvailabilitySpec) DeepCopy() *HighAvailabilitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HighAvailabilitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSpec) DeepCopyInto(out *ImageSpec) {
 	*out = *in
@@ -195,6 +517,113 @@ func (in *ImageSpec) DeepCopy() *ImageSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobAutoscalerSpec) DeepCopyInto(out *JobAutoscalerSpec) {
+	*out = *in
+	if in.MinParallelism != nil {
+		in, out := &in.MinParallelism, &out.MinParallelism
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetUtilization != nil {
+		in, out := &in.TargetUtilization, &out.TargetUtilization
+		*out = new(int32)
+		**out = **in
+	}
+	if in.StabilizationWindowSeconds != nil {
+		in, out := &in.StabilizationWindowSeconds, &out.StabilizationWindowSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobAutoscalerSpec.
+func (in *JobAutoscalerSpec) DeepCopy() *JobAutoscalerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(JobAutoscalerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobManagerIngressSpec) DeepCopyInto(out *JobManagerIngressSpec) {
+	*out = *in
+	if in.HostFormat != nil {
+		in, out := &in.HostFormat, &out.HostFormat
+		*out = new(string)
+		**out = **in
+	}
+	if in.Path != nil {
+		in, out := &in.Path, &out.Path
+		*out = new(string)
+		**out = **in
+	}
+	if in.IngressClass != nil {
+		in, out := &in.IngressClass, &out.IngressClass
+		*out = new(string)
+		**out = **in
+	}
+	if in.TLSSecretName != nil {
+		in, out := &in.TLSSecretName, &out.TLSSecretName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobManagerIngressSpec.
+func (in *JobManagerIngressSpec) DeepCopy() *JobManagerIngressSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(JobManagerIngressSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobManagerIngressStatus) DeepCopyInto(out *JobManagerIngressStatus) {
+	*out = *in
+	if in.URLs != nil {
+		in, out := &in.URLs, &out.URLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobManagerIngressStatus.
+func (in *JobManagerIngressStatus) DeepCopy() *JobManagerIngressStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(JobManagerIngressStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobManagerLeaderStatus) DeepCopyInto(out *JobManagerLeaderStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobManagerLeaderStatus.
+func (in *JobManagerLeaderStatus) DeepCopy() *JobManagerLeaderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(JobManagerLeaderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JobManagerPorts) DeepCopyInto(out *JobManagerPorts) {
 	*out = *in
@@ -239,6 +668,11 @@ func (in *JobManagerSpec) DeepCopyInto(out *JobManagerSpec) {
 		**out = **in
 	}
 	in.Ports.DeepCopyInto(&out.Ports)
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(JobManagerIngressSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Resources.DeepCopyInto(&out.Resources)
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
@@ -261,6 +695,11 @@ func (in *JobManagerSpec) DeepCopyInto(out *JobManagerSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(v1.PodTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobManagerSpec.
@@ -276,11 +715,58 @@ func (in *JobManagerSpec) DeepCopy() *JobManagerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JobSpec) DeepCopyInto(out *JobSpec) {
 	*out = *in
+	if in.Mode != nil {
+		in, out := &in.Mode, &out.Mode
+		*out = new(string)
+		**out = **in
+	}
+	if in.JarFileSHA256 != nil {
+		in, out := &in.JarFileSHA256, &out.JarFileSHA256
+		*out = new(string)
+		**out = **in
+	}
+	if in.Artifacts != nil {
+		in, out := &in.Artifacts, &out.Artifacts
+		*out = make([]ArtifactSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ArtifactFetcher != nil {
+		in, out := &in.ArtifactFetcher, &out.ArtifactFetcher
+		*out = new(ArtifactFetcherSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ClassName != nil {
 		in, out := &in.ClassName, &out.ClassName
 		*out = new(string)
 		**out = **in
 	}
+	if in.PyFile != nil {
+		in, out := &in.PyFile, &out.PyFile
+		*out = new(string)
+		**out = **in
+	}
+	if in.PyModule != nil {
+		in, out := &in.PyModule, &out.PyModule
+		*out = new(string)
+		**out = **in
+	}
+	if in.PyFiles != nil {
+		in, out := &in.PyFiles, &out.PyFiles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PyRequirements != nil {
+		in, out := &in.PyRequirements, &out.PyRequirements
+		*out = new(string)
+		**out = **in
+	}
+	if in.SQLScript != nil {
+		in, out := &in.SQLScript, &out.SQLScript
+		*out = new(SQLScriptSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Args != nil {
 		in, out := &in.Args, &out.Args
 		*out = make([]string, len(*in))
@@ -291,6 +777,11 @@ func (in *JobSpec) DeepCopyInto(out *JobSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.SavepointsDir != nil {
+		in, out := &in.SavepointsDir, &out.SavepointsDir
+		*out = new(string)
+		**out = **in
+	}
 	if in.AllowNonRestoredState != nil {
 		in, out := &in.AllowNonRestoredState, &out.AllowNonRestoredState
 		*out = new(bool)
@@ -311,6 +802,26 @@ func (in *JobSpec) DeepCopyInto(out *JobSpec) {
 		*out = new(v1.RestartPolicy)
 		**out = **in
 	}
+	if in.MaxRestartAttempts != nil {
+		in, out := &in.MaxRestartAttempts, &out.MaxRestartAttempts
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RestartBackoffSeconds != nil {
+		in, out := &in.RestartBackoffSeconds, &out.RestartBackoffSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TakeSavepointOnDelete != nil {
+		in, out := &in.TakeSavepointOnDelete, &out.TakeSavepointOnDelete
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SavepointOnDeleteTimeoutSeconds != nil {
+		in, out := &in.SavepointOnDeleteTimeoutSeconds, &out.SavepointOnDeleteTimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
 		*out = make([]v1.Volume, len(*in))
@@ -325,6 +836,16 @@ func (in *JobSpec) DeepCopyInto(out *JobSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(v1.PodTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Autoscaler != nil {
+		in, out := &in.Autoscaler, &out.Autoscaler
+		*out = new(JobAutoscalerSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobSpec.
@@ -340,6 +861,11 @@ func (in *JobSpec) DeepCopy() *JobSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JobStatus) DeepCopyInto(out *JobStatus) {
 	*out = *in
+	if in.SpecSavepoint != nil {
+		in, out := &in.SpecSavepoint, &out.SpecSavepoint
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobStatus.
@@ -352,6 +878,122 @@ func (in *JobStatus) DeepCopy() *JobStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringSpec) DeepCopyInto(out *MonitoringSpec) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Monitor != nil {
+		in, out := &in.Monitor, &out.Monitor
+		*out = new(string)
+		**out = **in
+	}
+	if in.MonitorLabels != nil {
+		in, out := &in.MonitorLabels, &out.MonitorLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ScrapeInterval != nil {
+		in, out := &in.ScrapeInterval, &out.ScrapeInterval
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringSpec.
+func (in *MonitoringSpec) DeepCopy() *MonitoringSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SQLScriptSpec) DeepCopyInto(out *SQLScriptSpec) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Inline != nil {
+		in, out := &in.Inline, &out.Inline
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SQLScriptSpec.
+func (in *SQLScriptSpec) DeepCopy() *SQLScriptSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SQLScriptSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskManagerPoolSpec) DeepCopyInto(out *TaskManagerPoolSpec) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TaskSlots != nil {
+		in, out := &in.TaskSlots, &out.TaskSlots
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskManagerPoolSpec.
+func (in *TaskManagerPoolSpec) DeepCopy() *TaskManagerPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskManagerPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskManagerPoolStatus) DeepCopyInto(out *TaskManagerPoolStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskManagerPoolStatus.
+func (in *TaskManagerPoolStatus) DeepCopy() *TaskManagerPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskManagerPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TaskManagerPorts) DeepCopyInto(out *TaskManagerPorts) {
 	*out = *in
@@ -415,6 +1057,28 @@ func (in *TaskManagerSpec) DeepCopyInto(out *TaskManagerSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(v1.PodTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeploymentType != nil {
+		in, out := &in.DeploymentType, &out.DeploymentType
+		*out = new(string)
+		**out = **in
+	}
+	if in.VolumeClaimTemplates != nil {
+		in, out := &in.VolumeClaimTemplates, &out.VolumeClaimTemplates
+		*out = make([]v1.PersistentVolumeClaim, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PodManagementPolicy != nil {
+		in, out := &in.PodManagementPolicy, &out.PodManagementPolicy
+		*out = new(appsv1.PodManagementPolicyType)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskManagerSpec.
@@ -426,3 +1090,18 @@ func (in *TaskManagerSpec) DeepCopy() *TaskManagerSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TaskManagerStatus) DeepCopyInto(out *TaskManagerStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskManagerStatus.
+func (in *TaskManagerStatus) DeepCopy() *TaskManagerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TaskManagerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Converter which converts the remote files of a job spec to the init
+// container which fetches them into a volume shared with the container which
+// runs the job, so the job can be run by stock Flink images.
+
+// The directory the remote files of the job are fetched into for the
+// submitter.
+const jobArtifactsDir = "/opt/flink/job"
+
+// The directory Flink loads the job from in Application mode, the remote
+// files of the job are fetched into it for JobManager.
+const userLibDir = "/opt/flink/usrlib"
+
+// The image of the init container if it is not specified.
+const defaultArtifactFetcherImage = "google/cloud-sdk:slim"
+
+// The name of the volume shared by the init container and the job container.
+const artifactsVolumeName = "job-artifacts"
+
+// The directory the PersistentVolumeClaims of `pvc://` files are mounted into.
+const artifactClaimsDir = "/mnt/artifacts"
+
+// Shell script of the init container. Each file is fetched by a call of
+// `fetch <source> <destination> <checksum>` appended to the script, where the
+// source of `pvc://` files is the path in the mounted claim. The credentials
+// are optional env variables from the Secret.
+const artifactFetcherScript = `set -eu
+if [ -n "${GOOGLE_SERVICE_ACCOUNT_KEY:-}" ]; then
+  printf '%s' "$GOOGLE_SERVICE_ACCOUNT_KEY" > /tmp/key.json
+  gcloud auth activate-service-account --key-file=/tmp/key.json
+fi
+fetch() {
+  case "$1" in
+  http://*|https://*)
+    if [ -n "${HTTP_AUTHORIZATION:-}" ]; then
+      curl -fsSL -H "Authorization: $HTTP_AUTHORIZATION" -o "$2" "$1"
+    else
+      curl -fsSL -o "$2" "$1"
+    fi
+    ;;
+  gs://*|s3://*)
+    gsutil cp "$1" "$2"
+    ;;
+  *)
+    cp "$1" "$2"
+    ;;
+  esac
+  if [ -n "$3" ]; then
+    echo "$3  $2" | sha256sum -c -
+  fi
+}
+`
+
+// Checks whether the file is fetched by the operator.
+func isRemoteArtifact(uri string) bool {
+	return strings.Contains(uri, "://")
+}
+
+// Gets the remote files of the job, including its JAR file.
+func getRemoteArtifacts(
+	jobSpec *flinkoperatorv1alpha1.JobSpec) []flinkoperatorv1alpha1.ArtifactSpec {
+	var artifacts = []flinkoperatorv1alpha1.ArtifactSpec{}
+	if isRemoteArtifact(jobSpec.JarFile) {
+		artifacts = append(artifacts, flinkoperatorv1alpha1.ArtifactSpec{
+			URI:    jobSpec.JarFile,
+			SHA256: jobSpec.JarFileSHA256,
+		})
+	}
+	return append(artifacts, jobSpec.Artifacts...)
+}
+
+// Gets the path of the remote file once it is fetched into the directory. The
+// query of the URI is not part of the file name.
+func getArtifactPath(uri string, dir string) string {
+	var filePath = uri
+	if parsed, err := url.Parse(uri); err == nil {
+		filePath = parsed.Path
+	}
+	return dir + "/" + path.Base(filePath)
+}
+
+// Gets the init container which fetches the remote files of the job into the
+// directory, the volumes it requires, and the mount of the fetched files for
+// the job container. Returns nil if the job has no remote file.
+func getArtifactFetcher(
+	jobSpec *flinkoperatorv1alpha1.JobSpec,
+	dir string) (*corev1.Container, []corev1.Volume, *corev1.VolumeMount) {
+	var artifacts = getRemoteArtifacts(jobSpec)
+	if len(artifacts) == 0 {
+		return nil, nil, nil
+	}
+
+	var artifactsMount = corev1.VolumeMount{
+		Name:      artifactsVolumeName,
+		MountPath: dir,
+	}
+	var volumes = []corev1.Volume{
+		{
+			Name: artifactsVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+	}
+	var mounts = []corev1.VolumeMount{artifactsMount}
+	var claimVolumes = map[string]string{}
+	var script strings.Builder
+	script.WriteString(artifactFetcherScript)
+	for _, artifact := range artifacts {
+		var source = artifact.URI
+		// The files in PersistentVolumeClaims are copied from the claims
+		// mounted read-only, each claim is mounted once.
+		if parsed, err := url.Parse(artifact.URI); err == nil &&
+			parsed.Scheme == "pvc" {
+			var claimName = parsed.Host
+			var claimDir = artifactClaimsDir + "/" + claimName
+			if _, ok := claimVolumes[claimName]; !ok {
+				var volumeName = fmt.Sprintf(
+					"%v-pvc-%v", artifactsVolumeName, len(claimVolumes))
+				claimVolumes[claimName] = volumeName
+				volumes = append(volumes, corev1.Volume{
+					Name: volumeName,
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: claimName,
+							ReadOnly:  true,
+						},
+					},
+				})
+				mounts = append(mounts, corev1.VolumeMount{
+					Name:      volumeName,
+					MountPath: claimDir,
+					ReadOnly:  true,
+				})
+			}
+			source = claimDir + parsed.Path
+		}
+		var checksum = ""
+		if artifact.SHA256 != nil {
+			checksum = strings.ToLower(*artifact.SHA256)
+		}
+		script.WriteString(fmt.Sprintf(
+			"fetch %v %v %v\n",
+			shellQuote(source),
+			shellQuote(getArtifactPath(artifact.URI, dir)),
+			shellQuote(checksum)))
+	}
+
+	var image = defaultArtifactFetcherImage
+	var envFrom []corev1.EnvFromSource
+	var fetcherSpec = jobSpec.ArtifactFetcher
+	if fetcherSpec != nil && fetcherSpec.Image != nil {
+		image = *fetcherSpec.Image
+	}
+	if fetcherSpec != nil && fetcherSpec.CredentialsSecret != nil {
+		envFrom = []corev1.EnvFromSource{
+			{
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: *fetcherSpec.CredentialsSecret,
+					},
+				},
+			},
+		}
+	}
+	var initContainer = &corev1.Container{
+		Name:         "artifact-fetcher",
+		Image:        image,
+		Command:      []string{"/bin/sh", "-c", script.String()},
+		EnvFrom:      envFrom,
+		VolumeMounts: mounts,
+	}
+	return initContainer, volumes, &artifactsMount
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetArtifactFetcher(t *testing.T) {
+	var checksum = strings.Repeat("a", 64)
+	var secretName = "artifact-credentials"
+	var jobSpec = &flinkoperatorv1alpha1.JobSpec{
+		JarFile:       "https://example.com/jobs/job.jar?token=abc",
+		JarFileSHA256: &checksum,
+		Artifacts: []flinkoperatorv1alpha1.ArtifactSpec{
+			{URI: "s3://my-bucket/libs/deps.jar"},
+			{URI: "pvc://my-claim/libs/udfs.jar"},
+			{URI: "pvc://my-claim/libs/connectors.jar"},
+		},
+		ArtifactFetcher: &flinkoperatorv1alpha1.ArtifactFetcherSpec{
+			CredentialsSecret: &secretName,
+		},
+	}
+
+	var fetcher, volumes, mount = getArtifactFetcher(jobSpec, jobArtifactsDir)
+
+	assert.Equal(t, fetcher.Image, defaultArtifactFetcherImage)
+	assert.Equal(t, fetcher.EnvFrom[0].SecretRef.Name, secretName)
+	var script = fetcher.Command[2]
+	assert.Assert(t, strings.HasSuffix(
+		script,
+		"fetch 'https://example.com/jobs/job.jar?token=abc' "+
+			"'/opt/flink/job/job.jar' '"+checksum+"'\n"+
+			"fetch 's3://my-bucket/libs/deps.jar' '/opt/flink/job/deps.jar' ''\n"+
+			"fetch '/mnt/artifacts/my-claim/libs/udfs.jar' "+
+			"'/opt/flink/job/udfs.jar' ''\n"+
+			"fetch '/mnt/artifacts/my-claim/libs/connectors.jar' "+
+			"'/opt/flink/job/connectors.jar' ''\n"))
+
+	// The claim is mounted once.
+	assert.Equal(t, len(volumes), 2)
+	assert.Equal(t, volumes[1].PersistentVolumeClaim.ClaimName, "my-claim")
+	assert.DeepEqual(
+		t,
+		fetcher.VolumeMounts,
+		[]corev1.VolumeMount{
+			{Name: "job-artifacts", MountPath: "/opt/flink/job"},
+			{
+				Name:      "job-artifacts-pvc-0",
+				MountPath: "/mnt/artifacts/my-claim",
+				ReadOnly:  true,
+			},
+		})
+	assert.DeepEqual(
+		t,
+		*mount,
+		corev1.VolumeMount{Name: "job-artifacts", MountPath: "/opt/flink/job"})
+
+	// The submitter runs the fetched JAR file.
+	var args, _ = getFlinkRunArgs(
+		jobSpec, "mycluster-jobmanager:8081", nil, false /* detached */)
+	assert.Equal(t, args[len(args)-1], "/opt/flink/job/job.jar")
+}
+
+func TestGetArtifactFetcherNoRemoteFiles(t *testing.T) {
+	var jobSpec = &flinkoperatorv1alpha1.JobSpec{JarFile: "/opt/flink/job/job.jar"}
+	var fetcher, volumes, mount = getArtifactFetcher(jobSpec, jobArtifactsDir)
+	assert.Assert(t, fetcher == nil)
+	assert.Assert(t, volumes == nil)
+	assert.Assert(t, mount == nil)
+}
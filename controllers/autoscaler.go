@@ -0,0 +1,158 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+)
+
+// Autoscaler which decides the parallelism of a job from the metrics of its
+// vertices, which are observed through the Flink REST API. The job is scaled
+// so that its busiest vertex is busy for the target share of time. The job is
+// rescaled by the upgrade flow of the reconciler, i.e., it is savepointed and
+// resubmitted with the new parallelism.
+
+// The metrics of a vertex the autoscaler reads, see
+// https://ci.apache.org/projects/flink/flink-docs-stable/monitoring/metrics.html
+const (
+	busyTimeMetric          = "busyTimeMsPerSecond"
+	backPressuredTimeMetric = "backPressuredTimeMsPerSecond"
+	recordsOutRateMetric    = "numRecordsOutPerSecond"
+	// The metric of the sources with the number of records not yet fetched,
+	// prefixed with the name of the source operator.
+	pendingRecordsMetricSuffix = ".pendingRecords"
+)
+
+// The relative change of the parallelism below which the job is not rescaled,
+// so the job is not restarted for small fluctuations of the load.
+const autoscalerTolerance = 0.1
+
+// The share of time above which a vertex is considered backpressured.
+const maxBackPressuredRatio = 0.5
+
+// The lag of the sources in seconds above which the job is not scaled down,
+// so the job can catch up first.
+const maxSourceLagSeconds = 60
+
+// _VertexMetrics holds the metrics of a vertex aggregated across its subtasks.
+type _VertexMetrics struct {
+	name string
+	// The average share of time the subtasks are busy.
+	busyRatio float64
+	// The max share of time the subtasks are backpressured.
+	backPressuredRatio float64
+	// The total number of records emitted per second.
+	recordsOutPerSecond float64
+	// The total number of records not yet fetched by the source.
+	pendingRecords float64
+	isSource       bool
+}
+
+// Gets the names of the metrics the autoscaler reads out of the metrics
+// available for a vertex.
+func getAutoscalerMetricNames(available []flinkclient.AggregatedMetric) []string {
+	var names []string
+	for _, metric := range available {
+		switch {
+		case metric.ID == busyTimeMetric,
+			metric.ID == backPressuredTimeMetric,
+			metric.ID == recordsOutRateMetric,
+			strings.HasSuffix(metric.ID, pendingRecordsMetricSuffix):
+			names = append(names, metric.ID)
+		}
+	}
+	return names
+}
+
+// Converts the aggregated metrics of a vertex.
+func getVertexMetrics(
+	name string, metrics []flinkclient.AggregatedMetric) _VertexMetrics {
+	var vertexMetrics = _VertexMetrics{name: name}
+	for _, metric := range metrics {
+		switch {
+		case metric.ID == busyTimeMetric:
+			vertexMetrics.busyRatio = metric.Avg / 1000
+		case metric.ID == backPressuredTimeMetric:
+			vertexMetrics.backPressuredRatio = metric.Max / 1000
+		case metric.ID == recordsOutRateMetric:
+			vertexMetrics.recordsOutPerSecond = metric.Sum
+		case strings.HasSuffix(metric.ID, pendingRecordsMetricSuffix):
+			vertexMetrics.pendingRecords += metric.Sum
+			vertexMetrics.isSource = true
+		}
+	}
+	return vertexMetrics
+}
+
+// Gets the parallelism of the job the autoscaler decides for the metrics of
+// its vertices, along with the reason if it differs from the current
+// parallelism. The job is not scaled down while it is backpressured or its
+// sources are lagging behind.
+func getAutoscaledParallelism(
+	autoscalerSpec *flinkoperatorv1alpha1.JobAutoscalerSpec,
+	parallelism int32,
+	vertices []_VertexMetrics) (int32, string) {
+	if len(vertices) == 0 {
+		return parallelism, ""
+	}
+
+	var busiest = vertices[0]
+	var backPressured, lagging bool
+	for _, vertex := range vertices {
+		if vertex.busyRatio > busiest.busyRatio {
+			busiest = vertex
+		}
+		if vertex.backPressuredRatio > maxBackPressuredRatio {
+			backPressured = true
+		}
+		if vertex.isSource && vertex.pendingRecords > 0 &&
+			(vertex.recordsOutPerSecond == 0 ||
+				vertex.pendingRecords/vertex.recordsOutPerSecond >
+					maxSourceLagSeconds) {
+			lagging = true
+		}
+	}
+
+	var targetRatio = float64(*autoscalerSpec.TargetUtilization) / 100
+	var desired = parallelism
+	var scaleFactor = busiest.busyRatio / targetRatio
+	if math.Abs(scaleFactor-1) > autoscalerTolerance {
+		desired = int32(math.Ceil(float64(parallelism) * scaleFactor))
+	}
+	if desired < parallelism && (backPressured || lagging) {
+		desired = parallelism
+	}
+	if desired < *autoscalerSpec.MinParallelism {
+		desired = *autoscalerSpec.MinParallelism
+	}
+	if desired > autoscalerSpec.MaxParallelism {
+		desired = autoscalerSpec.MaxParallelism
+	}
+	if desired == parallelism {
+		return parallelism, ""
+	}
+	return desired, fmt.Sprintf(
+		"vertex %q is busy %.0f%% of the time, target: %d%%",
+		busiest.name,
+		busiest.busyRatio*100,
+		*autoscalerSpec.TargetUtilization)
+}
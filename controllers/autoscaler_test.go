@@ -0,0 +1,155 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+	"gotest.tools/assert"
+)
+
+func TestGetVertexMetrics(t *testing.T) {
+	var available = []flinkclient.AggregatedMetric{
+		{ID: "busyTimeMsPerSecond"},
+		{ID: "idleTimeMsPerSecond"},
+		{ID: "backPressuredTimeMsPerSecond"},
+		{ID: "numRecordsOutPerSecond"},
+		{ID: "Source__Kafka.pendingRecords"},
+	}
+	assert.DeepEqual(
+		t,
+		getAutoscalerMetricNames(available),
+		[]string{
+			"busyTimeMsPerSecond",
+			"backPressuredTimeMsPerSecond",
+			"numRecordsOutPerSecond",
+			"Source__Kafka.pendingRecords",
+		})
+
+	var metrics = getVertexMetrics(
+		"Source: Kafka",
+		[]flinkclient.AggregatedMetric{
+			{ID: "busyTimeMsPerSecond", Avg: 400, Max: 900},
+			{ID: "backPressuredTimeMsPerSecond", Avg: 100, Max: 600},
+			{ID: "numRecordsOutPerSecond", Avg: 50, Sum: 200},
+			{ID: "Source__Kafka.pendingRecords", Sum: 1000},
+		})
+	assert.Equal(
+		t,
+		metrics,
+		_VertexMetrics{
+			name:                "Source: Kafka",
+			busyRatio:           0.4,
+			backPressuredRatio:  0.6,
+			recordsOutPerSecond: 200,
+			pendingRecords:      1000,
+			isSource:            true,
+		})
+}
+
+func TestGetAutoscaledParallelism(t *testing.T) {
+	var minParallelism int32 = 2
+	var targetUtilization int32 = 50
+	var autoscalerSpec = &flinkoperatorv1alpha1.JobAutoscalerSpec{
+		MinParallelism:    &minParallelism,
+		MaxParallelism:    10,
+		TargetUtilization: &targetUtilization,
+	}
+	var source = _VertexMetrics{
+		name:                "Source",
+		busyRatio:           0.1,
+		recordsOutPerSecond: 1000,
+		isSource:            true,
+	}
+	var testCases = []struct {
+		name        string
+		parallelism int32
+		vertices    []_VertexMetrics
+		expected    int32
+	}{
+		{
+			name:        "no metrics",
+			parallelism: 4,
+			vertices:    nil,
+			expected:    4,
+		},
+		{
+			name:        "scale up to the busiest vertex",
+			parallelism: 4,
+			vertices:    []_VertexMetrics{source, {name: "Map", busyRatio: 0.9}},
+			expected:    8,
+		},
+		{
+			name:        "scale up to max",
+			parallelism: 6,
+			vertices:    []_VertexMetrics{source, {name: "Map", busyRatio: 1}},
+			expected:    10,
+		},
+		{
+			name:        "within tolerance",
+			parallelism: 4,
+			vertices:    []_VertexMetrics{source, {name: "Map", busyRatio: 0.53}},
+			expected:    4,
+		},
+		{
+			name:        "scale down",
+			parallelism: 8,
+			vertices:    []_VertexMetrics{source, {name: "Map", busyRatio: 0.2}},
+			expected:    4,
+		},
+		{
+			name:        "scale down to min",
+			parallelism: 4,
+			vertices:    []_VertexMetrics{source, {name: "Map", busyRatio: 0.05}},
+			expected:    2,
+		},
+		{
+			name:        "no scale down while backpressured",
+			parallelism: 8,
+			vertices: []_VertexMetrics{
+				source,
+				{name: "Map", busyRatio: 0.2, backPressuredRatio: 0.8},
+			},
+			expected: 8,
+		},
+		{
+			name:        "no scale down while the sources are lagging",
+			parallelism: 8,
+			vertices: []_VertexMetrics{
+				{
+					name:                "Source",
+					busyRatio:           0.2,
+					recordsOutPerSecond: 1000,
+					pendingRecords:      120000,
+					isSource:            true,
+				},
+			},
+			expected: 8,
+		},
+	}
+
+	for _, testCase := range testCases {
+		var parallelism, reason = getAutoscaledParallelism(
+			autoscalerSpec, testCase.parallelism, testCase.vertices)
+		assert.Equal(t, parallelism, testCase.expected, testCase.name)
+		assert.Equal(
+			t, len(reason) > 0, testCase.expected != testCase.parallelism,
+			testCase.name)
+	}
+}
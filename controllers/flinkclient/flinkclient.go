@@ -0,0 +1,346 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package flinkclient provides a client of the Flink REST API served by
+// JobManager.
+package flinkclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// DefaultTimeout is the default timeout of a request to the Flink REST API.
+const DefaultTimeout = 15 * time.Second
+
+// FlinkClient is a client of the Flink REST API. The base URL of the API
+// (e.g., http://flinkjobcluster-sample-jobmanager:8081) is provided with each
+// call, so one client can be shared across clusters.
+type FlinkClient struct {
+	Log        logr.Logger
+	HTTPClient *http.Client
+}
+
+// HTTPError is returned when the Flink REST API responds with a non-2xx
+// status code.
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (err *HTTPError) Error() string {
+	return fmt.Sprintf(
+		"Flink API %s %s failed with status %s: %s",
+		err.Method, err.URL, err.Status, err.Body)
+}
+
+// IsNotFound checks whether the error is a 404 response of the API, e.g., the
+// job does not exist.
+func IsNotFound(err error) bool {
+	var httpErr, ok = err.(*HTTPError)
+	return ok && httpErr.StatusCode == http.StatusNotFound
+}
+
+// DecodeError is returned when the response of the Flink REST API cannot be
+// decoded.
+type DecodeError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (err *DecodeError) Error() string {
+	return fmt.Sprintf(
+		"failed to decode the response of Flink API %s %s: %v",
+		err.Method, err.URL, err.Err)
+}
+
+// NewFlinkClient creates a Flink REST API client with the default timeout.
+func NewFlinkClient(log logr.Logger) *FlinkClient {
+	return &FlinkClient{
+		Log:        log,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// GetJobsOverview gets the overview of all the jobs of the cluster.
+func (c *FlinkClient) GetJobsOverview(
+	ctx context.Context, apiBaseURL string) (*JobsOverview, error) {
+	var url = apiBaseURL + "/jobs/overview"
+	var overview = &JobsOverview{}
+	var err = c.doJSONRequest(ctx, "GET", url, nil, overview)
+	if err != nil {
+		return nil, err
+	}
+	return overview, nil
+}
+
+// GetJobDetails gets the details of the job.
+func (c *FlinkClient) GetJobDetails(
+	ctx context.Context, apiBaseURL string, jobID string) (*JobDetails, error) {
+	var url = fmt.Sprintf("%s/jobs/%s", apiBaseURL, jobID)
+	var details = &JobDetails{}
+	var err = c.doJSONRequest(ctx, "GET", url, nil, details)
+	if err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+// GetCheckpoints gets the checkpoint statistics of the job.
+func (c *FlinkClient) GetCheckpoints(
+	ctx context.Context, apiBaseURL string, jobID string) (*Checkpoints, error) {
+	var url = fmt.Sprintf("%s/jobs/%s/checkpoints", apiBaseURL, jobID)
+	var checkpoints = &Checkpoints{}
+	var err = c.doJSONRequest(ctx, "GET", url, nil, checkpoints)
+	if err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
+
+// GetCheckpointConfig gets the checkpoint config of the job.
+func (c *FlinkClient) GetCheckpointConfig(
+	ctx context.Context,
+	apiBaseURL string,
+	jobID string) (*CheckpointConfig, error) {
+	var url = fmt.Sprintf("%s/jobs/%s/checkpoints/config", apiBaseURL, jobID)
+	var config = &CheckpointConfig{}
+	var err = c.doJSONRequest(ctx, "GET", url, nil, config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// TriggerSavepoint triggers a savepoint for the job, optionally cancels the
+// job once the savepoint completes. Returns the trigger ID for querying the
+// status of the savepoint. If the target directory is nil, the default
+// savepoints dir of the cluster is used.
+func (c *FlinkClient) TriggerSavepoint(
+	ctx context.Context,
+	apiBaseURL string,
+	jobID string,
+	targetDirectory *string,
+	cancelJob bool) (string, error) {
+	var url = fmt.Sprintf("%s/jobs/%s/savepoints", apiBaseURL, jobID)
+	var request = SavepointTriggerRequest{
+		TargetDirectory: targetDirectory,
+		CancelJob:       cancelJob,
+	}
+	var response = &SavepointTriggerResponse{}
+	var err = c.doJSONRequest(ctx, "POST", url, request, response)
+	if err != nil {
+		return "", err
+	}
+	return response.RequestID, nil
+}
+
+// GetSavepointStatus gets the status of the savepoint identified by the
+// trigger ID.
+func (c *FlinkClient) GetSavepointStatus(
+	ctx context.Context,
+	apiBaseURL string,
+	jobID string,
+	triggerID string) (*SavepointStatus, error) {
+	var url = fmt.Sprintf(
+		"%s/jobs/%s/savepoints/%s", apiBaseURL, jobID, triggerID)
+	var status = &SavepointStatus{}
+	var err = c.doJSONRequest(ctx, "GET", url, nil, status)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// CancelJob cancels the job.
+func (c *FlinkClient) CancelJob(
+	ctx context.Context, apiBaseURL string, jobID string) error {
+	var url = fmt.Sprintf("%s/jobs/%s?mode=cancel", apiBaseURL, jobID)
+	return c.doJSONRequest(ctx, "PATCH", url, nil, nil)
+}
+
+// UploadJar uploads a JAR file to JobManager, returns the ID of the JAR for
+// running it later.
+func (c *FlinkClient) UploadJar(
+	ctx context.Context,
+	apiBaseURL string,
+	jarName string,
+	jar io.Reader) (string, error) {
+	var url = apiBaseURL + "/jars/upload"
+	var body = &bytes.Buffer{}
+	var writer = multipart.NewWriter(body)
+	var part, err = writer.CreateFormFile("jarfile", jarName)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(part, jar)
+	if err != nil {
+		return "", err
+	}
+	err = writer.Close()
+	if err != nil {
+		return "", err
+	}
+
+	var response = &JarUploadResponse{}
+	err = c.doRequest(
+		ctx, "POST", url, writer.FormDataContentType(), body, response)
+	if err != nil {
+		return "", err
+	}
+	// The filename is the path of the JAR on JobManager, of which the base
+	// name is the JAR ID.
+	var jarID = response.Filename
+	for i := len(jarID) - 1; i >= 0; i-- {
+		if jarID[i] == '/' {
+			jarID = jarID[i+1:]
+			break
+		}
+	}
+	return jarID, nil
+}
+
+// RunJar runs an uploaded JAR, returns the ID of the submitted job.
+func (c *FlinkClient) RunJar(
+	ctx context.Context,
+	apiBaseURL string,
+	jarID string,
+	request JarRunRequest) (string, error) {
+	var url = fmt.Sprintf("%s/jars/%s/run", apiBaseURL, jarID)
+	var response = &JarRunResponse{}
+	var err = c.doJSONRequest(ctx, "POST", url, request, response)
+	if err != nil {
+		return "", err
+	}
+	return response.JobID, nil
+}
+
+// GetTaskManagers gets the TaskManagers registered with JobManager.
+func (c *FlinkClient) GetTaskManagers(
+	ctx context.Context, apiBaseURL string) (*TaskManagers, error) {
+	var url = apiBaseURL + "/taskmanagers"
+	var taskManagers = &TaskManagers{}
+	var err = c.doJSONRequest(ctx, "GET", url, nil, taskManagers)
+	if err != nil {
+		return nil, err
+	}
+	return taskManagers, nil
+}
+
+// GetVertexMetrics gets the metrics of a vertex of the job aggregated across
+// its subtasks, lists the available metrics if no names are given.
+func (c *FlinkClient) GetVertexMetrics(
+	ctx context.Context,
+	apiBaseURL string,
+	jobID string,
+	vertexID string,
+	metricNames []string) ([]AggregatedMetric, error) {
+	var url = fmt.Sprintf(
+		"%s/jobs/%s/vertices/%s/subtasks/metrics", apiBaseURL, jobID, vertexID)
+	if len(metricNames) > 0 {
+		url += fmt.Sprintf(
+			"?get=%s&agg=min,max,avg,sum",
+			neturl.QueryEscape(strings.Join(metricNames, ",")))
+	}
+	var metrics []AggregatedMetric
+	var err = c.doJSONRequest(ctx, "GET", url, nil, &metrics)
+	if err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// Sends a request with the optional JSON body, decodes the JSON response into
+// the optional output.
+func (c *FlinkClient) doJSONRequest(
+	ctx context.Context,
+	method string,
+	url string,
+	in interface{},
+	out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		var buffer = &bytes.Buffer{}
+		var err = json.NewEncoder(buffer).Encode(in)
+		if err != nil {
+			return err
+		}
+		body = buffer
+	}
+	return c.doRequest(ctx, method, url, "application/json", body, out)
+}
+
+func (c *FlinkClient) doRequest(
+	ctx context.Context,
+	method string,
+	url string,
+	contentType string,
+	body io.Reader,
+	out interface{}) error {
+	var req, err = http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "flink-operator")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	c.Log.Info("Calling Flink API", "method", method, "url", url)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &HTTPError{
+			Method:     method,
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(respBody),
+		}
+	}
+	if out != nil {
+		err = json.Unmarshal(respBody, out)
+		if err != nil {
+			return &DecodeError{Method: method, URL: url, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flinkclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient/flinkclienttest"
+	"gotest.tools/assert"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const fakeBaseURL = "http://flinkjobcluster-sample-jobmanager.default.svc.cluster.local:8081"
+
+func TestGetJobsOverview(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var client = server.NewClient(log.NullLogger{})
+
+	var oldJobID = server.AddJob("old", flinkclient.JobState.Canceled)
+	var newJobID = server.AddJob("new", flinkclient.JobState.Running)
+
+	var overview, err = client.GetJobsOverview(context.Background(), fakeBaseURL)
+	assert.NilError(t, err)
+	assert.Equal(t, len(overview.Jobs), 2)
+	assert.Equal(t, overview.Jobs[0].ID, newJobID)
+	assert.Equal(t, overview.Jobs[0].State, flinkclient.JobState.Running)
+	assert.Equal(t, overview.Jobs[1].ID, oldJobID)
+	assert.Equal(t, overview.Jobs[1].State, flinkclient.JobState.Canceled)
+}
+
+func TestGetJobDetailsNotFound(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var client = server.NewClient(log.NullLogger{})
+
+	var _, err = client.GetJobDetails(
+		context.Background(), fakeBaseURL, "ffffffffffffffffffffffffffffffff")
+	assert.Assert(t, err != nil)
+	assert.Assert(t, flinkclient.IsNotFound(err))
+	assert.Assert(t, strings.Contains(err.Error(), "Job could not be found."))
+}
+
+func TestSavepointAndCancel(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var client = server.NewClient(log.NullLogger{})
+	var ctx = context.Background()
+	var savepointsDir = "gs://my-bucket/savepoints/"
+
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var triggerID, err = client.TriggerSavepoint(
+		ctx, fakeBaseURL, jobID, &savepointsDir, false /* cancelJob */)
+	assert.NilError(t, err)
+
+	status, err := client.GetSavepointStatus(ctx, fakeBaseURL, jobID, triggerID)
+	assert.NilError(t, err)
+	assert.Assert(t, status.IsCompleted())
+	assert.Assert(t, status.IsSuccessful())
+	assert.Assert(t, strings.HasPrefix(
+		status.Operation.Location, "gs://my-bucket/savepoints/savepoint-"+jobID))
+
+	checkpoints, err := client.GetCheckpoints(ctx, fakeBaseURL, jobID)
+	assert.NilError(t, err)
+	assert.Equal(
+		t, checkpoints.Latest.Savepoint.ExternalPath, status.Operation.Location)
+
+	err = client.CancelJob(ctx, fakeBaseURL, jobID)
+	assert.NilError(t, err)
+	assert.Equal(t, server.GetJob(jobID).State, flinkclient.JobState.Canceled)
+}
+
+func TestSavepointFailure(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var client = server.NewClient(log.NullLogger{})
+	var ctx = context.Background()
+
+	server.SavepointFailure = true
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var triggerID, err = client.TriggerSavepoint(
+		ctx, fakeBaseURL, jobID, nil /* targetDirectory */, false /* cancelJob */)
+	assert.NilError(t, err)
+
+	status, err := client.GetSavepointStatus(ctx, fakeBaseURL, jobID, triggerID)
+	assert.NilError(t, err)
+	assert.Assert(t, status.IsCompleted())
+	assert.Assert(t, !status.IsSuccessful())
+	assert.Equal(
+		t, status.FailureReason(), "java.util.concurrent.CompletionException")
+}
+
+func TestUploadAndRunJar(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var client = server.NewClient(log.NullLogger{})
+	var ctx = context.Background()
+	var savepointPath = "gs://my-bucket/savepoints/savepoint-1"
+	var parallelism = int32(2)
+
+	var jarID, err = client.UploadJar(
+		ctx, fakeBaseURL, "wordcount.jar", strings.NewReader("jar content"))
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasSuffix(jarID, "wordcount.jar"))
+
+	jobID, err := client.RunJar(ctx, fakeBaseURL, jarID, flinkclient.JarRunRequest{
+		ProgramArgs:   []string{"--input", "./README.txt"},
+		Parallelism:   &parallelism,
+		SavepointPath: &savepointPath,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, server.GetJob(jobID).State, flinkclient.JobState.Running)
+
+	checkpoints, err := client.GetCheckpoints(ctx, fakeBaseURL, jobID)
+	assert.NilError(t, err)
+	assert.Equal(t, checkpoints.Latest.Restored.ExternalPath, savepointPath)
+}
+
+func TestGetTaskManagers(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var client = server.NewClient(log.NullLogger{})
+
+	server.TaskManagers = []flinkclient.TaskManagerInfo{
+		{ID: "tm-1", SlotsNumber: 2, FreeSlots: 1},
+		{ID: "tm-2", SlotsNumber: 2, FreeSlots: 2},
+	}
+	var taskManagers, err = client.GetTaskManagers(
+		context.Background(), fakeBaseURL)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, taskManagers.TaskManagers, server.TaskManagers)
+}
+
+func TestGetVertexMetrics(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var client = server.NewClient(log.NullLogger{})
+	var ctx = context.Background()
+
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var vertexID = "cbc357ccb763df2852fee8c4fc7d55f2"
+	server.VertexMetrics = map[string][]flinkclient.AggregatedMetric{
+		vertexID: {
+			{ID: "busyTimeMsPerSecond", Min: 100, Max: 900, Avg: 500, Sum: 1000},
+			{ID: "backPressuredTimeMsPerSecond", Max: 300, Avg: 150, Sum: 300},
+		},
+	}
+
+	var available, err = client.GetVertexMetrics(
+		ctx, fakeBaseURL, jobID, vertexID, nil /* metricNames */)
+	assert.NilError(t, err)
+	assert.DeepEqual(
+		t,
+		available,
+		[]flinkclient.AggregatedMetric{
+			{ID: "busyTimeMsPerSecond"},
+			{ID: "backPressuredTimeMsPerSecond"},
+		})
+
+	metrics, err := client.GetVertexMetrics(
+		ctx, fakeBaseURL, jobID, vertexID, []string{"busyTimeMsPerSecond"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, metrics, server.VertexMetrics[vertexID][:1])
+}
+
+func TestDecodeError(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html>not json</html>"))
+		}))
+	defer server.Close()
+	var client = flinkclient.NewFlinkClient(log.NullLogger{})
+
+	var _, err = client.GetJobsOverview(context.Background(), server.URL)
+	var _, ok = err.(*flinkclient.DecodeError)
+	assert.Assert(t, ok)
+}
+
+func TestContextCancelled(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(1 * time.Second)
+		}))
+	defer server.Close()
+	var client = flinkclient.NewFlinkClient(log.NullLogger{})
+
+	var ctx, cancel = context.WithTimeout(
+		context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var _, err = client.GetJobsOverview(ctx, server.URL)
+	assert.Assert(t, err != nil)
+}
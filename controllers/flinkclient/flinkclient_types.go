@@ -0,0 +1,253 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flinkclient
+
+// Request and response types of the Flink REST API, see
+// https://ci.apache.org/projects/flink/flink-docs-stable/monitoring/rest_api.html
+
+// JobState defines states of a Flink job reported by the Flink REST API.
+var JobState = struct {
+	Created     string
+	Running     string
+	Failing     string
+	Failed      string
+	Cancelling  string
+	Canceled    string
+	Finished    string
+	Restarting  string
+	Suspended   string
+	Reconciling string
+}{
+	Created:     "CREATED",
+	Running:     "RUNNING",
+	Failing:     "FAILING",
+	Failed:      "FAILED",
+	Cancelling:  "CANCELLING",
+	Canceled:    "CANCELED",
+	Finished:    "FINISHED",
+	Restarting:  "RESTARTING",
+	Suspended:   "SUSPENDED",
+	Reconciling: "RECONCILING",
+}
+
+// IsJobTerminated checks whether the job state is globally terminal.
+func IsJobTerminated(state string) bool {
+	return state == JobState.Failed ||
+		state == JobState.Canceled ||
+		state == JobState.Finished
+}
+
+// JobOverview defines the overview of a job in the jobs overview.
+type JobOverview struct {
+	ID        string `json:"jid"`
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	StartTime int64  `json:"start-time"`
+	EndTime   int64  `json:"end-time"`
+}
+
+// JobsOverview defines the response of `GET /jobs/overview`.
+type JobsOverview struct {
+	Jobs []JobOverview `json:"jobs"`
+}
+
+// JobVertex defines a vertex of the job graph in the job details.
+type JobVertex struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Parallelism int32  `json:"parallelism"`
+	Status      string `json:"status"`
+}
+
+// JobDetails defines the response of `GET /jobs/:jobid`.
+type JobDetails struct {
+	ID        string      `json:"jid"`
+	Name      string      `json:"name"`
+	State     string      `json:"state"`
+	StartTime int64       `json:"start-time"`
+	EndTime   int64       `json:"end-time"`
+	Vertices  []JobVertex `json:"vertices"`
+}
+
+// CheckpointCounts defines the counts of checkpoints of a job.
+type CheckpointCounts struct {
+	Restored   int64 `json:"restored"`
+	Total      int64 `json:"total"`
+	InProgress int64 `json:"in_progress"`
+	Completed  int64 `json:"completed"`
+	Failed     int64 `json:"failed"`
+}
+
+// CheckpointStatistics defines the statistics of a checkpoint or savepoint.
+type CheckpointStatistics struct {
+	ID               int64  `json:"id"`
+	Status           string `json:"status"`
+	IsSavepoint      bool   `json:"is_savepoint"`
+	TriggerTimestamp int64  `json:"trigger_timestamp"`
+	ExternalPath     string `json:"external_path"`
+}
+
+// RestoredCheckpointStatistics defines the statistics of the checkpoint or
+// savepoint a job was restored from.
+type RestoredCheckpointStatistics struct {
+	ID               int64  `json:"id"`
+	RestoreTimestamp int64  `json:"restore_timestamp"`
+	IsSavepoint      bool   `json:"is_savepoint"`
+	ExternalPath     string `json:"external_path"`
+}
+
+// LatestCheckpoints defines the latest checkpoints of a job.
+type LatestCheckpoints struct {
+	Completed *CheckpointStatistics         `json:"completed"`
+	Savepoint *CheckpointStatistics         `json:"savepoint"`
+	Failed    *CheckpointStatistics         `json:"failed"`
+	Restored  *RestoredCheckpointStatistics `json:"restored"`
+}
+
+// Checkpoints defines the response of `GET /jobs/:jobid/checkpoints`.
+type Checkpoints struct {
+	Counts CheckpointCounts  `json:"counts"`
+	Latest LatestCheckpoints `json:"latest"`
+}
+
+// CheckpointExternalization defines whether the checkpoints of a job are
+// retained externally, and whether they are deleted when the job is cancelled.
+type CheckpointExternalization struct {
+	Enabled              bool `json:"enabled"`
+	DeleteOnCancellation bool `json:"delete_on_cancellation"`
+}
+
+// CheckpointConfig defines the response of
+// `GET /jobs/:jobid/checkpoints/config`.
+type CheckpointConfig struct {
+	Mode            string                    `json:"mode"`
+	Interval        int64                     `json:"interval"`
+	Timeout         int64                     `json:"timeout"`
+	Externalization CheckpointExternalization `json:"externalization"`
+}
+
+// SavepointTriggerRequest defines the request of
+// `POST /jobs/:jobid/savepoints`.
+type SavepointTriggerRequest struct {
+	TargetDirectory *string `json:"target-directory,omitempty"`
+	CancelJob       bool    `json:"cancel-job"`
+}
+
+// SavepointTriggerResponse defines the response of
+// `POST /jobs/:jobid/savepoints`.
+type SavepointTriggerResponse struct {
+	RequestID string `json:"request-id"`
+}
+
+// SavepointStatus defines the response of
+// `GET /jobs/:jobid/savepoints/:triggerid`.
+type SavepointStatus struct {
+	Status struct {
+		// "IN_PROGRESS" or "COMPLETED".
+		ID string `json:"id"`
+	} `json:"status"`
+
+	Operation struct {
+		// Location of the savepoint, only available if it succeeded.
+		Location string `json:"location,omitempty"`
+
+		// Failure cause, only available if it failed.
+		FailureCause *FailureCause `json:"failure-cause,omitempty"`
+	} `json:"operation"`
+}
+
+// FailureCause defines the cause of a failed asynchronous operation.
+type FailureCause struct {
+	Class      string `json:"class"`
+	StackTrace string `json:"stack-trace"`
+}
+
+// IsCompleted checks whether the savepoint operation has completed, either
+// successfully or not.
+func (status *SavepointStatus) IsCompleted() bool {
+	return status.Status.ID == "COMPLETED"
+}
+
+// IsSuccessful checks whether the savepoint has completed successfully.
+func (status *SavepointStatus) IsSuccessful() bool {
+	return status.IsCompleted() && len(status.Operation.Location) > 0
+}
+
+// FailureReason gets the reason of the failed savepoint.
+func (status *SavepointStatus) FailureReason() string {
+	if status.Operation.FailureCause == nil {
+		return "unknown"
+	}
+	return status.Operation.FailureCause.Class
+}
+
+// JarUploadResponse defines the response of `POST /jars/upload`.
+type JarUploadResponse struct {
+	// The path of the uploaded JAR on JobManager, the last path element is
+	// the JAR ID.
+	Filename string `json:"filename"`
+	Status   string `json:"status"`
+}
+
+// JarRunRequest defines the request of `POST /jars/:jarid/run`.
+type JarRunRequest struct {
+	EntryClass            *string  `json:"entryClass,omitempty"`
+	ProgramArgs           []string `json:"programArgsList,omitempty"`
+	Parallelism           *int32   `json:"parallelism,omitempty"`
+	SavepointPath         *string  `json:"savepointPath,omitempty"`
+	AllowNonRestoredState *bool    `json:"allowNonRestoredState,omitempty"`
+}
+
+// JarRunResponse defines the response of `POST /jars/:jarid/run`.
+type JarRunResponse struct {
+	JobID string `json:"jobid"`
+}
+
+// TaskManagerHardware defines the hardware of a TaskManager.
+type TaskManagerHardware struct {
+	CPUCores       int32 `json:"cpuCores"`
+	PhysicalMemory int64 `json:"physicalMemory"`
+	FreeMemory     int64 `json:"freeMemory"`
+	ManagedMemory  int64 `json:"managedMemory"`
+}
+
+// TaskManagerInfo defines a TaskManager registered with JobManager.
+type TaskManagerInfo struct {
+	ID                     string              `json:"id"`
+	Path                   string              `json:"path"`
+	DataPort               int32               `json:"dataPort"`
+	TimeSinceLastHeartbeat int64               `json:"timeSinceLastHeartbeat"`
+	SlotsNumber            int32               `json:"slotsNumber"`
+	FreeSlots              int32               `json:"freeSlots"`
+	Hardware               TaskManagerHardware `json:"hardware"`
+}
+
+// TaskManagers defines the response of `GET /taskmanagers`.
+type TaskManagers struct {
+	TaskManagers []TaskManagerInfo `json:"taskmanagers"`
+}
+
+// AggregatedMetric defines a metric aggregated across the subtasks of a
+// vertex in the response of `GET /jobs/:jobid/vertices/:vertexid/subtasks/metrics`.
+// Only the ID is set when the available metrics are listed.
+type AggregatedMetric struct {
+	ID  string  `json:"id"`
+	Min float64 `json:"min,omitempty"`
+	Max float64 `json:"max,omitempty"`
+	Avg float64 `json:"avg,omitempty"`
+	Sum float64 `json:"sum,omitempty"`
+}
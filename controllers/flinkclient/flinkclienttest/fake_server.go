@@ -0,0 +1,408 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package flinkclienttest provides a fake Flink REST API server for testing
+// the code which uses the Flink client.
+package flinkclienttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+)
+
+// FakeServer is an in-process fake of the Flink REST API for unit tests. It
+// keeps jobs in memory, savepoints complete immediately at
+// `<target-directory>/savepoint-<jobID>-<n>`.
+type FakeServer struct {
+	// Server is the underlying HTTP test server.
+	Server *httptest.Server
+
+	// SavepointFailure makes triggered savepoints fail when set.
+	SavepointFailure bool
+
+	// CancelFailure makes cancelling jobs fail with an internal error when set.
+	CancelFailure bool
+
+	// TaskManagers is returned by `GET /taskmanagers`.
+	TaskManagers []flinkclient.TaskManagerInfo
+
+	// VertexMetrics are the aggregated metrics of the vertices by vertex ID,
+	// returned by `GET /jobs/:jobid/vertices/:vertexid/subtasks/metrics`.
+	VertexMetrics map[string][]flinkclient.AggregatedMetric
+
+	mutex       sync.Mutex
+	jobs        map[string]*flinkclient.JobDetails
+	checkpoints map[string]*flinkclient.Checkpoints
+	configs     map[string]*flinkclient.CheckpointConfig
+	savepoints  map[string]*flinkclient.SavepointStatus
+	jars        map[string]bool
+	nextID      int
+	requests    []string
+}
+
+// NewFakeServer starts a fake Flink REST API server, it should be closed with
+// Close after use.
+func NewFakeServer() *FakeServer {
+	var server = &FakeServer{
+		jobs:        map[string]*flinkclient.JobDetails{},
+		checkpoints: map[string]*flinkclient.Checkpoints{},
+		configs:     map[string]*flinkclient.CheckpointConfig{},
+		savepoints:  map[string]*flinkclient.SavepointStatus{},
+		jars:        map[string]bool{},
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+// Close shuts down the server.
+func (server *FakeServer) Close() {
+	server.Server.Close()
+}
+
+// URL returns the base URL of the server.
+func (server *FakeServer) URL() string {
+	return server.Server.URL
+}
+
+// NewClient creates a FlinkClient which sends requests to any base URL (e.g.,
+// the URL of a JobManager service) to the fake server.
+func (server *FakeServer) NewClient(log logr.Logger) *flinkclient.FlinkClient {
+	var target, _ = url.Parse(server.Server.URL)
+	return &flinkclient.FlinkClient{
+		Log: log,
+		HTTPClient: &http.Client{
+			Timeout:   flinkclient.DefaultTimeout,
+			Transport: &_RedirectTransport{target: target},
+		},
+	}
+}
+
+// AddJob adds a job in the given state, returns the job ID.
+func (server *FakeServer) AddJob(name string, state string) string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.addJobLocked(name, state, "")
+}
+
+// SetJobState sets the state of the job.
+func (server *FakeServer) SetJobState(jobID string, state string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if job, ok := server.jobs[jobID]; ok {
+		job.State = state
+	}
+}
+
+// GetJob gets the job, returns nil if the job does not exist.
+func (server *FakeServer) GetJob(jobID string) *flinkclient.JobDetails {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	var job, ok = server.jobs[jobID]
+	if !ok {
+		return nil
+	}
+	var copied = *job
+	return &copied
+}
+
+// SetVertices sets the vertices of the job.
+func (server *FakeServer) SetVertices(
+	jobID string, vertices []flinkclient.JobVertex) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if job, ok := server.jobs[jobID]; ok {
+		job.Vertices = vertices
+	}
+}
+
+// SetCheckpoints sets the checkpoint statistics of the job.
+func (server *FakeServer) SetCheckpoints(
+	jobID string, checkpoints flinkclient.Checkpoints) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.checkpoints[jobID] = &checkpoints
+}
+
+// SetCheckpointConfig sets the checkpoint config of the job.
+func (server *FakeServer) SetCheckpointConfig(
+	jobID string, config flinkclient.CheckpointConfig) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.configs[jobID] = &config
+}
+
+// Requests returns the requests received so far as "<method> <path>".
+func (server *FakeServer) Requests() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string{}, server.requests...)
+}
+
+func (server *FakeServer) addJobLocked(
+	name string, state string, savepointPath string) string {
+	server.nextID++
+	var jobID = fmt.Sprintf("%032x", server.nextID)
+	server.jobs[jobID] = &flinkclient.JobDetails{
+		ID:        jobID,
+		Name:      name,
+		State:     state,
+		StartTime: int64(server.nextID),
+		EndTime:   -1,
+	}
+	var checkpoints = &flinkclient.Checkpoints{}
+	if len(savepointPath) > 0 {
+		checkpoints.Counts.Restored = 1
+		checkpoints.Latest.Restored = &flinkclient.RestoredCheckpointStatistics{
+			IsSavepoint:  true,
+			ExternalPath: savepointPath,
+		}
+	}
+	server.checkpoints[jobID] = checkpoints
+	// Checkpoints are not retained externally by default.
+	server.configs[jobID] = &flinkclient.CheckpointConfig{Mode: "exactly_once"}
+	return jobID
+}
+
+func (server *FakeServer) serveHTTP(
+	w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.requests = append(
+		server.requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+	var path = strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/jobs/overview":
+		server.getJobsOverview(w)
+	case r.Method == "GET" && r.URL.Path == "/taskmanagers":
+		writeJSON(
+			w,
+			http.StatusOK,
+			flinkclient.TaskManagers{TaskManagers: server.TaskManagers})
+	case r.Method == "POST" && r.URL.Path == "/jars/upload":
+		server.uploadJar(w, r)
+	case r.Method == "POST" && len(path) == 3 &&
+		path[0] == "jars" && path[2] == "run":
+		server.runJar(w, r, path[1])
+	case len(path) >= 2 && path[0] == "jobs":
+		server.serveJob(w, r, path[1], path[2:])
+	default:
+		writeError(w, http.StatusNotFound, "Not found.")
+	}
+}
+
+func (server *FakeServer) serveJob(
+	w http.ResponseWriter, r *http.Request, jobID string, path []string) {
+	var job, ok = server.jobs[jobID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Job could not be found.")
+		return
+	}
+	switch {
+	case r.Method == "GET" && len(path) == 0:
+		writeJSON(w, http.StatusOK, job)
+	case r.Method == "PATCH" && len(path) == 0:
+		if server.CancelFailure {
+			writeError(w, http.StatusInternalServerError, "Job could not be cancelled.")
+			return
+		}
+		if !flinkclient.IsJobTerminated(job.State) {
+			job.State = flinkclient.JobState.Canceled
+		}
+		writeJSON(w, http.StatusAccepted, struct{}{})
+	case r.Method == "GET" && len(path) == 1 && path[0] == "checkpoints":
+		writeJSON(w, http.StatusOK, server.checkpoints[jobID])
+	case r.Method == "GET" && len(path) == 2 && path[0] == "checkpoints" &&
+		path[1] == "config":
+		writeJSON(w, http.StatusOK, server.configs[jobID])
+	case r.Method == "POST" && len(path) == 1 && path[0] == "savepoints":
+		server.triggerSavepoint(w, r, job)
+	case r.Method == "GET" && len(path) == 2 && path[0] == "savepoints":
+		var status, ok = server.savepoints[path[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Operation not found.")
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	case r.Method == "GET" && len(path) == 4 && path[0] == "vertices" &&
+		path[2] == "subtasks" && path[3] == "metrics":
+		server.getVertexMetrics(w, r, path[1])
+	default:
+		writeError(w, http.StatusNotFound, "Not found.")
+	}
+}
+
+func (server *FakeServer) getVertexMetrics(
+	w http.ResponseWriter, r *http.Request, vertexID string) {
+	var names = r.URL.Query().Get("get")
+	var metrics = []flinkclient.AggregatedMetric{}
+	for _, metric := range server.VertexMetrics[vertexID] {
+		if len(names) == 0 {
+			metrics = append(metrics, flinkclient.AggregatedMetric{ID: metric.ID})
+			continue
+		}
+		for _, name := range strings.Split(names, ",") {
+			if name == metric.ID {
+				metrics = append(metrics, metric)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, metrics)
+}
+
+func (server *FakeServer) getJobsOverview(w http.ResponseWriter) {
+	var overview = flinkclient.JobsOverview{Jobs: []flinkclient.JobOverview{}}
+	for _, job := range server.jobs {
+		overview.Jobs = append(overview.Jobs, flinkclient.JobOverview{
+			ID:        job.ID,
+			Name:      job.Name,
+			State:     job.State,
+			StartTime: job.StartTime,
+			EndTime:   job.EndTime,
+		})
+	}
+	// Like Flink, the most recent job comes first.
+	sort.Slice(overview.Jobs, func(i, j int) bool {
+		return overview.Jobs[i].StartTime > overview.Jobs[j].StartTime
+	})
+	writeJSON(w, http.StatusOK, overview)
+}
+
+func (server *FakeServer) triggerSavepoint(
+	w http.ResponseWriter, r *http.Request, job *flinkclient.JobDetails) {
+	var request flinkclient.SavepointTriggerRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+	server.nextID++
+	var triggerID = fmt.Sprintf("%032x", server.nextID)
+	var status = &flinkclient.SavepointStatus{}
+	status.Status.ID = "COMPLETED"
+	if server.SavepointFailure || job.State != flinkclient.JobState.Running {
+		status.Operation.FailureCause = &flinkclient.FailureCause{
+			Class: "java.util.concurrent.CompletionException",
+		}
+	} else {
+		var dir = "/savepoints"
+		if request.TargetDirectory != nil {
+			dir = *request.TargetDirectory
+		}
+		var location = fmt.Sprintf(
+			"%s/savepoint-%s-%d", strings.TrimSuffix(dir, "/"), job.ID, server.nextID)
+		status.Operation.Location = location
+		var checkpoints = server.checkpoints[job.ID]
+		checkpoints.Counts.Total++
+		checkpoints.Counts.Completed++
+		checkpoints.Latest.Savepoint = &flinkclient.CheckpointStatistics{
+			ID:               int64(server.nextID),
+			Status:           "COMPLETED",
+			IsSavepoint:      true,
+			TriggerTimestamp: int64(server.nextID),
+			ExternalPath:     location,
+		}
+		if request.CancelJob {
+			job.State = flinkclient.JobState.Canceled
+		}
+	}
+	server.savepoints[triggerID] = status
+	writeJSON(
+		w,
+		http.StatusAccepted,
+		flinkclient.SavepointTriggerResponse{RequestID: triggerID})
+}
+
+func (server *FakeServer) uploadJar(w http.ResponseWriter, r *http.Request) {
+	var file, header, err = r.FormFile("jarfile")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	file.Close()
+	server.nextID++
+	var jarID = fmt.Sprintf("%d_%s", server.nextID, header.Filename)
+	server.jars[jarID] = true
+	writeJSON(w, http.StatusOK, flinkclient.JarUploadResponse{
+		Filename: "/tmp/flink-web-upload/" + jarID,
+		Status:   "success",
+	})
+}
+
+func (server *FakeServer) runJar(
+	w http.ResponseWriter, r *http.Request, jarID string) {
+	if !server.jars[jarID] {
+		writeError(w, http.StatusBadRequest, "Jar file does not exist.")
+		return
+	}
+	var request flinkclient.JarRunRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+	var savepointPath string
+	if request.SavepointPath != nil {
+		savepointPath = *request.SavepointPath
+	}
+	var jobID = server.addJobLocked(
+		jarID, flinkclient.JobState.Running, savepointPath)
+	writeJSON(w, http.StatusOK, flinkclient.JarRunResponse{JobID: jobID})
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, out interface{}) bool {
+	var body, err = ioutil.ReadAll(r.Body)
+	if err == nil && len(body) > 0 {
+		err = json.Unmarshal(body, out)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, map[string][]string{"errors": {message}})
+}
+
+// _RedirectTransport sends all requests to the target host.
+type _RedirectTransport struct {
+	target *url.URL
+}
+
+func (transport *_RedirectTransport) RoundTrip(
+	req *http.Request) (*http.Response, error) {
+	var redirected = req.WithContext(req.Context())
+	var url = *req.URL
+	url.Scheme = transport.target.Scheme
+	url.Host = transport.target.Host
+	redirected.URL = &url
+	redirected.Host = transport.target.Host
+	return http.DefaultTransport.RoundTrip(redirected)
+}
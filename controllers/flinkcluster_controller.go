@@ -22,14 +22,21 @@ import (
 
 	"github.com/go-logr/logr"
 	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+// The interval of polling the state of active jobs from the Flink REST API.
+var jobStatePollingInterval = 10 * time.Second
+
 // FlinkClusterReconciler reconciles a FlinkCluster object
 type FlinkClusterReconciler struct {
 	client.Client
@@ -41,6 +48,8 @@ type FlinkClusterReconciler struct {
 // +kubebuilder:rbac:groups=flinkoperator.k8s.io,resources=flinkclusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,resources=deployments/status,verbs=get
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=statefulsets/status,verbs=get
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get
 // +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
@@ -49,16 +58,23 @@ type FlinkClusterReconciler struct {
 // +kubebuilder:rbac:groups=core,resources=events/status,verbs=get
 // +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=batch,resources=jobs/status,verbs=get
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=podmonitors;servicemonitors,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile the observed state towards the desired state for a FlinkCluster custom resource.
 func (reconciler *FlinkClusterReconciler) Reconcile(
 	request ctrl.Request) (ctrl.Result, error) {
+	var log = reconciler.Log.WithValues(
+		"flinkcluster", request.NamespacedName)
 	var handler = _FlinkClusterHandler{
-		k8sClient: reconciler,
-		request:   request,
-		context:   context.Background(),
-		log: reconciler.Log.WithValues(
-			"flinkcluster", request.NamespacedName),
+		k8sClient:     reconciler,
+		flinkClient:   newFlinkClient(log),
+		request:       request,
+		context:       context.Background(),
+		log:           log,
 		eventRecorder: reconciler.mgr.GetEventRecorderFor("FlinkOperator"),
 		observedState: _ObservedClusterState{},
 	}
@@ -66,15 +82,29 @@ func (reconciler *FlinkClusterReconciler) Reconcile(
 }
 
 // SetupWithManager registers this reconciler with the controller manager and
-// starts watching FlinkCluster, Deployment and Service resources.
+// starts watching FlinkCluster, Deployment and Service resources. It also
+// registers the collector of the cluster state metrics.
 func (reconciler *FlinkClusterReconciler) SetupWithManager(
 	mgr ctrl.Manager) error {
 	reconciler.mgr = mgr
+	var err = metrics.Registry.Register(&_ClusterStateCollector{
+		k8sClient: mgr.GetClient(),
+		log:       reconciler.Log,
+	})
+	if err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&flinkoperatorv1alpha1.FlinkCluster{}).
 		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.StatefulSet{}).
 		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
 		Owns(&batchv1.Job{}).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Owns(&networkingv1beta1.Ingress{}).
 		Complete(reconciler)
 }
 
@@ -82,6 +112,7 @@ func (reconciler *FlinkClusterReconciler) SetupWithManager(
 // reconcile request.
 type _FlinkClusterHandler struct {
 	k8sClient     client.Client
+	flinkClient   *flinkclient.FlinkClient
 	request       ctrl.Request
 	context       context.Context
 	log           logr.Logger
@@ -98,20 +129,45 @@ func (handler *_FlinkClusterHandler) Reconcile(
 	var observedState = &handler.observedState
 	var desiredState = &handler.desiredState
 	var err error
+	var phaseStart = time.Now()
 
 	log.Info("============================================================")
 	log.Info("---------- 1. Observe the current state ----------")
 
 	var observer = _ClusterStateObserver{
-		k8sClient: k8sClient, request: request, context: context, log: log}
+		k8sClient:   k8sClient,
+		flinkClient: handler.flinkClient,
+		request:     request,
+		context:     context,
+		log:         log,
+	}
 	err = observer.observe(observedState)
+	observeReconcilePhase(reconcilePhase.Observe, phaseStart)
 	if err != nil {
 		log.Error(err, "Failed to observe the current state")
 		return ctrl.Result{}, err
 	}
 
+	// Errors of a single cluster are recorded in its status, they should not
+	// stop the operator from reconciling other clusters.
+	var updater = _ClusterStatusUpdater{
+		k8sClient:     handler.k8sClient,
+		context:       handler.context,
+		log:           handler.log,
+		eventRecorder: handler.eventRecorder,
+		observedState: handler.observedState,
+	}
+
 	log.Info("---------- 2. Compute the desired state ----------")
-	*desiredState = getDesiredClusterState(observedState.cluster)
+	phaseStart = time.Now()
+	*desiredState, err = getDesiredClusterState(observedState.cluster)
+	observeReconcilePhase(reconcilePhase.Derive, phaseStart)
+	if err != nil {
+		log.Error(err, "Failed to compute the desired state")
+		// Retrying does not help until the spec is changed, which triggers
+		// another reconcile request.
+		return ctrl.Result{}, updater.recordClusterFailure("InvalidSpec", err)
+	}
 	if desiredState.JmDeployment != nil {
 		log.Info("Desired state", "JobManager deployment", *desiredState.JmDeployment)
 	} else {
@@ -122,11 +178,29 @@ func (handler *_FlinkClusterHandler) Reconcile(
 	} else {
 		log.Info("Desired state", "JobManager service", "nil")
 	}
+	if desiredState.JmIngress != nil {
+		log.Info("Desired state", "JobManager ingress", *desiredState.JmIngress)
+	} else {
+		log.Info("Desired state", "JobManager ingress", "nil")
+	}
 	if desiredState.TmDeployment != nil {
 		log.Info("Desired state", "TaskManager deployment", *desiredState.TmDeployment)
 	} else {
 		log.Info("Desired state", "TaskManager deployment", "nil")
 	}
+	if desiredState.TmStatefulSet != nil {
+		log.Info("Desired state", "TaskManager StatefulSet", *desiredState.TmStatefulSet)
+	} else {
+		log.Info("Desired state", "TaskManager StatefulSet", "nil")
+	}
+	if desiredState.TmService != nil {
+		log.Info("Desired state", "TaskManager service", *desiredState.TmService)
+	} else {
+		log.Info("Desired state", "TaskManager service", "nil")
+	}
+	for _, poolDeployment := range desiredState.TmPoolDeployments {
+		log.Info("Desired state", "TaskManager pool deployment", *poolDeployment)
+	}
 	if desiredState.Job != nil {
 		log.Info("Desired state", "Job", *desiredState.Job)
 	} else {
@@ -136,14 +210,9 @@ func (handler *_FlinkClusterHandler) Reconcile(
 	log.Info("---------- 3. Update cluster status ----------")
 
 	// Update cluster status if changed.
-	var updater = _ClusterStatusUpdater{
-		k8sClient:     handler.k8sClient,
-		context:       handler.context,
-		log:           handler.log,
-		eventRecorder: handler.eventRecorder,
-		observedState: handler.observedState,
-	}
+	phaseStart = time.Now()
 	err = updater.updateClusterStatusIfChanged()
+	observeReconcilePhase(reconcilePhase.Update, phaseStart)
 	if err != nil {
 		log.Error(err, "Failed to update cluster status")
 		return ctrl.Result{}, err
@@ -153,12 +222,20 @@ func (handler *_FlinkClusterHandler) Reconcile(
 
 	var reconciler = _ClusterReconciler{
 		k8sClient:     handler.k8sClient,
+		flinkClient:   handler.flinkClient,
 		context:       handler.context,
 		log:           handler.log,
+		eventRecorder: handler.eventRecorder,
 		observedState: handler.observedState,
 		desiredState:  handler.desiredState,
 	}
+	phaseStart = time.Now()
 	err = reconciler.reconcile()
+	observeReconcilePhase(reconcilePhase.Act, phaseStart)
+	var conditionErr = updater.updateReconcileErrorCondition(err)
+	if conditionErr != nil {
+		log.Error(conditionErr, "Failed to update the ReconcileError condition")
+	}
 	if err != nil {
 		log.Error(err, "Failed to reconcile")
 		return ctrl.Result{
@@ -167,5 +244,41 @@ func (handler *_FlinkClusterHandler) Reconcile(
 		}, err
 	}
 
+	// Changes of the Flink job state do not trigger events, keep polling it
+	// while the job is active.
+	if shouldPollJobState(observedState) {
+		log.Info("Requeue to poll the job state",
+			"after", jobStatePollingInterval)
+		return ctrl.Result{RequeueAfter: jobStatePollingInterval}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
+
+// Checks whether the cluster has an active job, a job upgrade or a savepoint on
+// deletion in progress, of which the state needs to be polled from the Flink
+// REST API.
+func shouldPollJobState(observedState *_ObservedClusterState) bool {
+	var cluster = observedState.cluster
+	if cluster == nil || cluster.Spec.JobSpec == nil {
+		return false
+	}
+	if cluster.DeletionTimestamp != nil {
+		return hasFinalizer(cluster.Finalizers, savepointOnDeleteFinalizer)
+	}
+	var jobStatus = cluster.Status.Components.Job
+	if jobStatus != nil && len(jobStatus.UpgradeState) > 0 {
+		return true
+	}
+	// Waiting for the backoff to restart the failed job.
+	if shouldRestartJob(cluster, jobStatus) {
+		return true
+	}
+	// The job is run inside JobManager in Application mode.
+	if isApplicationMode(cluster) {
+		return observedState.jmDeployment != nil &&
+			(jobStatus == nil || !isJobTerminated(jobStatus.State))
+	}
+	var observedJob = observedState.job
+	return observedJob != nil && observedJob.Status.Active > 0
+}
@@ -17,51 +17,324 @@ limitations under the License.
 package controllers
 
 import (
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"net"
+	"sort"
+	"strconv"
 	"strings"
 
 	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // Converter which converts the FlinkCluster spec to the desired
 // underlying Kubernetes resource specs.
 
+// Annotation of the job resource which records the hash of the cluster spec
+// the job was submitted with.
+const jobSpecHashAnnotation = "flinkoperator.k8s.io/job-spec-hash"
+
+// Annotation of the JobManager and TaskManager pod templates which records the
+// hash of the config files, so the pods are restarted when the files change.
+const configHashAnnotation = "flinkoperator.k8s.io/config-hash"
+
+// The conf directory of Flink in the image, into which the config files are
+// mounted.
+const flinkConfDir = "/opt/flink/conf"
+
+// The volume of the ConfigMap which holds the config files, it is owned by the
+// operator along with its mounts.
+const configVolumeName = "flink-config-volume"
+
+// The factory of the Kubernetes HA services of Flink, `kubernetes` is only
+// accepted as its alias since Flink 1.15.
+const kubernetesHaServicesFactory = "org.apache.flink.kubernetes.highavailability.KubernetesHaServicesFactory"
+
+// The file the SQL script of the job is written to before it is run.
+const sqlScriptFile = "/tmp/job.sql"
+
+// Shell command which writes the SQL script in the env variable to the file,
+// then runs the SQL client with the positional arguments.
+const sqlScriptCommand = `printf '%s\n' "$FLINK_SQL_SCRIPT" > ` + sqlScriptFile +
+	` && exec "$@"`
+
+// Label of the deployments and pods of a TaskManager pool, whose value is the
+// name of the pool.
+const taskManagerPoolLabel = "taskmanager-pool"
+
 // _DesiredClusterState holds desired state of a cluster.
 type _DesiredClusterState struct {
-	JmDeployment *appsv1.Deployment
-	JmService    *corev1.Service
-	TmDeployment *appsv1.Deployment
-	Job          *batchv1.Job
+	HaServiceAccount  *corev1.ServiceAccount
+	HaRole            *rbacv1.Role
+	HaRoleBinding     *rbacv1.RoleBinding
+	ConfigMap         *corev1.ConfigMap
+	JmDeployment      *appsv1.Deployment
+	JmService         *corev1.Service
+	JmIngress         *networkingv1beta1.Ingress
+	TmDeployment      *appsv1.Deployment
+	TmStatefulSet     *appsv1.StatefulSet
+	TmService         *corev1.Service
+	TmPoolDeployments []*appsv1.Deployment
+	Job               *batchv1.Job
+	PodMonitor        *unstructured.Unstructured
+	ServiceMonitor    *unstructured.Unstructured
 }
 
 // Gets the desired state of a cluster.
 func getDesiredClusterState(
-	cluster *flinkoperatorv1alpha1.FlinkCluster) _DesiredClusterState {
+	cluster *flinkoperatorv1alpha1.FlinkCluster) (_DesiredClusterState, error) {
 	// The cluster has been deleted, all resources should be cleaned up.
 	if cluster == nil {
-		return _DesiredClusterState{}
+		return _DesiredClusterState{}, nil
+	}
+	var err = checkDefaultedFields(cluster)
+	if err != nil {
+		return _DesiredClusterState{}, err
+	}
+	err = checkHighAvailabilitySpec(cluster)
+	if err != nil {
+		return _DesiredClusterState{}, err
+	}
+	jmDeployment, err := getDesiredJobManagerDeployment(cluster)
+	if err != nil {
+		return _DesiredClusterState{}, err
+	}
+	jmService, err := getDesiredJobManagerService(cluster)
+	if err != nil {
+		return _DesiredClusterState{}, err
+	}
+	tmDeployment, err := getDesiredTaskManagerDeployment(cluster)
+	if err != nil {
+		return _DesiredClusterState{}, err
+	}
+	tmStatefulSet, err := getDesiredTaskManagerStatefulSet(cluster)
+	if err != nil {
+		return _DesiredClusterState{}, err
+	}
+	tmPoolDeployments, err := getDesiredTaskManagerPoolDeployments(cluster)
+	if err != nil {
+		return _DesiredClusterState{}, err
+	}
+	job, err := getDesiredJob(cluster)
+	if err != nil {
+		return _DesiredClusterState{}, err
+	}
+	podMonitor, err := getDesiredMonitor(
+		cluster, flinkoperatorv1alpha1.MonitorKind.PodMonitor)
+	if err != nil {
+		return _DesiredClusterState{}, err
+	}
+	serviceMonitor, err := getDesiredMonitor(
+		cluster, flinkoperatorv1alpha1.MonitorKind.ServiceMonitor)
+	if err != nil {
+		return _DesiredClusterState{}, err
 	}
 	return _DesiredClusterState{
-		JmDeployment: getDesiredJobManagerDeployment(cluster),
-		JmService:    getDesiredJobManagerService(cluster),
-		TmDeployment: getDesiredTaskManagerDeployment(cluster),
-		Job:          getDesiredJob(cluster),
+		HaServiceAccount:  getDesiredHaServiceAccount(cluster),
+		HaRole:            getDesiredHaRole(cluster),
+		HaRoleBinding:     getDesiredHaRoleBinding(cluster),
+		ConfigMap:         getDesiredConfigMap(cluster),
+		JmDeployment:      jmDeployment,
+		JmService:         jmService,
+		JmIngress:         getDesiredJobManagerIngress(cluster),
+		TmDeployment:      tmDeployment,
+		TmStatefulSet:     tmStatefulSet,
+		TmService:         getDesiredTaskManagerService(cluster),
+		TmPoolDeployments: tmPoolDeployments,
+		Job:               job,
+		PodMonitor:        podMonitor,
+		ServiceMonitor:    serviceMonitor,
+	}, nil
+}
+
+// Checks the fields which are set by the defaulting webhook, they could be
+// missing if the webhook is disabled.
+func checkDefaultedFields(cluster *flinkoperatorv1alpha1.FlinkCluster) error {
+	var jmPorts = cluster.Spec.JobManagerSpec.Ports
+	var tmPorts = cluster.Spec.TaskManagerSpec.Ports
+	var autoscalerSpec *flinkoperatorv1alpha1.JobAutoscalerSpec
+	if cluster.Spec.JobSpec != nil {
+		autoscalerSpec = cluster.Spec.JobSpec.Autoscaler
+	}
+	var requiredFields = []struct {
+		name  string
+		isSet bool
+	}{
+		{"spec.jobManager.ports.rpc", jmPorts.RPC != nil},
+		{"spec.jobManager.ports.blob", jmPorts.Blob != nil},
+		{"spec.jobManager.ports.query", jmPorts.Query != nil},
+		{"spec.jobManager.ports.ui", jmPorts.UI != nil},
+		{"spec.taskManager.ports.data", tmPorts.Data != nil},
+		{"spec.taskManager.ports.rpc", tmPorts.RPC != nil},
+		{"spec.taskManager.ports.query", tmPorts.Query != nil},
+		{"spec.jobManager.ingress.path",
+			cluster.Spec.JobManagerSpec.Ingress == nil ||
+				cluster.Spec.JobManagerSpec.Ingress.Path != nil},
+		{"spec.job.restartPolicy",
+			cluster.Spec.JobSpec == nil || cluster.Spec.JobSpec.RestartPolicy != nil},
+		{"spec.job.maxRestartAttempts",
+			!isRestartFromSavepointEnabled(cluster) ||
+				cluster.Spec.JobSpec.MaxRestartAttempts != nil},
+		{"spec.job.restartBackoffSeconds",
+			!isRestartFromSavepointEnabled(cluster) ||
+				cluster.Spec.JobSpec.RestartBackoffSeconds != nil},
+		{"spec.job.savepointOnDeleteTimeoutSeconds",
+			!isSavepointOnDeleteEnabled(cluster) ||
+				cluster.Spec.JobSpec.SavepointOnDeleteTimeoutSeconds != nil},
+		{"spec.job.parallelism",
+			autoscalerSpec == nil || cluster.Spec.JobSpec.Parallelism != nil},
+		{"spec.job.autoscaler.minParallelism",
+			autoscalerSpec == nil || autoscalerSpec.MinParallelism != nil},
+		{"spec.job.autoscaler.targetUtilization",
+			autoscalerSpec == nil || autoscalerSpec.TargetUtilization != nil},
+		{"spec.job.autoscaler.stabilizationWindowSeconds",
+			autoscalerSpec == nil ||
+				autoscalerSpec.StabilizationWindowSeconds != nil},
+		{"spec.monitoring.port",
+			cluster.Spec.Monitoring == nil || cluster.Spec.Monitoring.Port != nil},
+	}
+	for _, requiredField := range requiredFields {
+		if !requiredField.isSet {
+			return fmt.Errorf(
+				"%v is not set, check the defaulting webhook is enabled",
+				requiredField.name)
+		}
+	}
+	return nil
+}
+
+// Checks the high availability spec which is required to generate the Flink
+// properties, it could be invalid if the validating webhook is disabled.
+func checkHighAvailabilitySpec(cluster *flinkoperatorv1alpha1.FlinkCluster) error {
+	var haSpec = cluster.Spec.HighAvailability
+	if haSpec == nil {
+		return nil
+	}
+	switch haSpec.Mode {
+	case flinkoperatorv1alpha1.HighAvailabilityMode.Kubernetes:
+	case flinkoperatorv1alpha1.HighAvailabilityMode.ZooKeeper:
+		if haSpec.ZooKeeperQuorum == nil {
+			return fmt.Errorf("spec.highAvailability.zooKeeperQuorum is not set")
+		}
+	default:
+		return fmt.Errorf("unknown high availability mode: %v", haSpec.Mode)
+	}
+	return nil
+}
+
+// Gets the desired service account of the JobManager and TaskManager pods,
+// which is only required by Kubernetes HA to access the leader ConfigMaps.
+func getDesiredHaServiceAccount(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *corev1.ServiceAccount {
+	if !isKubernetesHaEnabled(flinkCluster) {
+		return nil
+	}
+	var clusterName = flinkCluster.ObjectMeta.Name
+	return &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: flinkCluster.ObjectMeta.Namespace,
+			Name:      getHaResourceName(clusterName),
+			OwnerReferences: []metav1.OwnerReference{
+				toOwnerReference(flinkCluster)},
+			Labels: map[string]string{"cluster": clusterName, "app": "flink"},
+		},
+	}
+}
+
+// Gets the desired role which allows Flink to elect the leader and record
+// the leader information in ConfigMaps.
+func getDesiredHaRole(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *rbacv1.Role {
+	if !isKubernetesHaEnabled(flinkCluster) {
+		return nil
+	}
+	var clusterName = flinkCluster.ObjectMeta.Name
+	return &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: flinkCluster.ObjectMeta.Namespace,
+			Name:      getHaResourceName(clusterName),
+			OwnerReferences: []metav1.OwnerReference{
+				toOwnerReference(flinkCluster)},
+			Labels: map[string]string{"cluster": clusterName, "app": "flink"},
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{""},
+				Resources: []string{"configmaps"},
+				Verbs: []string{
+					"get", "list", "watch", "create", "update", "patch", "delete"},
+			},
+		},
+	}
+}
+
+// Gets the desired role binding which grants the HA role to the service
+// account of the cluster.
+func getDesiredHaRoleBinding(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *rbacv1.RoleBinding {
+	if !isKubernetesHaEnabled(flinkCluster) {
+		return nil
+	}
+	var clusterNamespace = flinkCluster.ObjectMeta.Namespace
+	var clusterName = flinkCluster.ObjectMeta.Name
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: clusterNamespace,
+			Name:      getHaResourceName(clusterName),
+			OwnerReferences: []metav1.OwnerReference{
+				toOwnerReference(flinkCluster)},
+			Labels: map[string]string{"cluster": clusterName, "app": "flink"},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     getHaResourceName(clusterName),
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Namespace: clusterNamespace,
+				Name:      getHaResourceName(clusterName),
+			},
+		},
+	}
+}
+
+// Gets the desired ConfigMap which holds flink-conf.yaml and the logging config
+// files of the cluster. It is kept as long as the cluster exists, because the
+// job submitter may still be running after the cluster is stopped.
+func getDesiredConfigMap(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *corev1.ConfigMap {
+	var clusterName = flinkCluster.ObjectMeta.Name
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: flinkCluster.ObjectMeta.Namespace,
+			Name:      getConfigMapName(clusterName),
+			OwnerReferences: []metav1.OwnerReference{
+				toOwnerReference(flinkCluster)},
+			Labels: map[string]string{"cluster": clusterName, "app": "flink"},
+		},
+		Data: getConfigFiles(flinkCluster),
 	}
 }
 
 // Gets the desired JobManager deployment spec from the FlinkCluster spec.
 func getDesiredJobManagerDeployment(
-	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *appsv1.Deployment {
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) (*appsv1.Deployment, error) {
 
 	if flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopping ||
 		flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopped {
-		return nil
+		return nil, nil
 	}
 
 	var clusterNamespace = flinkCluster.ObjectMeta.Namespace
@@ -78,11 +351,34 @@ func getDesiredJobManagerDeployment(
 		"app":       "flink",
 		"component": "jobmanager",
 	}
-	var envVars = []corev1.EnvVar{
-		{
-			Name:  "JOB_MANAGER_RPC_ADDRESS",
-			Value: jobManagerDeploymentName,
-		},
+	var isApplicationMode = isApplicationMode(flinkCluster)
+	var args = []string{"jobmanager"}
+	if isApplicationMode {
+		args = getStandaloneJobArgs(flinkCluster)
+	}
+	var envVars = []corev1.EnvVar{}
+	// With high availability, each JobManager registers its own address to the
+	// leader election services instead of the address of the service shared
+	// by all the replicas, which is passed as the host argument because
+	// flink-conf.yaml is shared.
+	if flinkCluster.Spec.HighAvailability != nil {
+		envVars = append(envVars, corev1.EnvVar{
+			Name: "POD_IP",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"},
+			},
+		})
+		if isApplicationMode {
+			args = append(args, "--host", "$(POD_IP)")
+		} else {
+			args = append(args, "$(POD_IP)")
+		}
+	}
+	// The arguments of the job follow the options of `standalone-job`.
+	if isApplicationMode {
+		args = append(args, flinkCluster.Spec.JobSpec.Args...)
+	}
+	envVars = append(envVars, []corev1.EnvVar{
 		{
 			Name: "JOB_MANAGER_CPU_LIMIT",
 			ValueFrom: &corev1.EnvVarSource{
@@ -103,57 +399,88 @@ func getDesiredJobManagerDeployment(
 				},
 			},
 		},
-		{
-			Name:  "FLINK_PROPERTIES",
-			Value: getFlinkProperties(flinkCluster.Spec.FlinkProperties),
-		},
-	}
+	}...)
 	envVars = append(envVars, flinkCluster.Spec.EnvVars...)
+	var volumes = append(
+		[]corev1.Volume{getConfigVolume(clusterName)}, jobManagerSpec.Volumes...)
+	var mounts = append(
+		getConfigVolumeMounts(flinkCluster), jobManagerSpec.Mounts...)
+	// The job is run inside JobManager in Application mode, so the deployment
+	// takes the volumes of the job and the hash of the spec it was run with.
+	// The remote files of the job are fetched into the usrlib directory.
+	var annotations map[string]string
+	var initContainers []corev1.Container
+	if isApplicationMode {
+		var jobSpec = flinkCluster.Spec.JobSpec
+		volumes = append(volumes, jobSpec.Volumes...)
+		mounts = append(mounts, jobSpec.Mounts...)
+		annotations = map[string]string{
+			jobSpecHashAnnotation: getJobSpecHash(flinkCluster),
+		}
+		var fetcher, fetcherVolumes, artifactsMount = getArtifactFetcher(
+			jobSpec, userLibDir)
+		if fetcher != nil {
+			initContainers = []corev1.Container{*fetcher}
+			volumes = append(volumes, fetcherVolumes...)
+			mounts = append(mounts, *artifactsMount)
+		}
+	}
 	var jobManagerDeployment = &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace:       clusterNamespace,
 			Name:            jobManagerDeploymentName,
 			OwnerReferences: []metav1.OwnerReference{toOwnerReference(flinkCluster)},
 			Labels:          labels,
+			Annotations:     annotations,
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: jobManagerSpec.Replicas,
 			Selector: &metav1.LabelSelector{MatchLabels: labels},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
+					Labels:      labels,
+					Annotations: getConfigHashAnnotations(flinkCluster),
 				},
 				Spec: corev1.PodSpec{
+					InitContainers: initContainers,
 					Containers: []corev1.Container{
 						corev1.Container{
 							Name:            "jobmanager",
 							Image:           imageSpec.Name,
 							ImagePullPolicy: imageSpec.PullPolicy,
-							Args:            []string{"jobmanager"},
-							Ports: []corev1.ContainerPort{
-								rpcPort, blobPort, queryPort, uiPort},
+							Args:            args,
+							Ports: append(
+								[]corev1.ContainerPort{
+									rpcPort, blobPort, queryPort, uiPort},
+								getMetricsContainerPorts(flinkCluster)...),
 							Resources:    jobManagerSpec.Resources,
 							Env:          envVars,
-							VolumeMounts: jobManagerSpec.Mounts,
+							VolumeMounts: mounts,
 						},
 					},
-					Volumes:          jobManagerSpec.Volumes,
-					NodeSelector:     jobManagerSpec.NodeSelector,
-					ImagePullSecrets: imageSpec.PullSecrets,
+					Volumes:            volumes,
+					NodeSelector:       jobManagerSpec.NodeSelector,
+					ImagePullSecrets:   imageSpec.PullSecrets,
+					ServiceAccountName: getHaServiceAccountName(flinkCluster),
 				},
 			},
 		},
 	}
-	return jobManagerDeployment
+	var err = applyPodTemplate(
+		&jobManagerDeployment.Spec.Template, jobManagerSpec.PodTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return jobManagerDeployment, nil
 }
 
 // Gets the desired JobManager service spec from a cluster spec.
 func getDesiredJobManagerService(
-	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *corev1.Service {
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) (*corev1.Service, error) {
 
 	if flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopping ||
 		flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopped {
-		return nil
+		return nil, nil
 	}
 
 	var clusterNamespace = flinkCluster.ObjectMeta.Namespace
@@ -194,6 +521,17 @@ func getDesiredJobManagerService(
 			Ports:    []corev1.ServicePort{rpcPort, blobPort, queryPort, uiPort},
 		},
 	}
+	// The metrics of JobManager are scraped through the service by a
+	// ServiceMonitor.
+	if monitoringSpec := flinkCluster.Spec.Monitoring; monitoringSpec != nil {
+		jobManagerService.Spec.Ports = append(
+			jobManagerService.Spec.Ports,
+			corev1.ServicePort{
+				Name:       metricsPortName,
+				Port:       *monitoringSpec.Port,
+				TargetPort: intstr.FromString(metricsPortName),
+			})
+	}
 	// This implementation is specific to GKE, see details at
 	// https://cloud.google.com/kubernetes-engine/docs/how-to/exposing-apps
 	// https://cloud.google.com/kubernetes-engine/docs/how-to/internal-load-balancing
@@ -207,21 +545,227 @@ func getDesiredJobManagerService(
 	case flinkoperatorv1alpha1.AccessScope.External:
 		jobManagerService.Spec.Type = corev1.ServiceTypeLoadBalancer
 	default:
-		panic(fmt.Sprintf(
-			"Unknown service access cope: %v", jobManagerSpec.AccessScope))
+		return nil, fmt.Errorf(
+			"unknown service access scope: %v", jobManagerSpec.AccessScope)
 	}
-	return jobManagerService
+	return jobManagerService, nil
 }
 
-// Gets the desired TaskManager deployment spec from a cluster spec.
-func getDesiredTaskManagerDeployment(
-	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *appsv1.Deployment {
+// Gets the desired JobManager ingress spec from a cluster spec, which routes
+// the requests to the UI port of the JobManager service.
+func getDesiredJobManagerIngress(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *networkingv1beta1.Ingress {
+	var ingressSpec = flinkCluster.Spec.JobManagerSpec.Ingress
+	if ingressSpec == nil {
+		return nil
+	}
 
 	if flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopping ||
 		flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopped {
 		return nil
 	}
 
+	var clusterNamespace = flinkCluster.ObjectMeta.Namespace
+	var clusterName = flinkCluster.ObjectMeta.Name
+	var host = getJobManagerIngressHost(flinkCluster)
+	var labels = map[string]string{
+		"cluster":   clusterName,
+		"app":       "flink",
+		"component": "jobmanager",
+	}
+	var annotations = map[string]string{}
+	for key, value := range ingressSpec.Annotations {
+		annotations[key] = value
+	}
+	if ingressSpec.IngressClass != nil {
+		annotations["kubernetes.io/ingress.class"] = *ingressSpec.IngressClass
+	}
+	var jobManagerIngress = &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: clusterNamespace,
+			Name:      getJobManagerIngressName(clusterName),
+			OwnerReferences: []metav1.OwnerReference{
+				toOwnerReference(flinkCluster)},
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+							Paths: []networkingv1beta1.HTTPIngressPath{
+								{
+									Path: *ingressSpec.Path,
+									Backend: networkingv1beta1.IngressBackend{
+										ServiceName: getJobManagerServiceName(clusterName),
+										ServicePort: intstr.FromString("ui"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if ingressSpec.TLSSecretName != nil {
+		var tls = networkingv1beta1.IngressTLS{
+			SecretName: *ingressSpec.TLSSecretName,
+		}
+		if len(host) > 0 {
+			tls.Hosts = []string{host}
+		}
+		jobManagerIngress.Spec.TLS = []networkingv1beta1.IngressTLS{tls}
+	}
+	return jobManagerIngress
+}
+
+// Gets the host of the JobManager ingress from the host format, empty if the
+// ingress matches all hosts.
+func getJobManagerIngressHost(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) string {
+	var hostFormat = flinkCluster.Spec.JobManagerSpec.Ingress.HostFormat
+	if hostFormat == nil {
+		return ""
+	}
+	var host = strings.Replace(
+		*hostFormat, "{{$clusterName}}", flinkCluster.ObjectMeta.Name, -1)
+	return strings.Replace(
+		host, "{{$clusterNamespace}}", flinkCluster.ObjectMeta.Namespace, -1)
+}
+
+// Gets the desired TaskManager deployment spec from a cluster spec, returns
+// nil if TaskManagers are run as a StatefulSet.
+func getDesiredTaskManagerDeployment(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) (*appsv1.Deployment, error) {
+
+	if flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopping ||
+		flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopped {
+		return nil, nil
+	}
+	if isTaskManagerStatefulSet(flinkCluster) {
+		return nil, nil
+	}
+
+	var clusterName = flinkCluster.ObjectMeta.Name
+	var labels = getTaskManagerLabels(clusterName)
+	var podTemplate, err = getDesiredTaskManagerPodTemplate(flinkCluster, nil)
+	if err != nil {
+		return nil, err
+	}
+	var taskManagerDeployment = &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: flinkCluster.ObjectMeta.Namespace,
+			Name:      getTaskManagerDeploymentName(clusterName),
+			OwnerReferences: []metav1.OwnerReference{
+				toOwnerReference(flinkCluster)},
+			Labels: labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: getTaskManagerReplicas(flinkCluster),
+			Selector: getTaskManagerSelector(clusterName),
+			Template: *podTemplate,
+		},
+	}
+	return taskManagerDeployment, nil
+}
+
+// Gets the desired TaskManager StatefulSet spec from a cluster spec, returns
+// nil unless TaskManagers are run as a StatefulSet. Each pod gets the volumes
+// of the claim templates, which are kept across restarts of the pod, and a
+// stable hostname from the headless TaskManager service.
+func getDesiredTaskManagerStatefulSet(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) (*appsv1.StatefulSet, error) {
+
+	if flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopping ||
+		flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopped {
+		return nil, nil
+	}
+	if !isTaskManagerStatefulSet(flinkCluster) {
+		return nil, nil
+	}
+
+	var clusterName = flinkCluster.ObjectMeta.Name
+	var taskManagerSpec = flinkCluster.Spec.TaskManagerSpec
+	var labels = getTaskManagerLabels(clusterName)
+	var podTemplate, err = getDesiredTaskManagerPodTemplate(flinkCluster, nil)
+	if err != nil {
+		return nil, err
+	}
+	var podManagementPolicy appsv1.PodManagementPolicyType
+	if taskManagerSpec.PodManagementPolicy != nil {
+		podManagementPolicy = *taskManagerSpec.PodManagementPolicy
+	}
+	var taskManagerStatefulSet = &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: flinkCluster.ObjectMeta.Namespace,
+			Name:      getTaskManagerStatefulSetName(clusterName),
+			OwnerReferences: []metav1.OwnerReference{
+				toOwnerReference(flinkCluster)},
+			Labels: labels,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:             getTaskManagerReplicas(flinkCluster),
+			Selector:             &metav1.LabelSelector{MatchLabels: labels},
+			Template:             *podTemplate,
+			VolumeClaimTemplates: taskManagerSpec.VolumeClaimTemplates,
+			ServiceName:          getTaskManagerServiceName(clusterName),
+			PodManagementPolicy:  podManagementPolicy,
+		},
+	}
+	return taskManagerStatefulSet, nil
+}
+
+// Gets the desired deployments of the TaskManager pools from a cluster spec,
+// in the order of the pools.
+func getDesiredTaskManagerPoolDeployments(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) ([]*appsv1.Deployment, error) {
+
+	if flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopping ||
+		flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopped {
+		return nil, nil
+	}
+
+	var clusterName = flinkCluster.ObjectMeta.Name
+	var deployments []*appsv1.Deployment
+	for i := range flinkCluster.Spec.TaskManagerPools {
+		var poolSpec = &flinkCluster.Spec.TaskManagerPools[i]
+		var labels = getTaskManagerPoolLabels(clusterName, poolSpec.Name)
+		var podTemplate, err = getDesiredTaskManagerPodTemplate(
+			flinkCluster, poolSpec)
+		if err != nil {
+			return nil, err
+		}
+		var replicas = poolSpec.Replicas
+		deployments = append(deployments, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: flinkCluster.ObjectMeta.Namespace,
+				Name: getTaskManagerPoolDeploymentName(
+					clusterName, poolSpec.Name),
+				OwnerReferences: []metav1.OwnerReference{
+					toOwnerReference(flinkCluster)},
+				Labels: labels,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: *podTemplate,
+			},
+		})
+	}
+	return deployments, nil
+}
+
+// Gets the desired pod template of TaskManager from a cluster spec, which is
+// shared by the deployment and the StatefulSet. The resources, node selector,
+// tolerations and task slots of the pool are applied to the pod template of a
+// pool, the pool is nil for the other TaskManagers.
+func getDesiredTaskManagerPodTemplate(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster,
+	poolSpec *flinkoperatorv1alpha1.TaskManagerPoolSpec) (
+	*corev1.PodTemplateSpec, error) {
 	var clusterNamespace = flinkCluster.ObjectMeta.Namespace
 	var clusterName = flinkCluster.ObjectMeta.Name
 	var imageSpec = flinkCluster.Spec.ImageSpec
@@ -229,18 +773,46 @@ func getDesiredTaskManagerDeployment(
 	var dataPort = corev1.ContainerPort{Name: "data", ContainerPort: *taskManagerSpec.Ports.Data}
 	var rpcPort = corev1.ContainerPort{Name: "rpc", ContainerPort: *taskManagerSpec.Ports.RPC}
 	var queryPort = corev1.ContainerPort{Name: "query", ContainerPort: *taskManagerSpec.Ports.Query}
-	var taskManagerDeploymentName = getTaskManagerDeploymentName(clusterName)
-	var jobManagerDeploymentName = getJobManagerDeploymentName(clusterName)
-	var labels = map[string]string{
-		"cluster":   clusterName,
-		"app":       "flink",
-		"component": "taskmanager",
+	var labels = getTaskManagerLabels(clusterName)
+	var args = []string{"taskmanager"}
+	var resources = taskManagerSpec.Resources
+	var nodeSelector = taskManagerSpec.NodeSelector
+	var tolerations []corev1.Toleration
+	if poolSpec != nil {
+		labels = getTaskManagerPoolLabels(clusterName, poolSpec.Name)
+		if poolSpec.Resources != nil {
+			resources = *poolSpec.Resources
+		}
+		if poolSpec.NodeSelector != nil {
+			nodeSelector = poolSpec.NodeSelector
+		}
+		tolerations = poolSpec.Tolerations
+		// Dynamic properties take precedence over flink-conf.yaml.
+		if poolSpec.TaskSlots != nil {
+			args = append(args, fmt.Sprintf(
+				"-Dtaskmanager.numberOfTaskSlots=%d", *poolSpec.TaskSlots))
+		}
 	}
-	var envVars = []corev1.EnvVar{
-		{
-			Name:  "JOB_MANAGER_RPC_ADDRESS",
-			Value: jobManagerDeploymentName,
-		},
+	var envVars = []corev1.EnvVar{}
+	// In a StatefulSet, each TaskManager registers its stable hostname instead
+	// of its IP, and its pod name is the resource ID, so it finds its local
+	// state again after it is restarted.
+	if isTaskManagerStatefulSet(flinkCluster) {
+		envVars = append(envVars, corev1.EnvVar{
+			Name: "POD_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			},
+		})
+		args = append(
+			args,
+			fmt.Sprintf(
+				"-Dtaskmanager.host=$(POD_NAME).%s.%s.svc.cluster.local",
+				getTaskManagerServiceName(clusterName),
+				clusterNamespace),
+			"-Dtaskmanager.resource-id=$(POD_NAME)")
+	}
+	envVars = append(envVars, []corev1.EnvVar{
 		{
 			Name: "TASK_MANAGER_CPU_LIMIT",
 			ValueFrom: &corev1.EnvVarSource{
@@ -261,57 +833,105 @@ func getDesiredTaskManagerDeployment(
 				},
 			},
 		},
-		{
-			Name:  "FLINK_PROPERTIES",
-			Value: getFlinkProperties(flinkCluster.Spec.FlinkProperties),
-		},
-	}
+	}...)
 	envVars = append(envVars, flinkCluster.Spec.EnvVars...)
+	var volumes = append(
+		[]corev1.Volume{getConfigVolume(clusterName)}, taskManagerSpec.Volumes...)
+	var mounts = append(
+		getConfigVolumeMounts(flinkCluster), taskManagerSpec.Mounts...)
 	var containers = []corev1.Container{corev1.Container{
 		Name:            "taskmanager",
 		Image:           imageSpec.Name,
 		ImagePullPolicy: imageSpec.PullPolicy,
-		Args:            []string{"taskmanager"},
-		Ports: []corev1.ContainerPort{
-			dataPort, rpcPort, queryPort},
-		Resources:    taskManagerSpec.Resources,
+		Args:            args,
+		Ports: append(
+			[]corev1.ContainerPort{dataPort, rpcPort, queryPort},
+			getMetricsContainerPorts(flinkCluster)...),
+		Resources:    resources,
 		Env:          envVars,
-		VolumeMounts: taskManagerSpec.Mounts,
+		VolumeMounts: mounts,
 	}}
 	containers = append(containers, taskManagerSpec.Sidecars...)
-	var taskManagerDeployment = &appsv1.Deployment{
+	var podTemplate = &corev1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
-			Namespace: clusterNamespace,
-			Name:      taskManagerDeploymentName,
+			Labels:      labels,
+			Annotations: getConfigHashAnnotations(flinkCluster),
+		},
+		Spec: corev1.PodSpec{
+			Containers:         containers,
+			Volumes:            volumes,
+			NodeSelector:       nodeSelector,
+			Tolerations:        tolerations,
+			ImagePullSecrets:   imageSpec.PullSecrets,
+			ServiceAccountName: getHaServiceAccountName(flinkCluster),
+		},
+	}
+	var err = applyPodTemplate(podTemplate, taskManagerSpec.PodTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return podTemplate, nil
+}
+
+// Gets the desired headless TaskManager service from a cluster spec, which
+// gives the pods of the StatefulSet their stable hostnames. Returns nil
+// unless TaskManagers are run as a StatefulSet.
+func getDesiredTaskManagerService(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *corev1.Service {
+
+	if flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopping ||
+		flinkCluster.Status.State == flinkoperatorv1alpha1.ClusterState.Stopped {
+		return nil
+	}
+	if !isTaskManagerStatefulSet(flinkCluster) {
+		return nil
+	}
+
+	var clusterName = flinkCluster.ObjectMeta.Name
+	var taskManagerSpec = flinkCluster.Spec.TaskManagerSpec
+	var labels = getTaskManagerLabels(clusterName)
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: flinkCluster.ObjectMeta.Namespace,
+			Name:      getTaskManagerServiceName(clusterName),
 			OwnerReferences: []metav1.OwnerReference{
 				toOwnerReference(flinkCluster)},
 			Labels: labels,
 		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &taskManagerSpec.Replicas,
-			Selector: &metav1.LabelSelector{MatchLabels: labels},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
+		Spec: corev1.ServiceSpec{
+			Type:      corev1.ServiceTypeClusterIP,
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  labels,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "data",
+					Port:       *taskManagerSpec.Ports.Data,
+					TargetPort: intstr.FromString("data"),
 				},
-				Spec: corev1.PodSpec{
-					Containers:       containers,
-					Volumes:          taskManagerSpec.Volumes,
-					NodeSelector:     taskManagerSpec.NodeSelector,
-					ImagePullSecrets: imageSpec.PullSecrets,
+				{
+					Name:       "rpc",
+					Port:       *taskManagerSpec.Ports.RPC,
+					TargetPort: intstr.FromString("rpc"),
+				},
+				{
+					Name:       "query",
+					Port:       *taskManagerSpec.Ports.Query,
+					TargetPort: intstr.FromString("query"),
 				},
 			},
+			// TaskManagers register their hostnames before they are ready.
+			PublishNotReadyAddresses: true,
 		},
 	}
-	return taskManagerDeployment
 }
 
 // Gets the desired job spec from a cluster spec.
 func getDesiredJob(
-	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *batchv1.Job {
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) (*batchv1.Job, error) {
 	var jobSpec = flinkCluster.Spec.JobSpec
-	if jobSpec == nil {
-		return nil
+	// The job is run inside JobManager in Application mode.
+	if jobSpec == nil || isApplicationMode(flinkCluster) {
+		return nil, nil
 	}
 
 	var imageSpec = flinkCluster.Spec.ImageSpec
@@ -326,45 +946,38 @@ func getDesiredJob(
 		"cluster": clusterName,
 		"app":     "flink",
 	}
-	var jobArgs = []string{"./bin/flink", "run"}
-	jobArgs = append(jobArgs, "--jobmanager", jobManagerAddress)
-	if jobSpec.ClassName != nil {
-		jobArgs = append(jobArgs, "--class", *jobSpec.ClassName)
-	}
-	if jobSpec.Savepoint != nil {
-		jobArgs = append(jobArgs, "--fromSavepoint", *jobSpec.Savepoint)
-	}
-	if jobSpec.AllowNonRestoredState != nil &&
-		*jobSpec.AllowNonRestoredState == true {
-		jobArgs = append(jobArgs, "--allowNonRestoredState")
-	}
-	if jobSpec.Parallelism != nil {
-		jobArgs = append(
-			jobArgs, "--parallelism", fmt.Sprint(*jobSpec.Parallelism))
-	}
-	if jobSpec.NoLoggingToStdout != nil &&
-		*jobSpec.NoLoggingToStdout == true {
-		jobArgs = append(jobArgs, "--sysoutLogging")
-	}
-
+	// The job is submitted with the parallelism decided by the autoscaler.
+	var submittedJobSpec = jobSpec.DeepCopy()
+	submittedJobSpec.Parallelism = getJobParallelism(flinkCluster)
+	var jobArgs, submitEnvVars = getSubmitArgs(
+		submittedJobSpec,
+		jobManagerAddress,
+		getFromSavepoint(flinkCluster),
+		false /* detached */)
 	var envVars = []corev1.EnvVar{}
 	envVars = append(envVars, flinkCluster.Spec.EnvVars...)
+	envVars = append(envVars, submitEnvVars...)
+	var volumes = append(
+		[]corev1.Volume{getConfigVolume(clusterName)}, jobSpec.Volumes...)
+	var mounts = append(getConfigVolumeMounts(flinkCluster), jobSpec.Mounts...)
+	var initContainers []corev1.Container
+	var fetcher, fetcherVolumes, artifactsMount = getArtifactFetcher(
+		jobSpec, jobArtifactsDir)
+	if fetcher != nil {
+		initContainers = []corev1.Container{*fetcher}
+		volumes = append(volumes, fetcherVolumes...)
+		mounts = append(mounts, *artifactsMount)
+	}
 
-	// If the JAR file is remote, put the URI in the env variable
-	// FLINK_JOB_JAR_URI and rewrite the JAR path to a local path. The entrypoint
-	// script of the container will download it before submitting it to Flink.
-	var jarPath = jobSpec.JarFile
-	if strings.Contains(jobSpec.JarFile, "://") {
-		var parts = strings.Split(jobSpec.JarFile, "/")
-		jarPath = "/opt/flink/job/" + parts[len(parts)-1]
-		envVars = append(envVars, corev1.EnvVar{
-			Name:  "FLINK_JOB_JAR_URI",
-			Value: jobSpec.JarFile,
-		})
+	// The operator restarts the failed job from its latest checkpoint, instead
+	// of the submitter being restarted with the original arguments.
+	var restartPolicy = *jobSpec.RestartPolicy
+	var backoffLimit *int32
+	if isRestartFromSavepointEnabled(flinkCluster) {
+		restartPolicy = corev1.RestartPolicyNever
+		backoffLimit = new(int32)
 	}
-	jobArgs = append(jobArgs, jarPath)
 
-	jobArgs = append(jobArgs, jobSpec.Args...)
 	var job = &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: clusterNamespace,
@@ -372,13 +985,18 @@ func getDesiredJob(
 			OwnerReferences: []metav1.OwnerReference{
 				toOwnerReference(flinkCluster)},
 			Labels: labels,
+			Annotations: map[string]string{
+				jobSpecHashAnnotation: getJobSpecHash(flinkCluster),
+			},
 		},
 		Spec: batchv1.JobSpec{
+			BackoffLimit: backoffLimit,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: labels,
 				},
 				Spec: corev1.PodSpec{
+					InitContainers: initContainers,
 					Containers: []corev1.Container{
 						corev1.Container{
 							Name:            "main",
@@ -386,17 +1004,281 @@ func getDesiredJob(
 							ImagePullPolicy: imageSpec.PullPolicy,
 							Args:            jobArgs,
 							Env:             envVars,
-							VolumeMounts:    jobSpec.Mounts,
+							VolumeMounts:    mounts,
 						},
 					},
-					RestartPolicy:    *jobSpec.RestartPolicy,
-					Volumes:          jobSpec.Volumes,
+					RestartPolicy:    restartPolicy,
+					Volumes:          volumes,
 					ImagePullSecrets: imageSpec.PullSecrets,
 				},
 			},
 		},
 	}
-	return job
+	var err = applyPodTemplate(&job.Spec.Template, jobSpec.PodTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Gets the arguments which submit the job to the JobManager at the address, and
+// the env variables required by the arguments. SQL scripts are run by the SQL
+// client, JAR files and Python jobs by `flink run`.
+func getSubmitArgs(
+	jobSpec *flinkoperatorv1alpha1.JobSpec,
+	jobManagerAddress string,
+	fromSavepoint *string,
+	detached bool) ([]string, []corev1.EnvVar) {
+	if jobSpec.SQLScript != nil {
+		return getSQLClientArgs(
+			jobSpec, jobManagerAddress, fromSavepoint, detached)
+	}
+	return getFlinkRunArgs(jobSpec, jobManagerAddress, fromSavepoint, detached)
+}
+
+// Gets the arguments of `flink run` which submits the job to the JobManager
+// at the address, and the env variables required by the arguments.
+func getFlinkRunArgs(
+	jobSpec *flinkoperatorv1alpha1.JobSpec,
+	jobManagerAddress string,
+	fromSavepoint *string,
+	detached bool) ([]string, []corev1.EnvVar) {
+	var jobArgs = []string{"./bin/flink", "run"}
+	var envVars = []corev1.EnvVar{}
+	jobArgs = append(jobArgs, "--jobmanager", jobManagerAddress)
+	if detached {
+		jobArgs = append(jobArgs, "--detached")
+	}
+	if jobSpec.ClassName != nil {
+		jobArgs = append(jobArgs, "--class", *jobSpec.ClassName)
+	}
+	if fromSavepoint != nil {
+		jobArgs = append(jobArgs, "--fromSavepoint", *fromSavepoint)
+	}
+	if jobSpec.AllowNonRestoredState != nil &&
+		*jobSpec.AllowNonRestoredState == true {
+		jobArgs = append(jobArgs, "--allowNonRestoredState")
+	}
+	if jobSpec.Parallelism != nil {
+		jobArgs = append(
+			jobArgs, "--parallelism", fmt.Sprint(*jobSpec.Parallelism))
+	}
+	if jobSpec.NoLoggingToStdout != nil &&
+		*jobSpec.NoLoggingToStdout == true {
+		jobArgs = append(jobArgs, "--sysoutLogging")
+	}
+
+	if len(jobSpec.PyFiles) > 0 {
+		jobArgs = append(
+			jobArgs, "--pyFiles", strings.Join(jobSpec.PyFiles, ","))
+	}
+	if jobSpec.PyRequirements != nil {
+		jobArgs = append(jobArgs, "--pyRequirements", *jobSpec.PyRequirements)
+	}
+
+	switch {
+	case jobSpec.PyFile != nil:
+		jobArgs = append(jobArgs, "--python", *jobSpec.PyFile)
+	case jobSpec.PyModule != nil:
+		jobArgs = append(jobArgs, "--pyModule", *jobSpec.PyModule)
+	default:
+		// The remote JAR file is fetched into the artifacts directory by the
+		// init container of the submitter.
+		var jarPath = jobSpec.JarFile
+		if isRemoteArtifact(jobSpec.JarFile) {
+			jarPath = getArtifactPath(jobSpec.JarFile, jobArtifactsDir)
+		}
+		jobArgs = append(jobArgs, jarPath)
+	}
+
+	jobArgs = append(jobArgs, jobSpec.Args...)
+	return jobArgs, envVars
+}
+
+// Gets the arguments of the SQL client which runs the SQL script of the job
+// against the JobManager at the address, and the env variable which holds the
+// script. The SQL client only takes the script from a file, so it is written
+// to a file by the shell before the client is started. The job options are
+// passed as dynamic properties.
+func getSQLClientArgs(
+	jobSpec *flinkoperatorv1alpha1.JobSpec,
+	jobManagerAddress string,
+	fromSavepoint *string,
+	detached bool) ([]string, []corev1.EnvVar) {
+	var host, port, _ = net.SplitHostPort(jobManagerAddress)
+	var clientArgs = []string{
+		"./bin/sql-client.sh",
+		"-Drest.address=" + host,
+		"-Drest.port=" + port,
+	}
+	// The SQL client returns once the statements are submitted, unless it is
+	// told to wait for them to finish.
+	if !detached {
+		clientArgs = append(clientArgs, "-Dtable.dml-sync=true")
+	}
+	if fromSavepoint != nil {
+		clientArgs = append(
+			clientArgs, "-Dexecution.savepoint.path="+*fromSavepoint)
+	}
+	if jobSpec.AllowNonRestoredState != nil &&
+		*jobSpec.AllowNonRestoredState == true {
+		clientArgs = append(
+			clientArgs, "-Dexecution.savepoint.ignore-unclaimed-state=true")
+	}
+	if jobSpec.Parallelism != nil {
+		clientArgs = append(clientArgs,
+			fmt.Sprintf("-Dparallelism.default=%d", *jobSpec.Parallelism))
+	}
+	clientArgs = append(clientArgs, jobSpec.Args...)
+	clientArgs = append(clientArgs, "-f", sqlScriptFile)
+
+	var scriptEnvVar = corev1.EnvVar{Name: "FLINK_SQL_SCRIPT"}
+	var sqlScript = jobSpec.SQLScript
+	if sqlScript.ConfigMap != nil {
+		scriptEnvVar.ValueFrom = &corev1.EnvVarSource{
+			ConfigMapKeyRef: sqlScript.ConfigMap,
+		}
+	} else if sqlScript.Inline != nil {
+		scriptEnvVar.Value = *sqlScript.Inline
+	}
+
+	// The client is run with the positional arguments of the shell.
+	var args = []string{"/bin/sh", "-c", sqlScriptCommand, "sh"}
+	args = append(args, clientArgs...)
+	return args, []corev1.EnvVar{scriptEnvVar}
+}
+
+// Gets the arguments of `standalone-job` which runs the job inside JobManager
+// in Application mode. Flink loads the job from the JAR files in the usrlib
+// directory, so the JAR file is not passed.
+func getStandaloneJobArgs(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) []string {
+	var jobSpec = flinkCluster.Spec.JobSpec
+	var args = []string{"standalone-job"}
+	if jobSpec.ClassName != nil {
+		args = append(args, "--job-classname", *jobSpec.ClassName)
+	}
+	var fromSavepoint = getFromSavepoint(flinkCluster)
+	if fromSavepoint != nil {
+		args = append(args, "--fromSavepoint", *fromSavepoint)
+	}
+	if jobSpec.AllowNonRestoredState != nil &&
+		*jobSpec.AllowNonRestoredState == true {
+		args = append(args, "--allowNonRestoredState")
+	}
+	return args
+}
+
+// Gets the savepoint where to restore the job from. The savepoint taken by the
+// operator for the last upgrade or restart takes precedence over the one in the
+// job spec, unless the latter has been changed since.
+func getFromSavepoint(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *string {
+	var jobStatus = flinkCluster.Status.Components.Job
+	if jobStatus != nil && len(jobStatus.FromSavepoint) > 0 &&
+		!isSpecSavepointChanged(flinkCluster) {
+		return &jobStatus.FromSavepoint
+	}
+	return flinkCluster.Spec.JobSpec.Savepoint
+}
+
+// Checks whether a savepoint has been set in the job spec since the job was
+// last submitted. Removing the savepoint from the spec does not count, the job
+// keeps its recorded savepoint.
+func isSpecSavepointChanged(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+	var savepoint = flinkCluster.Spec.JobSpec.Savepoint
+	var jobStatus = flinkCluster.Status.Components.Job
+	return savepoint != nil && len(*savepoint) > 0 &&
+		jobStatus != nil && jobStatus.SpecSavepoint != nil &&
+		*jobStatus.SpecSavepoint != *savepoint
+}
+
+// Gets the parallelism of the job. The parallelism decided by the autoscaler
+// takes precedence over the one in the job spec.
+func getJobParallelism(flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *int32 {
+	var jobSpec = flinkCluster.Spec.JobSpec
+	var jobStatus = flinkCluster.Status.Components.Job
+	if jobSpec.Autoscaler != nil && jobStatus != nil && jobStatus.Parallelism > 0 {
+		var parallelism = jobStatus.Parallelism
+		return &parallelism
+	}
+	return jobSpec.Parallelism
+}
+
+// Gets the number of TaskManager replicas. With the autoscaler, it is derived
+// from the parallelism of the job and the number of task slots of each
+// TaskManager.
+func getTaskManagerReplicas(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) *int32 {
+	var replicas = flinkCluster.Spec.TaskManagerSpec.Replicas
+	var jobSpec = flinkCluster.Spec.JobSpec
+	if jobSpec != nil && jobSpec.Autoscaler != nil {
+		var parallelism = getJobParallelism(flinkCluster)
+		var slots = getTaskSlots(flinkCluster)
+		if parallelism != nil {
+			replicas = (*parallelism + slots - 1) / slots
+		}
+	}
+	return &replicas
+}
+
+// Gets the number of task slots of each TaskManager, 1 unless it is set in the
+// Flink properties.
+func getTaskSlots(flinkCluster *flinkoperatorv1alpha1.FlinkCluster) int32 {
+	var value, ok = flinkCluster.Spec.FlinkProperties["taskmanager.numberOfTaskSlots"]
+	if ok {
+		var slots, err = strconv.Atoi(strings.TrimSpace(value))
+		if err == nil && slots > 0 {
+			return int32(slots)
+		}
+	}
+	return 1
+}
+
+// Gets the hash of the parts of the cluster spec which require the job to be
+// resubmitted when changed, that is, the job itself and everything which
+// causes JobManager to be restarted.
+func getJobSpecHash(flinkCluster *flinkoperatorv1alpha1.FlinkCluster) string {
+	var spec = flinkCluster.Spec
+	// The restarts and the savepoint on deletion do not affect the running job.
+	var jobSpec = spec.JobSpec
+	if jobSpec != nil {
+		jobSpec = jobSpec.DeepCopy()
+		jobSpec.MaxRestartAttempts = nil
+		jobSpec.RestartBackoffSeconds = nil
+		jobSpec.TakeSavepointOnDelete = nil
+		jobSpec.SavepointOnDeleteTimeoutSeconds = nil
+		// The job is resubmitted when the autoscaler changes its parallelism,
+		// but not when only the options of the autoscaler change.
+		jobSpec.Autoscaler = nil
+		jobSpec.Parallelism = getJobParallelism(flinkCluster)
+	}
+	var hashedSpec = struct {
+		ImageSpec       flinkoperatorv1alpha1.ImageSpec
+		JobManagerSpec  flinkoperatorv1alpha1.JobManagerSpec
+		JobSpec         *flinkoperatorv1alpha1.JobSpec
+		FlinkProperties map[string]string
+		LogConfig       map[string]string
+		EnvVars         []corev1.EnvVar
+	}{
+		ImageSpec:       spec.ImageSpec,
+		JobManagerSpec:  spec.JobManagerSpec,
+		JobSpec:         jobSpec,
+		FlinkProperties: spec.FlinkProperties,
+		LogConfig:       spec.LogConfig,
+		EnvVars:         spec.EnvVars,
+	}
+	return getHash(hashedSpec)
+}
+
+// Gets the hash of the JSON encoding of the object. Map keys are sorted by the
+// JSON encoder, so the hash is stable.
+func getHash(object interface{}) string {
+	var objectJSON, _ = json.Marshal(object)
+	var hasher = fnv.New32a()
+	hasher.Write(objectJSON)
+	return fmt.Sprint(hasher.Sum32())
 }
 
 // Converts the FlinkCluster as owner reference for its child resources.
@@ -412,6 +1294,11 @@ func toOwnerReference(
 	}
 }
 
+// Gets ConfigMap name
+func getConfigMapName(clusterName string) string {
+	return clusterName + "-configmap"
+}
+
 // Gets JobManager deployment name
 func getJobManagerDeploymentName(clusterName string) string {
 	return clusterName + "-jobmanager"
@@ -422,21 +1309,288 @@ func getJobManagerServiceName(clusterName string) string {
 	return clusterName + "-jobmanager"
 }
 
+// Gets the base URL of the Flink REST API of the cluster.
+func getFlinkAPIBaseURL(
+	cluster *flinkoperatorv1alpha1.FlinkCluster) string {
+	return fmt.Sprintf(
+		"http://%s.%s.svc.cluster.local:%d",
+		getJobManagerServiceName(cluster.ObjectMeta.Name),
+		cluster.ObjectMeta.Namespace,
+		*cluster.Spec.JobManagerSpec.Ports.UI)
+}
+
+// Checks whether the job of the cluster is run inside JobManager.
+func isApplicationMode(cluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+	var jobSpec = cluster.Spec.JobSpec
+	return jobSpec != nil && jobSpec.Mode != nil &&
+		*jobSpec.Mode == flinkoperatorv1alpha1.JobMode.Application
+}
+
+// Checks whether the TaskManagers of the cluster are run as a StatefulSet.
+func isTaskManagerStatefulSet(cluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+	var deploymentType = cluster.Spec.TaskManagerSpec.DeploymentType
+	return deploymentType != nil && *deploymentType ==
+		flinkoperatorv1alpha1.TaskManagerDeploymentType.StatefulSet
+}
+
+// Checks whether the failed job of the cluster is restarted from its latest
+// checkpoint or savepoint by the operator.
+func isRestartFromSavepointEnabled(
+	cluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+	var jobSpec = cluster.Spec.JobSpec
+	return jobSpec != nil && jobSpec.RestartPolicy != nil &&
+		string(*jobSpec.RestartPolicy) ==
+			flinkoperatorv1alpha1.JobRestartPolicy.FromSavepointOnFailure
+}
+
+// Checks whether the job of the cluster is cancelled with a savepoint before
+// the cluster is deleted.
+func isSavepointOnDeleteEnabled(
+	cluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+	var jobSpec = cluster.Spec.JobSpec
+	return jobSpec != nil && jobSpec.TakeSavepointOnDelete != nil &&
+		*jobSpec.TakeSavepointOnDelete
+}
+
+// Gets JobManager ingress name
+func getJobManagerIngressName(clusterName string) string {
+	return clusterName + "-jobmanager"
+}
+
 // Gets TaskManager name
 func getTaskManagerDeploymentName(clusterName string) string {
 	return clusterName + "-taskmanager"
 }
 
+// Gets the name of the deployment of a TaskManager pool
+func getTaskManagerPoolDeploymentName(clusterName string, poolName string) string {
+	return clusterName + "-taskmanager-" + poolName
+}
+
+// Gets TaskManager StatefulSet name
+func getTaskManagerStatefulSetName(clusterName string) string {
+	return clusterName + "-taskmanager"
+}
+
+// Gets the name of the headless TaskManager service
+func getTaskManagerServiceName(clusterName string) string {
+	return clusterName + "-taskmanager"
+}
+
 // Gets Job name
+// Gets the labels of the TaskManager deployment and its pods.
+func getTaskManagerLabels(clusterName string) map[string]string {
+	return map[string]string{
+		"cluster":   clusterName,
+		"app":       "flink",
+		"component": "taskmanager",
+	}
+}
+
+// Gets the selector of the TaskManager deployment. The pods of the pools have
+// its labels too, so they are excluded. The selector is immutable, so they are
+// excluded even if the cluster has no pools yet.
+func getTaskManagerSelector(clusterName string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: getTaskManagerLabels(clusterName),
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      taskManagerPoolLabel,
+			Operator: metav1.LabelSelectorOpDoesNotExist,
+		}},
+	}
+}
+
+// Gets the labels of the deployment of a TaskManager pool and its pods. They
+// include the labels of the other TaskManagers, so the pods of the pools are
+// selected along with them, e.g., by the PodMonitor.
+func getTaskManagerPoolLabels(
+	clusterName string, poolName string) map[string]string {
+	var labels = getTaskManagerLabels(clusterName)
+	labels[taskManagerPoolLabel] = poolName
+	return labels
+}
+
 func getJobName(clusterName string) string {
 	return clusterName + "-job"
 }
 
-// Gets Flink properties
+// Gets the Flink properties of the cluster, which are generated from the
+// spec, e.g., the address and ports of JobManager and high availability, and
+// overridden by the Flink properties in the spec, so advanced options can
+// still be tuned by users.
+func getDesiredFlinkProperties(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) map[string]string {
+	var jmPorts = flinkCluster.Spec.JobManagerSpec.Ports
+	var tmPorts = flinkCluster.Spec.TaskManagerSpec.Ports
+	var properties = map[string]string{
+		"jobmanager.rpc.address": getJobManagerServiceName(
+			flinkCluster.ObjectMeta.Name),
+		"jobmanager.rpc.port":   fmt.Sprint(*jmPorts.RPC),
+		"blob.server.port":      fmt.Sprint(*jmPorts.Blob),
+		"query.server.port":     fmt.Sprint(*jmPorts.Query),
+		"rest.port":             fmt.Sprint(*jmPorts.UI),
+		"taskmanager.data.port": fmt.Sprint(*tmPorts.Data),
+		"taskmanager.rpc.port":  fmt.Sprint(*tmPorts.RPC),
+	}
+	var haSpec = flinkCluster.Spec.HighAvailability
+	if haSpec != nil {
+		var clusterID = getHaClusterID(flinkCluster)
+		properties["high-availability.storageDir"] = haSpec.StorageDir
+		properties["high-availability.cluster-id"] = clusterID
+		// The RPC port of JobManager is random with high availability unless
+		// it is set explicitly.
+		properties["high-availability.jobmanager.port"] =
+			fmt.Sprint(*flinkCluster.Spec.JobManagerSpec.Ports.RPC)
+		switch haSpec.Mode {
+		case flinkoperatorv1alpha1.HighAvailabilityMode.Kubernetes:
+			properties["high-availability"] = kubernetesHaServicesFactory
+			properties["kubernetes.cluster-id"] = clusterID
+			properties["kubernetes.namespace"] = flinkCluster.ObjectMeta.Namespace
+		case flinkoperatorv1alpha1.HighAvailabilityMode.ZooKeeper:
+			properties["high-availability"] = "zookeeper"
+			properties["high-availability.zookeeper.quorum"] =
+				*haSpec.ZooKeeperQuorum
+		}
+	}
+	// JobManager keeps running once the job terminates in Application mode,
+	// otherwise the job would be run again when the pod is restarted.
+	if isApplicationMode(flinkCluster) {
+		properties["execution.shutdown-on-application-finish"] = "false"
+		var parallelism = getJobParallelism(flinkCluster)
+		if parallelism != nil {
+			properties["parallelism.default"] = fmt.Sprint(*parallelism)
+		}
+	}
+	if monitoringSpec := flinkCluster.Spec.Monitoring; monitoringSpec != nil {
+		properties["metrics.reporter.prom.class"] = prometheusReporterClass
+		properties["metrics.reporter.prom.port"] = fmt.Sprint(*monitoringSpec.Port)
+	}
+	for key, value := range flinkCluster.Spec.FlinkProperties {
+		properties[key] = value
+	}
+	return properties
+}
+
+// Gets the port of the Prometheus reporter of the JobManager and TaskManager
+// containers, returns nil if monitoring is disabled.
+func getMetricsContainerPorts(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) []corev1.ContainerPort {
+	var monitoringSpec = flinkCluster.Spec.Monitoring
+	if monitoringSpec == nil {
+		return nil
+	}
+	return []corev1.ContainerPort{
+		{Name: metricsPortName, ContainerPort: *monitoringSpec.Port},
+	}
+}
+
+// Gets the content of flink-conf.yaml from the Flink properties.
 func getFlinkProperties(properties map[string]string) string {
+	// Sort the keys, so the file does not change between reconcile requests.
+	var keys = []string{}
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 	var builder strings.Builder
-	for key, value := range properties {
-		builder.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+	for _, key := range keys {
+		builder.WriteString(fmt.Sprintf("%s: %s\n", key, properties[key]))
 	}
 	return builder.String()
 }
+
+// Gets the config files of the cluster keyed by the file name, which are
+// flink-conf.yaml and the logging config files in the spec.
+func getConfigFiles(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) map[string]string {
+	var configFiles = map[string]string{}
+	for fileName, content := range flinkCluster.Spec.LogConfig {
+		configFiles[fileName] = content
+	}
+	configFiles["flink-conf.yaml"] =
+		getFlinkProperties(getDesiredFlinkProperties(flinkCluster))
+	return configFiles
+}
+
+// Gets the annotations of the pod templates which record the hash of the
+// config files.
+func getConfigHashAnnotations(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) map[string]string {
+	return map[string]string{
+		configHashAnnotation: getHash(getConfigFiles(flinkCluster)),
+	}
+}
+
+// Gets the volume of the ConfigMap which holds the config files.
+func getConfigVolume(clusterName string) corev1.Volume {
+	return corev1.Volume{
+		Name: configVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: getConfigMapName(clusterName),
+				},
+			},
+		},
+	}
+}
+
+// Gets the mounts of the config files. Each file is mounted separately, so
+// the other files in the conf directory of the image are kept.
+func getConfigVolumeMounts(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) []corev1.VolumeMount {
+	var fileNames = []string{}
+	for fileName := range getConfigFiles(flinkCluster) {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	var mounts = []corev1.VolumeMount{}
+	for _, fileName := range fileNames {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      configVolumeName,
+			MountPath: flinkConfDir + "/" + fileName,
+			SubPath:   fileName,
+		})
+	}
+	return mounts
+}
+
+// Checks whether Kubernetes HA is enabled for the cluster.
+func isKubernetesHaEnabled(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+	var haSpec = flinkCluster.Spec.HighAvailability
+	return haSpec != nil &&
+		haSpec.Mode == flinkoperatorv1alpha1.HighAvailabilityMode.Kubernetes
+}
+
+// Gets the ID of the cluster in the HA services.
+func getHaClusterID(flinkCluster *flinkoperatorv1alpha1.FlinkCluster) string {
+	var haSpec = flinkCluster.Spec.HighAvailability
+	if haSpec.ClusterID != nil {
+		return *haSpec.ClusterID
+	}
+	return flinkCluster.ObjectMeta.Namespace + "-" + flinkCluster.ObjectMeta.Name
+}
+
+// Gets the name of the ConfigMap in which Kubernetes HA records the leader of
+// the REST endpoints of JobManagers.
+func getHaLeaderConfigMapName(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) string {
+	return getHaClusterID(flinkCluster) + "-restserver-leader"
+}
+
+// Gets the name of the service account, role and role binding for Kubernetes
+// HA.
+func getHaResourceName(clusterName string) string {
+	return clusterName + "-ha"
+}
+
+// Gets the service account of the JobManager and TaskManager pods, empty for
+// the default service account.
+func getHaServiceAccountName(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster) string {
+	if !isKubernetesHaEnabled(flinkCluster) {
+		return ""
+	}
+	return getHaResourceName(flinkCluster.ObjectMeta.Name)
+}
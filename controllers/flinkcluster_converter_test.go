@@ -17,8 +17,10 @@ limitations under the License.
 package controllers
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
 	"gotest.tools/assert"
@@ -26,8 +28,10 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
@@ -115,14 +119,75 @@ func TestGetDesiredClusterState(t *testing.T) {
 				},
 			},
 			FlinkProperties: map[string]string{"taskmanager.numberOfTaskSlots": "1"},
-			EnvVars:         []corev1.EnvVar{{Name: "FOO", Value: "abc"}},
+			LogConfig: map[string]string{
+				"log4j-console.properties": "log4j.rootLogger=INFO, console\n",
+			},
+			EnvVars: []corev1.EnvVar{{Name: "FOO", Value: "abc"}},
 		},
 	}
 
 	// Run.
-	var desiredState = getDesiredClusterState(cluster)
+	var desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
 
 	// Verify.
+	var configHash = getConfigHashAnnotations(cluster)
+	var configVolume = corev1.Volume{
+		Name: "flink-config-volume",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: "flinkjobcluster-sample-configmap",
+				},
+			},
+		},
+	}
+	var configMounts = []corev1.VolumeMount{
+		{
+			Name:      "flink-config-volume",
+			MountPath: "/opt/flink/conf/flink-conf.yaml",
+			SubPath:   "flink-conf.yaml",
+		},
+		{
+			Name:      "flink-config-volume",
+			MountPath: "/opt/flink/conf/log4j-console.properties",
+			SubPath:   "log4j-console.properties",
+		},
+	}
+
+	// ConfigMap
+	var expectedDesiredConfigMap = corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "flinkjobcluster-sample-configmap",
+			Namespace: "default",
+			Labels: map[string]string{
+				"app":     "flink",
+				"cluster": "flinkjobcluster-sample",
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         "flinkoperator.k8s.io/v1alpha1",
+					Kind:               "FlinkCluster",
+					Name:               "flinkjobcluster-sample",
+					Controller:         &controller,
+					BlockOwnerDeletion: &blockOwnerDeletion,
+				},
+			},
+		},
+		Data: map[string]string{
+			"flink-conf.yaml": "blob.server.port: 6124\n" +
+				"jobmanager.rpc.address: flinkjobcluster-sample-jobmanager\n" +
+				"jobmanager.rpc.port: 6123\n" +
+				"query.server.port: 6125\n" +
+				"rest.port: 8081\n" +
+				"taskmanager.data.port: 6121\n" +
+				"taskmanager.numberOfTaskSlots: 1\n" +
+				"taskmanager.rpc.port: 6122\n",
+			"log4j-console.properties": "log4j.rootLogger=INFO, console\n",
+		},
+	}
+	assert.Assert(t, desiredState.ConfigMap != nil)
+	assert.DeepEqual(t, *desiredState.ConfigMap, expectedDesiredConfigMap)
 
 	// JmDeployment
 	var expectedDesiredJmDeployment = appsv1.Deployment{
@@ -159,6 +224,7 @@ func TestGetDesiredClusterState(t *testing.T) {
 						"cluster":   "flinkjobcluster-sample",
 						"component": "jobmanager",
 					},
+					Annotations: configHash,
 				},
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
@@ -173,10 +239,6 @@ func TestGetDesiredClusterState(t *testing.T) {
 								{Name: "ui", ContainerPort: jmUIPort},
 							},
 							Env: []corev1.EnvVar{
-								{
-									Name:  "JOB_MANAGER_RPC_ADDRESS",
-									Value: "flinkjobcluster-sample-jobmanager",
-								},
 								{
 									Name: "JOB_MANAGER_CPU_LIMIT",
 									ValueFrom: &corev1.EnvVarSource{
@@ -197,10 +259,6 @@ func TestGetDesiredClusterState(t *testing.T) {
 										},
 									},
 								},
-								{
-									Name:  "FLINK_PROPERTIES",
-									Value: "taskmanager.numberOfTaskSlots: 1\n",
-								},
 								{
 									Name:  "FOO",
 									Value: "abc",
@@ -216,8 +274,10 @@ func TestGetDesiredClusterState(t *testing.T) {
 									"Memory": resource.MustParse("512Mi"),
 								},
 							},
+							VolumeMounts: configMounts,
 						},
 					},
+					Volumes: []corev1.Volume{configVolume},
 				},
 			},
 		},
@@ -302,6 +362,10 @@ func TestGetDesiredClusterState(t *testing.T) {
 					"cluster":   "flinkjobcluster-sample",
 					"component": "taskmanager",
 				},
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      "taskmanager-pool",
+					Operator: metav1.LabelSelectorOpDoesNotExist,
+				}},
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
@@ -310,6 +374,7 @@ func TestGetDesiredClusterState(t *testing.T) {
 						"cluster":   "flinkjobcluster-sample",
 						"component": "taskmanager",
 					},
+					Annotations: configHash,
 				},
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
@@ -323,10 +388,6 @@ func TestGetDesiredClusterState(t *testing.T) {
 								{Name: "query", ContainerPort: 6125},
 							},
 							Env: []corev1.EnvVar{
-								{
-									Name:  "JOB_MANAGER_RPC_ADDRESS",
-									Value: "flinkjobcluster-sample-jobmanager",
-								},
 								{
 									Name: "TASK_MANAGER_CPU_LIMIT",
 									ValueFrom: &corev1.EnvVarSource{
@@ -347,10 +408,6 @@ func TestGetDesiredClusterState(t *testing.T) {
 										},
 									},
 								},
-								{
-									Name:  "FLINK_PROPERTIES",
-									Value: "taskmanager.numberOfTaskSlots: 1\n",
-								},
 								{
 									Name:  "FOO",
 									Value: "abc",
@@ -366,13 +423,14 @@ func TestGetDesiredClusterState(t *testing.T) {
 									"Memory": resource.MustParse("1Gi"),
 								},
 							},
-							VolumeMounts: []v1.VolumeMount{
-								{Name: "cache-volume", MountPath: "/cache"},
-							},
+							VolumeMounts: append(
+								configMounts,
+								v1.VolumeMount{Name: "cache-volume", MountPath: "/cache"}),
 						},
 						corev1.Container{Name: "sidecar", Image: "alpine"},
 					},
 					Volumes: []corev1.Volume{
+						configVolume,
 						{
 							Name: "cache-volume",
 							VolumeSource: corev1.VolumeSource{
@@ -399,6 +457,9 @@ func TestGetDesiredClusterState(t *testing.T) {
 			Namespace: "default",
 			Labels: map[string]string{
 				"app": "flink", "cluster": "flinkjobcluster-sample"},
+			Annotations: map[string]string{
+				"flinkoperator.k8s.io/job-spec-hash": getJobSpecHash(cluster),
+			},
 			OwnerReferences: []metav1.OwnerReference{
 				{APIVersion: "flinkoperator.k8s.io/v1alpha1",
 					Kind:               "FlinkCluster",
@@ -431,10 +492,12 @@ func TestGetDesiredClusterState(t *testing.T) {
 								"--input",
 								"./README.txt",
 							},
-							Env: []v1.EnvVar{{Name: "FOO", Value: "abc"}},
+							Env:          []v1.EnvVar{{Name: "FOO", Value: "abc"}},
+							VolumeMounts: configMounts,
 						},
 					},
 					RestartPolicy: "OnFailure",
+					Volumes:       []corev1.Volume{configVolume},
 				},
 			},
 		},
@@ -446,3 +509,911 @@ func TestGetDesiredClusterState(t *testing.T) {
 		*desiredState.Job,
 		expectedDesiredJob)
 }
+
+func TestGetDesiredClusterStateErrors(t *testing.T) {
+	var port int32 = 6123
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				AccessScope: "Internet",
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{
+					RPC: &port, Blob: &port, Query: &port, UI: &port,
+				},
+			},
+			TaskManagerSpec: flinkoperatorv1alpha1.TaskManagerSpec{
+				Ports: flinkoperatorv1alpha1.TaskManagerPorts{
+					Data: &port, RPC: &port, Query: &port,
+				},
+			},
+		},
+	}
+
+	var _, err = getDesiredClusterState(cluster)
+	assert.Error(t, err, "unknown service access scope: Internet")
+
+	cluster.Spec.TaskManagerSpec.Ports.Data = nil
+	_, err = getDesiredClusterState(cluster)
+	assert.Error(
+		t,
+		err,
+		"spec.taskManager.ports.data is not set, "+
+			"check the defaulting webhook is enabled")
+}
+
+func TestGetDesiredClusterStateHighAvailability(t *testing.T) {
+	var port int32 = 6123
+	var uiPort int32 = 8081
+	var replicas int32 = 2
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				Replicas:    &replicas,
+				AccessScope: flinkoperatorv1alpha1.AccessScope.Cluster,
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{
+					RPC: &port, Blob: &port, Query: &port, UI: &uiPort,
+				},
+			},
+			TaskManagerSpec: flinkoperatorv1alpha1.TaskManagerSpec{
+				Ports: flinkoperatorv1alpha1.TaskManagerPorts{
+					Data: &port, RPC: &port, Query: &port,
+				},
+			},
+			HighAvailability: &flinkoperatorv1alpha1.HighAvailabilitySpec{
+				Mode:       flinkoperatorv1alpha1.HighAvailabilityMode.Kubernetes,
+				StorageDir: "gs://my-bucket/flink/ha",
+			},
+			FlinkProperties: map[string]string{"taskmanager.numberOfTaskSlots": "1"},
+		},
+	}
+
+	var desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+
+	assert.Equal(t, desiredState.HaServiceAccount.ObjectMeta.Name, "mycluster-ha")
+	assert.Equal(t, desiredState.HaRole.Rules[0].Resources[0], "configmaps")
+	assert.Equal(t, desiredState.HaRoleBinding.RoleRef.Name, "mycluster-ha")
+	assert.Equal(
+		t, desiredState.HaRoleBinding.Subjects[0].Name, "mycluster-ha")
+
+	var jmPodSpec = desiredState.JmDeployment.Spec.Template.Spec
+	assert.Equal(t, jmPodSpec.ServiceAccountName, "mycluster-ha")
+	assert.Equal(
+		t,
+		desiredState.TmDeployment.Spec.Template.Spec.ServiceAccountName,
+		"mycluster-ha")
+	var jmEnv = jmPodSpec.Containers[0].Env
+	assert.Equal(t, jmEnv[0].Name, "POD_IP")
+	assert.Equal(t, jmEnv[0].ValueFrom.FieldRef.FieldPath, "status.podIP")
+	assert.DeepEqual(
+		t, jmPodSpec.Containers[0].Args, []string{"jobmanager", "$(POD_IP)"})
+	assert.Equal(
+		t,
+		desiredState.ConfigMap.Data["flink-conf.yaml"],
+		"blob.server.port: 6123\n"+
+			"high-availability: "+kubernetesHaServicesFactory+"\n"+
+			"high-availability.cluster-id: default-mycluster\n"+
+			"high-availability.jobmanager.port: 6123\n"+
+			"high-availability.storageDir: gs://my-bucket/flink/ha\n"+
+			"jobmanager.rpc.address: mycluster-jobmanager\n"+
+			"jobmanager.rpc.port: 6123\n"+
+			"kubernetes.cluster-id: default-mycluster\n"+
+			"kubernetes.namespace: default\n"+
+			"query.server.port: 6123\n"+
+			"rest.port: 8081\n"+
+			"taskmanager.data.port: 6123\n"+
+			"taskmanager.numberOfTaskSlots: 1\n"+
+			"taskmanager.rpc.port: 6123\n")
+
+	// ZooKeeper HA does not require access to Kubernetes resources.
+	var quorum = "zk-0.zk:2181"
+	cluster.Spec.HighAvailability.Mode =
+		flinkoperatorv1alpha1.HighAvailabilityMode.ZooKeeper
+	cluster.Spec.HighAvailability.ZooKeeperQuorum = &quorum
+	desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+	assert.Assert(t, desiredState.HaServiceAccount == nil)
+	assert.Assert(t, desiredState.HaRole == nil)
+	assert.Assert(t, desiredState.HaRoleBinding == nil)
+	assert.Equal(
+		t, desiredState.JmDeployment.Spec.Template.Spec.ServiceAccountName, "")
+	assert.Equal(
+		t,
+		getDesiredFlinkProperties(cluster)["high-availability.zookeeper.quorum"],
+		quorum)
+}
+
+func TestGetDesiredClusterStateJobManagerIngress(t *testing.T) {
+	var port int32 = 6123
+	var uiPort int32 = 8081
+	var hostFormat = "{{$clusterName}}.{{$clusterNamespace}}.example.com"
+	var path = "/"
+	var ingressClass = "nginx"
+	var tlsSecretName = "example-tls"
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				AccessScope: flinkoperatorv1alpha1.AccessScope.Cluster,
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{
+					RPC: &port, Blob: &port, Query: &port, UI: &uiPort,
+				},
+				Ingress: &flinkoperatorv1alpha1.JobManagerIngressSpec{
+					HostFormat:    &hostFormat,
+					Path:          &path,
+					IngressClass:  &ingressClass,
+					TLSSecretName: &tlsSecretName,
+					Annotations: map[string]string{
+						"nginx.ingress.kubernetes.io/ssl-redirect": "true",
+					},
+				},
+			},
+			TaskManagerSpec: flinkoperatorv1alpha1.TaskManagerSpec{
+				Ports: flinkoperatorv1alpha1.TaskManagerPorts{
+					Data: &port, RPC: &port, Query: &port,
+				},
+			},
+		},
+	}
+
+	var desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+
+	var host = "mycluster.default.example.com"
+	assert.DeepEqual(
+		t,
+		*desiredState.JmIngress,
+		networkingv1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "mycluster-jobmanager",
+				OwnerReferences: []metav1.OwnerReference{
+					toOwnerReference(cluster)},
+				Labels: map[string]string{
+					"cluster":   "mycluster",
+					"app":       "flink",
+					"component": "jobmanager",
+				},
+				Annotations: map[string]string{
+					"kubernetes.io/ingress.class":              "nginx",
+					"nginx.ingress.kubernetes.io/ssl-redirect": "true",
+				},
+			},
+			Spec: networkingv1beta1.IngressSpec{
+				TLS: []networkingv1beta1.IngressTLS{
+					{Hosts: []string{host}, SecretName: "example-tls"},
+				},
+				Rules: []networkingv1beta1.IngressRule{
+					{
+						Host: host,
+						IngressRuleValue: networkingv1beta1.IngressRuleValue{
+							HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+								Paths: []networkingv1beta1.HTTPIngressPath{
+									{
+										Path: "/",
+										Backend: networkingv1beta1.IngressBackend{
+											ServiceName: "mycluster-jobmanager",
+											ServicePort: intstr.FromString("ui"),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+
+	// The ingress is deleted with the other components once the cluster is
+	// stopped.
+	cluster.Status.State = flinkoperatorv1alpha1.ClusterState.Stopped
+	desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+	assert.Assert(t, desiredState.JmIngress == nil)
+
+	// The path must have been defaulted.
+	cluster.Status.State = ""
+	cluster.Spec.JobManagerSpec.Ingress.Path = nil
+	_, err = getDesiredClusterState(cluster)
+	assert.Error(
+		t,
+		err,
+		"spec.jobManager.ingress.path is not set, check the defaulting webhook is enabled")
+}
+
+func TestGetDesiredClusterStateApplicationMode(t *testing.T) {
+	var port int32 = 6123
+	var uiPort int32 = 8081
+	var mode = flinkoperatorv1alpha1.JobMode.Application
+	var className = "com.example.MyJob"
+	var savepoint = "gs://my-bucket/savepoints/savepoint-1"
+	var allowNonRestoredState = true
+	var parallelism int32 = 4
+	var restartPolicy = corev1.RestartPolicy("OnFailure")
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				AccessScope: flinkoperatorv1alpha1.AccessScope.Cluster,
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{
+					RPC: &port, Blob: &port, Query: &port, UI: &uiPort,
+				},
+			},
+			TaskManagerSpec: flinkoperatorv1alpha1.TaskManagerSpec{
+				Ports: flinkoperatorv1alpha1.TaskManagerPorts{
+					Data: &port, RPC: &port, Query: &port,
+				},
+			},
+			JobSpec: &flinkoperatorv1alpha1.JobSpec{
+				Mode:                  &mode,
+				JarFile:               "/opt/flink/usrlib/my-job.jar",
+				ClassName:             &className,
+				Args:                  []string{"--input", "gs://my-bucket/input"},
+				Savepoint:             &savepoint,
+				AllowNonRestoredState: &allowNonRestoredState,
+				Parallelism:           &parallelism,
+				RestartPolicy:         &restartPolicy,
+				Volumes: []corev1.Volume{
+					{
+						Name: "usrlib",
+						VolumeSource: corev1.VolumeSource{
+							EmptyDir: &corev1.EmptyDirVolumeSource{},
+						},
+					},
+				},
+				Mounts: []corev1.VolumeMount{
+					{Name: "usrlib", MountPath: "/opt/flink/usrlib"},
+				},
+			},
+		},
+	}
+
+	var desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+
+	// The job is run inside JobManager, there is no submitter.
+	assert.Assert(t, desiredState.Job == nil)
+	var jmDeployment = desiredState.JmDeployment
+	assert.Equal(
+		t,
+		jmDeployment.Annotations[jobSpecHashAnnotation],
+		getJobSpecHash(cluster))
+	var jmPodSpec = jmDeployment.Spec.Template.Spec
+	assert.DeepEqual(
+		t,
+		jmPodSpec.Containers[0].Args,
+		[]string{
+			"standalone-job",
+			"--job-classname", className,
+			"--fromSavepoint", savepoint,
+			"--allowNonRestoredState",
+			"--input", "gs://my-bucket/input",
+		})
+	assert.Equal(t, jmPodSpec.Volumes[len(jmPodSpec.Volumes)-1].Name, "usrlib")
+	var jmMounts = jmPodSpec.Containers[0].VolumeMounts
+	assert.Equal(t, jmMounts[len(jmMounts)-1].MountPath, "/opt/flink/usrlib")
+	var properties = getDesiredFlinkProperties(cluster)
+	assert.Equal(t, properties["parallelism.default"], "4")
+	assert.Equal(
+		t, properties["execution.shutdown-on-application-finish"], "false")
+}
+
+func TestGetDesiredClusterStateMonitoring(t *testing.T) {
+	var port int32 = 6123
+	var uiPort int32 = 8081
+	var metricsPort int32 = 9249
+	var monitor = flinkoperatorv1alpha1.MonitorKind.PodMonitor
+	var scrapeInterval = "30s"
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				AccessScope: flinkoperatorv1alpha1.AccessScope.Cluster,
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{
+					RPC: &port, Blob: &port, Query: &port, UI: &uiPort,
+				},
+			},
+			TaskManagerSpec: flinkoperatorv1alpha1.TaskManagerSpec{
+				Ports: flinkoperatorv1alpha1.TaskManagerPorts{
+					Data: &port, RPC: &port, Query: &port,
+				},
+			},
+			Monitoring: &flinkoperatorv1alpha1.MonitoringSpec{
+				Port:           &metricsPort,
+				Monitor:        &monitor,
+				MonitorLabels:  map[string]string{"release": "prometheus"},
+				ScrapeInterval: &scrapeInterval,
+			},
+		},
+	}
+
+	var desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+
+	var properties = getDesiredFlinkProperties(cluster)
+	assert.Equal(
+		t,
+		properties["metrics.reporter.prom.class"],
+		"org.apache.flink.metrics.prometheus.PrometheusReporter")
+	assert.Equal(t, properties["metrics.reporter.prom.port"], "9249")
+	var metricsContainerPort = corev1.ContainerPort{
+		Name: "metrics", ContainerPort: 9249}
+	var jmPorts = desiredState.JmDeployment.Spec.Template.Spec.Containers[0].Ports
+	assert.DeepEqual(t, jmPorts[len(jmPorts)-1], metricsContainerPort)
+	var tmPorts = desiredState.TmDeployment.Spec.Template.Spec.Containers[0].Ports
+	assert.DeepEqual(t, tmPorts[len(tmPorts)-1], metricsContainerPort)
+	var servicePorts = desiredState.JmService.Spec.Ports
+	assert.DeepEqual(
+		t,
+		servicePorts[len(servicePorts)-1],
+		corev1.ServicePort{
+			Name:       "metrics",
+			Port:       9249,
+			TargetPort: intstr.FromString("metrics"),
+		})
+
+	assert.Assert(t, desiredState.ServiceMonitor == nil)
+	var podMonitor = desiredState.PodMonitor
+	assert.Equal(t, podMonitor.GetKind(), "PodMonitor")
+	assert.Equal(t, podMonitor.GetAPIVersion(), "monitoring.coreos.com/v1")
+	assert.Equal(t, podMonitor.GetName(), "mycluster-flink-metrics")
+	assert.Equal(t, podMonitor.GetOwnerReferences()[0].Name, "mycluster")
+	assert.DeepEqual(
+		t,
+		podMonitor.GetLabels(),
+		map[string]string{
+			"cluster": "mycluster", "app": "flink", "release": "prometheus"})
+	assert.DeepEqual(
+		t,
+		podMonitor.Object["spec"],
+		map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					"cluster": "mycluster",
+					"app":     "flink",
+				},
+				"matchExpressions": []interface{}{
+					map[string]interface{}{
+						"key":      "component",
+						"operator": "In",
+						"values":   []interface{}{"jobmanager", "taskmanager"},
+					},
+				},
+			},
+			"podMetricsEndpoints": []interface{}{
+				map[string]interface{}{"port": "metrics", "interval": "30s"},
+			},
+		})
+}
+
+func TestGetDesiredMonitorUnknownKind(t *testing.T) {
+	var kind = "Unknown"
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			Monitoring: &flinkoperatorv1alpha1.MonitoringSpec{Monitor: &kind},
+		},
+	}
+
+	var _, err = getDesiredMonitor(cluster, kind)
+	assert.Error(t, err, "unknown monitor kind: Unknown")
+}
+
+func TestGetDesiredClusterStatePodTemplate(t *testing.T) {
+	var port int32 = 6123
+	var uiPort int32 = 8081
+	var restartPolicy = corev1.RestartPolicy("OnFailure")
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			ImageSpec: flinkoperatorv1alpha1.ImageSpec{Name: "flink:1.8.1"},
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				AccessScope: flinkoperatorv1alpha1.AccessScope.Cluster,
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{
+					RPC: &port, Blob: &port, Query: &port, UI: &uiPort,
+				},
+				PodTemplate: &corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							"team":      "data",
+							"component": "web",
+						},
+					},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Name:  "jobmanager",
+								Image: "my-flink:latest",
+								Args:  []string{"bash"},
+								Env: []corev1.EnvVar{
+									{Name: "JOB_MANAGER_CPU_LIMIT", Value: "1000"},
+									{Name: "FOO", Value: "bar"},
+								},
+								LivenessProbe: &corev1.Probe{
+									Handler: corev1.Handler{
+										TCPSocket: &corev1.TCPSocketAction{
+											Port: intstr.FromString("rpc"),
+										},
+									},
+								},
+							},
+						},
+						Tolerations: []corev1.Toleration{
+							{Key: "dedicated", Value: "flink", Effect: "NoSchedule"},
+						},
+					},
+				},
+			},
+			TaskManagerSpec: flinkoperatorv1alpha1.TaskManagerSpec{
+				Ports: flinkoperatorv1alpha1.TaskManagerPorts{
+					Data: &port, RPC: &port, Query: &port,
+				},
+				PodTemplate: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						InitContainers: []corev1.Container{
+							{Name: "setup", Image: "busybox"},
+						},
+						PriorityClassName: "high-priority",
+					},
+				},
+			},
+			JobSpec: &flinkoperatorv1alpha1.JobSpec{
+				JarFile:       "/opt/flink/job/my-job.jar",
+				RestartPolicy: &restartPolicy,
+				PodTemplate: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						RestartPolicy:      corev1.RestartPolicyAlways,
+						ServiceAccountName: "job-submitter",
+					},
+				},
+			},
+		},
+	}
+
+	var desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+
+	// The override is merged, the fields of the operator are kept.
+	var jmPodTemplate = desiredState.JmDeployment.Spec.Template
+	assert.DeepEqual(
+		t,
+		jmPodTemplate.ObjectMeta.Labels,
+		map[string]string{
+			"cluster":   "mycluster",
+			"app":       "flink",
+			"component": "jobmanager",
+			"team":      "data",
+		})
+	assert.Equal(t, jmPodTemplate.Spec.Tolerations[0].Key, "dedicated")
+	assert.Equal(t, len(jmPodTemplate.Spec.Containers), 1)
+	var jmContainer = jmPodTemplate.Spec.Containers[0]
+	assert.Equal(t, jmContainer.Image, "flink:1.8.1")
+	assert.DeepEqual(t, jmContainer.Args, []string{"jobmanager"})
+	assert.Equal(t, jmContainer.LivenessProbe.TCPSocket.Port.StrVal, "rpc")
+	assert.Equal(t, len(jmContainer.Ports), 4)
+	var jmEnvVars = map[string]corev1.EnvVar{}
+	for _, envVar := range jmContainer.Env {
+		jmEnvVars[envVar.Name] = envVar
+	}
+	assert.Equal(t, len(jmEnvVars), 3)
+	assert.Equal(t, jmEnvVars["JOB_MANAGER_CPU_LIMIT"].Value, "")
+	assert.Equal(
+		t,
+		jmEnvVars["JOB_MANAGER_CPU_LIMIT"].ValueFrom.ResourceFieldRef.Resource,
+		"limits.cpu")
+	assert.Equal(t, jmEnvVars["FOO"].Value, "bar")
+	var _, hasConfigHash = jmPodTemplate.ObjectMeta.Annotations[configHashAnnotation]
+	assert.Assert(t, hasConfigHash)
+	assert.DeepEqual(
+		t,
+		desiredState.JmDeployment.Spec.Selector.MatchLabels,
+		map[string]string{
+			"cluster":   "mycluster",
+			"app":       "flink",
+			"component": "jobmanager",
+		})
+
+	var tmPodSpec = desiredState.TmDeployment.Spec.Template.Spec
+	assert.Equal(t, tmPodSpec.PriorityClassName, "high-priority")
+	assert.Equal(t, tmPodSpec.InitContainers[0].Name, "setup")
+	assert.Equal(t, tmPodSpec.Containers[0].Name, "taskmanager")
+	assert.DeepEqual(t, tmPodSpec.Containers[0].Args, []string{"taskmanager"})
+
+	var jobPodSpec = desiredState.Job.Spec.Template.Spec
+	assert.Equal(t, jobPodSpec.RestartPolicy, corev1.RestartPolicyOnFailure)
+	assert.Equal(t, jobPodSpec.ServiceAccountName, "job-submitter")
+	assert.Equal(t, jobPodSpec.Containers[0].Name, "main")
+}
+
+func TestGetDesiredClusterStateAutoscaler(t *testing.T) {
+	var port int32 = 6123
+	var uiPort int32 = 8081
+	var parallelism int32 = 2
+	var minParallelism int32 = 1
+	var targetUtilization int32 = 70
+	var stabilizationWindowSeconds int32 = 300
+	var restartPolicy = corev1.RestartPolicy("OnFailure")
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			ImageSpec: flinkoperatorv1alpha1.ImageSpec{Name: "flink:1.8.1"},
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				AccessScope: flinkoperatorv1alpha1.AccessScope.Cluster,
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{
+					RPC: &port, Blob: &port, Query: &port, UI: &uiPort,
+				},
+			},
+			TaskManagerSpec: flinkoperatorv1alpha1.TaskManagerSpec{
+				Replicas: 1,
+				Ports: flinkoperatorv1alpha1.TaskManagerPorts{
+					Data: &port, RPC: &port, Query: &port,
+				},
+			},
+			JobSpec: &flinkoperatorv1alpha1.JobSpec{
+				JarFile:       "/opt/flink/job/my-job.jar",
+				Parallelism:   &parallelism,
+				RestartPolicy: &restartPolicy,
+				Autoscaler: &flinkoperatorv1alpha1.JobAutoscalerSpec{
+					MinParallelism:             &minParallelism,
+					MaxParallelism:             8,
+					TargetUtilization:          &targetUtilization,
+					StabilizationWindowSeconds: &stabilizationWindowSeconds,
+				},
+			},
+			FlinkProperties: map[string]string{
+				"taskmanager.numberOfTaskSlots": "2",
+			},
+		},
+	}
+
+	// The parallelism in the job spec is the initial parallelism.
+	var desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+	assert.Equal(t, *desiredState.TmDeployment.Spec.Replicas, int32(1))
+	var initialHash = getJobSpecHash(cluster)
+
+	// The parallelism decided by the autoscaler takes precedence, the job is
+	// resubmitted with it along with enough TaskManagers.
+	cluster.Status.Components.Job = &flinkoperatorv1alpha1.JobStatus{
+		Parallelism: 5,
+	}
+	desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+	assert.Equal(t, *desiredState.TmDeployment.Spec.Replicas, int32(3))
+	var jobArgs = desiredState.Job.Spec.Template.Spec.Containers[0].Args
+	assert.DeepEqual(
+		t,
+		jobArgs[len(jobArgs)-3:],
+		[]string{"--parallelism", "5", "/opt/flink/job/my-job.jar"})
+	assert.Assert(t, getJobSpecHash(cluster) != initialHash)
+	assert.Equal(t, *cluster.Spec.JobSpec.Parallelism, int32(2))
+
+	// The options of the autoscaler do not affect the running job.
+	var hash = getJobSpecHash(cluster)
+	cluster.Spec.JobSpec.Autoscaler.MaxParallelism = 16
+	assert.Equal(t, getJobSpecHash(cluster), hash)
+
+	// The options are dereferenced by the autoscaler, they must be defaulted.
+	cluster.Spec.JobSpec.Autoscaler.TargetUtilization = nil
+	_, err = getDesiredClusterState(cluster)
+	assert.Error(
+		t,
+		err,
+		"spec.job.autoscaler.targetUtilization is not set, "+
+			"check the defaulting webhook is enabled")
+}
+
+// Compares quantities by their values, the unexported fields of
+// resource.Quantity cannot be compared by DeepEqual.
+var quantityComparer = cmp.Comparer(func(a, b resource.Quantity) bool {
+	return a.Cmp(b) == 0
+})
+
+func TestGetDesiredClusterStateStatefulSet(t *testing.T) {
+	var port int32 = 6123
+	var dataPort int32 = 6121
+	var queryPort int32 = 6125
+	var deploymentType = flinkoperatorv1alpha1.TaskManagerDeploymentType.StatefulSet
+	var podManagementPolicy = appsv1.ParallelPodManagement
+	var volumeClaimTemplates = []corev1.PersistentVolumeClaim{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "state"},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{
+					corev1.ReadWriteOnce,
+				},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("10Gi"),
+					},
+				},
+			},
+		},
+	}
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			ImageSpec: flinkoperatorv1alpha1.ImageSpec{Name: "flink:1.8.1"},
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				AccessScope: flinkoperatorv1alpha1.AccessScope.Cluster,
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{
+					RPC: &port, Blob: &port, Query: &port, UI: &port,
+				},
+			},
+			TaskManagerSpec: flinkoperatorv1alpha1.TaskManagerSpec{
+				Replicas: 2,
+				Ports: flinkoperatorv1alpha1.TaskManagerPorts{
+					Data: &dataPort, RPC: &port, Query: &queryPort,
+				},
+				Mounts: []corev1.VolumeMount{
+					{Name: "state", MountPath: "/flink-state"},
+				},
+				DeploymentType:       &deploymentType,
+				VolumeClaimTemplates: volumeClaimTemplates,
+				PodManagementPolicy:  &podManagementPolicy,
+			},
+		},
+	}
+
+	var desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+	assert.Assert(t, desiredState.TmDeployment == nil)
+
+	var statefulSet = desiredState.TmStatefulSet
+	assert.Equal(t, statefulSet.ObjectMeta.Name, "mycluster-taskmanager")
+	assert.Equal(t, *statefulSet.Spec.Replicas, int32(2))
+	assert.Equal(t, statefulSet.Spec.ServiceName, "mycluster-taskmanager")
+	assert.Equal(
+		t, statefulSet.Spec.PodManagementPolicy, appsv1.ParallelPodManagement)
+	assert.DeepEqual(
+		t,
+		statefulSet.Spec.VolumeClaimTemplates,
+		volumeClaimTemplates,
+		quantityComparer)
+	var container = statefulSet.Spec.Template.Spec.Containers[0]
+	assert.DeepEqual(
+		t,
+		container.Args,
+		[]string{
+			"taskmanager",
+			"-Dtaskmanager.host=$(POD_NAME).mycluster-taskmanager.default.svc.cluster.local",
+			"-Dtaskmanager.resource-id=$(POD_NAME)",
+		})
+	assert.Equal(t, container.Env[0].Name, "POD_NAME")
+	assert.Equal(
+		t, container.Env[0].ValueFrom.FieldRef.FieldPath, "metadata.name")
+	var mounts = container.VolumeMounts
+	assert.Equal(t, mounts[len(mounts)-1].MountPath, "/flink-state")
+
+	var service = desiredState.TmService
+	assert.Equal(t, service.ObjectMeta.Name, "mycluster-taskmanager")
+	assert.Equal(t, service.Spec.ClusterIP, corev1.ClusterIPNone)
+	assert.Assert(t, service.Spec.PublishNotReadyAddresses)
+	assert.DeepEqual(
+		t, service.Spec.Selector, getTaskManagerLabels("mycluster"))
+
+	// The StatefulSet and its service are deleted when the cluster is stopped.
+	cluster.Status.State = flinkoperatorv1alpha1.ClusterState.Stopped
+	desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+	assert.Assert(t, desiredState.TmStatefulSet == nil)
+	assert.Assert(t, desiredState.TmService == nil)
+}
+
+func TestGetDesiredClusterStateTaskManagerPools(t *testing.T) {
+	var port int32 = 6123
+	var dataPort int32 = 6121
+	var queryPort int32 = 6125
+	var taskSlots int32 = 4
+	var poolResources = corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("16Gi"),
+		},
+	}
+	var tolerations = []corev1.Toleration{
+		{
+			Key:      "dedicated",
+			Operator: corev1.TolerationOpEqual,
+			Value:    "highmem",
+			Effect:   corev1.TaintEffectNoSchedule,
+		},
+	}
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			ImageSpec: flinkoperatorv1alpha1.ImageSpec{Name: "flink:1.8.1"},
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				AccessScope: flinkoperatorv1alpha1.AccessScope.Cluster,
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{
+					RPC: &port, Blob: &port, Query: &port, UI: &port,
+				},
+			},
+			TaskManagerSpec: flinkoperatorv1alpha1.TaskManagerSpec{
+				Replicas: 2,
+				Ports: flinkoperatorv1alpha1.TaskManagerPorts{
+					Data: &dataPort, RPC: &port, Query: &queryPort,
+				},
+				NodeSelector: map[string]string{"pool": "default"},
+			},
+			TaskManagerPools: []flinkoperatorv1alpha1.TaskManagerPoolSpec{
+				{Name: "small", Replicas: 1},
+				{
+					Name:         "highmem",
+					Replicas:     3,
+					Resources:    &poolResources,
+					NodeSelector: map[string]string{"pool": "highmem"},
+					Tolerations:  tolerations,
+					TaskSlots:    &taskSlots,
+				},
+			},
+		},
+	}
+
+	var desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+	assert.Equal(t, len(desiredState.TmPoolDeployments), 2)
+
+	// The pools without overrides inherit TaskManagerSpec.
+	var small = desiredState.TmPoolDeployments[0]
+	assert.Equal(t, small.ObjectMeta.Name, "mycluster-taskmanager-small")
+	assert.Equal(t, *small.Spec.Replicas, int32(1))
+	assert.DeepEqual(
+		t, small.Spec.Template.Spec.NodeSelector, map[string]string{"pool": "default"})
+	assert.DeepEqual(
+		t, small.Spec.Template.Spec.Containers[0].Args, []string{"taskmanager"})
+
+	var highmem = desiredState.TmPoolDeployments[1]
+	var labels = map[string]string{
+		"cluster":          "mycluster",
+		"app":              "flink",
+		"component":        "taskmanager",
+		"taskmanager-pool": "highmem",
+	}
+	assert.Equal(t, highmem.ObjectMeta.Name, "mycluster-taskmanager-highmem")
+	assert.DeepEqual(t, highmem.ObjectMeta.Labels, labels)
+	assert.DeepEqual(t, highmem.Spec.Selector.MatchLabels, labels)
+	assert.DeepEqual(t, highmem.Spec.Template.ObjectMeta.Labels, labels)
+	assert.Equal(t, *highmem.Spec.Replicas, int32(3))
+	var podSpec = highmem.Spec.Template.Spec
+	assert.DeepEqual(
+		t, podSpec.NodeSelector, map[string]string{"pool": "highmem"})
+	assert.DeepEqual(t, podSpec.Tolerations, tolerations)
+	assert.DeepEqual(
+		t, podSpec.Containers[0].Resources, poolResources, quantityComparer)
+	assert.DeepEqual(
+		t,
+		podSpec.Containers[0].Args,
+		[]string{"taskmanager", "-Dtaskmanager.numberOfTaskSlots=4"})
+
+	// The pools do not change the TaskManager deployment, whose selector does
+	// not select the pods of the pools.
+	assert.Equal(t, *desiredState.TmDeployment.Spec.Replicas, int32(2))
+	assert.Assert(t, desiredState.TmDeployment.Spec.Template.Spec.Tolerations == nil)
+	tmSelector, err := metav1.LabelSelectorAsSelector(
+		desiredState.TmDeployment.Spec.Selector)
+	assert.NilError(t, err)
+	assert.Assert(t, tmSelector.Matches(
+		k8slabels.Set(desiredState.TmDeployment.Spec.Template.ObjectMeta.Labels)))
+	assert.Assert(t, !tmSelector.Matches(k8slabels.Set(labels)))
+
+	// The pools are deleted when the cluster is stopped.
+	cluster.Status.State = flinkoperatorv1alpha1.ClusterState.Stopped
+	desiredState, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+	assert.Assert(t, desiredState.TmPoolDeployments == nil)
+}
+
+func TestGetSubmitArgsPython(t *testing.T) {
+	var pyModule = "word_count"
+	var pyRequirements = "/opt/flink/job/requirements.txt"
+	var parallelism int32 = 2
+	var jobSpec = &flinkoperatorv1alpha1.JobSpec{
+		PyModule:       &pyModule,
+		PyFiles:        []string{"/opt/flink/job/word_count.zip", "/opt/flink/job/udfs.py"},
+		PyRequirements: &pyRequirements,
+		Parallelism:    &parallelism,
+		Args:           []string{"--output", "/tmp/output"},
+	}
+
+	var args, envVars = getSubmitArgs(
+		jobSpec, "mycluster-jobmanager:8081", nil, false /* detached */)
+	assert.DeepEqual(
+		t,
+		args,
+		[]string{
+			"./bin/flink", "run",
+			"--jobmanager", "mycluster-jobmanager:8081",
+			"--parallelism", "2",
+			"--pyFiles", "/opt/flink/job/word_count.zip,/opt/flink/job/udfs.py",
+			"--pyRequirements", "/opt/flink/job/requirements.txt",
+			"--pyModule", "word_count",
+			"--output", "/tmp/output",
+		})
+	assert.Equal(t, len(envVars), 0)
+}
+
+func TestGetSubmitArgsSQLScript(t *testing.T) {
+	var savepoint = "gs://my-bucket/savepoints/savepoint-1"
+	var parallelism int32 = 2
+	var jobSpec = &flinkoperatorv1alpha1.JobSpec{
+		SQLScript: &flinkoperatorv1alpha1.SQLScriptSpec{
+			ConfigMap: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: "my-sql-scripts",
+				},
+				Key: "job.sql",
+			},
+		},
+		Parallelism: &parallelism,
+	}
+
+	var args, envVars = getSubmitArgs(
+		jobSpec, "mycluster-jobmanager:8081", &savepoint, false /* detached */)
+	assert.DeepEqual(
+		t,
+		args,
+		[]string{
+			"/bin/sh", "-c", sqlScriptCommand, "sh",
+			"./bin/sql-client.sh",
+			"-Drest.address=mycluster-jobmanager",
+			"-Drest.port=8081",
+			"-Dtable.dml-sync=true",
+			"-Dexecution.savepoint.path=gs://my-bucket/savepoints/savepoint-1",
+			"-Dparallelism.default=2",
+			"-f", "/tmp/job.sql",
+		})
+	assert.DeepEqual(
+		t,
+		envVars,
+		[]corev1.EnvVar{
+			{
+				Name: "FLINK_SQL_SCRIPT",
+				ValueFrom: &corev1.EnvVarSource{
+					ConfigMapKeyRef: jobSpec.SQLScript.ConfigMap,
+				},
+			},
+		})
+
+	// The inline script is passed as is, and the client returns once the
+	// statements are submitted in detached mode.
+	var script = "INSERT INTO sink SELECT * FROM source;"
+	jobSpec.SQLScript = &flinkoperatorv1alpha1.SQLScriptSpec{Inline: &script}
+	args, envVars = getSubmitArgs(
+		jobSpec, "mycluster-jobmanager:8081", nil, true /* detached */)
+	assert.Assert(t, !strings.Contains(
+		strings.Join(args, " "), "-Dtable.dml-sync=true"))
+	assert.DeepEqual(
+		t,
+		envVars,
+		[]corev1.EnvVar{{Name: "FLINK_SQL_SCRIPT", Value: script}})
+}
+
+// Tests the savepoint recorded by the operator takes precedence over the one in
+// the job spec, until a different savepoint is set in the spec.
+func TestGetFromSavepoint(t *testing.T) {
+	var specSavepoint = "gs://my-bucket/savepoints/savepoint-1"
+	var recordedSavepoint = "gs://my-bucket/savepoints/savepoint-2"
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			JobSpec: &flinkoperatorv1alpha1.JobSpec{Savepoint: &specSavepoint},
+		},
+		Status: flinkoperatorv1alpha1.FlinkClusterStatus{
+			Components: flinkoperatorv1alpha1.FlinkClusterComponentsStatus{
+				Job: &flinkoperatorv1alpha1.JobStatus{
+					FromSavepoint: recordedSavepoint,
+					SpecSavepoint: &specSavepoint,
+				},
+			},
+		},
+	}
+	assert.Equal(t, *getFromSavepoint(cluster), recordedSavepoint)
+
+	var newSavepoint = "gs://my-bucket/savepoints/savepoint-3"
+	cluster.Spec.JobSpec.Savepoint = &newSavepoint
+	assert.Equal(t, *getFromSavepoint(cluster), newSavepoint)
+
+	// Removing the savepoint from the spec keeps the recorded one.
+	cluster.Spec.JobSpec.Savepoint = nil
+	assert.Equal(t, *getFromSavepoint(cluster), recordedSavepoint)
+}
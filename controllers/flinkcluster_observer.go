@@ -18,17 +18,19 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"time"
 
 	"github.com/go-logr/logr"
 	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -36,32 +38,36 @@ import (
 
 // _ClusterStateObserver gets the observed state of the cluster.
 type _ClusterStateObserver struct {
-	k8sClient client.Client
-	request   ctrl.Request
-	context   context.Context
-	log       logr.Logger
+	k8sClient   client.Client
+	flinkClient *flinkclient.FlinkClient
+	request     ctrl.Request
+	context     context.Context
+	log         logr.Logger
 }
 
 // _ObservedClusterState holds observed state of a cluster.
 type _ObservedClusterState struct {
-	cluster      *flinkoperatorv1alpha1.FlinkCluster
-	jmDeployment *appsv1.Deployment
-	jmService    *corev1.Service
-	tmDeployment *appsv1.Deployment
-	job          *batchv1.Job
-	jobPod       *corev1.Pod
-	flinkJobID   *string
-}
-
-// Flink job status.
-type _JobStatus struct {
-	ID     string
-	Status string
-}
-
-// Flink job status list.
-type _JobStatusList struct {
-	Jobs []_JobStatus
+	cluster           *flinkoperatorv1alpha1.FlinkCluster
+	haServiceAccount  *corev1.ServiceAccount
+	haRole            *rbacv1.Role
+	haRoleBinding     *rbacv1.RoleBinding
+	haLeaderConfigMap *corev1.ConfigMap
+	configMap         *corev1.ConfigMap
+	jmDeployment      *appsv1.Deployment
+	jmPods            []corev1.Pod
+	jmService         *corev1.Service
+	jmIngress         *networkingv1beta1.Ingress
+	tmDeployment      *appsv1.Deployment
+	tmStatefulSet     *appsv1.StatefulSet
+	tmService         *corev1.Service
+	tmPoolDeployments map[string]*appsv1.Deployment
+	job               *batchv1.Job
+	jobPod            *corev1.Pod
+	podMonitor        *unstructured.Unstructured
+	serviceMonitor    *unstructured.Unstructured
+	flinkJobID        *string
+	flinkJob          *flinkclient.JobDetails
+	flinkJobMetrics   []_VertexMetrics
 }
 
 // Observes the state of the cluster and its components.
@@ -86,6 +92,27 @@ func (observer *_ClusterStateObserver) observe(
 		observedState.cluster = observedCluster
 	}
 
+	// (Optional) high availability.
+	err = observer.observeHighAvailability(observedState)
+	if err != nil {
+		return err
+	}
+
+	// ConfigMap.
+	var observedConfigMap = new(corev1.ConfigMap)
+	err = observer.observeObject(
+		getConfigMapName(observer.request.Name), observedConfigMap)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get ConfigMap")
+			return err
+		}
+		log.Info("Observed ConfigMap", "state", "nil")
+	} else {
+		log.Info("Observed ConfigMap", "state", *observedConfigMap)
+		observedState.configMap = observedConfigMap
+	}
+
 	// JobManager deployment.
 	var observedJmDeployment = new(appsv1.Deployment)
 	err = observer.observeJobManagerDeployment(observedJmDeployment)
@@ -116,6 +143,21 @@ func (observer *_ClusterStateObserver) observe(
 		observedState.jmService = observedJmService
 	}
 
+	// (Optional) JobManager ingress.
+	var observedJmIngress = new(networkingv1beta1.Ingress)
+	err = observer.observeObject(
+		getJobManagerIngressName(observer.request.Name), observedJmIngress)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get JobManager ingress")
+			return err
+		}
+		log.Info("Observed JobManager ingress", "state", "nil")
+	} else {
+		log.Info("Observed JobManager ingress", "state", *observedJmIngress)
+		observedState.jmIngress = observedJmIngress
+	}
+
 	// TaskManager deployment.
 	var observedTmDeployment = new(appsv1.Deployment)
 	err = observer.observeTaskManagerDeployment(observedTmDeployment)
@@ -131,10 +173,178 @@ func (observer *_ClusterStateObserver) observe(
 		observedState.tmDeployment = observedTmDeployment
 	}
 
+	// (Optional) TaskManager StatefulSet.
+	var observedTmStatefulSet = new(appsv1.StatefulSet)
+	err = observer.observeObject(
+		getTaskManagerStatefulSetName(observer.request.Name),
+		observedTmStatefulSet)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get TaskManager StatefulSet")
+			return err
+		}
+		log.Info("Observed TaskManager StatefulSet", "state", "nil")
+	} else {
+		log.Info("Observed TaskManager StatefulSet", "state", *observedTmStatefulSet)
+		observedState.tmStatefulSet = observedTmStatefulSet
+	}
+
+	// (Optional) TaskManager service of the StatefulSet.
+	var observedTmService = new(corev1.Service)
+	err = observer.observeObject(
+		getTaskManagerServiceName(observer.request.Name), observedTmService)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get TaskManager service")
+			return err
+		}
+		log.Info("Observed TaskManager service", "state", "nil")
+	} else {
+		log.Info("Observed TaskManager service", "state", *observedTmService)
+		observedState.tmService = observedTmService
+	}
+
+	// (Optional) TaskManager pool deployments.
+	observedState.tmPoolDeployments, err =
+		observer.observeTaskManagerPoolDeployments()
+	if err != nil {
+		log.Error(err, "Failed to list TaskManager pool deployments")
+		return err
+	}
+	log.Info(
+		"Observed TaskManager pool deployments",
+		"count", len(observedState.tmPoolDeployments))
+
+	// (Optional) monitors.
+	observedState.podMonitor, err = observer.observeMonitor(
+		flinkoperatorv1alpha1.MonitorKind.PodMonitor)
+	if err != nil {
+		return err
+	}
+	observedState.serviceMonitor, err = observer.observeMonitor(
+		flinkoperatorv1alpha1.MonitorKind.ServiceMonitor)
+	if err != nil {
+		return err
+	}
+
 	// (Optional) job.
 	err = observer.observeJob(observedState)
+	if err != nil {
+		return err
+	}
 
-	return err
+	// (Optional) job metrics for the autoscaler.
+	observer.observeJobMetrics(observedState)
+
+	return nil
+}
+
+// Observes the monitor of the kind, which is observed even if it is not
+// specified, so it can be cleaned up. Returns nil if the monitor does not
+// exist or the CRD of the kind is not installed.
+func (observer *_ClusterStateObserver) observeMonitor(
+	kind string) (*unstructured.Unstructured, error) {
+	var log = observer.log.WithValues("component", kind)
+	var observedMonitor = newMonitor(kind)
+	var err = observer.observeObject(
+		getMonitorName(observer.request.Name), observedMonitor)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			log.Info("Observed monitor", "state", "CRD not installed")
+			return nil, nil
+		}
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get monitor")
+			return nil, err
+		}
+		log.Info("Observed monitor", "state", "nil")
+		return nil, nil
+	}
+	log.Info("Observed monitor", "state", *observedMonitor)
+	return observedMonitor, nil
+}
+
+// Observes the resources for Kubernetes HA, the leader of JobManagers and the
+// JobManager pods which the leader is looked up from.
+func (observer *_ClusterStateObserver) observeHighAvailability(
+	observedState *_ObservedClusterState) error {
+	var err error
+	var log = observer.log
+	var cluster = observedState.cluster
+	var clusterName = observer.request.Name
+
+	// The resources are observed even if HA is disabled, so they can be
+	// cleaned up.
+	var observedServiceAccount = new(corev1.ServiceAccount)
+	err = observer.observeObject(
+		getHaResourceName(clusterName), observedServiceAccount)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get HA service account")
+			return err
+		}
+		log.Info("Observed HA service account", "state", "nil")
+	} else {
+		log.Info("Observed HA service account", "state", *observedServiceAccount)
+		observedState.haServiceAccount = observedServiceAccount
+	}
+
+	var observedRole = new(rbacv1.Role)
+	err = observer.observeObject(getHaResourceName(clusterName), observedRole)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get HA role")
+			return err
+		}
+		log.Info("Observed HA role", "state", "nil")
+	} else {
+		log.Info("Observed HA role", "state", *observedRole)
+		observedState.haRole = observedRole
+	}
+
+	var observedRoleBinding = new(rbacv1.RoleBinding)
+	err = observer.observeObject(
+		getHaResourceName(clusterName), observedRoleBinding)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get HA role binding")
+			return err
+		}
+		log.Info("Observed HA role binding", "state", "nil")
+	} else {
+		log.Info("Observed HA role binding", "state", *observedRoleBinding)
+		observedState.haRoleBinding = observedRoleBinding
+	}
+
+	if cluster == nil || !isKubernetesHaEnabled(cluster) {
+		return nil
+	}
+
+	// The leader ConfigMap is created by Flink, it is not found until the
+	// leader has been elected.
+	var observedLeaderConfigMap = new(corev1.ConfigMap)
+	err = observer.observeObject(
+		getHaLeaderConfigMapName(cluster), observedLeaderConfigMap)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get HA leader ConfigMap")
+			return err
+		}
+		log.Info("Observed HA leader ConfigMap", "state", "nil")
+	} else {
+		log.Info("Observed HA leader ConfigMap", "state", *observedLeaderConfigMap)
+		observedState.haLeaderConfigMap = observedLeaderConfigMap
+	}
+
+	var observedJmPods = new(corev1.PodList)
+	err = observer.observeJobManagerPods(observedJmPods)
+	if err != nil {
+		log.Error(err, "Failed to get JobManager pods")
+		return err
+	}
+	observedState.jmPods = observedJmPods.Items
+
+	return nil
 }
 
 func (observer *_ClusterStateObserver) observeJob(
@@ -148,6 +358,12 @@ func (observer *_ClusterStateObserver) observeJob(
 		return nil
 	}
 
+	// The job is run inside JobManager in Application mode, there is no job
+	// submitter.
+	if isApplicationMode(observedState.cluster) {
+		return observer.observeApplicationJob(observedState)
+	}
+
 	// Job resource.
 	var observedJob = new(batchv1.Job)
 	err = observer.observeJobResource(observedJob)
@@ -197,18 +413,14 @@ func (observer *_ClusterStateObserver) observeJob(
 			observedState.jobPod.Status.Phase != corev1.PodPhase("Pending") &&
 			observedState.jobPod.Status.Phase != corev1.PodPhase("Unknown")
 		if isJobCreated && observedState.jmService != nil {
-			var url = fmt.Sprintf(
-				"http://%s.%s.svc.cluster.local:%d/jobs",
-				observedState.jmService.GetName(),
-				observedState.jmService.GetNamespace(),
-				*observedState.cluster.Spec.JobManagerSpec.Ports.UI)
+			var apiBaseURL = getFlinkAPIBaseURL(observedState.cluster)
 			log.Info(
 				"Polling job status from Flink API...",
 				"url",
-				url,
+				apiBaseURL,
 				"jobPodPhase",
 				observedState.jobPod.Status.Phase)
-			var flinkJobID = observer.getFlinkJobID(url)
+			var flinkJobID = observer.getFlinkJobID(apiBaseURL)
 			if flinkJobID != nil {
 				observedState.flinkJobID = flinkJobID
 			}
@@ -217,36 +429,133 @@ func (observer *_ClusterStateObserver) observeJob(
 		}
 	}
 
+	// (Optional) Flink job state.
+	if observedState.flinkJobID != nil && observedState.jmService != nil {
+		observedState.flinkJob = observer.getFlinkJob(
+			getFlinkAPIBaseURL(observedState.cluster), *observedState.flinkJobID)
+	}
+
+	return nil
+}
+
+// Observes the Flink job run inside JobManager in Application mode.
+func (observer *_ClusterStateObserver) observeApplicationJob(
+	observedState *_ObservedClusterState) error {
+	var log = observer.log
+
+	// Flink job ID.
+	var observedJobStatus = observedState.cluster.Status.Components.Job
+	if observedJobStatus != nil && len(observedJobStatus.ID) > 0 {
+		log.Info("Flink job ID is already available.", "ID", observedJobStatus.ID)
+		observedState.flinkJobID = &observedJobStatus.ID
+	} else if observedState.jmDeployment != nil &&
+		observedState.jmService != nil {
+		var apiBaseURL = getFlinkAPIBaseURL(observedState.cluster)
+		log.Info("Polling job status from Flink API...", "url", apiBaseURL)
+		observedState.flinkJobID = observer.getFlinkJobID(apiBaseURL)
+	} else {
+		log.Info("Skip getting Flink job ID")
+	}
+
+	// (Optional) Flink job state.
+	if observedState.flinkJobID != nil && observedState.jmService != nil {
+		observedState.flinkJob = observer.getFlinkJob(
+			getFlinkAPIBaseURL(observedState.cluster), *observedState.flinkJobID)
+	}
+
 	return nil
 }
 
 // Gets Flink job ID through Flink REST API.
-func (observer *_ClusterStateObserver) getFlinkJobID(url string) *string {
+func (observer *_ClusterStateObserver) getFlinkJobID(apiBaseURL string) *string {
 	var log = observer.log
-	var client = &http.Client{
-		Timeout: 15 * time.Second,
-	}
-	var req, err = http.NewRequest("GET", url, nil)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "flink-operator")
-	resp, err := client.Do(req)
-	if err == nil {
-		defer resp.Body.Close()
-		var body []byte
-		body, err = ioutil.ReadAll(resp.Body)
-		if err == nil {
-			var jobStatusList _JobStatusList
-			json.Unmarshal(body, &jobStatusList)
-			log.Info("Flink job status list", "jobs", jobStatusList)
-			if len(jobStatusList.Jobs) > 0 {
-				return &jobStatusList.Jobs[0].ID
-			}
+	var overview, err = observer.flinkClient.GetJobsOverview(
+		observer.context, apiBaseURL)
+	if err != nil {
+		log.Error(err, "Failed to get Flink job ID.")
+		return nil
+	}
+	log.Info("Flink jobs overview", "jobs", overview.Jobs)
+	// Jobs cancelled for upgrade, failed before a restart or finished before a
+	// resubmit are still listed, skip them. The jobs are not listed in a
+	// particular order, so the one started last is taken.
+	var flinkJob *flinkclient.JobOverview
+	for i := range overview.Jobs {
+		var job = &overview.Jobs[i]
+		if flinkclient.IsJobTerminated(job.State) ||
+			job.State == flinkclient.JobState.Cancelling {
+			continue
+		}
+		if flinkJob == nil || job.StartTime > flinkJob.StartTime {
+			flinkJob = job
 		}
 	}
+	if flinkJob == nil {
+		return nil
+	}
+	return &flinkJob.ID
+}
+
+// Gets the details of the Flink job through Flink REST API, returns nil if the
+// job is not found or the API is not available.
+func (observer *_ClusterStateObserver) getFlinkJob(
+	apiBaseURL string, jobID string) *flinkclient.JobDetails {
+	var log = observer.log
+	var flinkJob, err = observer.flinkClient.GetJobDetails(
+		observer.context, apiBaseURL, jobID)
 	if err != nil {
-		log.Error(err, "Failed to get Flink job ID.")
+		if flinkclient.IsNotFound(err) {
+			log.Info("Flink job not found", "ID", jobID)
+		} else {
+			log.Error(err, "Failed to get Flink job state.")
+		}
+		return nil
 	}
-	return nil
+	log.Info("Observed Flink job", "ID", jobID, "state", flinkJob.State)
+	return flinkJob
+}
+
+// Observes the metrics of the vertices of the running job, which the
+// autoscaler decides the parallelism of the job from. The metrics are left nil
+// if any of them is not available, so the job is not rescaled on partial
+// metrics.
+func (observer *_ClusterStateObserver) observeJobMetrics(
+	observedState *_ObservedClusterState) {
+	var log = observer.log
+	var cluster = observedState.cluster
+	var flinkJob = observedState.flinkJob
+	if cluster == nil || cluster.Spec.JobSpec == nil ||
+		cluster.Spec.JobSpec.Autoscaler == nil ||
+		flinkJob == nil || flinkJob.State != flinkclient.JobState.Running {
+		return
+	}
+
+	var apiBaseURL = getFlinkAPIBaseURL(cluster)
+	var vertices []_VertexMetrics
+	for _, vertex := range flinkJob.Vertices {
+		var available, err = observer.flinkClient.GetVertexMetrics(
+			observer.context, apiBaseURL, flinkJob.ID, vertex.ID, nil)
+		if err != nil {
+			log.Error(err, "Failed to get the metrics of the vertex",
+				"vertex", vertex.Name)
+			return
+		}
+		var names = getAutoscalerMetricNames(available)
+		if len(names) == 0 {
+			log.Info("No metrics available for the vertex", "vertex", vertex.Name)
+			return
+		}
+		metrics, err := observer.flinkClient.GetVertexMetrics(
+			observer.context, apiBaseURL, flinkJob.ID, vertex.ID, names)
+		if err != nil {
+			log.Error(err, "Failed to get the metrics of the vertex",
+				"vertex", vertex.Name)
+			return
+		}
+		vertices = append(vertices, getVertexMetrics(vertex.Name, metrics))
+	}
+	log.Info("Observed job metrics", "vertices", len(vertices))
+	observedState.flinkJobMetrics = vertices
 }
 
 func (observer *_ClusterStateObserver) observeCluster(
@@ -273,6 +582,34 @@ func (observer *_ClusterStateObserver) observeTaskManagerDeployment(
 		clusterNamespace, tmDeploymentName, "TaskManager", observedDeployment)
 }
 
+// Observes the deployments of the TaskManager pools by the names of the pools,
+// including the deployments of the pools which have been removed from the
+// spec, so they can be cleaned up.
+func (observer *_ClusterStateObserver) observeTaskManagerPoolDeployments() (
+	map[string]*appsv1.Deployment, error) {
+	var inNamespace = client.InNamespace(observer.request.Namespace)
+	var matchingLabels client.MatchingLabels = map[string]string{
+		"app":       "flink",
+		"cluster":   observer.request.Name,
+		"component": "taskmanager",
+	}
+	var deployments = new(appsv1.DeploymentList)
+	var err = observer.k8sClient.List(
+		observer.context, deployments, inNamespace, matchingLabels)
+	if err != nil {
+		return nil, err
+	}
+	var poolDeployments = map[string]*appsv1.Deployment{}
+	for i := range deployments.Items {
+		var deployment = &deployments.Items[i]
+		var poolName, ok = deployment.ObjectMeta.Labels[taskManagerPoolLabel]
+		if ok {
+			poolDeployments[poolName] = deployment
+		}
+	}
+	return poolDeployments, nil
+}
+
 func (observer *_ClusterStateObserver) observeDeployment(
 	namespace string,
 	name string,
@@ -296,6 +633,30 @@ func (observer *_ClusterStateObserver) observeDeployment(
 	return err
 }
 
+// Gets the object with the name in the namespace of the cluster.
+func (observer *_ClusterStateObserver) observeObject(
+	name string, observedObject runtime.Object) error {
+	return observer.k8sClient.Get(
+		observer.context,
+		types.NamespacedName{
+			Namespace: observer.request.Namespace,
+			Name:      name,
+		},
+		observedObject)
+}
+
+func (observer *_ClusterStateObserver) observeJobManagerPods(
+	observedPods *corev1.PodList) error {
+	var inNamespace = client.InNamespace(observer.request.Namespace)
+	var matchingLabels client.MatchingLabels = map[string]string{
+		"app":       "flink",
+		"cluster":   observer.request.Name,
+		"component": "jobmanager",
+	}
+	return observer.k8sClient.List(
+		observer.context, observedPods, inNamespace, matchingLabels)
+}
+
 func (observer *_ClusterStateObserver) observeJobManagerService(
 	observedService *corev1.Service) error {
 	var clusterNamespace = observer.request.Namespace
@@ -0,0 +1,66 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient/flinkclienttest"
+	"gotest.tools/assert"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestGetFlinkJobIDSkipsTerminatedJobs(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var observer = _ClusterStateObserver{
+		flinkClient: server.NewClient(log.NullLogger{}),
+		context:     context.Background(),
+		log:         log.NullLogger{},
+	}
+	var apiBaseURL = "http://mycluster-jobmanager.default.svc.cluster.local:8081"
+
+	server.AddJob("cancelled", flinkclient.JobState.Canceled)
+	server.AddJob("failed", flinkclient.JobState.Failed)
+	assert.Assert(t, observer.getFlinkJobID(apiBaseURL) == nil)
+
+	var jobID = server.AddJob("new", flinkclient.JobState.Running)
+	var flinkJobID = observer.getFlinkJobID(apiBaseURL)
+	assert.Assert(t, flinkJobID != nil)
+	assert.Equal(t, *flinkJobID, jobID)
+
+	// The job finished after the one which is running is skipped as well.
+	server.AddJob("finished", flinkclient.JobState.Finished)
+	flinkJobID = observer.getFlinkJobID(apiBaseURL)
+	assert.Assert(t, flinkJobID != nil)
+	assert.Equal(t, *flinkJobID, jobID)
+}
+
+func TestGetFlinkJobIDAPIError(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	var observer = _ClusterStateObserver{
+		flinkClient: server.NewClient(log.NullLogger{}),
+		context:     context.Background(),
+		log:         log.NullLogger{},
+	}
+	server.Close()
+
+	var apiBaseURL = "http://mycluster-jobmanager.default.svc.cluster.local:8081"
+	assert.Assert(t, observer.getFlinkJobID(apiBaseURL) == nil)
+}
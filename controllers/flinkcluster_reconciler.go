@@ -18,19 +18,51 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// The finalizer which cancels the job with a savepoint before the FlinkCluster
+// is deleted.
+const savepointOnDeleteFinalizer = "flinkoperator.k8s.io/savepoint-on-delete"
+
+// Annotation which releases the finalizer without the savepoint when set to
+// "true".
+const forceDeleteAnnotation = "flinkoperator.k8s.io/force-delete"
+
+// Annotation which records the location of the savepoint taken on deletion.
+const finalSavepointAnnotation = "flinkoperator.k8s.io/final-savepoint"
+
+// The external path Flink reports for checkpoints which are not retained
+// externally.
+const checkpointNotExternallyAddressable = "<checkpoint-not-externally-addressable>"
+
+// The period the restarted job has to keep running before its restart count
+// is reset, so the max restart attempts only limit consecutive failures.
+const restartCountResetPeriod = 10 * time.Minute
+
 type _ClusterReconciler struct {
 	k8sClient     client.Client
+	flinkClient   *flinkclient.FlinkClient
 	context       context.Context
 	log           logr.Logger
+	eventRecorder record.EventRecorder
 	observedState _ObservedClusterState
 	desiredState  _DesiredClusterState
 }
@@ -46,6 +78,29 @@ func (reconciler *_ClusterReconciler) reconcile() error {
 		return nil
 	}
 
+	if reconciler.observedState.cluster.DeletionTimestamp != nil {
+		return reconciler.finalize()
+	}
+
+	err = reconciler.reconcileFinalizer()
+	if err != nil {
+		return err
+	}
+
+	// The service account of the JobManager and TaskManager pods must exist
+	// before the pods are created.
+	err = reconciler.reconcileHighAvailability()
+	if err != nil {
+		return err
+	}
+
+	// The config files are mounted into the pods, so the ConfigMap must exist
+	// before the pods are created.
+	err = reconciler.reconcileConfigMap()
+	if err != nil {
+		return err
+	}
+
 	err = reconciler.reconcileJobManagerDeployment()
 	if err != nil {
 		return err
@@ -56,11 +111,54 @@ func (reconciler *_ClusterReconciler) reconcile() error {
 		return err
 	}
 
+	err = reconciler.reconcileJobManagerIngress()
+	if err != nil {
+		return err
+	}
+
 	err = reconciler.reconcileTaskManagerDeployment()
 	if err != nil {
 		return err
 	}
 
+	err = reconciler.reconcileTaskManagerPools()
+	if err != nil {
+		return err
+	}
+
+	// The StatefulSet needs its headless service for the hostnames of the
+	// pods.
+	err = reconciler.reconcileTaskManagerService()
+	if err != nil {
+		return err
+	}
+
+	err = reconciler.reconcileTaskManagerStatefulSet()
+	if err != nil {
+		return err
+	}
+
+	err = reconciler.reconcileMonitor(
+		flinkoperatorv1alpha1.MonitorKind.PodMonitor,
+		reconciler.desiredState.PodMonitor,
+		reconciler.observedState.podMonitor)
+	if err != nil {
+		return err
+	}
+
+	err = reconciler.reconcileMonitor(
+		flinkoperatorv1alpha1.MonitorKind.ServiceMonitor,
+		reconciler.desiredState.ServiceMonitor,
+		reconciler.observedState.serviceMonitor)
+	if err != nil {
+		return err
+	}
+
+	err = reconciler.reconcileAutoscaler()
+	if err != nil {
+		return err
+	}
+
 	err = reconciler.reconcileJob()
 	if err != nil {
 		return err
@@ -69,6 +167,154 @@ func (reconciler *_ClusterReconciler) reconcile() error {
 	return nil
 }
 
+// Reconciles the service account, role and role binding for Kubernetes HA.
+// The role binding cannot be updated in place, so it is only created or
+// deleted.
+func (reconciler *_ClusterReconciler) reconcileHighAvailability() error {
+	var desiredState = reconciler.desiredState
+	var observedState = reconciler.observedState
+	var err error
+
+	if desiredState.HaServiceAccount != nil &&
+		observedState.haServiceAccount == nil {
+		err = reconciler.createObject(
+			desiredState.HaServiceAccount, "HA service account")
+	} else if desiredState.HaServiceAccount == nil &&
+		observedState.haServiceAccount != nil {
+		err = reconciler.deleteObject(
+			observedState.haServiceAccount, "HA service account")
+	}
+	if err != nil {
+		return err
+	}
+
+	if desiredState.HaRole != nil && observedState.haRole == nil {
+		err = reconciler.createObject(desiredState.HaRole, "HA role")
+	} else if desiredState.HaRole != nil &&
+		!equality.Semantic.DeepEqual(
+			desiredState.HaRole.Rules, observedState.haRole.Rules) {
+		var updatedRole = observedState.haRole.DeepCopy()
+		updatedRole.Rules = desiredState.HaRole.Rules
+		err = reconciler.updateObject(updatedRole, "HA role")
+	} else if desiredState.HaRole == nil && observedState.haRole != nil {
+		err = reconciler.deleteObject(observedState.haRole, "HA role")
+	}
+	if err != nil {
+		return err
+	}
+
+	if desiredState.HaRoleBinding != nil &&
+		observedState.haRoleBinding == nil {
+		err = reconciler.createObject(
+			desiredState.HaRoleBinding, "HA role binding")
+	} else if desiredState.HaRoleBinding == nil &&
+		observedState.haRoleBinding != nil {
+		err = reconciler.deleteObject(
+			observedState.haRoleBinding, "HA role binding")
+	}
+	return err
+}
+
+// Reconciles the ConfigMap which holds the config files. Running pods are
+// restarted through the config hash annotation of the deployments.
+func (reconciler *_ClusterReconciler) reconcileConfigMap() error {
+	var desiredConfigMap = reconciler.desiredState.ConfigMap
+	var observedConfigMap = reconciler.observedState.configMap
+
+	if desiredConfigMap != nil && observedConfigMap == nil {
+		return reconciler.createObject(desiredConfigMap, "ConfigMap")
+	}
+
+	if desiredConfigMap != nil && observedConfigMap != nil {
+		if equality.Semantic.DeepEqual(
+			desiredConfigMap.Data, observedConfigMap.Data) {
+			reconciler.log.Info("ConfigMap already exists, no change")
+			return nil
+		}
+		var updatedConfigMap = observedConfigMap.DeepCopy()
+		updatedConfigMap.Data = desiredConfigMap.Data
+		return reconciler.updateObject(updatedConfigMap, "ConfigMap")
+	}
+
+	if desiredConfigMap == nil && observedConfigMap != nil {
+		return reconciler.deleteObject(observedConfigMap, "ConfigMap")
+	}
+
+	return nil
+}
+
+// Reconciles the PodMonitor or ServiceMonitor of the cluster. The labels and
+// the spec of the monitor are owned by the operator, they are replaced when
+// they differ from the desired ones.
+func (reconciler *_ClusterReconciler) reconcileMonitor(
+	kind string,
+	desiredMonitor *unstructured.Unstructured,
+	observedMonitor *unstructured.Unstructured) error {
+	if desiredMonitor != nil && observedMonitor == nil {
+		return reconciler.createObject(desiredMonitor, kind)
+	}
+
+	if desiredMonitor != nil && observedMonitor != nil {
+		if equality.Semantic.DeepEqual(
+			desiredMonitor.GetLabels(), observedMonitor.GetLabels()) &&
+			equality.Semantic.DeepEqual(
+				desiredMonitor.Object["spec"], observedMonitor.Object["spec"]) {
+			reconciler.log.Info(kind + " already exists, no change")
+			return nil
+		}
+		var updatedMonitor = observedMonitor.DeepCopy()
+		updatedMonitor.SetLabels(desiredMonitor.GetLabels())
+		updatedMonitor.Object["spec"] = desiredMonitor.Object["spec"]
+		return reconciler.updateObject(updatedMonitor, kind)
+	}
+
+	if desiredMonitor == nil && observedMonitor != nil {
+		return reconciler.deleteObject(observedMonitor, kind)
+	}
+
+	return nil
+}
+
+func (reconciler *_ClusterReconciler) createObject(
+	object runtime.Object, component string) error {
+	var log = reconciler.log.WithValues("component", component)
+	log.Info("Creating resource", "resource", object)
+	var err = reconciler.k8sClient.Create(reconciler.context, object)
+	if err != nil {
+		log.Error(err, "Failed to create resource")
+	} else {
+		log.Info("Resource created")
+	}
+	return err
+}
+
+func (reconciler *_ClusterReconciler) updateObject(
+	object runtime.Object, component string) error {
+	var log = reconciler.log.WithValues("component", component)
+	log.Info("Updating resource", "resource", object)
+	var err = reconciler.k8sClient.Update(reconciler.context, object)
+	if err != nil {
+		log.Error(err, "Failed to update resource")
+	} else {
+		log.Info("Resource updated")
+	}
+	return err
+}
+
+func (reconciler *_ClusterReconciler) deleteObject(
+	object runtime.Object, component string) error {
+	var log = reconciler.log.WithValues("component", component)
+	log.Info("Deleting resource", "resource", object)
+	var err = reconciler.k8sClient.Delete(reconciler.context, object)
+	err = client.IgnoreNotFound(err)
+	if err != nil {
+		log.Error(err, "Failed to delete resource")
+	} else {
+		log.Info("Resource deleted")
+	}
+	return err
+}
+
 func (reconciler *_ClusterReconciler) reconcileJobManagerDeployment() error {
 	return reconciler.reconcileDeployment(
 		"JobManager",
@@ -83,6 +329,95 @@ func (reconciler *_ClusterReconciler) reconcileTaskManagerDeployment() error {
 		reconciler.observedState.tmDeployment)
 }
 
+// Reconciles the deployments of the TaskManager pools, the deployments of the
+// pools which have been removed from the spec are deleted.
+func (reconciler *_ClusterReconciler) reconcileTaskManagerPools() error {
+	var observedDeployments = reconciler.observedState.tmPoolDeployments
+	var desiredPools = map[string]bool{}
+	for _, desiredDeployment := range reconciler.desiredState.TmPoolDeployments {
+		var poolName = desiredDeployment.ObjectMeta.Labels[taskManagerPoolLabel]
+		desiredPools[poolName] = true
+		var err = reconciler.reconcileDeployment(
+			"TaskManager pool "+poolName,
+			desiredDeployment,
+			observedDeployments[poolName])
+		if err != nil {
+			return err
+		}
+	}
+	for poolName, observedDeployment := range observedDeployments {
+		if desiredPools[poolName] {
+			continue
+		}
+		var err = reconciler.deleteDeployment(
+			observedDeployment, "TaskManager pool "+poolName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reconciles the TaskManager StatefulSet. Like the deployments, it is only
+// updated with the changes which can be applied in place, the volume claim
+// templates and the pod management policy are immutable.
+func (reconciler *_ClusterReconciler) reconcileTaskManagerStatefulSet() error {
+	var desiredStatefulSet = reconciler.desiredState.TmStatefulSet
+	var observedStatefulSet = reconciler.observedState.tmStatefulSet
+	var log = reconciler.log.WithValues("component", "TaskManager")
+
+	if desiredStatefulSet != nil && observedStatefulSet == nil {
+		return reconciler.createObject(desiredStatefulSet, "TaskManager")
+	}
+
+	if desiredStatefulSet != nil && observedStatefulSet != nil {
+		var updatedStatefulSet = getUpdatedStatefulSet(
+			desiredStatefulSet, observedStatefulSet)
+		if updatedStatefulSet == nil {
+			log.Info("StatefulSet already exists, no change")
+			return nil
+		}
+		// Updating the StatefulSet restarts the running job, so a savepoint
+		// needs to be taken first.
+		if reconciler.isJobUpgradePending() {
+			log.Info("Deferring StatefulSet update until the job is savepointed")
+			return nil
+		}
+		return reconciler.updateObject(updatedStatefulSet, "TaskManager")
+	}
+
+	if desiredStatefulSet == nil && observedStatefulSet != nil {
+		return reconciler.deleteObject(observedStatefulSet, "TaskManager")
+	}
+
+	return nil
+}
+
+func (reconciler *_ClusterReconciler) reconcileTaskManagerService() error {
+	var desiredTmService = reconciler.desiredState.TmService
+	var observedTmService = reconciler.observedState.tmService
+
+	if desiredTmService != nil && observedTmService == nil {
+		return reconciler.createService(desiredTmService, "TaskManager")
+	}
+
+	if desiredTmService != nil && observedTmService != nil {
+		var updatedTmService = getUpdatedService(
+			desiredTmService, observedTmService)
+		if updatedTmService == nil {
+			reconciler.log.Info("TaskManager service already exists, no change")
+			return nil
+		}
+		return reconciler.updateService(updatedTmService, "TaskManager")
+	}
+
+	if desiredTmService == nil && observedTmService != nil {
+		return reconciler.deleteService(observedTmService, "TaskManager")
+	}
+
+	return nil
+}
+
 func (reconciler *_ClusterReconciler) reconcileDeployment(
 	component string,
 	desiredDeployment *appsv1.Deployment,
@@ -94,9 +429,19 @@ func (reconciler *_ClusterReconciler) reconcileDeployment(
 	}
 
 	if desiredDeployment != nil && observedDeployment != nil {
-		log.Info("Deployment already exists, no action")
-		return nil
-		// TODO(dagang): compare and update if needed.
+		var updatedDeployment = getUpdatedDeployment(
+			desiredDeployment, observedDeployment)
+		if updatedDeployment == nil {
+			log.Info("Deployment already exists, no change")
+			return nil
+		}
+		// Updating the deployments restarts the running job, so a savepoint
+		// needs to be taken first.
+		if reconciler.isJobUpgradePending() {
+			log.Info("Deferring deployment update until the job is savepointed")
+			return nil
+		}
+		return reconciler.updateDeployment(updatedDeployment, component)
 	}
 
 	if desiredDeployment == nil && observedDeployment != nil {
@@ -118,6 +463,12 @@ func (reconciler *_ClusterReconciler) createDeployment(
 		log.Error(err, "Failed to create deployment")
 	} else {
 		log.Info("Deployment created")
+		// JobManager submits the job itself in Application mode.
+		if component == "JobManager" &&
+			isApplicationMode(reconciler.observedState.cluster) {
+			recordJobSubmission(
+				deployment.Namespace, flinkoperatorv1alpha1.JobMode.Application)
+		}
 	}
 	return err
 }
@@ -164,14 +515,44 @@ func (reconciler *_ClusterReconciler) reconcileJobManagerService() error {
 	}
 
 	if desiredJmService != nil && observedJmService != nil {
-		reconciler.log.Info("JobManager service already exists, no action")
-		return nil
-		// TODO(dagang): compare and update if needed.
+		var updatedJmService = getUpdatedService(
+			desiredJmService, observedJmService)
+		if updatedJmService == nil {
+			reconciler.log.Info("JobManager service already exists, no change")
+			return nil
+		}
+		return reconciler.updateService(updatedJmService, "JobManager")
 	}
 
 	if desiredJmService == nil && observedJmService != nil {
 		return reconciler.deleteService(observedJmService, "JobManager")
-		// TODO(dagang): compare and update if needed.
+	}
+
+	return nil
+}
+
+// Reconciles the JobManager ingress. Annotations added by ingress controllers
+// are kept, only the desired annotations are enforced on update.
+func (reconciler *_ClusterReconciler) reconcileJobManagerIngress() error {
+	var desiredJmIngress = reconciler.desiredState.JmIngress
+	var observedJmIngress = reconciler.observedState.jmIngress
+
+	if desiredJmIngress != nil && observedJmIngress == nil {
+		return reconciler.createObject(desiredJmIngress, "JobManager ingress")
+	}
+
+	if desiredJmIngress != nil && observedJmIngress != nil {
+		var updatedJmIngress = getUpdatedIngress(
+			desiredJmIngress, observedJmIngress)
+		if updatedJmIngress == nil {
+			reconciler.log.Info("JobManager ingress already exists, no change")
+			return nil
+		}
+		return reconciler.updateObject(updatedJmIngress, "JobManager ingress")
+	}
+
+	if desiredJmIngress == nil && observedJmIngress != nil {
+		return reconciler.deleteObject(observedJmIngress, "JobManager ingress")
 	}
 
 	return nil
@@ -193,6 +574,22 @@ func (reconciler *_ClusterReconciler) createService(
 	return err
 }
 
+func (reconciler *_ClusterReconciler) updateService(
+	service *corev1.Service, component string) error {
+	var context = reconciler.context
+	var log = reconciler.log.WithValues("component", component)
+	var k8sClient = reconciler.k8sClient
+
+	log.Info("Updating service", "service", service)
+	var err = k8sClient.Update(context, service)
+	if err != nil {
+		log.Error(err, "Failed to update service")
+	} else {
+		log.Info("Service updated")
+	}
+	return err
+}
+
 func (reconciler *_ClusterReconciler) deleteService(
 	service *corev1.Service, component string) error {
 	var context = reconciler.context
@@ -214,24 +611,561 @@ func (reconciler *_ClusterReconciler) reconcileJob() error {
 	var log = reconciler.log
 	var desiredJob = reconciler.desiredState.Job
 	var observedJob = reconciler.observedState.job
+
+	if isApplicationMode(reconciler.observedState.cluster) {
+		return reconciler.reconcileApplicationJob()
+	}
+
+	if desiredJob == nil {
+		return nil
+	}
+
+	if observedJob == nil {
+		if !reconciler.isClusterReady() {
+			log.Info("Skip creating job, waiting for other components to be ready")
+			return nil
+		}
+		var err = reconciler.createJob(desiredJob)
+		if err != nil {
+			return err
+		}
+		err = reconciler.recordSpecSavepoint()
+		if err != nil {
+			return err
+		}
+		// The job has been resubmitted from the savepoint, which completes the
+		// upgrade.
+		var jobStatus = reconciler.observedState.cluster.Status.Components.Job
+		if jobStatus != nil && len(jobStatus.UpgradeState) > 0 {
+			log.Info("Job upgrade completed", "fromSavepoint", jobStatus.FromSavepoint)
+			return reconciler.updateJobStatus(
+				func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+					jobStatus.UpgradeState = ""
+				})
+		}
+		return nil
+	}
+
+	if observedJob.ObjectMeta.DeletionTimestamp != nil {
+		log.Info("Job is being deleted, waiting for it to be gone")
+		return nil
+	}
+
+	var jobStatus = reconciler.observedState.cluster.Status.Components.Job
+	if shouldRestartJob(reconciler.observedState.cluster, jobStatus) {
+		return reconciler.restartJob(jobStatus, observedJob)
+	}
+	if shouldResetRestartCount(jobStatus, reconciler.observedState.flinkJob) {
+		log.Info("Job has been running stably since the last restart, " +
+			"resetting the restart count")
+		return reconciler.updateJobStatus(
+			func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+				jobStatus.RestartCount = 0
+			})
+	}
+
+	if !isJobUpgradeRequired(desiredJob, observedJob) {
+		log.Info("Job already exists, no change")
+		return nil
+	}
+
+	return reconciler.upgradeJob(observedJob)
+}
+
+// Reconciles the job run inside JobManager in Application mode. The job is
+// upgraded by updating the JobManager deployment, which restarts the job with
+// the new spec once the running job has been savepointed and cancelled.
+func (reconciler *_ClusterReconciler) reconcileApplicationJob() error {
+	var log = reconciler.log
+	var desiredDeployment = reconciler.desiredState.JmDeployment
+	var observedDeployment = reconciler.observedState.jmDeployment
+
+	if desiredDeployment == nil || observedDeployment == nil {
+		return nil
+	}
+
+	var jobStatus = reconciler.observedState.cluster.Status.Components.Job
+	if !isJobUpgradeRequired(desiredDeployment, observedDeployment) {
+		// JobManager has been updated to run the job from the savepoint, which
+		// completes the upgrade.
+		if jobStatus != nil && len(jobStatus.UpgradeState) > 0 {
+			var err = reconciler.recordSpecSavepoint()
+			if err != nil {
+				return err
+			}
+			log.Info("Job upgrade completed", "fromSavepoint", jobStatus.FromSavepoint)
+			recordJobSubmission(
+				observedDeployment.Namespace,
+				flinkoperatorv1alpha1.JobMode.Application)
+			return reconciler.updateJobStatus(
+				func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+					jobStatus.UpgradeState = ""
+				})
+		}
+		log.Info("Job already exists, no change")
+		return nil
+	}
+
+	return reconciler.upgradeJob(nil)
+}
+
+// Checks whether all the components are ready and up to date for the job to be
+// submitted.
+func (reconciler *_ClusterReconciler) isClusterReady() bool {
 	var observedClusterComponents = reconciler.observedState.cluster.Status.Components
-	if desiredJob != nil {
+	var jmDeploymentReady = observedClusterComponents.JobManagerDeployment.State ==
+		flinkoperatorv1alpha1.ClusterComponentState.Ready
+	var jmServiceReady = observedClusterComponents.JobManagerService.State ==
+		flinkoperatorv1alpha1.ClusterComponentState.Ready
+	var tmDeploymentReady = observedClusterComponents.TaskManagerDeployment.State ==
+		flinkoperatorv1alpha1.ClusterComponentState.Ready
+	if !jmDeploymentReady || !jmServiceReady || !tmDeploymentReady {
+		return false
+	}
+	for _, poolStatus := range observedClusterComponents.TaskManagerPools {
+		if poolStatus.State != flinkoperatorv1alpha1.ClusterComponentState.Ready {
+			return false
+		}
+	}
+
+	// The recorded state might be stale if the deployments are being updated
+	// in this round.
+	var desiredState = reconciler.desiredState
+	var observedState = reconciler.observedState
+	if observedState.jmDeployment == nil ||
+		getUpdatedDeployment(
+			desiredState.JmDeployment, observedState.jmDeployment) != nil {
+		return false
+	}
+	for _, desiredPoolDeployment := range desiredState.TmPoolDeployments {
+		var poolName = desiredPoolDeployment.ObjectMeta.Labels[taskManagerPoolLabel]
+		var observedPoolDeployment = observedState.tmPoolDeployments[poolName]
+		if observedPoolDeployment == nil ||
+			getUpdatedDeployment(
+				desiredPoolDeployment, observedPoolDeployment) != nil {
+			return false
+		}
+	}
+	if desiredState.TmStatefulSet != nil {
+		return observedState.tmStatefulSet != nil &&
+			getUpdatedStatefulSet(
+				desiredState.TmStatefulSet, observedState.tmStatefulSet) == nil
+	}
+	return observedState.tmDeployment != nil &&
+		getUpdatedDeployment(
+			desiredState.TmDeployment, observedState.tmDeployment) == nil
+}
+
+// Checks whether the observed job needs to be upgraded to the desired job. In
+// Application mode, the JobManager deployment is updated once the running job
+// has been cancelled for the upgrade.
+func (reconciler *_ClusterReconciler) isJobUpgradePending() bool {
+	if isApplicationMode(reconciler.observedState.cluster) {
+		var desiredDeployment = reconciler.desiredState.JmDeployment
+		var observedDeployment = reconciler.observedState.jmDeployment
+		var jobStatus = reconciler.observedState.cluster.Status.Components.Job
+		return desiredDeployment != nil && observedDeployment != nil &&
+			isJobUpgradeRequired(desiredDeployment, observedDeployment) &&
+			(jobStatus == nil || jobStatus.UpgradeState !=
+				flinkoperatorv1alpha1.JobUpgradeState.Resubmitting)
+	}
+	var desiredJob = reconciler.desiredState.Job
+	var observedJob = reconciler.observedState.job
+	return desiredJob != nil && observedJob != nil &&
+		isJobUpgradeRequired(desiredJob, observedJob)
+}
+
+// Upgrades the job to the desired spec. A savepoint is taken for the running
+// job first, then the job is cancelled and resubmitted from the savepoint. The
+// progress is recorded in the job status, so the upgrade can be resumed in the
+// following reconcile requests. The observed job is nil in Application mode.
+func (reconciler *_ClusterReconciler) upgradeJob(observedJob *batchv1.Job) error {
+	var log = reconciler.log
+	var jobStatus = reconciler.observedState.cluster.Status.Components.Job
+
+	if jobStatus == nil {
+		log.Info("Skip upgrading job, waiting for the job status")
+		return nil
+	}
+
+	switch jobStatus.UpgradeState {
+	case flinkoperatorv1alpha1.JobUpgradeState.TakingSavepoint:
+		return reconciler.checkSavepointForUpgrade(jobStatus, observedJob)
+	case flinkoperatorv1alpha1.JobUpgradeState.Resubmitting:
+		return reconciler.cancelJobForUpgrade(jobStatus, observedJob)
+	}
+
+	switch jobStatus.State {
+	case flinkoperatorv1alpha1.JobState.Pending:
+		if len(jobStatus.ID) > 0 {
+			log.Info("Skip upgrading job, waiting for the Flink job to start")
+			return nil
+		}
+		// The job has not been submitted yet, there is no state to keep.
+		log.Info("Job is pending, resubmitting it without savepoint")
 		if observedJob == nil {
-			var jmDeploymentReady = observedClusterComponents.JobManagerDeployment.State ==
-				flinkoperatorv1alpha1.ClusterComponentState.Ready
-			var jmServiceReady = observedClusterComponents.JobManagerService.State ==
-				flinkoperatorv1alpha1.ClusterComponentState.Ready
-			var tmDeploymentReady = observedClusterComponents.TaskManagerDeployment.State ==
-				flinkoperatorv1alpha1.ClusterComponentState.Ready
-			if jmDeploymentReady && jmServiceReady && tmDeploymentReady {
-				return reconciler.createJob(desiredJob)
+			return reconciler.updateJobStatus(
+				func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+					jobStatus.UpgradeState =
+						flinkoperatorv1alpha1.JobUpgradeState.Resubmitting
+				})
+		}
+		return reconciler.deleteJob(observedJob)
+	case flinkoperatorv1alpha1.JobState.Running:
+		if len(jobStatus.ID) == 0 {
+			log.Info("Skip upgrading job, waiting for the Flink job ID")
+			return nil
+		}
+		return reconciler.triggerSavepointForUpgrade(jobStatus)
+	default:
+		log.Info("Skip upgrading job, the job is not running",
+			"state", jobStatus.State)
+		return nil
+	}
+}
+
+func (reconciler *_ClusterReconciler) triggerSavepointForUpgrade(
+	jobStatus *flinkoperatorv1alpha1.JobStatus) error {
+	var log = reconciler.log
+	var cluster = reconciler.observedState.cluster
+
+	log.Info("Triggering savepoint for job upgrade", "jobID", jobStatus.ID)
+	var triggerID, err = reconciler.flinkClient.TriggerSavepoint(
+		reconciler.context,
+		getFlinkAPIBaseURL(cluster),
+		jobStatus.ID,
+		cluster.Spec.JobSpec.SavepointsDir,
+		false /* cancelJob */)
+	if err != nil {
+		log.Error(err, "Failed to trigger savepoint")
+		return err
+	}
+	log.Info("Savepoint triggered", "triggerID", triggerID)
+	return reconciler.updateJobStatus(
+		func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+			jobStatus.UpgradeState =
+				flinkoperatorv1alpha1.JobUpgradeState.TakingSavepoint
+			jobStatus.SavepointTriggerID = triggerID
+		})
+}
+
+func (reconciler *_ClusterReconciler) checkSavepointForUpgrade(
+	jobStatus *flinkoperatorv1alpha1.JobStatus, observedJob *batchv1.Job) error {
+	var log = reconciler.log
+	var cluster = reconciler.observedState.cluster
+
+	var savepointStatus, err = reconciler.flinkClient.GetSavepointStatus(
+		reconciler.context,
+		getFlinkAPIBaseURL(cluster),
+		jobStatus.ID,
+		jobStatus.SavepointTriggerID)
+	if err != nil {
+		log.Error(err, "Failed to get savepoint status")
+		return err
+	}
+	if !savepointStatus.IsCompleted() {
+		log.Info("Savepoint in progress", "status", savepointStatus.Status.ID)
+		return nil
+	}
+
+	var location = savepointStatus.Operation.Location
+	if !savepointStatus.IsSuccessful() {
+		// Reset the upgrade state, so it will be retried.
+		err = fmt.Errorf(
+			"savepoint %v failed: %v",
+			jobStatus.SavepointTriggerID,
+			savepointStatus.FailureReason())
+		log.Error(err, "Failed to take savepoint for job upgrade")
+		var updateErr = reconciler.updateJobStatus(
+			func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+				jobStatus.UpgradeState = ""
+				jobStatus.SavepointTriggerID = ""
+			})
+		if updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+
+	log.Info("Savepoint completed", "location", location)
+	// The savepoint newly set in the job spec takes precedence over the one
+	// just taken, which is still recorded as the last savepoint.
+	var fromSavepoint = location
+	if isSpecSavepointChanged(cluster) {
+		fromSavepoint = *cluster.Spec.JobSpec.Savepoint
+		log.Info("Resubmitting job from the savepoint in the job spec",
+			"savepoint", fromSavepoint)
+	}
+	err = reconciler.updateJobStatus(
+		func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+			jobStatus.UpgradeState =
+				flinkoperatorv1alpha1.JobUpgradeState.Resubmitting
+			jobStatus.SavepointTriggerID = ""
+			jobStatus.SavepointLocation = location
+			jobStatus.FromSavepoint = fromSavepoint
+		})
+	if err != nil {
+		return err
+	}
+	return reconciler.cancelJobForUpgrade(jobStatus, observedJob)
+}
+
+// Deletes the job submitter then cancels the Flink job, so the submitter will
+// not restart the old job once it is cancelled.
+func (reconciler *_ClusterReconciler) cancelJobForUpgrade(
+	jobStatus *flinkoperatorv1alpha1.JobStatus, observedJob *batchv1.Job) error {
+	var log = reconciler.log
+	var cluster = reconciler.observedState.cluster
+
+	// There is no submitter in Application mode.
+	if observedJob != nil {
+		var err = reconciler.deleteJob(observedJob)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(jobStatus.ID) > 0 {
+		log.Info("Cancelling job for upgrade", "jobID", jobStatus.ID)
+		var err = reconciler.flinkClient.CancelJob(
+			reconciler.context, getFlinkAPIBaseURL(cluster), jobStatus.ID)
+		// The job might have already been cancelled and removed.
+		if err != nil && !flinkclient.IsNotFound(err) {
+			log.Error(err, "Failed to cancel job")
+			return err
+		}
+	}
+
+	// The resubmitted job will have a new ID.
+	return reconciler.updateJobStatus(
+		func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+			jobStatus.ID = ""
+		})
+}
+
+// Restarts the failed job from its latest checkpoint or savepoint after the
+// backoff, which is doubled for each restart. The submitter is deleted, then
+// it is recreated with the restore path recorded in the job status.
+func (reconciler *_ClusterReconciler) restartJob(
+	jobStatus *flinkoperatorv1alpha1.JobStatus, observedJob *batchv1.Job) error {
+	var log = reconciler.log
+	var cluster = reconciler.observedState.cluster
+	var jobSpec = cluster.Spec.JobSpec
+
+	// The submitter might not have been deleted yet when the failure is
+	// observed again.
+	var failureTime = getJobFailureTime(&reconciler.observedState)
+	if lastRestartTime, err := time.Parse(
+		time.RFC3339, jobStatus.LastRestartTime); err == nil &&
+		!failureTime.IsZero() &&
+		!failureTime.Truncate(time.Second).After(lastRestartTime) {
+		log.Info("Job has been restarted, waiting for it to be resubmitted")
+		return nil
+	}
+
+	var backoff = time.Duration(*jobSpec.RestartBackoffSeconds) * time.Second
+	backoff <<= uint(jobStatus.RestartCount)
+	if time.Now().Before(failureTime.Add(backoff)) {
+		log.Info("Waiting for the backoff to restart the job",
+			"failureTime", failureTime, "backoff", backoff)
+		return nil
+	}
+
+	// The savepoint newly set in the job spec takes precedence over the
+	// checkpoints of the failed job.
+	var fromSavepoint = getFromSavepoint(cluster)
+	if !isSpecSavepointChanged(cluster) {
+		var latestPath, err = reconciler.getRestorePath(jobStatus.ID)
+		if err != nil {
+			return err
+		}
+		if latestPath != nil {
+			fromSavepoint = latestPath
+		}
+	}
+
+	var err = reconciler.deleteJob(observedJob)
+	if err != nil {
+		return err
+	}
+
+	var restartCount = jobStatus.RestartCount + 1
+	var restorePath = ""
+	if fromSavepoint != nil {
+		restorePath = *fromSavepoint
+	}
+	log.Info("Restarting job",
+		"attempt", restartCount, "fromSavepoint", restorePath)
+	reconciler.eventRecorder.Event(
+		cluster,
+		"Normal",
+		"JobRestarting",
+		fmt.Sprintf(
+			"Restarting failed job, attempt %v of %v, from savepoint %q",
+			restartCount, *jobSpec.MaxRestartAttempts, restorePath))
+	// The resubmitted job will have a new ID.
+	err = reconciler.updateJobStatus(
+		func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+			jobStatus.ID = ""
+			jobStatus.State = flinkoperatorv1alpha1.JobState.Pending
+			jobStatus.RestartCount = restartCount
+			jobStatus.LastRestartTime = time.Now().Format(time.RFC3339)
+			jobStatus.FromSavepoint = restorePath
+		})
+	if err == nil {
+		recordJobRestart(cluster.Namespace)
+	}
+	return err
+}
+
+// Decides the parallelism of the running job from the observed metrics of its
+// vertices, and records it in the job status if it changes. The new
+// parallelism changes the desired job, so the job is savepointed and
+// resubmitted with it by the job upgrade in the following reconcile requests.
+func (reconciler *_ClusterReconciler) reconcileAutoscaler() error {
+	var log = reconciler.log
+	var cluster = reconciler.observedState.cluster
+	var jobSpec = cluster.Spec.JobSpec
+	if jobSpec == nil || jobSpec.Autoscaler == nil {
+		return nil
+	}
+
+	var jobStatus = cluster.Status.Components.Job
+	var flinkJob = reconciler.observedState.flinkJob
+	var vertices = reconciler.observedState.flinkJobMetrics
+	if jobStatus == nil ||
+		jobStatus.State != flinkoperatorv1alpha1.JobState.Running ||
+		len(jobStatus.UpgradeState) > 0 || reconciler.isJobUpgradePending() ||
+		flinkJob == nil || vertices == nil {
+		return nil
+	}
+
+	// Leave the job some time to stabilize after it starts or is rescaled.
+	var stableSince = time.Unix(0, flinkJob.StartTime*int64(time.Millisecond))
+	if lastRescaleTime, err := time.Parse(
+		time.RFC3339, jobStatus.LastRescaleTime); err == nil &&
+		lastRescaleTime.After(stableSince) {
+		stableSince = lastRescaleTime
+	}
+	var window = time.Duration(
+		*jobSpec.Autoscaler.StabilizationWindowSeconds) * time.Second
+	if time.Now().Before(stableSince.Add(window)) {
+		log.Info("Skip autoscaling, waiting for the job to stabilize",
+			"stableSince", stableSince, "window", window)
+		return nil
+	}
+
+	var parallelism = *getJobParallelism(cluster)
+	var newParallelism, reason = getAutoscaledParallelism(
+		jobSpec.Autoscaler, parallelism, vertices)
+	if newParallelism == parallelism {
+		log.Info("Job parallelism is up to date", "parallelism", parallelism)
+		return nil
+	}
+
+	log.Info("Rescaling job",
+		"parallelism", parallelism, "newParallelism", newParallelism,
+		"reason", reason)
+	reconciler.eventRecorder.Event(
+		cluster,
+		"Normal",
+		"JobRescaling",
+		fmt.Sprintf(
+			"Rescaling job from parallelism %v to %v, %v",
+			parallelism, newParallelism, reason))
+	var err = reconciler.updateJobStatus(
+		func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+			jobStatus.Parallelism = newParallelism
+			jobStatus.LastRescaleTime = time.Now().Format(time.RFC3339)
+		})
+	if err == nil {
+		recordJobRescale(cluster.Namespace)
+	}
+	return err
+}
+
+// Gets the time the job failed, from the end time of the Flink job, or the
+// time the submitter failed if the Flink job is not available. Returns the
+// zero time if neither is known.
+func getJobFailureTime(observedState *_ObservedClusterState) time.Time {
+	var flinkJob = observedState.flinkJob
+	if flinkJob != nil && flinkJob.EndTime > 0 {
+		return time.Unix(0, flinkJob.EndTime*int64(time.Millisecond))
+	}
+	if observedState.job != nil {
+		for _, condition := range observedState.job.Status.Conditions {
+			if condition.Type == batchv1.JobFailed &&
+				condition.Status == corev1.ConditionTrue {
+				return condition.LastTransitionTime.Time
 			}
-			log.Info("Skip creating job, waiting for other components to be ready")
-		} else {
-			log.Info("Job already exists, no action")
 		}
 	}
-	return nil
+	return time.Time{}
+}
+
+// Gets the path of the latest checkpoint or savepoint the failed job can be
+// restored from, returns nil if there is none or the job is not found, e.g.,
+// JobManager has been restarted.
+func (reconciler *_ClusterReconciler) getRestorePath(jobID string) (
+	*string, error) {
+	if len(jobID) == 0 {
+		return nil, nil
+	}
+	var log = reconciler.log
+	var apiBaseURL = getFlinkAPIBaseURL(reconciler.observedState.cluster)
+	var checkpoints, err = reconciler.flinkClient.GetCheckpoints(
+		reconciler.context, apiBaseURL, jobID)
+	if err != nil {
+		if flinkclient.IsNotFound(err) {
+			return nil, nil
+		}
+		log.Error(err, "Failed to get checkpoints")
+		return nil, err
+	}
+	config, err := reconciler.flinkClient.GetCheckpointConfig(
+		reconciler.context, apiBaseURL, jobID)
+	if err != nil {
+		if flinkclient.IsNotFound(err) {
+			return nil, nil
+		}
+		log.Error(err, "Failed to get checkpoint config")
+		return nil, err
+	}
+	return getLatestCheckpointPath(
+		checkpoints, config.Externalization.Enabled), nil
+}
+
+// Gets the external path of the latest completed checkpoint or savepoint of
+// the job, including the one it was restored from, returns nil if there is
+// none. Checkpoints are skipped unless they are retained externally, otherwise
+// they are discarded with the job. Checkpoints and savepoints share the same
+// increasing IDs.
+func getLatestCheckpointPath(
+	checkpoints *flinkclient.Checkpoints, checkpointsRetained bool) *string {
+	var latestID int64 = -1
+	var latestPath *string
+	var candidates = []*flinkclient.CheckpointStatistics{
+		checkpoints.Latest.Completed, checkpoints.Latest.Savepoint}
+	if restored := checkpoints.Latest.Restored; restored != nil {
+		candidates = append(candidates, &flinkclient.CheckpointStatistics{
+			ID:           restored.ID,
+			IsSavepoint:  restored.IsSavepoint,
+			ExternalPath: restored.ExternalPath,
+		})
+	}
+	for _, candidate := range candidates {
+		if candidate == nil || len(candidate.ExternalPath) == 0 ||
+			candidate.ExternalPath == checkpointNotExternallyAddressable ||
+			(!candidate.IsSavepoint && !checkpointsRetained) {
+			continue
+		}
+		if candidate.ID > latestID {
+			latestID = candidate.ID
+			var path = candidate.ExternalPath
+			latestPath = &path
+		}
+	}
+	return latestPath
 }
 
 func (reconciler *_ClusterReconciler) createJob(job *batchv1.Job) error {
@@ -245,6 +1179,690 @@ func (reconciler *_ClusterReconciler) createJob(job *batchv1.Job) error {
 		log.Info("Failed to created job", "error", err)
 	} else {
 		log.Info("Job created")
+		recordJobSubmission(job.Namespace, flinkoperatorv1alpha1.JobMode.Client)
+	}
+	return err
+}
+
+// Compares the desired deployment with the observed deployment, returns a copy
+// of the observed deployment with the changes which can be applied in place,
+// or nil if they are already consistent. Fields defaulted by the API server
+// are kept untouched, so only the fields generated from the FlinkCluster spec
+// are compared.
+func getUpdatedDeployment(
+	desiredDeployment *appsv1.Deployment,
+	observedDeployment *appsv1.Deployment) *appsv1.Deployment {
+	var updatedDeployment = observedDeployment.DeepCopy()
+	var changed = false
+	if !equality.Semantic.DeepEqual(
+		desiredDeployment.Spec.Replicas, observedDeployment.Spec.Replicas) {
+		updatedDeployment.Spec.Replicas = desiredDeployment.Spec.Replicas
+		changed = true
+	}
+	if updatePodTemplate(
+		&desiredDeployment.Spec.Template,
+		&updatedDeployment.Spec.Template) {
+		changed = true
+	}
+	// The hash of the job spec the JobManager runs the job with in Application
+	// mode.
+	var desiredHash, ok = desiredDeployment.Annotations[jobSpecHashAnnotation]
+	if ok && observedDeployment.Annotations[jobSpecHashAnnotation] != desiredHash {
+		if updatedDeployment.Annotations == nil {
+			updatedDeployment.Annotations = map[string]string{}
+		}
+		updatedDeployment.Annotations[jobSpecHashAnnotation] = desiredHash
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return updatedDeployment
+}
+
+// Compares the desired StatefulSet with the observed StatefulSet, returns a
+// copy of the observed StatefulSet with the changes which can be applied in
+// place, or nil if they are already consistent.
+func getUpdatedStatefulSet(
+	desiredStatefulSet *appsv1.StatefulSet,
+	observedStatefulSet *appsv1.StatefulSet) *appsv1.StatefulSet {
+	var updatedStatefulSet = observedStatefulSet.DeepCopy()
+	var changed = false
+	if !equality.Semantic.DeepEqual(
+		desiredStatefulSet.Spec.Replicas, observedStatefulSet.Spec.Replicas) {
+		updatedStatefulSet.Spec.Replicas = desiredStatefulSet.Spec.Replicas
+		changed = true
+	}
+	if updatePodTemplate(
+		&desiredStatefulSet.Spec.Template,
+		&updatedStatefulSet.Spec.Template) {
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return updatedStatefulSet
+}
+
+// Updates the observed pod template with the config hash and the pod spec of
+// the desired pod template, returns whether it is changed. A change of the
+// config hash restarts the pods with the new config files.
+func updatePodTemplate(
+	desiredTemplate *corev1.PodTemplateSpec,
+	observedTemplate *corev1.PodTemplateSpec) bool {
+	var changed = false
+	var desiredHash = desiredTemplate.Annotations[configHashAnnotation]
+	if observedTemplate.Annotations[configHashAnnotation] != desiredHash {
+		if observedTemplate.Annotations == nil {
+			observedTemplate.Annotations = map[string]string{}
+		}
+		observedTemplate.Annotations[configHashAnnotation] = desiredHash
+		changed = true
+	}
+	if updatePodSpec(&desiredTemplate.Spec, &observedTemplate.Spec) {
+		changed = true
+	}
+	return changed
+}
+
+// Updates the observed pod spec with the images, resources and environment
+// variables of the desired pod spec, returns whether it is changed. Volumes
+// which are missing, e.g., the config volume for deployments created by an
+// earlier version of the operator, are added, and the config volume is
+// replaced as a whole.
+func updatePodSpec(
+	desiredPodSpec *corev1.PodSpec, observedPodSpec *corev1.PodSpec) bool {
+	var changed = false
+	if !equality.Semantic.DeepEqual(
+		desiredPodSpec.ImagePullSecrets, observedPodSpec.ImagePullSecrets) {
+		observedPodSpec.ImagePullSecrets = desiredPodSpec.ImagePullSecrets
+		changed = true
+	}
+	for _, desiredVolume := range desiredPodSpec.Volumes {
+		var found = false
+		for i := range observedPodSpec.Volumes {
+			var observedVolume = &observedPodSpec.Volumes[i]
+			if observedVolume.Name != desiredVolume.Name {
+				continue
+			}
+			found = true
+			// The mode of the files is defaulted by the API server, only the
+			// ConfigMap is compared.
+			if observedVolume.Name == configVolumeName &&
+				(observedVolume.ConfigMap == nil ||
+					observedVolume.ConfigMap.Name != desiredVolume.ConfigMap.Name) {
+				observedVolume.VolumeSource = desiredVolume.VolumeSource
+				changed = true
+			}
+			break
+		}
+		if !found {
+			observedPodSpec.Volumes = append(observedPodSpec.Volumes, desiredVolume)
+			changed = true
+		}
+	}
+	for _, desiredContainer := range desiredPodSpec.Containers {
+		for i := range observedPodSpec.Containers {
+			var observedContainer = &observedPodSpec.Containers[i]
+			if observedContainer.Name != desiredContainer.Name {
+				continue
+			}
+			if updateContainer(&desiredContainer, observedContainer) {
+				changed = true
+			}
+		}
+	}
+	// The init containers are added or removed as a whole, e.g., when the job
+	// gets remote files in Application mode.
+	for _, desiredContainer := range desiredPodSpec.InitContainers {
+		var found = false
+		for i := range observedPodSpec.InitContainers {
+			var observedContainer = &observedPodSpec.InitContainers[i]
+			if observedContainer.Name != desiredContainer.Name {
+				continue
+			}
+			found = true
+			if updateContainer(&desiredContainer, observedContainer) {
+				changed = true
+			}
+		}
+		if !found {
+			observedPodSpec.InitContainers =
+				append(observedPodSpec.InitContainers, desiredContainer)
+			changed = true
+		}
+	}
+	if len(desiredPodSpec.InitContainers) < len(observedPodSpec.InitContainers) {
+		var initContainers = []corev1.Container{}
+		for _, observedContainer := range observedPodSpec.InitContainers {
+			for _, desiredContainer := range desiredPodSpec.InitContainers {
+				if observedContainer.Name == desiredContainer.Name {
+					initContainers = append(initContainers, observedContainer)
+				}
+			}
+		}
+		observedPodSpec.InitContainers = initContainers
+		changed = true
+	}
+	return changed
+}
+
+// Updates the observed container with the image, resources, environment
+// variables, command, arguments and ports of the desired container, replaces
+// the mounts of the config files, and adds the other missing volume mounts,
+// returns whether it is changed. The config files are mounted by subPath, so a
+// mount of a file which has been removed from the ConfigMap would keep the
+// pods from starting.
+func updateContainer(
+	desiredContainer *corev1.Container,
+	observedContainer *corev1.Container) bool {
+	var changed = false
+	if desiredContainer.Image != observedContainer.Image {
+		observedContainer.Image = desiredContainer.Image
+		changed = true
+	}
+	// Empty pull policy is defaulted by the API server.
+	if len(desiredContainer.ImagePullPolicy) > 0 &&
+		desiredContainer.ImagePullPolicy != observedContainer.ImagePullPolicy {
+		observedContainer.ImagePullPolicy = desiredContainer.ImagePullPolicy
+		changed = true
+	}
+	// Empty requests are defaulted to the limits by the API server.
+	if !equality.Semantic.DeepEqual(
+		desiredContainer.Resources.Limits,
+		observedContainer.Resources.Limits) ||
+		(len(desiredContainer.Resources.Requests) > 0 &&
+			!equality.Semantic.DeepEqual(
+				desiredContainer.Resources.Requests,
+				observedContainer.Resources.Requests)) {
+		observedContainer.Resources = desiredContainer.Resources
+		changed = true
+	}
+	if !equality.Semantic.DeepEqual(
+		desiredContainer.Env, observedContainer.Env) {
+		observedContainer.Env = desiredContainer.Env
+		changed = true
+	}
+	if !equality.Semantic.DeepEqual(
+		desiredContainer.Command, observedContainer.Command) {
+		observedContainer.Command = desiredContainer.Command
+		changed = true
+	}
+	if !equality.Semantic.DeepEqual(
+		desiredContainer.Args, observedContainer.Args) {
+		observedContainer.Args = desiredContainer.Args
+		changed = true
+	}
+	if !equality.Semantic.DeepEqual(
+		desiredContainer.EnvFrom, observedContainer.EnvFrom) {
+		observedContainer.EnvFrom = desiredContainer.EnvFrom
+		changed = true
+	}
+	if !isContainerPortsEqual(
+		desiredContainer.Ports, observedContainer.Ports) {
+		observedContainer.Ports = desiredContainer.Ports
+		changed = true
+	}
+	var desiredConfigMounts, _ = splitConfigVolumeMounts(
+		desiredContainer.VolumeMounts)
+	var observedConfigMounts, observedOtherMounts = splitConfigVolumeMounts(
+		observedContainer.VolumeMounts)
+	if !equality.Semantic.DeepEqual(desiredConfigMounts, observedConfigMounts) {
+		observedContainer.VolumeMounts = append(
+			observedOtherMounts, desiredConfigMounts...)
+		changed = true
+	}
+	for _, desiredMount := range desiredContainer.VolumeMounts {
+		var found = false
+		for _, observedMount := range observedContainer.VolumeMounts {
+			if observedMount.MountPath == desiredMount.MountPath {
+				found = true
+				break
+			}
+		}
+		if !found {
+			observedContainer.VolumeMounts =
+				append(observedContainer.VolumeMounts, desiredMount)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Splits the volume mounts into the mounts of the config volume and the other
+// mounts, keeping their order.
+func splitConfigVolumeMounts(
+	mounts []corev1.VolumeMount) ([]corev1.VolumeMount, []corev1.VolumeMount) {
+	var configMounts, otherMounts []corev1.VolumeMount
+	for _, mount := range mounts {
+		if mount.Name == configVolumeName {
+			configMounts = append(configMounts, mount)
+		} else {
+			otherMounts = append(otherMounts, mount)
+		}
+	}
+	return configMounts, otherMounts
+}
+
+// Compares the names and numbers of the container ports, the protocol is
+// defaulted by the API server.
+func isContainerPortsEqual(
+	desiredPorts []corev1.ContainerPort,
+	observedPorts []corev1.ContainerPort) bool {
+	if len(desiredPorts) != len(observedPorts) {
+		return false
+	}
+	for i := range desiredPorts {
+		if desiredPorts[i].Name != observedPorts[i].Name ||
+			desiredPorts[i].ContainerPort != observedPorts[i].ContainerPort {
+			return false
+		}
+	}
+	return true
+}
+
+// Compares the desired service with the observed service, returns a copy of
+// the observed service with the changes applied, or nil if they are already
+// consistent. The cluster IP and node ports allocated by the API server are
+// preserved.
+func getUpdatedService(
+	desiredService *corev1.Service,
+	observedService *corev1.Service) *corev1.Service {
+	var updatedService = observedService.DeepCopy()
+	var changed = false
+	if desiredService.Spec.Type != observedService.Spec.Type {
+		updatedService.Spec.Type = desiredService.Spec.Type
+		changed = true
+	}
+	for key, value := range desiredService.Annotations {
+		if observedValue, ok := observedService.Annotations[key]; !ok ||
+			observedValue != value {
+			if updatedService.Annotations == nil {
+				updatedService.Annotations = map[string]string{}
+			}
+			updatedService.Annotations[key] = value
+			changed = true
+		}
+	}
+	if !isServicePortsEqual(
+		desiredService.Spec.Ports, observedService.Spec.Ports) {
+		var ports = []corev1.ServicePort{}
+		for _, desiredPort := range desiredService.Spec.Ports {
+			for _, observedPort := range observedService.Spec.Ports {
+				if observedPort.Name == desiredPort.Name {
+					desiredPort.NodePort = observedPort.NodePort
+				}
+			}
+			ports = append(ports, desiredPort)
+		}
+		updatedService.Spec.Ports = ports
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return updatedService
+}
+
+// Gets the updated ingress if the observed ingress differs from the desired
+// one in its spec or the desired annotations, otherwise returns nil.
+func getUpdatedIngress(
+	desiredIngress *networkingv1beta1.Ingress,
+	observedIngress *networkingv1beta1.Ingress) *networkingv1beta1.Ingress {
+	var annotationsChanged = false
+	for key, value := range desiredIngress.Annotations {
+		if observedValue, ok := observedIngress.Annotations[key]; !ok ||
+			observedValue != value {
+			annotationsChanged = true
+			break
+		}
+	}
+	if !annotationsChanged &&
+		equality.Semantic.DeepEqual(desiredIngress.Spec, observedIngress.Spec) {
+		return nil
+	}
+
+	var updatedIngress = observedIngress.DeepCopy()
+	if updatedIngress.Annotations == nil {
+		updatedIngress.Annotations = map[string]string{}
+	}
+	for key, value := range desiredIngress.Annotations {
+		updatedIngress.Annotations[key] = value
+	}
+	updatedIngress.Spec = desiredIngress.Spec
+	return updatedIngress
+}
+
+func isServicePortsEqual(
+	desiredPorts []corev1.ServicePort, observedPorts []corev1.ServicePort) bool {
+	if len(desiredPorts) != len(observedPorts) {
+		return false
+	}
+	for i := range desiredPorts {
+		if desiredPorts[i].Name != observedPorts[i].Name ||
+			desiredPorts[i].Port != observedPorts[i].Port ||
+			desiredPorts[i].TargetPort != observedPorts[i].TargetPort {
+			return false
+		}
+	}
+	return true
+}
+
+func (reconciler *_ClusterReconciler) deleteJob(job *batchv1.Job) error {
+	var context = reconciler.context
+	var log = reconciler.log
+	var k8sClient = reconciler.k8sClient
+
+	// Delete the pods of the job before the job, so there will not be more than
+	// one job pod when it is resubmitted.
+	var deletePolicy = metav1.DeletePropagationForeground
+	log.Info("Deleting job", "job", job)
+	var err = k8sClient.Delete(
+		context, job, client.PropagationPolicy(deletePolicy))
+	err = client.IgnoreNotFound(err)
+	if err != nil {
+		log.Error(err, "Failed to delete job")
+	} else {
+		log.Info("Job deleted")
+	}
+	return err
+}
+
+// Records the savepoint in the job spec once the job has been submitted from
+// it, so the savepoints taken by the operator take precedence over it again.
+func (reconciler *_ClusterReconciler) recordSpecSavepoint() error {
+	var cluster = reconciler.observedState.cluster
+	if !isSpecSavepointChanged(cluster) {
+		return nil
+	}
+	var savepoint = *cluster.Spec.JobSpec.Savepoint
+	return reconciler.updateJobStatus(
+		func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+			jobStatus.FromSavepoint = savepoint
+			jobStatus.SpecSavepoint = &savepoint
+		})
+}
+
+// Updates the job status of the latest version of the cluster, so it will not
+// conflict with the status update earlier in the same reconcile request.
+func (reconciler *_ClusterReconciler) updateJobStatus(
+	update func(jobStatus *flinkoperatorv1alpha1.JobStatus)) error {
+	var jobStatus *flinkoperatorv1alpha1.JobStatus
+	var err = updateLatestClusterStatus(
+		reconciler.context,
+		reconciler.k8sClient,
+		reconciler.observedState.cluster,
+		func(cluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+			if cluster.Status.Components.Job == nil {
+				cluster.Status.Components.Job = &flinkoperatorv1alpha1.JobStatus{}
+			}
+			update(cluster.Status.Components.Job)
+			jobStatus = cluster.Status.Components.Job
+			return true
+		})
+	if err != nil {
+		reconciler.log.Error(err, "Failed to update job status")
+		return err
+	}
+	// Keep the observed state consistent with the recorded status.
+	reconciler.observedState.cluster.Status.Components.Job = jobStatus
+	return nil
+}
+
+// Adds the savepoint finalizer if the savepoint on deletion is enabled, or
+// removes it if disabled.
+func (reconciler *_ClusterReconciler) reconcileFinalizer() error {
+	var cluster = reconciler.observedState.cluster
+	var enabled = isSavepointOnDeleteEnabled(cluster)
+	if enabled == hasFinalizer(cluster.Finalizers, savepointOnDeleteFinalizer) {
+		return nil
+	}
+	return reconciler.updateCluster(
+		func(cluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+			var found = hasFinalizer(
+				cluster.Finalizers, savepointOnDeleteFinalizer)
+			if enabled && !found {
+				reconciler.log.Info("Adding finalizer")
+				cluster.Finalizers = append(
+					cluster.Finalizers, savepointOnDeleteFinalizer)
+				return true
+			}
+			if !enabled && found {
+				reconciler.log.Info("Removing finalizer")
+				cluster.Finalizers = removeFinalizer(
+					cluster.Finalizers, savepointOnDeleteFinalizer)
+				return true
+			}
+			return false
+		})
+}
+
+// Cancels the job with a savepoint, then removes the finalizer so the cluster
+// can be deleted. The progress is recorded in the job status, so it can be
+// resumed in the following reconcile requests. The finalizer is released
+// without the savepoint if the job is not running, the timeout is exceeded or
+// the cluster is annotated for force deletion.
+func (reconciler *_ClusterReconciler) finalize() error {
+	var log = reconciler.log
+	var cluster = reconciler.observedState.cluster
+	if !hasFinalizer(cluster.Finalizers, savepointOnDeleteFinalizer) {
+		return nil
+	}
+
+	if cluster.Annotations[forceDeleteAnnotation] == "true" {
+		log.Info("Force deleting the cluster without savepoint")
+		reconciler.eventRecorder.Event(
+			cluster,
+			"Warning",
+			"SavepointSkipped",
+			"Force deleting the cluster without savepoint")
+		return reconciler.releaseFinalizer("")
+	}
+
+	// The savepoint might be disabled after the deletion started.
+	if !isSavepointOnDeleteEnabled(cluster) {
+		return reconciler.releaseFinalizer("")
+	}
+
+	var timeout = time.Duration(
+		*cluster.Spec.JobSpec.SavepointOnDeleteTimeoutSeconds) * time.Second
+	if time.Now().After(cluster.DeletionTimestamp.Add(timeout)) {
+		log.Info("Savepoint on deletion timed out", "timeout", timeout)
+		reconciler.eventRecorder.Event(
+			cluster,
+			"Warning",
+			"SavepointSkipped",
+			fmt.Sprintf(
+				"Savepoint was not taken in %v, deleting the cluster", timeout))
+		return reconciler.releaseFinalizer("")
+	}
+
+	var jobStatus = cluster.Status.Components.Job
+	if jobStatus != nil && len(jobStatus.SavepointTriggerID) > 0 &&
+		len(jobStatus.UpgradeState) == 0 {
+		return reconciler.checkSavepointForDeletion(jobStatus)
+	}
+
+	var jobID = ""
+	if jobStatus != nil &&
+		jobStatus.State == flinkoperatorv1alpha1.JobState.Running {
+		jobID = jobStatus.ID
+	}
+	// The job status might not have caught up with the job, e.g., the job was
+	// submitted right before the deletion, so the job is looked up through
+	// JobManager before the finalizer is released.
+	if len(jobID) == 0 {
+		var activeJob, err = reconciler.getActiveJob()
+		if err != nil {
+			return err
+		}
+		if activeJob == nil {
+			log.Info("Job is not running, deleting the cluster without savepoint")
+			return reconciler.releaseFinalizer("")
+		}
+		if activeJob.State != flinkclient.JobState.Running {
+			log.Info("Waiting for the job to run before cancelling it with savepoint",
+				"jobID", activeJob.ID, "state", activeJob.State)
+			return nil
+		}
+		jobID = activeJob.ID
+	}
+
+	// Delete the job submitter first, so it will not resubmit the job once it
+	// is cancelled.
+	if reconciler.observedState.job != nil {
+		var err = reconciler.deleteJob(reconciler.observedState.job)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info("Cancelling job with savepoint", "jobID", jobID)
+	var triggerID, err = reconciler.flinkClient.TriggerSavepoint(
+		reconciler.context,
+		getFlinkAPIBaseURL(cluster),
+		jobID,
+		cluster.Spec.JobSpec.SavepointsDir,
+		true /* cancelJob */)
+	if err != nil {
+		log.Error(err, "Failed to trigger savepoint")
+		return err
+	}
+	log.Info("Savepoint triggered", "triggerID", triggerID)
+	return reconciler.updateJobStatus(
+		func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+			jobStatus.ID = jobID
+			jobStatus.UpgradeState = ""
+			jobStatus.SavepointTriggerID = triggerID
+		})
+}
+
+// Looks up the job which is not terminated through JobManager, the one started
+// last if there are more. Returns nil if there is no such job, or JobManager is
+// gone. Fails if JobManager is not reachable, so the lookup is retried until
+// the savepoint on deletion times out.
+func (reconciler *_ClusterReconciler) getActiveJob() (
+	*flinkclient.JobOverview, error) {
+	if reconciler.observedState.jmDeployment == nil {
+		return nil, nil
+	}
+	var overview, err = reconciler.flinkClient.GetJobsOverview(
+		reconciler.context, getFlinkAPIBaseURL(reconciler.observedState.cluster))
+	if err != nil {
+		reconciler.log.Error(err, "Failed to get jobs overview")
+		return nil, err
+	}
+	// The jobs are not listed in a particular order.
+	var activeJob *flinkclient.JobOverview
+	for i := range overview.Jobs {
+		var job = &overview.Jobs[i]
+		if flinkclient.IsJobTerminated(job.State) {
+			continue
+		}
+		if activeJob == nil || job.StartTime > activeJob.StartTime {
+			activeJob = job
+		}
+	}
+	return activeJob, nil
+}
+
+func (reconciler *_ClusterReconciler) checkSavepointForDeletion(
+	jobStatus *flinkoperatorv1alpha1.JobStatus) error {
+	var log = reconciler.log
+	var cluster = reconciler.observedState.cluster
+
+	var savepointStatus, err = reconciler.flinkClient.GetSavepointStatus(
+		reconciler.context,
+		getFlinkAPIBaseURL(cluster),
+		jobStatus.ID,
+		jobStatus.SavepointTriggerID)
+	if err != nil {
+		log.Error(err, "Failed to get savepoint status")
+		return err
+	}
+	if !savepointStatus.IsCompleted() {
+		log.Info("Savepoint in progress", "status", savepointStatus.Status.ID)
+		return nil
+	}
+
+	if !savepointStatus.IsSuccessful() {
+		// Reset the trigger ID, so it will be retried until the timeout.
+		err = fmt.Errorf(
+			"savepoint %v failed: %v",
+			jobStatus.SavepointTriggerID,
+			savepointStatus.FailureReason())
+		log.Error(err, "Failed to take savepoint on deletion")
+		reconciler.eventRecorder.Event(
+			cluster, "Warning", "SavepointFailed", err.Error())
+		var updateErr = reconciler.updateJobStatus(
+			func(jobStatus *flinkoperatorv1alpha1.JobStatus) {
+				jobStatus.SavepointTriggerID = ""
+			})
+		if updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+
+	var location = savepointStatus.Operation.Location
+	log.Info("Savepoint completed", "location", location)
+	reconciler.eventRecorder.Event(
+		cluster,
+		"Normal",
+		"SavepointCreated",
+		fmt.Sprintf("Job cancelled with savepoint %v", location))
+	return reconciler.releaseFinalizer(location)
+}
+
+// Removes the savepoint finalizer, and records the location of the final
+// savepoint in the annotations if it is not empty.
+func (reconciler *_ClusterReconciler) releaseFinalizer(location string) error {
+	reconciler.log.Info("Removing finalizer")
+	return reconciler.updateCluster(
+		func(cluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+			if len(location) > 0 {
+				if cluster.Annotations == nil {
+					cluster.Annotations = map[string]string{}
+				}
+				cluster.Annotations[finalSavepointAnnotation] = location
+			}
+			cluster.Finalizers = removeFinalizer(
+				cluster.Finalizers, savepointOnDeleteFinalizer)
+			return true
+		})
+}
+
+// Applies the update to the metadata or spec of the latest version of the
+// cluster, retrying on conflicts. The update function returns false if there
+// is nothing to write.
+func (reconciler *_ClusterReconciler) updateCluster(
+	update func(cluster *flinkoperatorv1alpha1.FlinkCluster) bool) error {
+	var observedCluster = reconciler.observedState.cluster
+	var key = types.NamespacedName{
+		Namespace: observedCluster.Namespace,
+		Name:      observedCluster.Name,
+	}
+	var err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var cluster = &flinkoperatorv1alpha1.FlinkCluster{}
+		var err = reconciler.k8sClient.Get(reconciler.context, key, cluster)
+		if err != nil {
+			return err
+		}
+		if !update(cluster) {
+			return nil
+		}
+		return reconciler.k8sClient.Update(reconciler.context, cluster)
+	})
+	if err != nil {
+		reconciler.log.Error(err, "Failed to update cluster")
 	}
 	return err
 }
+
+// Checks whether the observed job was submitted with a different spec from the
+// desired job. Jobs submitted before the hash was recorded are never
+// upgraded. In Application mode, the JobManager deployments are compared.
+func isJobUpgradeRequired(desiredJob metav1.Object, observedJob metav1.Object) bool {
+	var observedHash, ok = observedJob.GetAnnotations()[jobSpecHashAnnotation]
+	return ok && observedHash != desiredJob.GetAnnotations()[jobSpecHashAnnotation]
+}
@@ -0,0 +1,793 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient/flinkclienttest"
+	"gotest.tools/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newTestDeployment(
+	replicas int32, image string, memoryLimit string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "taskmanager",
+							Image: image,
+							Resources: corev1.ResourceRequirements{
+								Limits: map[corev1.ResourceName]resource.Quantity{
+									"memory": resource.MustParse(memoryLimit),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Tests no update is generated for a deployment which is up to date, even if
+// the API server has defaulted some of its fields.
+func TestGetUpdatedDeploymentNoChange(t *testing.T) {
+	var desired = newTestDeployment(2, "flink:1.8.1", "1Gi")
+	var observed = newTestDeployment(2, "flink:1.8.1", "1024Mi")
+	observed.Spec.Template.Spec.Containers[0].TerminationMessagePath =
+		"/dev/termination-log"
+	observed.Spec.Template.Spec.Containers[0].ImagePullPolicy = "IfNotPresent"
+
+	assert.Assert(t, getUpdatedDeployment(desired, observed) == nil)
+}
+
+// Tests the desired deployments and config do not change between reconcile
+// requests for the same spec, otherwise the pods would be restarted on every
+// reconcile. Go randomizes the iteration order of the Flink properties.
+func TestGetUpdatedDeploymentStableFlinkProperties(t *testing.T) {
+	var port int32 = 6123
+	var uiPort int32 = 8081
+	var replicas int32 = 1
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				Replicas:    &replicas,
+				AccessScope: flinkoperatorv1alpha1.AccessScope.Cluster,
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{
+					RPC: &port, Blob: &port, Query: &port, UI: &uiPort,
+				},
+			},
+			TaskManagerSpec: flinkoperatorv1alpha1.TaskManagerSpec{
+				Replicas: 2,
+				Ports: flinkoperatorv1alpha1.TaskManagerPorts{
+					Data: &port, RPC: &port, Query: &port,
+				},
+			},
+			FlinkProperties: map[string]string{},
+		},
+	}
+	for i := 0; i < 20; i++ {
+		cluster.Spec.FlinkProperties[fmt.Sprintf("my.property.%d", i)] = "value"
+	}
+
+	var first, err = getDesiredClusterState(cluster)
+	assert.NilError(t, err)
+	for i := 0; i < 10; i++ {
+		var desired, err = getDesiredClusterState(cluster)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, desired.ConfigMap.Data, first.ConfigMap.Data)
+		assert.Assert(
+			t, getUpdatedDeployment(desired.JmDeployment, first.JmDeployment) == nil)
+		assert.Assert(
+			t, getUpdatedDeployment(desired.TmDeployment, first.TmDeployment) == nil)
+	}
+}
+
+// Tests replicas and image changes are applied to the observed deployment.
+func TestGetUpdatedDeploymentChanged(t *testing.T) {
+	var desired = newTestDeployment(3, "flink:1.8.2", "1Gi")
+	var observed = newTestDeployment(2, "flink:1.8.1", "1Gi")
+	observed.ResourceVersion = "42"
+
+	var updated = getUpdatedDeployment(desired, observed)
+
+	assert.Assert(t, updated != nil)
+	assert.Equal(t, updated.ResourceVersion, "42")
+	assert.Equal(t, *updated.Spec.Replicas, int32(3))
+	assert.Equal(
+		t, updated.Spec.Template.Spec.Containers[0].Image, "flink:1.8.2")
+	// The observed deployment should not be modified.
+	assert.Equal(t, *observed.Spec.Replicas, int32(2))
+}
+
+// Tests a change of the config files restarts the pods through the config
+// hash annotation, and the missing config volume is added to deployments
+// created without it.
+func TestGetUpdatedDeploymentConfigChanged(t *testing.T) {
+	var desired = newTestDeployment(2, "flink:1.8.1", "1Gi")
+	desired.Spec.Template.Annotations = map[string]string{
+		configHashAnnotation: "2"}
+	desired.Spec.Template.Spec.Volumes = []corev1.Volume{
+		getConfigVolume("mycluster")}
+	desired.Spec.Template.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+		{
+			Name:      "flink-config-volume",
+			MountPath: "/opt/flink/conf/flink-conf.yaml",
+			SubPath:   "flink-conf.yaml",
+		},
+	}
+	var observed = newTestDeployment(2, "flink:1.8.1", "1Gi")
+
+	var updated = getUpdatedDeployment(desired, observed)
+
+	assert.Assert(t, updated != nil)
+	assert.Equal(t, updated.Spec.Template.Annotations[configHashAnnotation], "2")
+	assert.Equal(
+		t, updated.Spec.Template.Spec.Volumes[0].Name, "flink-config-volume")
+	assert.Equal(
+		t,
+		updated.Spec.Template.Spec.Containers[0].VolumeMounts[0].SubPath,
+		"flink-conf.yaml")
+	assert.Assert(t, getUpdatedDeployment(desired, updated) == nil)
+}
+
+// Tests the mount of a config file which has been removed from the spec is
+// removed, while the other mounts are kept.
+func TestGetUpdatedDeploymentConfigFileRemoved(t *testing.T) {
+	var newConfigMount = func(fileName string) corev1.VolumeMount {
+		return corev1.VolumeMount{
+			Name:      configVolumeName,
+			MountPath: "/opt/flink/conf/" + fileName,
+			SubPath:   fileName,
+		}
+	}
+	var dataMount = corev1.VolumeMount{Name: "data", MountPath: "/data"}
+	var desired = newTestDeployment(2, "flink:1.8.1", "1Gi")
+	desired.Spec.Template.Spec.Volumes = []corev1.Volume{
+		getConfigVolume("mycluster")}
+	desired.Spec.Template.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+		dataMount,
+		newConfigMount("flink-conf.yaml"),
+	}
+	var observed = newTestDeployment(2, "flink:1.8.1", "1Gi")
+	observed.Spec.Template.Spec.Volumes = []corev1.Volume{
+		getConfigVolume("mycluster")}
+	observed.Spec.Template.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+		dataMount,
+		newConfigMount("flink-conf.yaml"),
+		newConfigMount("log4j-console.properties"),
+	}
+
+	var updated = getUpdatedDeployment(desired, observed)
+
+	assert.Assert(t, updated != nil)
+	assert.DeepEqual(
+		t,
+		updated.Spec.Template.Spec.Containers[0].VolumeMounts,
+		[]corev1.VolumeMount{dataMount, newConfigMount("flink-conf.yaml")})
+	assert.Assert(t, getUpdatedDeployment(desired, updated) == nil)
+}
+
+// Tests replicas and pod template changes are applied to the observed
+// StatefulSet, while its immutable fields are kept.
+func TestGetUpdatedStatefulSetChanged(t *testing.T) {
+	var newTestStatefulSet = func(
+		replicas int32, image string) *appsv1.StatefulSet {
+		var deployment = newTestDeployment(replicas, image, "1Gi")
+		return &appsv1.StatefulSet{
+			Spec: appsv1.StatefulSetSpec{
+				Replicas:            deployment.Spec.Replicas,
+				Template:            deployment.Spec.Template,
+				ServiceName:         "mycluster-taskmanager",
+				PodManagementPolicy: appsv1.ParallelPodManagement,
+			},
+		}
+	}
+	var desired = newTestStatefulSet(3, "flink:1.8.2")
+	desired.Spec.Template.Annotations = map[string]string{
+		configHashAnnotation: "2"}
+	var observed = newTestStatefulSet(2, "flink:1.8.1")
+	observed.Spec.PodManagementPolicy = appsv1.OrderedReadyPodManagement
+
+	var updated = getUpdatedStatefulSet(desired, observed)
+
+	assert.Assert(t, updated != nil)
+	assert.Equal(t, *updated.Spec.Replicas, int32(3))
+	assert.Equal(
+		t, updated.Spec.Template.Spec.Containers[0].Image, "flink:1.8.2")
+	assert.Equal(t, updated.Spec.Template.Annotations[configHashAnnotation], "2")
+	assert.Equal(
+		t, updated.Spec.PodManagementPolicy, appsv1.OrderedReadyPodManagement)
+	assert.Assert(t, getUpdatedStatefulSet(desired, updated) == nil)
+}
+
+// Tests node ports allocated by the API server are preserved when the service
+// is updated.
+func TestGetUpdatedServicePreservesNodePort(t *testing.T) {
+	var desired = &corev1.Service{
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{
+				{Name: "ui", Port: 8081, TargetPort: intstr.FromString("ui")},
+			},
+		},
+	}
+	var observed = &corev1.Service{
+		Spec: corev1.ServiceSpec{
+			Type:      corev1.ServiceTypeLoadBalancer,
+			ClusterIP: "10.0.0.1",
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "ui",
+					Port:       8080,
+					TargetPort: intstr.FromString("ui"),
+					NodePort:   30000,
+				},
+			},
+		},
+	}
+
+	var updated = getUpdatedService(desired, observed)
+
+	assert.Assert(t, updated != nil)
+	assert.Equal(t, updated.Spec.ClusterIP, "10.0.0.1")
+	assert.Equal(t, updated.Spec.Ports[0].Port, int32(8081))
+	assert.Equal(t, updated.Spec.Ports[0].NodePort, int32(30000))
+}
+
+// Tests annotations added by the ingress controller are kept when the ingress
+// is updated.
+func TestGetUpdatedIngressKeepsControllerAnnotations(t *testing.T) {
+	var desired = &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"kubernetes.io/ingress.class": "nginx"},
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{{Host: "mycluster.example.com"}},
+		},
+	}
+	var observed = desired.DeepCopy()
+	observed.Annotations["ingress.kubernetes.io/backends"] = "{}"
+
+	assert.Assert(t, getUpdatedIngress(desired, observed) == nil)
+
+	desired.Spec.Rules[0].Host = "mycluster.default.example.com"
+	var updated = getUpdatedIngress(desired, observed)
+	assert.Assert(t, updated != nil)
+	assert.Equal(t, updated.Spec.Rules[0].Host, "mycluster.default.example.com")
+	assert.Equal(t, updated.Annotations["ingress.kubernetes.io/backends"], "{}")
+}
+
+func newTestClusterReconciler(
+	server *flinkclienttest.FakeServer,
+	cluster *flinkoperatorv1alpha1.FlinkCluster,
+	objects ...runtime.Object) (*_ClusterReconciler, client.Client) {
+	var scheme = runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	flinkoperatorv1alpha1.AddToScheme(scheme)
+	var k8sClient = fake.NewFakeClientWithScheme(
+		scheme, append(objects, cluster.DeepCopy())...)
+	return &_ClusterReconciler{
+		k8sClient:     k8sClient,
+		flinkClient:   server.NewClient(log.NullLogger{}),
+		context:       context.Background(),
+		log:           log.NullLogger{},
+		eventRecorder: record.NewFakeRecorder(10),
+		observedState: _ObservedClusterState{cluster: cluster},
+	}, k8sClient
+}
+
+// Creates a reconciler for a job cluster which is being deleted with the
+// savepoint finalizer.
+func newTestDeletedClusterReconciler(
+	server *flinkclienttest.FakeServer,
+	jobID string,
+	deletionTime time.Time,
+	annotations map[string]string) (*_ClusterReconciler, client.Client) {
+	var cluster = newTestRunningJobCluster(jobID)
+	var takeSavepointOnDelete = true
+	var timeoutSeconds int32 = 600
+	var deletionTimestamp = metav1.NewTime(deletionTime)
+	cluster.ObjectMeta.Annotations = annotations
+	cluster.ObjectMeta.Finalizers = []string{savepointOnDeleteFinalizer}
+	cluster.ObjectMeta.DeletionTimestamp = &deletionTimestamp
+	cluster.Spec.JobSpec.TakeSavepointOnDelete = &takeSavepointOnDelete
+	cluster.Spec.JobSpec.SavepointOnDeleteTimeoutSeconds = &timeoutSeconds
+	return newTestClusterReconciler(server, cluster)
+}
+
+func getTestCluster(
+	t *testing.T, k8sClient client.Client) *flinkoperatorv1alpha1.FlinkCluster {
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{}
+	var err = k8sClient.Get(
+		context.Background(),
+		types.NamespacedName{Namespace: "default", Name: "mycluster"},
+		cluster)
+	assert.NilError(t, err)
+	return cluster
+}
+
+// Tests the job is cancelled with a savepoint before the finalizer is removed,
+// and the location of the savepoint is recorded.
+func TestFinalizeClusterWithSavepoint(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var reconciler, k8sClient = newTestDeletedClusterReconciler(
+		server, jobID, time.Now(), nil)
+
+	var err = reconciler.reconcile()
+	assert.NilError(t, err)
+	assert.Equal(t, server.GetJob(jobID).State, flinkclient.JobState.Canceled)
+	var cluster = getTestCluster(t, k8sClient)
+	assert.Assert(t, len(cluster.Status.Components.Job.SavepointTriggerID) > 0)
+	assert.DeepEqual(
+		t, cluster.ObjectMeta.Finalizers, []string{savepointOnDeleteFinalizer})
+
+	err = reconciler.reconcile()
+	assert.NilError(t, err)
+	cluster = getTestCluster(t, k8sClient)
+	assert.Equal(t, len(cluster.ObjectMeta.Finalizers), 0)
+	assert.Assert(t, strings.HasPrefix(
+		cluster.ObjectMeta.Annotations[finalSavepointAnnotation],
+		"gs://my-bucket/savepoints/savepoint-"))
+}
+
+// Tests the running job is looked up through JobManager when it has not been
+// recorded in the job status, and the finalizer is only removed once there is
+// no running job.
+func TestFinalizeClusterWithUnrecordedJob(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var reconciler, k8sClient = newTestDeletedClusterReconciler(
+		server, "", time.Now(), nil)
+	reconciler.observedState.cluster.Status.Components.Job.State =
+		flinkoperatorv1alpha1.JobState.Pending
+	reconciler.observedState.jmDeployment = &appsv1.Deployment{}
+
+	var err = reconciler.reconcile()
+	assert.NilError(t, err)
+	assert.Equal(t, server.GetJob(jobID).State, flinkclient.JobState.Canceled)
+	var cluster = getTestCluster(t, k8sClient)
+	assert.Equal(t, cluster.Status.Components.Job.ID, jobID)
+	assert.Assert(t, len(cluster.Status.Components.Job.SavepointTriggerID) > 0)
+	assert.DeepEqual(
+		t, cluster.ObjectMeta.Finalizers, []string{savepointOnDeleteFinalizer})
+
+	// The finalizer is kept while JobManager is not reachable.
+	reconciler, k8sClient = newTestDeletedClusterReconciler(
+		server, "", time.Now(), nil)
+	reconciler.observedState.jmDeployment = &appsv1.Deployment{}
+	server.Close()
+	err = reconciler.reconcile()
+	assert.Assert(t, err != nil)
+	cluster = getTestCluster(t, k8sClient)
+	assert.DeepEqual(
+		t, cluster.ObjectMeta.Finalizers, []string{savepointOnDeleteFinalizer})
+
+	// The finalizer is removed once JobManager is gone.
+	reconciler.observedState.jmDeployment = nil
+	err = reconciler.reconcile()
+	assert.NilError(t, err)
+	cluster = getTestCluster(t, k8sClient)
+	assert.Equal(t, len(cluster.ObjectMeta.Finalizers), 0)
+}
+
+// Tests the finalizer is removed without savepoint when the cluster is
+// annotated for force deletion or the timeout is exceeded.
+func TestFinalizeClusterWithoutSavepoint(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		deletionTime time.Time
+		annotations  map[string]string
+	}{
+		{
+			name:         "force delete",
+			deletionTime: time.Now(),
+			annotations:  map[string]string{forceDeleteAnnotation: "true"},
+		},
+		{
+			name:         "timeout",
+			deletionTime: time.Now().Add(-time.Hour),
+		},
+	}
+
+	for _, testCase := range testCases {
+		var server = flinkclienttest.NewFakeServer()
+		var jobID = server.AddJob("job", flinkclient.JobState.Running)
+		var reconciler, k8sClient = newTestDeletedClusterReconciler(
+			server, jobID, testCase.deletionTime, testCase.annotations)
+
+		var err = reconciler.reconcile()
+		assert.NilError(t, err, testCase.name)
+		assert.Equal(
+			t,
+			server.GetJob(jobID).State,
+			flinkclient.JobState.Running,
+			testCase.name)
+		var cluster = getTestCluster(t, k8sClient)
+		assert.Equal(t, len(cluster.ObjectMeta.Finalizers), 0, testCase.name)
+		server.Close()
+	}
+}
+
+// Creates a reconciler for the job cluster of which the running job is
+// upgraded to a new spec.
+func newTestUpgradingJobReconciler(
+	server *flinkclienttest.FakeServer,
+	cluster *flinkoperatorv1alpha1.FlinkCluster) (*_ClusterReconciler, client.Client) {
+	var submitter = &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "mycluster-job",
+			Annotations: map[string]string{jobSpecHashAnnotation: "old"},
+		},
+	}
+	var desiredJob = submitter.DeepCopy()
+	desiredJob.ObjectMeta.Annotations[jobSpecHashAnnotation] = "new"
+	var reconciler, k8sClient = newTestClusterReconciler(
+		server, cluster, submitter.DeepCopy())
+	reconciler.observedState.job = submitter
+	reconciler.desiredState.Job = desiredJob
+	return reconciler, k8sClient
+}
+
+func getTestSubmitters(
+	t *testing.T, k8sClient client.Client) []batchv1.Job {
+	var submitters = &batchv1.JobList{}
+	var err = k8sClient.List(context.Background(), submitters)
+	assert.NilError(t, err)
+	return submitters.Items
+}
+
+// Tests the running job is savepointed, then the submitter is deleted and the
+// job is cancelled, so the job is resubmitted from the savepoint.
+func TestUpgradeJob(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var reconciler, k8sClient = newTestUpgradingJobReconciler(
+		server, newTestRunningJobCluster(jobID))
+
+	var err = reconciler.reconcileJob()
+	assert.NilError(t, err)
+	var jobStatus = getTestCluster(t, k8sClient).Status.Components.Job
+	assert.Equal(
+		t,
+		jobStatus.UpgradeState,
+		flinkoperatorv1alpha1.JobUpgradeState.TakingSavepoint)
+	assert.Assert(t, len(jobStatus.SavepointTriggerID) > 0)
+
+	err = reconciler.reconcileJob()
+	assert.NilError(t, err)
+	jobStatus = getTestCluster(t, k8sClient).Status.Components.Job
+	assert.Equal(
+		t,
+		jobStatus.UpgradeState,
+		flinkoperatorv1alpha1.JobUpgradeState.Resubmitting)
+	assert.Equal(t, jobStatus.SavepointTriggerID, "")
+	assert.Equal(t, jobStatus.ID, "")
+	assert.Assert(t, strings.HasPrefix(
+		jobStatus.FromSavepoint, "gs://my-bucket/savepoints/savepoint-"))
+	assert.Equal(t, jobStatus.SavepointLocation, jobStatus.FromSavepoint)
+	assert.Equal(t, server.GetJob(jobID).State, flinkclient.JobState.Canceled)
+	assert.Equal(t, len(getTestSubmitters(t, k8sClient)), 0)
+}
+
+// Tests the upgrade is reset when the savepoint fails, so it is retried, and
+// the job keeps running.
+func TestUpgradeJobSavepointFailed(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var reconciler, k8sClient = newTestUpgradingJobReconciler(
+		server, newTestRunningJobCluster(jobID))
+	server.SavepointFailure = true
+
+	var err = reconciler.reconcileJob()
+	assert.NilError(t, err)
+	err = reconciler.reconcileJob()
+	assert.ErrorContains(t, err, "savepoint")
+
+	var jobStatus = getTestCluster(t, k8sClient).Status.Components.Job
+	assert.Equal(t, jobStatus.UpgradeState, "")
+	assert.Equal(t, jobStatus.SavepointTriggerID, "")
+	assert.Equal(t, jobStatus.ID, jobID)
+	assert.Equal(t, jobStatus.FromSavepoint, "")
+	assert.Equal(t, server.GetJob(jobID).State, flinkclient.JobState.Running)
+	assert.Equal(t, len(getTestSubmitters(t, k8sClient)), 1)
+}
+
+// Tests the job is cancelled again in the following reconcile request when
+// cancelling it fails after the savepoint.
+func TestUpgradeJobCancelFailed(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var reconciler, k8sClient = newTestUpgradingJobReconciler(
+		server, newTestRunningJobCluster(jobID))
+	server.CancelFailure = true
+
+	var err = reconciler.reconcileJob()
+	assert.NilError(t, err)
+	err = reconciler.reconcileJob()
+	assert.Assert(t, err != nil)
+
+	var jobStatus = getTestCluster(t, k8sClient).Status.Components.Job
+	assert.Equal(
+		t,
+		jobStatus.UpgradeState,
+		flinkoperatorv1alpha1.JobUpgradeState.Resubmitting)
+	assert.Equal(t, jobStatus.ID, jobID)
+	assert.Assert(t, len(jobStatus.FromSavepoint) > 0)
+	assert.Equal(t, server.GetJob(jobID).State, flinkclient.JobState.Running)
+	assert.Equal(t, len(getTestSubmitters(t, k8sClient)), 0)
+
+	server.CancelFailure = false
+	err = reconciler.reconcileJob()
+	assert.NilError(t, err)
+	jobStatus = getTestCluster(t, k8sClient).Status.Components.Job
+	assert.Equal(t, jobStatus.ID, "")
+	assert.Equal(t, server.GetJob(jobID).State, flinkclient.JobState.Canceled)
+}
+
+// Tests the job is resubmitted from the savepoint newly set in the job spec
+// instead of the savepoint taken for the upgrade.
+func TestUpgradeJobSpecSavepointChanged(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var cluster = newTestRunningJobCluster(jobID)
+	var oldSavepoint = "gs://my-bucket/savepoints/savepoint-1"
+	var newSavepoint = "gs://my-bucket/savepoints/savepoint-2"
+	cluster.Spec.JobSpec.Savepoint = &newSavepoint
+	cluster.Status.Components.Job.FromSavepoint = oldSavepoint
+	cluster.Status.Components.Job.SpecSavepoint = &oldSavepoint
+	assert.Equal(t, *getFromSavepoint(cluster), newSavepoint)
+	var reconciler, k8sClient = newTestUpgradingJobReconciler(server, cluster)
+
+	var err = reconciler.reconcileJob()
+	assert.NilError(t, err)
+	err = reconciler.reconcileJob()
+	assert.NilError(t, err)
+
+	var jobStatus = getTestCluster(t, k8sClient).Status.Components.Job
+	assert.Equal(t, jobStatus.FromSavepoint, newSavepoint)
+	assert.Assert(t, jobStatus.SavepointLocation != newSavepoint)
+
+	// The savepoint in the spec is recorded once the job is resubmitted.
+	err = reconciler.recordSpecSavepoint()
+	assert.NilError(t, err)
+	jobStatus = getTestCluster(t, k8sClient).Status.Components.Job
+	assert.Equal(t, *jobStatus.SpecSavepoint, newSavepoint)
+	assert.Assert(t, !isSpecSavepointChanged(reconciler.observedState.cluster))
+}
+
+// Creates a reconciler for a job cluster of which the job has failed and will
+// be restarted from savepoint.
+func newTestFailedJobReconciler(
+	server *flinkclienttest.FakeServer,
+	jobID string,
+	failureTime time.Time) (*_ClusterReconciler, client.Client) {
+	var cluster = newTestRunningJobCluster(jobID)
+	var restartPolicy = corev1.RestartPolicy(
+		flinkoperatorv1alpha1.JobRestartPolicy.FromSavepointOnFailure)
+	var maxRestartAttempts int32 = 3
+	var restartBackoffSeconds int32 = 10
+	cluster.Spec.JobSpec.RestartPolicy = &restartPolicy
+	cluster.Spec.JobSpec.MaxRestartAttempts = &maxRestartAttempts
+	cluster.Spec.JobSpec.RestartBackoffSeconds = &restartBackoffSeconds
+	cluster.Status.Components.Job.State = flinkoperatorv1alpha1.JobState.Failed
+
+	var submitter = &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "mycluster-job",
+		},
+		Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{{
+				Type:               batchv1.JobFailed,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(failureTime),
+			}},
+		},
+	}
+	var reconciler, k8sClient = newTestClusterReconciler(
+		server, cluster, submitter.DeepCopy())
+	reconciler.observedState.job = submitter
+	reconciler.desiredState.Job = submitter
+	return reconciler, k8sClient
+}
+
+// Tests the failed job is resubmitted from the latest checkpoint, and the
+// restart is recorded in the job status.
+func TestRestartJobFromLatestCheckpoint(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Failed)
+	server.SetCheckpoints(jobID, flinkclient.Checkpoints{
+		Latest: flinkclient.LatestCheckpoints{
+			Completed: &flinkclient.CheckpointStatistics{
+				ID:           5,
+				ExternalPath: "gs://my-bucket/checkpoints/chk-5",
+			},
+			Savepoint: &flinkclient.CheckpointStatistics{
+				ID:           3,
+				IsSavepoint:  true,
+				ExternalPath: "gs://my-bucket/savepoints/savepoint-3",
+			},
+		},
+	})
+	server.SetCheckpointConfig(jobID, flinkclient.CheckpointConfig{
+		Externalization: flinkclient.CheckpointExternalization{Enabled: true},
+	})
+	var reconciler, k8sClient = newTestFailedJobReconciler(
+		server, jobID, time.Now().Add(-time.Minute))
+
+	var err = reconciler.reconcileJob()
+	assert.NilError(t, err)
+
+	var cluster = getTestCluster(t, k8sClient)
+	var jobStatus = cluster.Status.Components.Job
+	assert.Equal(t, jobStatus.ID, "")
+	assert.Equal(t, jobStatus.State, flinkoperatorv1alpha1.JobState.Pending)
+	assert.Equal(t, jobStatus.RestartCount, int32(1))
+	assert.Equal(t, jobStatus.FromSavepoint, "gs://my-bucket/checkpoints/chk-5")
+	assert.Assert(t, len(jobStatus.LastRestartTime) > 0)
+	var submitters = &batchv1.JobList{}
+	err = k8sClient.List(context.Background(), submitters)
+	assert.NilError(t, err)
+	assert.Equal(t, len(submitters.Items), 0)
+}
+
+// Tests the failed job is restarted from the latest savepoint when its
+// checkpoints are not retained externally.
+func TestRestartJobFromLatestSavepoint(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Failed)
+	server.SetCheckpoints(jobID, flinkclient.Checkpoints{
+		Latest: flinkclient.LatestCheckpoints{
+			Completed: &flinkclient.CheckpointStatistics{
+				ID:           5,
+				ExternalPath: checkpointNotExternallyAddressable,
+			},
+			Savepoint: &flinkclient.CheckpointStatistics{
+				ID:           3,
+				IsSavepoint:  true,
+				ExternalPath: "gs://my-bucket/savepoints/savepoint-3",
+			},
+			Restored: &flinkclient.RestoredCheckpointStatistics{
+				ID:           4,
+				ExternalPath: "gs://my-bucket/checkpoints/chk-4",
+			},
+		},
+	})
+	var reconciler, k8sClient = newTestFailedJobReconciler(
+		server, jobID, time.Now().Add(-time.Minute))
+
+	var err = reconciler.reconcileJob()
+	assert.NilError(t, err)
+
+	var cluster = getTestCluster(t, k8sClient)
+	assert.Equal(
+		t,
+		cluster.Status.Components.Job.FromSavepoint,
+		"gs://my-bucket/savepoints/savepoint-3")
+}
+
+// Tests the restart count is reset once the restarted job has been running
+// for the reset period.
+func TestResetRestartCount(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+
+	var testCases = []struct {
+		name             string
+		lastRestartTime  time.Time
+		wantRestartCount int32
+	}{
+		{
+			name:             "running stably",
+			lastRestartTime:  time.Now().Add(-restartCountResetPeriod - time.Minute),
+			wantRestartCount: 0,
+		},
+		{
+			name:             "recently restarted",
+			lastRestartTime:  time.Now().Add(-time.Minute),
+			wantRestartCount: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		var cluster = newTestRunningJobCluster(jobID)
+		var jobStatus = cluster.Status.Components.Job
+		jobStatus.RestartCount = 2
+		jobStatus.LastRestartTime = testCase.lastRestartTime.Format(time.RFC3339)
+		var submitter = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "mycluster-job",
+			},
+		}
+		var reconciler, k8sClient = newTestClusterReconciler(
+			server, cluster, submitter.DeepCopy())
+		reconciler.observedState.job = submitter
+		reconciler.observedState.flinkJob = server.GetJob(jobID)
+		reconciler.desiredState.Job = submitter
+
+		var err = reconciler.reconcileJob()
+		assert.NilError(t, err, testCase.name)
+		cluster = getTestCluster(t, k8sClient)
+		assert.Equal(
+			t,
+			cluster.Status.Components.Job.RestartCount,
+			testCase.wantRestartCount,
+			testCase.name)
+	}
+}
+
+// Tests the failed job is not restarted before the backoff, or after the max
+// attempts.
+func TestRestartJobNotAllowed(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Failed)
+
+	// The backoff of the second restart is 20 seconds.
+	var reconciler, k8sClient = newTestFailedJobReconciler(
+		server, jobID, time.Now().Add(-15*time.Second))
+	reconciler.observedState.cluster.Status.Components.Job.RestartCount = 1
+	var err = reconciler.reconcileJob()
+	assert.NilError(t, err)
+	var cluster = getTestCluster(t, k8sClient)
+	assert.Equal(t, cluster.Status.Components.Job.RestartCount, int32(0))
+
+	reconciler, _ = newTestFailedJobReconciler(
+		server, jobID, time.Now().Add(-time.Hour))
+	reconciler.observedState.cluster.Status.Components.Job.RestartCount = 3
+	assert.Assert(t, !shouldRestartJob(
+		reconciler.observedState.cluster,
+		reconciler.observedState.cluster.Status.Components.Job))
+}
@@ -22,12 +22,21 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"reflect"
 	"time"
 
 	"github.com/go-logr/logr"
 	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -54,7 +63,15 @@ func (updater *_ClusterStatusUpdater) updateClusterStatusIfChanged() error {
 	oldStatus.LastUpdateTime = ""
 
 	// New status derived from the cluster's components.
-	var newStatus = updater.deriveClusterStatus()
+	var newStatus, err = updater.deriveClusterStatus()
+	if err != nil {
+		updater.log.Error(err, "Failed to derive the cluster status")
+		var recordErr = updater.recordClusterFailure("InvalidStatus", err)
+		if recordErr != nil {
+			return recordErr
+		}
+		return err
+	}
 
 	// Compare
 	var changed = updater.isStatusChanged(oldStatus, newStatus)
@@ -68,7 +85,14 @@ func (updater *_ClusterStatusUpdater) updateClusterStatusIfChanged() error {
 			"new", newStatus)
 		updater.createStatusChangeEvents(oldStatus, newStatus)
 		newStatus.LastUpdateTime = time.Now().Format(time.RFC3339)
-		return updater.updateClusterStatus(newStatus)
+		err = updater.updateClusterStatus(newStatus)
+		if err == nil {
+			recordJobRunning(
+				&updater.observedState,
+				oldStatus.Components.Job,
+				newStatus.Components.Job)
+		}
+		return err
 	}
 
 	updater.log.Info("No status change", "state", oldStatus.State)
@@ -95,6 +119,18 @@ func (updater *_ClusterStatusUpdater) createStatusChangeEvents(
 			newStatus.Components.JobManagerService.State)
 	}
 
+	// (Optional) JobManager ingress.
+	var oldIngress = oldStatus.Components.JobManagerIngress
+	var newIngress = newStatus.Components.JobManagerIngress
+	if newIngress != nil && (oldIngress == nil || oldIngress.State != newIngress.State) {
+		var oldIngressState = ""
+		if oldIngress != nil {
+			oldIngressState = oldIngress.State
+		}
+		updater.createStatusChangeEvent(
+			"JobManager ingress", oldIngressState, newIngress.State)
+	}
+
 	// TaskManager.
 	if oldStatus.Components.TaskManagerDeployment.State !=
 		newStatus.Components.TaskManagerDeployment.State {
@@ -104,6 +140,32 @@ func (updater *_ClusterStatusUpdater) createStatusChangeEvents(
 			newStatus.Components.TaskManagerDeployment.State)
 	}
 
+	// (Optional) TaskManager pools.
+	for _, newPool := range newStatus.Components.TaskManagerPools {
+		var oldPoolState = ""
+		var oldPool = getTaskManagerPoolStatus(
+			oldStatus.Components.TaskManagerPools, newPool.Name)
+		if oldPool != nil {
+			oldPoolState = oldPool.State
+		}
+		if oldPoolState != newPool.State {
+			updater.createStatusChangeEvent(
+				"TaskManager pool "+newPool.Name, oldPoolState, newPool.State)
+		}
+	}
+
+	// JobManager leader.
+	var oldLeader = oldStatus.Components.JobManagerLeader
+	var newLeader = newStatus.Components.JobManagerLeader
+	if newLeader != nil && (oldLeader == nil || *oldLeader != *newLeader) {
+		var oldLeaderName = ""
+		if oldLeader != nil {
+			oldLeaderName = getJobManagerLeaderName(oldLeader)
+		}
+		updater.createStatusChangeEvent(
+			"JobManager leader", oldLeaderName, getJobManagerLeaderName(newLeader))
+	}
+
 	// Job.
 	if oldStatus.Components.Job == nil && newStatus.Components.Job != nil {
 		updater.createStatusChangeEvent(
@@ -117,10 +179,180 @@ func (updater *_ClusterStatusUpdater) createStatusChangeEvents(
 			newStatus.Components.Job.State)
 	}
 
+	// Job submitter.
+	var newJobStatus = newStatus.Components.Job
+	if !isSubmitterStateConsistent(newJobStatus) &&
+		(isSubmitterStateConsistent(oldStatus.Components.Job) ||
+			oldStatus.Components.Job.SubmitterState != newJobStatus.SubmitterState ||
+			oldStatus.Components.Job.FlinkJobState != newJobStatus.FlinkJobState) {
+		updater.eventRecorder.Event(
+			updater.observedState.cluster,
+			"Warning",
+			"JobStateMismatch",
+			fmt.Sprintf(
+				"Job submitter state %v disagrees with Flink job state %v",
+				newJobStatus.SubmitterState,
+				newJobStatus.FlinkJobState))
+	}
+
 	// Cluster.
 	if oldStatus.State != newStatus.State {
 		updater.createStatusChangeEvent("Cluster", oldStatus.State, newStatus.State)
 	}
+
+	// Conditions.
+	for _, newCondition := range newStatus.Conditions {
+		var oldCondition = getClusterCondition(
+			oldStatus.Conditions, newCondition.Type)
+		updater.createConditionChangeEvent(oldCondition, newCondition)
+	}
+}
+
+// Creates an event when the status of a condition changes. New conditions are
+// only reported when they are true, to avoid flooding the events of a new
+// cluster.
+func (updater *_ClusterStatusUpdater) createConditionChangeEvent(
+	oldCondition *flinkoperatorv1alpha1.FlinkClusterCondition,
+	newCondition flinkoperatorv1alpha1.FlinkClusterCondition) {
+	if oldCondition != nil && oldCondition.Status == newCondition.Status {
+		return
+	}
+	if oldCondition == nil && newCondition.Status != corev1.ConditionTrue {
+		return
+	}
+	var eventType = "Normal"
+	var conditionTypes = flinkoperatorv1alpha1.ClusterConditionType
+	if newCondition.Status == corev1.ConditionTrue &&
+		(newCondition.Type == conditionTypes.Degraded ||
+			newCondition.Type == conditionTypes.ReconcileError) {
+		eventType = "Warning"
+	}
+	var message = fmt.Sprintf(
+		"Condition %v changed to %v", newCondition.Type, newCondition.Status)
+	if len(newCondition.Message) > 0 {
+		message += ": " + newCondition.Message
+	}
+	updater.eventRecorder.Event(
+		updater.observedState.cluster, eventType, newCondition.Reason, message)
+}
+
+// Records the result of the actions taken by the reconciler in the
+// ReconcileError condition, the cluster status is only updated when the
+// condition changes.
+func (updater *_ClusterStatusUpdater) updateReconcileErrorCondition(
+	reconcileErr error) error {
+	if updater.observedState.cluster == nil {
+		return nil
+	}
+	var conditionType = flinkoperatorv1alpha1.ClusterConditionType.ReconcileError
+	var oldCondition *flinkoperatorv1alpha1.FlinkClusterCondition
+	var newCondition flinkoperatorv1alpha1.FlinkClusterCondition
+	var changed = false
+	var err = updateLatestClusterStatus(
+		updater.context,
+		updater.k8sClient,
+		updater.observedState.cluster,
+		func(cluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+			var conditions = cluster.Status.Conditions
+			if reconcileErr != nil {
+				newCondition = newClusterCondition(
+					conditions,
+					conditionType,
+					corev1.ConditionTrue,
+					"ReconcileFailed",
+					reconcileErr.Error(),
+					cluster.ObjectMeta.Generation)
+			} else {
+				newCondition = newClusterCondition(
+					conditions,
+					conditionType,
+					corev1.ConditionFalse,
+					"ReconcileSucceeded",
+					"",
+					cluster.ObjectMeta.Generation)
+			}
+			oldCondition = getClusterCondition(conditions, conditionType)
+			if oldCondition != nil && oldCondition.Status == newCondition.Status &&
+				oldCondition.Message == newCondition.Message {
+				changed = false
+				return false
+			}
+			oldCondition = oldCondition.DeepCopy()
+			cluster.Status.Conditions = setClusterCondition(
+				conditions, newCondition)
+			changed = true
+			return true
+		})
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if changed {
+		updater.createConditionChangeEvent(oldCondition, newCondition)
+	}
+	return nil
+}
+
+// Marks the cluster as failed when its status or desired state cannot be
+// derived, e.g., the spec is invalid. The reason is recorded in the
+// ReconcileError condition and a Warning event, the cluster stays failed until
+// it is reconciled successfully.
+func (updater *_ClusterStatusUpdater) recordClusterFailure(
+	reason string, failure error) error {
+	if updater.observedState.cluster == nil {
+		return nil
+	}
+	var conditionTypes = flinkoperatorv1alpha1.ClusterConditionType
+	var changed = false
+	var err = updateLatestClusterStatus(
+		updater.context,
+		updater.k8sClient,
+		updater.observedState.cluster,
+		func(cluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+			var status = &cluster.Status
+			var reconcileError = getClusterCondition(
+				status.Conditions, conditionTypes.ReconcileError)
+			if status.State == flinkoperatorv1alpha1.ClusterState.Failed &&
+				reconcileError != nil &&
+				reconcileError.Message == failure.Error() {
+				changed = false
+				return false
+			}
+			var generation = cluster.ObjectMeta.Generation
+			status.State = flinkoperatorv1alpha1.ClusterState.Failed
+			status.ObservedGeneration = generation
+			status.Conditions = setClusterCondition(
+				status.Conditions,
+				newClusterCondition(
+					status.Conditions,
+					conditionTypes.Ready,
+					corev1.ConditionFalse,
+					flinkoperatorv1alpha1.ClusterState.Failed,
+					"The cluster has failed",
+					generation))
+			status.Conditions = setClusterCondition(
+				status.Conditions,
+				newClusterCondition(
+					status.Conditions,
+					conditionTypes.ReconcileError,
+					corev1.ConditionTrue,
+					reason,
+					failure.Error(),
+					generation))
+			status.LastUpdateTime = time.Now().Format(time.RFC3339)
+			changed = true
+			return true
+		})
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if changed {
+		updater.eventRecorder.Event(
+			updater.observedState.cluster,
+			"Warning",
+			reason,
+			fmt.Sprintf("Cluster failed: %v", failure))
+	}
+	return nil
 }
 
 func (updater *_ClusterStatusUpdater) createStatusChangeEvent(
@@ -141,7 +373,8 @@ func (updater *_ClusterStatusUpdater) createStatusChangeEvent(
 	}
 }
 
-func (updater *_ClusterStatusUpdater) deriveClusterStatus() flinkoperatorv1alpha1.FlinkClusterStatus {
+func (updater *_ClusterStatusUpdater) deriveClusterStatus() (
+	flinkoperatorv1alpha1.FlinkClusterStatus, error) {
 	var status = flinkoperatorv1alpha1.FlinkClusterStatus{}
 	var runningComponents = 0
 	var recordedClusterStatus = &updater.observedState.cluster.Status
@@ -154,10 +387,7 @@ func (updater *_ClusterStatusUpdater) deriveClusterStatus() flinkoperatorv1alpha
 	if observedJmDeployment != nil {
 		status.Components.JobManagerDeployment.Name =
 			observedJmDeployment.ObjectMeta.Name
-		if observedJmDeployment.Status.AvailableReplicas <
-			observedJmDeployment.Status.Replicas ||
-			observedJmDeployment.Status.ReadyReplicas <
-				observedJmDeployment.Status.Replicas {
+		if !isDeploymentReady(observedJmDeployment) {
 			status.Components.JobManagerDeployment.State =
 				flinkoperatorv1alpha1.ClusterComponentState.NotReady
 		} else {
@@ -205,15 +435,46 @@ func (updater *_ClusterStatusUpdater) deriveClusterStatus() flinkoperatorv1alpha
 			}
 	}
 
-	// TaskManager deployment.
+	// (Optional) JobManager ingress, it is not counted in the running
+	// components because the cluster works without it.
+	var observedJmIngress = updater.observedState.jmIngress
+	if observedJmIngress != nil {
+		var state = flinkoperatorv1alpha1.ClusterComponentState.NotReady
+		if len(observedJmIngress.Status.LoadBalancer.Ingress) > 0 {
+			state = flinkoperatorv1alpha1.ClusterComponentState.Ready
+		}
+		status.Components.JobManagerIngress =
+			&flinkoperatorv1alpha1.JobManagerIngressStatus{
+				Name:  observedJmIngress.ObjectMeta.Name,
+				State: state,
+				URLs:  getJobManagerIngressURLs(observedJmIngress),
+			}
+	} else if recordedClusterStatus.Components.JobManagerIngress != nil {
+		status.Components.JobManagerIngress =
+			&flinkoperatorv1alpha1.JobManagerIngressStatus{
+				Name:  recordedClusterStatus.Components.JobManagerIngress.Name,
+				State: flinkoperatorv1alpha1.ClusterComponentState.Deleted,
+			}
+	}
+
+	// TaskManager deployment, or the StatefulSet which takes its place.
 	var observedTmDeployment = updater.observedState.tmDeployment
+	var observedTmStatefulSet = updater.observedState.tmStatefulSet
 	if observedTmDeployment != nil {
 		status.Components.TaskManagerDeployment.Name =
 			observedTmDeployment.ObjectMeta.Name
-		if observedTmDeployment.Status.AvailableReplicas <
-			observedTmDeployment.Status.Replicas ||
-			observedTmDeployment.Status.ReadyReplicas <
-				observedTmDeployment.Status.Replicas {
+		if !isDeploymentReady(observedTmDeployment) {
+			status.Components.TaskManagerDeployment.State =
+				flinkoperatorv1alpha1.ClusterComponentState.NotReady
+		} else {
+			status.Components.TaskManagerDeployment.State =
+				flinkoperatorv1alpha1.ClusterComponentState.Ready
+			runningComponents++
+		}
+	} else if observedTmStatefulSet != nil {
+		status.Components.TaskManagerDeployment.Name =
+			observedTmStatefulSet.ObjectMeta.Name
+		if !isStatefulSetReady(observedTmStatefulSet) {
 			status.Components.TaskManagerDeployment.State =
 				flinkoperatorv1alpha1.ClusterComponentState.NotReady
 		} else {
@@ -229,29 +490,115 @@ func (updater *_ClusterStatusUpdater) deriveClusterStatus() flinkoperatorv1alpha
 			}
 	}
 
+	// (Optional) TaskManager pools, each pool counts as a component.
+	var clusterSpec = updater.observedState.cluster.Spec
+	for _, poolSpec := range clusterSpec.TaskManagerPools {
+		totalComponents++
+		var poolStatus = flinkoperatorv1alpha1.TaskManagerPoolStatus{
+			Name: poolSpec.Name,
+			Deployment: getTaskManagerPoolDeploymentName(
+				updater.observedState.cluster.ObjectMeta.Name, poolSpec.Name),
+		}
+		var observedPoolDeployment = updater.observedState.tmPoolDeployments[poolSpec.Name]
+		if observedPoolDeployment != nil {
+			poolStatus.Replicas = observedPoolDeployment.Status.Replicas
+			poolStatus.ReadyReplicas = observedPoolDeployment.Status.ReadyReplicas
+			if !isDeploymentReady(observedPoolDeployment) {
+				poolStatus.State =
+					flinkoperatorv1alpha1.ClusterComponentState.NotReady
+			} else {
+				poolStatus.State =
+					flinkoperatorv1alpha1.ClusterComponentState.Ready
+				runningComponents++
+			}
+		} else if getTaskManagerPoolStatus(
+			recordedClusterStatus.Components.TaskManagerPools,
+			poolSpec.Name) != nil {
+			poolStatus.State = flinkoperatorv1alpha1.ClusterComponentState.Deleted
+		} else {
+			poolStatus.State = flinkoperatorv1alpha1.ClusterComponentState.NotReady
+		}
+		status.Components.TaskManagerPools = append(
+			status.Components.TaskManagerPools, poolStatus)
+	}
+
+	// TaskManager scale, for the scale subresource. The selector is set even
+	// without the deployment, so autoscalers can find the pods once it is
+	// created. It is the selector of the deployment, so the pods of the pools,
+	// which are scaled with the pools, are not selected.
+	var tmSelector, err = metav1.LabelSelectorAsSelector(getTaskManagerSelector(
+		updater.observedState.cluster.ObjectMeta.Name))
+	if err != nil {
+		return status, err
+	}
+	status.TaskManager = &flinkoperatorv1alpha1.TaskManagerStatus{
+		Selector: tmSelector.String(),
+	}
+	if observedTmDeployment != nil {
+		status.TaskManager.Replicas = observedTmDeployment.Status.Replicas
+	} else if observedTmStatefulSet != nil {
+		status.TaskManager.Replicas = observedTmStatefulSet.Status.Replicas
+	}
+
+	// (Optional) JobManager leader.
+	status.Components.JobManagerLeader = getJobManagerLeader(
+		updater.observedState.haLeaderConfigMap, updater.observedState.jmPods)
+
 	// (Optional) Job.
 	var jobFinished = false
 	var observedJob = updater.observedState.job
-	if observedJob != nil {
+	var recordedJobStatus = recordedClusterStatus.Components.Job
+	var isJobUpgrading = recordedJobStatus != nil &&
+		len(recordedJobStatus.UpgradeState) > 0
+	var isApplicationMode = isApplicationMode(updater.observedState.cluster)
+	if isApplicationMode && observedJmDeployment != nil {
+		// The job is run inside JobManager, there is no submitter.
+		status.Components.Job = new(flinkoperatorv1alpha1.JobStatus)
+		status.Components.Job.Name = observedJmDeployment.ObjectMeta.Name
+		var flinkJob = updater.observedState.flinkJob
+		var hasOldID = recordedJobStatus != nil && len(recordedJobStatus.ID) > 0
+		if flinkJob != nil {
+			status.Components.Job.FlinkJobState = flinkJob.State
+			status.Components.Job.State = getJobStateFromFlinkJobState(
+				flinkJob.State)
+		} else if hasOldID && len(recordedJobStatus.State) > 0 {
+			// Keep the recorded state if the Flink REST API is temporarily not
+			// available.
+			status.Components.Job.FlinkJobState = recordedJobStatus.FlinkJobState
+			status.Components.Job.State = recordedJobStatus.State
+		} else {
+			// The job has not been started by JobManager yet.
+			status.Components.Job.State = flinkoperatorv1alpha1.JobState.Pending
+		}
+		if updater.observedState.flinkJobID != nil {
+			status.Components.Job.ID = *updater.observedState.flinkJobID
+		} else if hasOldID {
+			status.Components.Job.ID = recordedJobStatus.ID
+		}
+	} else if observedJob != nil {
 		status.Components.Job = new(flinkoperatorv1alpha1.JobStatus)
 		status.Components.Job.Name = observedJob.ObjectMeta.Name
-		if observedJob.Status.Active > 0 {
-			var observedJobPod = updater.observedState.jobPod
-			// When job status is Active, it is possible that the pod is still
-			// Pending.
-			if observedJobPod != nil && observedJobPod.Status.Phase == "Pending" {
-				status.Components.Job.State = flinkoperatorv1alpha1.JobState.Pending
-			} else {
-				status.Components.Job.State = flinkoperatorv1alpha1.JobState.Running
-			}
-		} else if observedJob.Status.Failed > 0 {
-			status.Components.Job.State = flinkoperatorv1alpha1.JobState.Failed
-			jobFinished = true
-		} else if observedJob.Status.Succeeded > 0 {
-			status.Components.Job.State = flinkoperatorv1alpha1.JobState.Succeeded
-			jobFinished = true
+		status.Components.Job.SubmitterState = getSubmitterState(
+			observedJob, updater.observedState.jobPod)
+
+		// The state of the Flink job takes precedence over the state of the
+		// submitter, e.g., the submitter is still running while the Flink job is
+		// restarting.
+		var flinkJob = updater.observedState.flinkJob
+		if flinkJob != nil {
+			status.Components.Job.FlinkJobState = flinkJob.State
+			status.Components.Job.State = getJobStateFromFlinkJobState(
+				flinkJob.State)
+		} else if recordedJobStatus != nil &&
+			len(recordedJobStatus.FlinkJobState) > 0 &&
+			status.Components.Job.SubmitterState ==
+				flinkoperatorv1alpha1.JobState.Running {
+			// Keep the recorded state if the Flink REST API is temporarily not
+			// available while the job is still running.
+			status.Components.Job.FlinkJobState = recordedJobStatus.FlinkJobState
+			status.Components.Job.State = recordedJobStatus.State
 		} else {
-			status.Components.Job = nil
+			status.Components.Job.State = status.Components.Job.SubmitterState
 		}
 
 		// (Optional) Flink Job ID.
@@ -262,21 +609,71 @@ func (updater *_ClusterStatusUpdater) deriveClusterStatus() flinkoperatorv1alpha
 
 		// Keep the previous ID if no longer being able to retrieve the current ID,
 		// maybe the JobManager has been deleted, or transient error.
-		var hasOldID = (recordedClusterStatus.Components.Job != nil &&
-			len(recordedClusterStatus.Components.Job.ID) > 0)
+		var hasOldID = (recordedJobStatus != nil &&
+			len(recordedJobStatus.ID) > 0)
 		if !hasID && hasOldID {
-			status.Components.Job.ID = recordedClusterStatus.Components.Job.ID
+			status.Components.Job.ID = recordedJobStatus.ID
 		}
 
 		if hasID && hasOldID &&
-			status.Components.Job.ID != recordedClusterStatus.Components.Job.ID {
+			status.Components.Job.ID != recordedJobStatus.ID {
 			updater.log.Info(
 				"Flink job ID changed unexpectedly!",
 				"current",
-				recordedClusterStatus.Components.Job.ID,
+				recordedJobStatus.ID,
 				"new",
 				status.Components.Job.ID)
 		}
+	} else if recordedJobStatus != nil {
+		// The job is being resubmitted for upgrade or restart.
+		status.Components.Job = recordedJobStatus.DeepCopy()
+	}
+
+	// Keep the progress of the job upgrade and the savepoints, which are
+	// recorded by the reconciler.
+	if status.Components.Job != nil && recordedJobStatus != nil {
+		status.Components.Job.UpgradeState = recordedJobStatus.UpgradeState
+		status.Components.Job.SavepointTriggerID =
+			recordedJobStatus.SavepointTriggerID
+		status.Components.Job.SavepointLocation =
+			recordedJobStatus.SavepointLocation
+		status.Components.Job.FromSavepoint = recordedJobStatus.FromSavepoint
+		status.Components.Job.SpecSavepoint = recordedJobStatus.SpecSavepoint
+		status.Components.Job.RestartCount = recordedJobStatus.RestartCount
+		status.Components.Job.LastRestartTime = recordedJobStatus.LastRestartTime
+		status.Components.Job.LastRescaleTime = recordedJobStatus.LastRescaleTime
+		// The parallelism decided by the autoscaler is dropped once the
+		// autoscaler is removed, the job spec takes effect again.
+		var jobSpec = updater.observedState.cluster.Spec.JobSpec
+		if jobSpec != nil && jobSpec.Autoscaler != nil {
+			status.Components.Job.Parallelism = recordedJobStatus.Parallelism
+		}
+	}
+
+	// The job is first submitted from the savepoint in the job spec, later
+	// changes of it are recorded by the reconciler once the job is resubmitted.
+	if status.Components.Job != nil &&
+		status.Components.Job.SpecSavepoint == nil {
+		var specSavepoint = ""
+		var jobSpec = updater.observedState.cluster.Spec.JobSpec
+		if jobSpec != nil && jobSpec.Savepoint != nil {
+			specSavepoint = *jobSpec.Savepoint
+		}
+		status.Components.Job.SpecSavepoint = &specSavepoint
+	}
+
+	// A failed job which will be restarted by the operator is not finished.
+	// The submitter waits for the job it submitted, so a terminated job is
+	// resubmitted when the pod of the submitter is restarted, unless the
+	// operator restarts the job itself.
+	if observedJob != nil || (isApplicationMode && observedJmDeployment != nil) {
+		jobFinished = isJobTerminated(status.Components.Job.State) &&
+			!isJobUpgrading &&
+			!shouldRestartJob(updater.observedState.cluster, status.Components.Job)
+		if !isApplicationMode &&
+			!isRestartFromSavepointEnabled(updater.observedState.cluster) {
+			jobFinished = jobFinished && isSubmitterTerminated(observedJob)
+		}
 	}
 
 	// Derive the new cluster state.
@@ -288,7 +685,10 @@ func (updater *_ClusterStatusUpdater) deriveClusterStatus() flinkoperatorv1alpha
 			status.State = flinkoperatorv1alpha1.ClusterState.Running
 		}
 	case flinkoperatorv1alpha1.ClusterState.Running,
-		flinkoperatorv1alpha1.ClusterState.Reconciling:
+		flinkoperatorv1alpha1.ClusterState.Reconciling,
+		flinkoperatorv1alpha1.ClusterState.Failed:
+		// A failed cluster recovers once its desired state can be computed
+		// again, e.g., the spec has been fixed.
 		if jobFinished {
 			status.State = flinkoperatorv1alpha1.ClusterState.Stopping
 		} else if runningComponents < totalComponents {
@@ -305,10 +705,423 @@ func (updater *_ClusterStatusUpdater) deriveClusterStatus() flinkoperatorv1alpha
 	case flinkoperatorv1alpha1.ClusterState.Stopped:
 		status.State = flinkoperatorv1alpha1.ClusterState.Stopped
 	default:
-		panic(fmt.Sprintf("Unknown cluster state: %v", recordedClusterStatus.State))
+		return status, fmt.Errorf(
+			"unknown cluster state: %v", recordedClusterStatus.State)
+	}
+
+	// Conditions.
+	status.ObservedGeneration = updater.observedState.cluster.ObjectMeta.Generation
+	status.Conditions = updater.deriveClusterConditions(&status)
+
+	return status, nil
+}
+
+// Derives the conditions of the cluster from its new status. The transition
+// time of a condition is kept as long as its status does not change.
+func (updater *_ClusterStatusUpdater) deriveClusterConditions(
+	status *flinkoperatorv1alpha1.FlinkClusterStatus) []flinkoperatorv1alpha1.FlinkClusterCondition {
+	var cluster = updater.observedState.cluster
+	var recorded = cluster.Status.Conditions
+	var generation = cluster.ObjectMeta.Generation
+	var conditionTypes = flinkoperatorv1alpha1.ClusterConditionType
+	var conditions = []flinkoperatorv1alpha1.FlinkClusterCondition{}
+	var isJobCluster = cluster.Spec.JobSpec != nil
+	var jobStatus = status.Components.Job
+
+	// Ready.
+	switch {
+	case status.State != flinkoperatorv1alpha1.ClusterState.Running:
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.Ready,
+			corev1.ConditionFalse,
+			status.State,
+			fmt.Sprintf("The cluster is %v", status.State),
+			generation))
+	case isJobCluster && (jobStatus == nil ||
+		jobStatus.State != flinkoperatorv1alpha1.JobState.Running):
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.Ready,
+			corev1.ConditionFalse,
+			"JobNotRunning",
+			"The cluster is running but the job is not running",
+			generation))
+	default:
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.Ready,
+			corev1.ConditionTrue,
+			status.State,
+			"The cluster is running",
+			generation))
+	}
+
+	// JobManagerReady.
+	var readyState = flinkoperatorv1alpha1.ClusterComponentState.Ready
+	switch {
+	case status.Components.JobManagerDeployment.State != readyState:
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.JobManagerReady,
+			corev1.ConditionFalse,
+			"DeploymentNotReady",
+			"The JobManager deployment is not ready",
+			generation))
+	case status.Components.JobManagerService.State != readyState:
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.JobManagerReady,
+			corev1.ConditionFalse,
+			"ServiceNotReady",
+			"The JobManager service is not ready",
+			generation))
+	default:
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.JobManagerReady,
+			corev1.ConditionTrue,
+			"DeploymentAndServiceReady",
+			"The JobManager deployment and service are ready",
+			generation))
+	}
+
+	// TaskManagersReady.
+	var tmDeployment = updater.observedState.tmDeployment
+	var tmStatefulSet = updater.observedState.tmStatefulSet
+	var tmMessage = "The TaskManager deployment does not exist"
+	if tmDeployment != nil && tmDeployment.Spec.Replicas != nil {
+		tmMessage = fmt.Sprintf(
+			"%v/%v TaskManager replicas are ready",
+			tmDeployment.Status.ReadyReplicas,
+			*tmDeployment.Spec.Replicas)
+	} else if tmStatefulSet != nil && tmStatefulSet.Spec.Replicas != nil {
+		tmMessage = fmt.Sprintf(
+			"%v/%v TaskManager replicas are ready",
+			tmStatefulSet.Status.ReadyReplicas,
+			*tmStatefulSet.Spec.Replicas)
+	}
+	var poolsReady = true
+	if len(status.Components.TaskManagerPools) > 0 {
+		var poolReplicas, poolReadyReplicas int32
+		for _, poolStatus := range status.Components.TaskManagerPools {
+			poolReplicas += poolStatus.Replicas
+			poolReadyReplicas += poolStatus.ReadyReplicas
+			if poolStatus.State != readyState {
+				poolsReady = false
+			}
+		}
+		tmMessage += fmt.Sprintf(
+			", %v/%v replicas of the TaskManager pools are ready",
+			poolReadyReplicas,
+			poolReplicas)
+	}
+	if status.Components.TaskManagerDeployment.State == readyState &&
+		poolsReady {
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.TaskManagersReady,
+			corev1.ConditionTrue,
+			"DeploymentReady",
+			tmMessage,
+			generation))
+	} else {
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.TaskManagersReady,
+			corev1.ConditionFalse,
+			"DeploymentNotReady",
+			tmMessage,
+			generation))
+	}
+
+	// JobRunning, only for job clusters.
+	if isJobCluster {
+		switch {
+		case jobStatus == nil:
+			conditions = append(conditions, newClusterCondition(
+				recorded,
+				conditionTypes.JobRunning,
+				corev1.ConditionFalse,
+				"JobNotSubmitted",
+				"The job has not been submitted",
+				generation))
+		case jobStatus.State == flinkoperatorv1alpha1.JobState.Running:
+			conditions = append(conditions, newClusterCondition(
+				recorded,
+				conditionTypes.JobRunning,
+				corev1.ConditionTrue,
+				"JobRunning",
+				fmt.Sprintf("The job %v is running", jobStatus.ID),
+				generation))
+		default:
+			conditions = append(conditions, newClusterCondition(
+				recorded,
+				conditionTypes.JobRunning,
+				corev1.ConditionFalse,
+				"Job"+jobStatus.State,
+				fmt.Sprintf("The job is %v", jobStatus.State),
+				generation))
+		}
+	}
+
+	// Progressing.
+	switch {
+	case jobStatus != nil && len(jobStatus.UpgradeState) > 0:
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.Progressing,
+			corev1.ConditionTrue,
+			"JobUpgrading",
+			fmt.Sprintf("The job is being upgraded: %v", jobStatus.UpgradeState),
+			generation))
+	case status.State == flinkoperatorv1alpha1.ClusterState.Creating ||
+		status.State == flinkoperatorv1alpha1.ClusterState.Reconciling ||
+		status.State == flinkoperatorv1alpha1.ClusterState.Stopping:
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.Progressing,
+			corev1.ConditionTrue,
+			status.State,
+			fmt.Sprintf("The cluster is %v", status.State),
+			generation))
+	default:
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.Progressing,
+			corev1.ConditionFalse,
+			status.State,
+			fmt.Sprintf("The cluster is %v", status.State),
+			generation))
+	}
+
+	// Degraded.
+	switch {
+	case jobStatus != nil &&
+		jobStatus.State == flinkoperatorv1alpha1.JobState.Failed:
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.Degraded,
+			corev1.ConditionTrue,
+			"JobFailed",
+			"The job has failed",
+			generation))
+	case jobStatus != nil &&
+		(jobStatus.State == flinkoperatorv1alpha1.JobState.Failing ||
+			jobStatus.State == flinkoperatorv1alpha1.JobState.Restarting):
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.Degraded,
+			corev1.ConditionTrue,
+			"JobRestarting",
+			fmt.Sprintf("The job is %v", jobStatus.State),
+			generation))
+	case !isSubmitterStateConsistent(jobStatus):
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.Degraded,
+			corev1.ConditionTrue,
+			"JobStateMismatch",
+			fmt.Sprintf(
+				"Job submitter state %v disagrees with Flink job state %v",
+				jobStatus.SubmitterState,
+				jobStatus.FlinkJobState),
+			generation))
+	default:
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.Degraded,
+			corev1.ConditionFalse,
+			"AsExpected",
+			"",
+			generation))
+	}
+
+	// ReconcileError, which is set by the reconciler after taking actions.
+	var reconcileError = getClusterCondition(
+		recorded, conditionTypes.ReconcileError)
+	if reconcileError != nil {
+		conditions = append(conditions, *reconcileError.DeepCopy())
+	} else {
+		conditions = append(conditions, newClusterCondition(
+			recorded,
+			conditionTypes.ReconcileError,
+			corev1.ConditionFalse,
+			"ReconcileSucceeded",
+			"",
+			generation))
+	}
+
+	return conditions
+}
+
+// Creates a cluster condition. The transition time of the recorded condition
+// of the same type is kept if the status of the condition does not change.
+func newClusterCondition(
+	recorded []flinkoperatorv1alpha1.FlinkClusterCondition,
+	conditionType string,
+	status corev1.ConditionStatus,
+	reason string,
+	message string,
+	generation int64) flinkoperatorv1alpha1.FlinkClusterCondition {
+	var condition = flinkoperatorv1alpha1.FlinkClusterCondition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: generation,
+		LastTransitionTime: metav1.NewTime(time.Now().Truncate(time.Second)),
+		Reason:             reason,
+		Message:            message,
+	}
+	var recordedCondition = getClusterCondition(recorded, conditionType)
+	if recordedCondition != nil && recordedCondition.Status == status {
+		condition.LastTransitionTime = recordedCondition.LastTransitionTime
+	}
+	return condition
+}
+
+// Gets the condition of the type, returns nil if it is not found.
+func getClusterCondition(
+	conditions []flinkoperatorv1alpha1.FlinkClusterCondition,
+	conditionType string) *flinkoperatorv1alpha1.FlinkClusterCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// Checks whether the conditions are different, ignoring the transition times
+// which only change along with the status of a condition.
+func isClusterConditionsChanged(
+	currentConditions []flinkoperatorv1alpha1.FlinkClusterCondition,
+	newConditions []flinkoperatorv1alpha1.FlinkClusterCondition) bool {
+	if len(currentConditions) != len(newConditions) {
+		return true
+	}
+	for i := range newConditions {
+		var currentCondition = currentConditions[i]
+		var newCondition = newConditions[i]
+		if currentCondition.Type != newCondition.Type ||
+			currentCondition.Status != newCondition.Status ||
+			currentCondition.Reason != newCondition.Reason ||
+			currentCondition.Message != newCondition.Message ||
+			currentCondition.ObservedGeneration != newCondition.ObservedGeneration {
+			return true
+		}
+	}
+	return false
+}
+
+// Derives the state of the Kubernetes job which submits the Flink job.
+func getSubmitterState(
+	observedJob *batchv1.Job, observedJobPod *corev1.Pod) string {
+	if observedJob.Status.Active > 0 {
+		// When job status is Active, it is possible that the pod is still
+		// Pending.
+		if observedJobPod != nil && observedJobPod.Status.Phase == "Pending" {
+			return flinkoperatorv1alpha1.JobState.Pending
+		}
+		return flinkoperatorv1alpha1.JobState.Running
+	} else if observedJob.Status.Failed > 0 {
+		return flinkoperatorv1alpha1.JobState.Failed
+	} else if observedJob.Status.Succeeded > 0 {
+		return flinkoperatorv1alpha1.JobState.Succeeded
 	}
+	// The job has just been created, its pod is not scheduled yet.
+	return flinkoperatorv1alpha1.JobState.Pending
+}
 
-	return status
+// Checks whether the submitter has completed, or failed after its retries are
+// exhausted, so it will not submit the job again.
+func isSubmitterTerminated(observedJob *batchv1.Job) bool {
+	for _, condition := range observedJob.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete ||
+			condition.Type == batchv1.JobFailed) &&
+			condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// Maps the state of the Flink job reported by the Flink REST API to JobState.
+func getJobStateFromFlinkJobState(flinkJobState string) string {
+	switch flinkJobState {
+	case flinkclient.JobState.Created, flinkclient.JobState.Reconciling:
+		return flinkoperatorv1alpha1.JobState.Pending
+	case flinkclient.JobState.Running:
+		return flinkoperatorv1alpha1.JobState.Running
+	case flinkclient.JobState.Failing:
+		return flinkoperatorv1alpha1.JobState.Failing
+	case flinkclient.JobState.Restarting, flinkclient.JobState.Suspended:
+		return flinkoperatorv1alpha1.JobState.Restarting
+	case flinkclient.JobState.Finished:
+		return flinkoperatorv1alpha1.JobState.Succeeded
+	case flinkclient.JobState.Failed:
+		return flinkoperatorv1alpha1.JobState.Failed
+	case flinkclient.JobState.Cancelling, flinkclient.JobState.Canceled:
+		return flinkoperatorv1alpha1.JobState.Cancelled
+	default:
+		return flinkoperatorv1alpha1.JobState.Unknown
+	}
+}
+
+// Checks whether the job has terminated, and will not be restarted by Flink.
+func isJobTerminated(jobState string) bool {
+	return jobState == flinkoperatorv1alpha1.JobState.Succeeded ||
+		jobState == flinkoperatorv1alpha1.JobState.Failed ||
+		jobState == flinkoperatorv1alpha1.JobState.Cancelled
+}
+
+// Checks whether the failed job should be restarted from its latest checkpoint
+// or savepoint by the operator.
+func shouldRestartJob(
+	cluster *flinkoperatorv1alpha1.FlinkCluster,
+	jobStatus *flinkoperatorv1alpha1.JobStatus) bool {
+	var jobSpec = cluster.Spec.JobSpec
+	return isRestartFromSavepointEnabled(cluster) &&
+		jobSpec.MaxRestartAttempts != nil &&
+		jobStatus != nil &&
+		jobStatus.State == flinkoperatorv1alpha1.JobState.Failed &&
+		jobStatus.RestartCount < *jobSpec.MaxRestartAttempts
+}
+
+// Checks whether the restart count of the job should be reset, i.e., the job
+// has been running for the reset period since the operator restarted it.
+func shouldResetRestartCount(
+	jobStatus *flinkoperatorv1alpha1.JobStatus,
+	flinkJob *flinkclient.JobDetails) bool {
+	if jobStatus == nil || jobStatus.RestartCount == 0 ||
+		flinkJob == nil || flinkJob.ID != jobStatus.ID ||
+		flinkJob.State != flinkclient.JobState.Running {
+		return false
+	}
+	var lastRestartTime, err = time.Parse(
+		time.RFC3339, jobStatus.LastRestartTime)
+	return err == nil &&
+		time.Now().After(lastRestartTime.Add(restartCountResetPeriod))
+}
+
+// Checks whether the state of the submitter agrees with the state of the Flink
+// job, e.g., the submitter should not be running when the Flink job has been
+// cancelled.
+func isSubmitterStateConsistent(jobStatus *flinkoperatorv1alpha1.JobStatus) bool {
+	if jobStatus == nil || len(jobStatus.FlinkJobState) == 0 {
+		return true
+	}
+	var flinkJobState = getJobStateFromFlinkJobState(jobStatus.FlinkJobState)
+	switch jobStatus.SubmitterState {
+	case flinkoperatorv1alpha1.JobState.Running:
+		return !isJobTerminated(flinkJobState)
+	case flinkoperatorv1alpha1.JobState.Succeeded:
+		return flinkJobState == flinkoperatorv1alpha1.JobState.Succeeded
+	case flinkoperatorv1alpha1.JobState.Failed:
+		return flinkJobState == flinkoperatorv1alpha1.JobState.Failed ||
+			flinkJobState == flinkoperatorv1alpha1.JobState.Cancelled
+	default:
+		return true
+	}
 }
 
 func (updater *_ClusterStatusUpdater) isStatusChanged(
@@ -352,6 +1165,17 @@ func (updater *_ClusterStatusUpdater) isStatusChanged(
 			newStatus.Components.TaskManagerDeployment)
 		changed = true
 	}
+	if !reflect.DeepEqual(
+		newStatus.Components.TaskManagerPools,
+		currentStatus.Components.TaskManagerPools) {
+		updater.log.Info(
+			"TaskManager pools status changed",
+			"current",
+			currentStatus.Components.TaskManagerPools,
+			"new",
+			newStatus.Components.TaskManagerPools)
+		changed = true
+	}
 	if currentStatus.Components.Job == nil {
 		if newStatus.Components.Job != nil {
 			updater.log.Info(
@@ -362,8 +1186,17 @@ func (updater *_ClusterStatusUpdater) isStatusChanged(
 				*newStatus.Components.Job)
 			changed = true
 		}
+	} else if newStatus.Components.Job == nil {
+		updater.log.Info(
+			"Job status changed",
+			"current",
+			*currentStatus.Components.Job,
+			"new",
+			"nil")
+		changed = true
 	} else {
-		if *newStatus.Components.Job != *currentStatus.Components.Job {
+		if !reflect.DeepEqual(
+			*newStatus.Components.Job, *currentStatus.Components.Job) {
 			updater.log.Info(
 				"Job status changed",
 				"current",
@@ -373,13 +1206,230 @@ func (updater *_ClusterStatusUpdater) isStatusChanged(
 			changed = true
 		}
 	}
+	if !reflect.DeepEqual(
+		newStatus.Components.JobManagerIngress,
+		currentStatus.Components.JobManagerIngress) {
+		updater.log.Info(
+			"JobManager ingress status changed",
+			"current",
+			currentStatus.Components.JobManagerIngress,
+			"new",
+			newStatus.Components.JobManagerIngress)
+		changed = true
+	}
+	if !reflect.DeepEqual(
+		newStatus.Components.JobManagerLeader,
+		currentStatus.Components.JobManagerLeader) {
+		updater.log.Info(
+			"JobManager leader changed",
+			"current",
+			currentStatus.Components.JobManagerLeader,
+			"new",
+			newStatus.Components.JobManagerLeader)
+		changed = true
+	}
+	if !reflect.DeepEqual(newStatus.TaskManager, currentStatus.TaskManager) {
+		updater.log.Info(
+			"TaskManager scale changed",
+			"current",
+			currentStatus.TaskManager,
+			"new",
+			newStatus.TaskManager)
+		changed = true
+	}
+	if newStatus.ObservedGeneration != currentStatus.ObservedGeneration {
+		updater.log.Info(
+			"Observed generation changed",
+			"current",
+			currentStatus.ObservedGeneration,
+			"new",
+			newStatus.ObservedGeneration)
+		changed = true
+	}
+	if isClusterConditionsChanged(
+		currentStatus.Conditions, newStatus.Conditions) {
+		updater.log.Info(
+			"Conditions changed",
+			"current",
+			currentStatus.Conditions,
+			"new",
+			newStatus.Conditions)
+		changed = true
+	}
 	return changed
 }
 
 func (updater *_ClusterStatusUpdater) updateClusterStatus(
 	status flinkoperatorv1alpha1.FlinkClusterStatus) error {
-	var flinkCluster = flinkoperatorv1alpha1.FlinkCluster{}
-	updater.observedState.cluster.DeepCopyInto(&flinkCluster)
-	flinkCluster.Status = status
-	return updater.k8sClient.Update(updater.context, &flinkCluster)
+	return updateLatestClusterStatus(
+		updater.context,
+		updater.k8sClient,
+		updater.observedState.cluster,
+		func(cluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+			status.DeepCopyInto(&cluster.Status)
+			return true
+		})
+}
+
+// Gets the latest version of the cluster, applies the change to its status and
+// writes it through the status subresource, so it never overwrites changes of
+// the spec. The change is applied again to the latest version on conflicts.
+// The update function returns false if there is nothing to write.
+func updateLatestClusterStatus(
+	context context.Context,
+	k8sClient client.Client,
+	cluster *flinkoperatorv1alpha1.FlinkCluster,
+	update func(latest *flinkoperatorv1alpha1.FlinkCluster) bool) error {
+	var key = types.NamespacedName{
+		Namespace: cluster.ObjectMeta.Namespace,
+		Name:      cluster.ObjectMeta.Name,
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var latest = &flinkoperatorv1alpha1.FlinkCluster{}
+		var err = k8sClient.Get(context, key, latest)
+		if err != nil {
+			return err
+		}
+		if !update(latest) {
+			return nil
+		}
+		return k8sClient.Status().Update(context, latest)
+	})
+}
+
+// Replaces the condition of the same type, or appends it if there is none.
+func setClusterCondition(
+	conditions []flinkoperatorv1alpha1.FlinkClusterCondition,
+	condition flinkoperatorv1alpha1.FlinkClusterCondition) []flinkoperatorv1alpha1.FlinkClusterCondition {
+	var existing = getClusterCondition(conditions, condition.Type)
+	if existing != nil {
+		*existing = condition
+		return conditions
+	}
+	return append(conditions, condition)
+}
+
+// Checks whether the deployment has been rolled out and all its replicas are
+// ready.
+// Gets the status of the TaskManager pool with the name, returns nil if it is
+// not found.
+func getTaskManagerPoolStatus(
+	poolStatuses []flinkoperatorv1alpha1.TaskManagerPoolStatus,
+	name string) *flinkoperatorv1alpha1.TaskManagerPoolStatus {
+	for i := range poolStatuses {
+		if poolStatuses[i].Name == name {
+			return &poolStatuses[i]
+		}
+	}
+	return nil
+}
+
+func isDeploymentReady(deployment *appsv1.Deployment) bool {
+	var status = deployment.Status
+	return status.ObservedGeneration >= deployment.ObjectMeta.Generation &&
+		status.UpdatedReplicas >= status.Replicas &&
+		status.AvailableReplicas >= status.Replicas &&
+		status.ReadyReplicas >= status.Replicas
+}
+
+// Checks whether the StatefulSet has been rolled out and all its replicas are
+// ready. The status of a StatefulSet has no available replicas, so the ready
+// replicas are compared with the desired replicas.
+func isStatefulSetReady(statefulSet *appsv1.StatefulSet) bool {
+	var status = statefulSet.Status
+	var replicas int32 = 1
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	return status.ObservedGeneration >= statefulSet.ObjectMeta.Generation &&
+		status.UpdatedReplicas >= replicas &&
+		status.ReadyReplicas >= replicas
+}
+
+// Gets the leader of JobManagers from the ConfigMap in which Kubernetes HA
+// records the address of the leading REST endpoint, e.g.,
+// "http://10.8.0.12:8081". The leader pod is looked up by its IP, which is
+// the address registered by each JobManager. Returns nil if the leader has
+// not been elected.
+func getJobManagerLeader(
+	leaderConfigMap *corev1.ConfigMap,
+	jmPods []corev1.Pod) *flinkoperatorv1alpha1.JobManagerLeaderStatus {
+	if leaderConfigMap == nil || len(leaderConfigMap.Data["address"]) == 0 {
+		return nil
+	}
+	var leader = &flinkoperatorv1alpha1.JobManagerLeaderStatus{
+		Address: leaderConfigMap.Data["address"],
+	}
+	var leaderURL, err = url.Parse(leader.Address)
+	if err != nil {
+		return leader
+	}
+	for _, pod := range jmPods {
+		if len(pod.Status.PodIP) > 0 &&
+			pod.Status.PodIP == leaderURL.Hostname() {
+			leader.PodName = pod.ObjectMeta.Name
+			break
+		}
+	}
+	return leader
+}
+
+// Gets the name of the leader in events, the pod name if it is known,
+// otherwise the address.
+func getJobManagerLeaderName(
+	leader *flinkoperatorv1alpha1.JobManagerLeaderStatus) string {
+	if len(leader.PodName) > 0 {
+		return leader.PodName
+	}
+	return leader.Address
+}
+
+// Gets the URLs of the web UI from the rules of the JobManager ingress. Rules
+// without a host are reached through the address assigned by the ingress
+// controller, they have no URL until the address is assigned.
+func getJobManagerIngressURLs(ingress *networkingv1beta1.Ingress) []string {
+	var tlsHosts = map[string]bool{}
+	var tlsEnabled = len(ingress.Spec.TLS) > 0
+	for _, tls := range ingress.Spec.TLS {
+		for _, host := range tls.Hosts {
+			tlsHosts[host] = true
+		}
+	}
+	var lbAddress = ""
+	for _, lbIngress := range ingress.Status.LoadBalancer.Ingress {
+		if len(lbIngress.Hostname) > 0 {
+			lbAddress = lbIngress.Hostname
+			break
+		}
+		if len(lbIngress.IP) > 0 {
+			lbAddress = lbIngress.IP
+			break
+		}
+	}
+
+	var urls []string
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		var scheme = "http"
+		var host = rule.Host
+		if len(host) > 0 {
+			if tlsHosts[host] {
+				scheme = "https"
+			}
+		} else {
+			if len(lbAddress) == 0 {
+				continue
+			}
+			host = lbAddress
+			if tlsEnabled {
+				scheme = "https"
+			}
+		}
+		for _, path := range rule.HTTP.Paths {
+			urls = append(urls, scheme+"://"+host+path.Path)
+		}
+	}
+	return urls
 }
@@ -0,0 +1,676 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+	"gotest.tools/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newTestReadyDeployment(name string) *appsv1.Deployment {
+	var replicas int32 = 1
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			Replicas:          1,
+			UpdatedReplicas:   1,
+			AvailableReplicas: 1,
+			ReadyReplicas:     1,
+		},
+	}
+}
+
+func newTestObservedJobClusterState(
+	flinkJobState string) _ObservedClusterState {
+	var jobID = "ec7c9dd3f4f7b1b5a0b0ac3d3b08a3d1"
+	var observedState = _ObservedClusterState{
+		cluster: &flinkoperatorv1alpha1.FlinkCluster{
+			Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+				JobSpec: &flinkoperatorv1alpha1.JobSpec{},
+			},
+			Status: flinkoperatorv1alpha1.FlinkClusterStatus{
+				State: flinkoperatorv1alpha1.ClusterState.Running,
+				Components: flinkoperatorv1alpha1.FlinkClusterComponentsStatus{
+					Job: &flinkoperatorv1alpha1.JobStatus{
+						Name:           "mycluster-job",
+						ID:             jobID,
+						State:          flinkoperatorv1alpha1.JobState.Running,
+						FlinkJobState:  flinkclient.JobState.Running,
+						SubmitterState: flinkoperatorv1alpha1.JobState.Running,
+					},
+				},
+			},
+		},
+		jmDeployment: newTestReadyDeployment("mycluster-jobmanager"),
+		jmService: &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "mycluster-jobmanager"},
+			Spec: corev1.ServiceSpec{
+				Type:      corev1.ServiceTypeClusterIP,
+				ClusterIP: "10.0.0.1",
+			},
+		},
+		tmDeployment: newTestReadyDeployment("mycluster-taskmanager"),
+		job: &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "mycluster-job"},
+			Status:     batchv1.JobStatus{Active: 1},
+		},
+		jobPod: &corev1.Pod{
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		flinkJobID: &jobID,
+	}
+	if len(flinkJobState) > 0 {
+		observedState.flinkJob = &flinkclient.JobDetails{
+			ID:    jobID,
+			State: flinkJobState,
+		}
+	}
+	return observedState
+}
+
+func TestDeriveJobStatusFromFlinkJobState(t *testing.T) {
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: newTestObservedJobClusterState(flinkclient.JobState.Restarting),
+	}
+
+	var status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Running)
+	assert.Equal(
+		t, status.Components.Job.State, flinkoperatorv1alpha1.JobState.Restarting)
+	assert.Equal(
+		t, status.Components.Job.FlinkJobState, flinkclient.JobState.Restarting)
+	assert.Equal(
+		t,
+		status.Components.Job.SubmitterState,
+		flinkoperatorv1alpha1.JobState.Running)
+	assert.Assert(t, isSubmitterStateConsistent(status.Components.Job))
+}
+
+func TestDeriveTaskManagerScaleStatus(t *testing.T) {
+	var observedState = newTestObservedJobClusterState(
+		flinkclient.JobState.Running)
+	observedState.cluster.ObjectMeta.Name = "mycluster"
+	observedState.tmDeployment.Status.Replicas = 3
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: observedState,
+	}
+
+	var status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.DeepEqual(
+		t,
+		*status.TaskManager,
+		flinkoperatorv1alpha1.TaskManagerStatus{
+			Replicas: 3,
+			Selector: "app=flink,cluster=mycluster,component=taskmanager,!taskmanager-pool",
+		})
+}
+
+// Tests the TaskManager StatefulSet is reported like the deployment.
+func TestDeriveTaskManagerStatefulSetStatus(t *testing.T) {
+	var replicas int32 = 2
+	var observedState = newTestObservedJobClusterState(
+		flinkclient.JobState.Running)
+	observedState.tmDeployment = nil
+	observedState.tmStatefulSet = &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "mycluster-taskmanager"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status: appsv1.StatefulSetStatus{
+			Replicas:        2,
+			UpdatedReplicas: 2,
+			ReadyReplicas:   1,
+		},
+	}
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: observedState,
+	}
+
+	var status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(
+		t,
+		status.Components.TaskManagerDeployment,
+		flinkoperatorv1alpha1.FlinkClusterComponentState{
+			Name:  "mycluster-taskmanager",
+			State: flinkoperatorv1alpha1.ClusterComponentState.NotReady,
+		})
+	assert.Equal(t, status.TaskManager.Replicas, int32(2))
+
+	observedState.tmStatefulSet.Status.ReadyReplicas = 2
+	status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(
+		t,
+		status.Components.TaskManagerDeployment.State,
+		flinkoperatorv1alpha1.ClusterComponentState.Ready)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Running)
+}
+
+// Tests each TaskManager pool is reported and counts as a component of the
+// cluster.
+func TestDeriveTaskManagerPoolsStatus(t *testing.T) {
+	var observedState = newTestObservedJobClusterState(
+		flinkclient.JobState.Running)
+	observedState.cluster.ObjectMeta.Name = "mycluster"
+	observedState.cluster.Spec.TaskManagerPools =
+		[]flinkoperatorv1alpha1.TaskManagerPoolSpec{
+			{Name: "small", Replicas: 1},
+			{Name: "highmem", Replicas: 2},
+		}
+	var highmem = newTestReadyDeployment("mycluster-taskmanager-highmem")
+	highmem.Status.Replicas = 2
+	highmem.Status.UpdatedReplicas = 2
+	highmem.Status.AvailableReplicas = 2
+	highmem.Status.ReadyReplicas = 1
+	observedState.tmPoolDeployments = map[string]*appsv1.Deployment{
+		"small":   newTestReadyDeployment("mycluster-taskmanager-small"),
+		"highmem": highmem,
+	}
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: observedState,
+	}
+
+	var status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.DeepEqual(
+		t,
+		status.Components.TaskManagerPools,
+		[]flinkoperatorv1alpha1.TaskManagerPoolStatus{
+			{
+				Name:          "small",
+				Deployment:    "mycluster-taskmanager-small",
+				State:         flinkoperatorv1alpha1.ClusterComponentState.Ready,
+				Replicas:      1,
+				ReadyReplicas: 1,
+			},
+			{
+				Name:          "highmem",
+				Deployment:    "mycluster-taskmanager-highmem",
+				State:         flinkoperatorv1alpha1.ClusterComponentState.NotReady,
+				Replicas:      2,
+				ReadyReplicas: 1,
+			},
+		})
+	assert.Equal(
+		t, status.State, flinkoperatorv1alpha1.ClusterState.Reconciling)
+	assert.Equal(
+		t,
+		status.TaskManager.Selector,
+		"app=flink,cluster=mycluster,component=taskmanager,!taskmanager-pool")
+
+	highmem.Status.ReadyReplicas = 2
+	status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(
+		t,
+		status.Components.TaskManagerPools[1].State,
+		flinkoperatorv1alpha1.ClusterComponentState.Ready)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Running)
+}
+
+func TestDeriveJobStatusCancelledWhileSubmitterRunning(t *testing.T) {
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: newTestObservedJobClusterState(flinkclient.JobState.Canceled),
+	}
+
+	var status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Running)
+	assert.Equal(
+		t, status.Components.Job.State, flinkoperatorv1alpha1.JobState.Cancelled)
+	assert.Assert(t, !isSubmitterStateConsistent(status.Components.Job))
+
+	// The cluster is stopped once the submitter has terminated.
+	updater.observedState.job.Status = batchv1.JobStatus{
+		Failed: 1,
+		Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+		},
+	}
+	status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Stopping)
+}
+
+// Tests the cluster keeps running when the failed job will be resubmitted by
+// the restarted submitter, and stops once the submitter has failed.
+func TestDeriveJobStatusFailedWithSubmitterRetry(t *testing.T) {
+	var observedState = newTestObservedJobClusterState(flinkclient.JobState.Failed)
+	var restartPolicy = corev1.RestartPolicy(
+		flinkoperatorv1alpha1.JobRestartPolicy.OnFailure)
+	observedState.cluster.Spec.JobSpec.RestartPolicy = &restartPolicy
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: observedState,
+	}
+
+	var status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Running)
+	assert.Equal(
+		t, status.Components.Job.State, flinkoperatorv1alpha1.JobState.Failed)
+
+	observedState.job.Status = batchv1.JobStatus{
+		Failed: 6,
+		Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+		},
+	}
+	status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Stopping)
+}
+
+// Tests the cluster keeps running when the failed job will be restarted by the
+// operator, and stops once the restarts are exhausted.
+func TestDeriveJobStatusFailedWithRestart(t *testing.T) {
+	var observedState = newTestObservedJobClusterState(flinkclient.JobState.Failed)
+	var restartPolicy = corev1.RestartPolicy(
+		flinkoperatorv1alpha1.JobRestartPolicy.FromSavepointOnFailure)
+	var maxRestartAttempts int32 = 2
+	observedState.cluster.Spec.JobSpec.RestartPolicy = &restartPolicy
+	observedState.cluster.Spec.JobSpec.MaxRestartAttempts = &maxRestartAttempts
+	observedState.cluster.Status.Components.Job.RestartCount = 1
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: observedState,
+	}
+
+	var status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Running)
+	assert.Equal(
+		t, status.Components.Job.State, flinkoperatorv1alpha1.JobState.Failed)
+	assert.Equal(t, status.Components.Job.RestartCount, int32(1))
+
+	observedState.cluster.Status.Components.Job.RestartCount = 2
+	status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Stopping)
+}
+
+func TestDeriveJobStatusKeepsRecordedStateWhenAPIUnavailable(t *testing.T) {
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: newTestObservedJobClusterState(""),
+	}
+
+	var status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Running)
+	assert.Equal(
+		t, status.Components.Job.State, flinkoperatorv1alpha1.JobState.Running)
+	assert.Equal(
+		t, status.Components.Job.FlinkJobState, flinkclient.JobState.Running)
+}
+
+// Tests the job status is derived from JobManager in Application mode, and the
+// job is pending once it has been resubmitted for upgrade.
+func TestDeriveJobStatusApplicationMode(t *testing.T) {
+	var observedState = newTestObservedJobClusterState(flinkclient.JobState.Running)
+	var mode = flinkoperatorv1alpha1.JobMode.Application
+	observedState.cluster.Spec.JobSpec.Mode = &mode
+	observedState.job = nil
+	observedState.jobPod = nil
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: observedState,
+	}
+
+	var status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Running)
+	assert.Equal(t, status.Components.Job.Name, "mycluster-jobmanager")
+	assert.Equal(
+		t, status.Components.Job.State, flinkoperatorv1alpha1.JobState.Running)
+	assert.Equal(t, status.Components.Job.SubmitterState, "")
+
+	observedState.cluster.Status.Components.Job.ID = ""
+	observedState.flinkJobID = nil
+	observedState.flinkJob = nil
+	updater.observedState = observedState
+	status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, status.State, flinkoperatorv1alpha1.ClusterState.Running)
+	assert.Equal(
+		t, status.Components.Job.State, flinkoperatorv1alpha1.JobState.Pending)
+}
+
+func TestGetJobStateFromFlinkJobState(t *testing.T) {
+	var expected = map[string]string{
+		flinkclient.JobState.Created:    flinkoperatorv1alpha1.JobState.Pending,
+		flinkclient.JobState.Running:    flinkoperatorv1alpha1.JobState.Running,
+		flinkclient.JobState.Failing:    flinkoperatorv1alpha1.JobState.Failing,
+		flinkclient.JobState.Restarting: flinkoperatorv1alpha1.JobState.Restarting,
+		flinkclient.JobState.Finished:   flinkoperatorv1alpha1.JobState.Succeeded,
+		flinkclient.JobState.Failed:     flinkoperatorv1alpha1.JobState.Failed,
+		flinkclient.JobState.Canceled:   flinkoperatorv1alpha1.JobState.Cancelled,
+		"UNKNOWN_STATE":                 flinkoperatorv1alpha1.JobState.Unknown,
+	}
+	for flinkJobState, jobState := range expected {
+		assert.Equal(t, getJobStateFromFlinkJobState(flinkJobState), jobState)
+	}
+}
+
+func TestDeriveClusterConditions(t *testing.T) {
+	var conditionTypes = flinkoperatorv1alpha1.ClusterConditionType
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: newTestObservedJobClusterState(flinkclient.JobState.Running),
+	}
+
+	var status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	var expected = map[string]corev1.ConditionStatus{
+		conditionTypes.Ready:             corev1.ConditionTrue,
+		conditionTypes.JobManagerReady:   corev1.ConditionTrue,
+		conditionTypes.TaskManagersReady: corev1.ConditionTrue,
+		conditionTypes.JobRunning:        corev1.ConditionTrue,
+		conditionTypes.Progressing:       corev1.ConditionFalse,
+		conditionTypes.Degraded:          corev1.ConditionFalse,
+		conditionTypes.ReconcileError:    corev1.ConditionFalse,
+	}
+	assert.Equal(t, len(status.Conditions), len(expected))
+	for conditionType, conditionStatus := range expected {
+		var condition = getClusterCondition(status.Conditions, conditionType)
+		assert.Assert(t, condition != nil, conditionType)
+		assert.Equal(t, condition.Status, conditionStatus, conditionType)
+	}
+	assert.Equal(
+		t,
+		getClusterCondition(status.Conditions, conditionTypes.TaskManagersReady).Message,
+		"1/1 TaskManager replicas are ready")
+}
+
+func TestDeriveClusterConditionsJobRestarting(t *testing.T) {
+	var conditionTypes = flinkoperatorv1alpha1.ClusterConditionType
+	var transitionTime = metav1.NewTime(time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC))
+	var observedState = newTestObservedJobClusterState(
+		flinkclient.JobState.Restarting)
+	observedState.cluster.Status.Conditions = []flinkoperatorv1alpha1.FlinkClusterCondition{
+		{
+			Type:               conditionTypes.JobManagerReady,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: transitionTime,
+		},
+		{
+			Type:               conditionTypes.Degraded,
+			Status:             corev1.ConditionFalse,
+			LastTransitionTime: transitionTime,
+		},
+	}
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: observedState,
+	}
+
+	var status, err = updater.deriveClusterStatus()
+	assert.NilError(t, err)
+	var ready = getClusterCondition(status.Conditions, conditionTypes.Ready)
+	assert.Equal(t, ready.Status, corev1.ConditionFalse)
+	assert.Equal(t, ready.Reason, "JobNotRunning")
+	var jobRunning = getClusterCondition(
+		status.Conditions, conditionTypes.JobRunning)
+	assert.Equal(t, jobRunning.Status, corev1.ConditionFalse)
+	assert.Equal(t, jobRunning.Reason, "JobRestarting")
+	var degraded = getClusterCondition(status.Conditions, conditionTypes.Degraded)
+	assert.Equal(t, degraded.Status, corev1.ConditionTrue)
+	assert.Equal(t, degraded.Reason, "JobRestarting")
+	assert.Assert(t, degraded.LastTransitionTime != transitionTime)
+
+	// The transition time is kept when the status does not change.
+	var jmReady = getClusterCondition(
+		status.Conditions, conditionTypes.JobManagerReady)
+	assert.Equal(t, jmReady.LastTransitionTime, transitionTime)
+	assert.Assert(t, isClusterConditionsChanged(
+		observedState.cluster.Status.Conditions, status.Conditions))
+}
+
+func TestUpdateReconcileErrorCondition(t *testing.T) {
+	var conditionType = flinkoperatorv1alpha1.ClusterConditionType.ReconcileError
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+	}
+	var scheme = runtime.NewScheme()
+	flinkoperatorv1alpha1.AddToScheme(scheme)
+	var k8sClient = fake.NewFakeClientWithScheme(scheme, cluster)
+	var eventRecorder = record.NewFakeRecorder(10)
+	var updater = _ClusterStatusUpdater{
+		k8sClient:     k8sClient,
+		context:       context.Background(),
+		log:           log.NullLogger{},
+		eventRecorder: eventRecorder,
+		observedState: _ObservedClusterState{cluster: cluster},
+	}
+	var key = types.NamespacedName{Namespace: "default", Name: "mycluster"}
+
+	var err = updater.updateReconcileErrorCondition(
+		errors.New("failed to create deployment"))
+	assert.NilError(t, err)
+	err = k8sClient.Get(context.Background(), key, cluster)
+	assert.NilError(t, err)
+	var condition = getClusterCondition(cluster.Status.Conditions, conditionType)
+	assert.Equal(t, condition.Status, corev1.ConditionTrue)
+	assert.Equal(t, condition.Reason, "ReconcileFailed")
+	assert.Equal(t, condition.Message, "failed to create deployment")
+	assert.Equal(
+		t,
+		<-eventRecorder.Events,
+		"Warning ReconcileFailed Condition ReconcileError changed to True: "+
+			"failed to create deployment")
+
+	err = updater.updateReconcileErrorCondition(nil)
+	assert.NilError(t, err)
+	err = k8sClient.Get(context.Background(), key, cluster)
+	assert.NilError(t, err)
+	condition = getClusterCondition(cluster.Status.Conditions, conditionType)
+	assert.Equal(t, condition.Status, corev1.ConditionFalse)
+	assert.Equal(t, condition.Reason, "ReconcileSucceeded")
+	assert.Equal(
+		t,
+		<-eventRecorder.Events,
+		"Normal ReconcileSucceeded Condition ReconcileError changed to False")
+}
+
+func TestUpdateClusterStatusKeepsSpecChanges(t *testing.T) {
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			ImageSpec: flinkoperatorv1alpha1.ImageSpec{Name: "flink:1.8.1"},
+		},
+	}
+	var scheme = runtime.NewScheme()
+	flinkoperatorv1alpha1.AddToScheme(scheme)
+	var k8sClient = fake.NewFakeClientWithScheme(scheme, cluster.DeepCopy())
+	var key = types.NamespacedName{Namespace: "default", Name: "mycluster"}
+
+	// The spec is updated by the user after the cluster has been observed.
+	var latest = &flinkoperatorv1alpha1.FlinkCluster{}
+	var err = k8sClient.Get(context.Background(), key, latest)
+	assert.NilError(t, err)
+	latest.Spec.ImageSpec.Name = "flink:1.9.0"
+	err = k8sClient.Update(context.Background(), latest)
+	assert.NilError(t, err)
+
+	var updater = _ClusterStatusUpdater{
+		k8sClient:     k8sClient,
+		context:       context.Background(),
+		log:           log.NullLogger{},
+		observedState: _ObservedClusterState{cluster: cluster},
+	}
+	err = updater.updateClusterStatus(flinkoperatorv1alpha1.FlinkClusterStatus{
+		State: flinkoperatorv1alpha1.ClusterState.Creating,
+	})
+	assert.NilError(t, err)
+
+	err = k8sClient.Get(context.Background(), key, latest)
+	assert.NilError(t, err)
+	assert.Equal(t, latest.Spec.ImageSpec.Name, "flink:1.9.0")
+	assert.Equal(
+		t, latest.Status.State, flinkoperatorv1alpha1.ClusterState.Creating)
+}
+
+func TestDeriveClusterStatusUnknownState(t *testing.T) {
+	var observedState = newTestObservedJobClusterState(
+		flinkclient.JobState.Running)
+	observedState.cluster.Status.State = "Exploded"
+	var updater = _ClusterStatusUpdater{
+		log:           log.NullLogger{},
+		observedState: observedState,
+	}
+
+	var _, err = updater.deriveClusterStatus()
+	assert.Error(t, err, "unknown cluster state: Exploded")
+}
+
+func TestRecordClusterFailure(t *testing.T) {
+	var conditionTypes = flinkoperatorv1alpha1.ClusterConditionType
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Status: flinkoperatorv1alpha1.FlinkClusterStatus{
+			State: flinkoperatorv1alpha1.ClusterState.Running,
+		},
+	}
+	var scheme = runtime.NewScheme()
+	flinkoperatorv1alpha1.AddToScheme(scheme)
+	var k8sClient = fake.NewFakeClientWithScheme(scheme, cluster)
+	var eventRecorder = record.NewFakeRecorder(10)
+	var updater = _ClusterStatusUpdater{
+		k8sClient:     k8sClient,
+		context:       context.Background(),
+		log:           log.NullLogger{},
+		eventRecorder: eventRecorder,
+		observedState: _ObservedClusterState{cluster: cluster},
+	}
+	var key = types.NamespacedName{Namespace: "default", Name: "mycluster"}
+	var failure = errors.New("unknown service access scope: Internet")
+
+	var err = updater.recordClusterFailure("InvalidSpec", failure)
+	assert.NilError(t, err)
+	err = k8sClient.Get(context.Background(), key, cluster)
+	assert.NilError(t, err)
+	assert.Equal(
+		t, cluster.Status.State, flinkoperatorv1alpha1.ClusterState.Failed)
+	var ready = getClusterCondition(cluster.Status.Conditions, conditionTypes.Ready)
+	assert.Equal(t, ready.Status, corev1.ConditionFalse)
+	var reconcileError = getClusterCondition(
+		cluster.Status.Conditions, conditionTypes.ReconcileError)
+	assert.Equal(t, reconcileError.Status, corev1.ConditionTrue)
+	assert.Equal(t, reconcileError.Reason, "InvalidSpec")
+	assert.Equal(t, reconcileError.Message, failure.Error())
+	assert.Equal(
+		t,
+		<-eventRecorder.Events,
+		"Warning InvalidSpec Cluster failed: "+failure.Error())
+
+	// The same failure is recorded only once.
+	err = updater.recordClusterFailure("InvalidSpec", failure)
+	assert.NilError(t, err)
+	assert.Equal(t, len(eventRecorder.Events), 0)
+}
+
+func TestGetJobManagerLeader(t *testing.T) {
+	var jmPods = []corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "mycluster-jobmanager-abcde"},
+			Status:     corev1.PodStatus{PodIP: "10.8.0.11"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "mycluster-jobmanager-fghij"},
+			Status:     corev1.PodStatus{PodIP: "10.8.0.12"},
+		},
+	}
+	var leaderConfigMap = &corev1.ConfigMap{
+		Data: map[string]string{"address": "http://10.8.0.12:8081"},
+	}
+
+	assert.DeepEqual(
+		t,
+		*getJobManagerLeader(leaderConfigMap, jmPods),
+		flinkoperatorv1alpha1.JobManagerLeaderStatus{
+			PodName: "mycluster-jobmanager-fghij",
+			Address: "http://10.8.0.12:8081",
+		})
+
+	// The leader pod has been deleted.
+	assert.Equal(t, getJobManagerLeader(leaderConfigMap, jmPods[:1]).PodName, "")
+
+	// The leader has not been elected.
+	assert.Assert(t, getJobManagerLeader(nil, jmPods) == nil)
+	assert.Assert(t, getJobManagerLeader(&corev1.ConfigMap{}, jmPods) == nil)
+}
+
+func TestGetJobManagerIngressURLs(t *testing.T) {
+	var paths = &networkingv1beta1.HTTPIngressRuleValue{
+		Paths: []networkingv1beta1.HTTPIngressPath{{Path: "/"}},
+	}
+	var ingress = &networkingv1beta1.Ingress{
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host:             "mycluster.example.com",
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{HTTP: paths},
+				},
+			},
+		},
+	}
+	assert.DeepEqual(
+		t,
+		getJobManagerIngressURLs(ingress),
+		[]string{"http://mycluster.example.com/"})
+
+	ingress.Spec.TLS = []networkingv1beta1.IngressTLS{
+		{Hosts: []string{"mycluster.example.com"}, SecretName: "example-tls"},
+	}
+	assert.DeepEqual(
+		t,
+		getJobManagerIngressURLs(ingress),
+		[]string{"https://mycluster.example.com/"})
+
+	// Without a host, the URL is not known until an address is assigned.
+	ingress.Spec.TLS = nil
+	ingress.Spec.Rules[0].Host = ""
+	assert.Assert(t, getJobManagerIngressURLs(ingress) == nil)
+	ingress.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{
+		{IP: "35.1.2.3"},
+	}
+	assert.DeepEqual(
+		t, getJobManagerIngressURLs(ingress), []string{"http://35.1.2.3/"})
+}
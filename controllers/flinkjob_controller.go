@@ -0,0 +1,423 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The finalizer which cancels the Flink job before the FlinkJob is deleted.
+const cancelJobFinalizer = "flinkoperator.k8s.io/cancel-job"
+
+// FlinkJobReconciler reconciles a FlinkJob object
+type FlinkJobReconciler struct {
+	client.Client
+	Log logr.Logger
+	mgr ctrl.Manager
+}
+
+// +kubebuilder:rbac:groups=flinkoperator.k8s.io,resources=flinkjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=flinkoperator.k8s.io,resources=flinkjobs/status,verbs=get;update;patch
+
+// Reconcile submits the job of a FlinkJob custom resource to its session
+// cluster and tracks the state of the job.
+func (reconciler *FlinkJobReconciler) Reconcile(
+	request ctrl.Request) (ctrl.Result, error) {
+	var log = reconciler.Log.WithValues("flinkjob", request.NamespacedName)
+	var handler = _FlinkJobHandler{
+		k8sClient:     reconciler,
+		flinkClient:   newFlinkClient(log),
+		request:       request,
+		context:       context.Background(),
+		log:           log,
+		eventRecorder: reconciler.mgr.GetEventRecorderFor("FlinkOperator"),
+	}
+	return handler.reconcile()
+}
+
+// SetupWithManager registers this reconciler with the controller manager and
+// starts watching FlinkJob and Job resources.
+func (reconciler *FlinkJobReconciler) SetupWithManager(
+	mgr ctrl.Manager) error {
+	reconciler.mgr = mgr
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&flinkoperatorv1alpha1.FlinkJob{}).
+		Owns(&batchv1.Job{}).
+		Complete(reconciler)
+}
+
+// _FlinkJobHandler holds the context and state for a reconcile request of a
+// FlinkJob.
+type _FlinkJobHandler struct {
+	k8sClient     client.Client
+	flinkClient   *flinkclient.FlinkClient
+	request       ctrl.Request
+	context       context.Context
+	log           logr.Logger
+	eventRecorder record.EventRecorder
+}
+
+// _ObservedFlinkJobState holds observed state of a FlinkJob.
+type _ObservedFlinkJobState struct {
+	flinkJob      *flinkoperatorv1alpha1.FlinkJob
+	cluster       *flinkoperatorv1alpha1.FlinkCluster
+	submitter     *batchv1.Job
+	submitterPods []corev1.Pod
+	flinkJobID    string
+	flinkJobState string
+	flinkAPIErr   error
+}
+
+func (handler *_FlinkJobHandler) reconcile() (ctrl.Result, error) {
+	var log = handler.log
+	var observed = _ObservedFlinkJobState{}
+
+	var flinkJob = &flinkoperatorv1alpha1.FlinkJob{}
+	var err = handler.k8sClient.Get(
+		handler.context, handler.request.NamespacedName, flinkJob)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get the FlinkJob resource")
+			return ctrl.Result{}, err
+		}
+		log.Info("The FlinkJob has been deleted")
+		return ctrl.Result{}, nil
+	}
+	observed.flinkJob = flinkJob
+
+	err = handler.observe(&observed)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if flinkJob.ObjectMeta.DeletionTimestamp != nil {
+		return ctrl.Result{}, handler.finalize(&observed)
+	}
+	if !hasFinalizer(flinkJob.ObjectMeta.Finalizers, cancelJobFinalizer) {
+		log.Info("Adding finalizer")
+		flinkJob.ObjectMeta.Finalizers = append(
+			flinkJob.ObjectMeta.Finalizers, cancelJobFinalizer)
+		return ctrl.Result{}, handler.k8sClient.Update(handler.context, flinkJob)
+	}
+
+	var newStatus = handler.deriveStatus(&observed)
+	if newStatus != flinkJob.Status {
+		handler.createStatusChangeEvents(flinkJob, flinkJob.Status, newStatus)
+		flinkJob.Status = newStatus
+		flinkJob.Status.LastUpdateTime = time.Now().Format(time.RFC3339)
+		err = handler.k8sClient.Status().Update(handler.context, flinkJob)
+		if err != nil {
+			log.Error(err, "Failed to update FlinkJob status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if isJobTerminated(newStatus.State) {
+		log.Info("The job has terminated", "state", newStatus.State)
+		return ctrl.Result{}, nil
+	}
+	if observed.submitter == nil && len(newStatus.ID) == 0 &&
+		isClusterReadyForJob(observed.cluster) {
+		err = handler.createSubmitter(&observed)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Changes of the Flink job state do not trigger events, keep polling it
+	// while the job is active.
+	return ctrl.Result{RequeueAfter: jobStatePollingInterval}, nil
+}
+
+// Observes the cluster, the submitter and the Flink job.
+func (handler *_FlinkJobHandler) observe(
+	observed *_ObservedFlinkJobState) error {
+	var log = handler.log
+	var flinkJob = observed.flinkJob
+	var namespace = flinkJob.ObjectMeta.Namespace
+
+	// Cluster.
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{}
+	var err = handler.k8sClient.Get(
+		handler.context,
+		types.NamespacedName{Namespace: namespace, Name: flinkJob.Spec.ClusterName},
+		cluster)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get the cluster")
+			return err
+		}
+		log.Info("Cluster not found", "cluster", flinkJob.Spec.ClusterName)
+	} else {
+		observed.cluster = cluster
+	}
+
+	// Submitter.
+	var submitter = &batchv1.Job{}
+	err = handler.k8sClient.Get(
+		handler.context,
+		types.NamespacedName{
+			Namespace: namespace,
+			Name:      getSubmitterName(flinkJob.ObjectMeta.Name),
+		},
+		submitter)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get the submitter")
+			return err
+		}
+		log.Info("Submitter not found")
+	} else {
+		observed.submitter = submitter
+		var pods = &corev1.PodList{}
+		err = handler.k8sClient.List(
+			handler.context,
+			pods,
+			client.InNamespace(namespace),
+			client.MatchingLabels(map[string]string{
+				"job-name": submitter.ObjectMeta.Name,
+			}))
+		if err != nil {
+			log.Error(err, "Failed to get the submitter pods")
+			return err
+		}
+		observed.submitterPods = pods.Items
+	}
+
+	// Flink job ID, either recorded or from the output of the submitter.
+	observed.flinkJobID = flinkJob.Status.ID
+	if len(observed.flinkJobID) == 0 {
+		for i := range observed.submitterPods {
+			var jobID = getSubmittedJobID(&observed.submitterPods[i])
+			if len(jobID) > 0 {
+				observed.flinkJobID = jobID
+				break
+			}
+		}
+	}
+
+	// Flink job state.
+	if len(observed.flinkJobID) > 0 && isClusterReadyForJob(observed.cluster) {
+		var jobDetails, err = handler.flinkClient.GetJobDetails(
+			handler.context,
+			getFlinkAPIBaseURL(observed.cluster),
+			observed.flinkJobID)
+		if err != nil {
+			if flinkclient.IsNotFound(err) {
+				log.Info("Flink job not found", "ID", observed.flinkJobID)
+			} else {
+				log.Error(err, "Failed to get Flink job state")
+				observed.flinkAPIErr = err
+			}
+		} else {
+			observed.flinkJobState = jobDetails.State
+		}
+	}
+	return nil
+}
+
+// Derives the new status of the FlinkJob from the observed state.
+func (handler *_FlinkJobHandler) deriveStatus(
+	observed *_ObservedFlinkJobState) flinkoperatorv1alpha1.FlinkJobStatus {
+	var recorded = observed.flinkJob.Status
+	var status = flinkoperatorv1alpha1.FlinkJobStatus{
+		State:         recorded.State,
+		ID:            observed.flinkJobID,
+		FlinkJobState: recorded.FlinkJobState,
+	}
+
+	// The job has terminated, nothing will change anymore.
+	if isJobTerminated(recorded.State) {
+		return recorded
+	}
+
+	if observed.submitter != nil {
+		status.SubmitterName = observed.submitter.ObjectMeta.Name
+		var submitterPod *corev1.Pod
+		if len(observed.submitterPods) > 0 {
+			submitterPod = &observed.submitterPods[0]
+		}
+		status.SubmitterState = getSubmitterState(
+			observed.submitter, submitterPod)
+	}
+
+	switch {
+	case observed.cluster == nil:
+		status.State = flinkoperatorv1alpha1.JobState.Pending
+		status.FailureReason = fmt.Sprintf(
+			"cluster %v not found", observed.flinkJob.Spec.ClusterName)
+	case observed.cluster.Spec.JobSpec != nil:
+		status.State = flinkoperatorv1alpha1.JobState.Failed
+		status.FailureReason = fmt.Sprintf(
+			"cluster %v is not a session cluster", observed.cluster.ObjectMeta.Name)
+	case len(observed.flinkJobState) > 0:
+		status.FlinkJobState = observed.flinkJobState
+		status.State = getJobStateFromFlinkJobState(observed.flinkJobState)
+	case len(status.ID) > 0 && len(recorded.FlinkJobState) > 0:
+		// Keep the recorded state if the Flink REST API is temporarily not
+		// available.
+	case status.SubmitterState == flinkoperatorv1alpha1.JobState.Failed:
+		status.State = flinkoperatorv1alpha1.JobState.Failed
+		status.FailureReason = "failed to submit the job, see the logs of the submitter"
+	case status.SubmitterState == flinkoperatorv1alpha1.JobState.Succeeded &&
+		len(status.ID) == 0:
+		status.State = flinkoperatorv1alpha1.JobState.Unknown
+		status.FailureReason = "the job ID is not found in the output of the submitter"
+	default:
+		status.State = flinkoperatorv1alpha1.JobState.Pending
+	}
+	status.LastUpdateTime = recorded.LastUpdateTime
+	return status
+}
+
+func (handler *_FlinkJobHandler) createStatusChangeEvents(
+	flinkJob *flinkoperatorv1alpha1.FlinkJob,
+	oldStatus flinkoperatorv1alpha1.FlinkJobStatus,
+	newStatus flinkoperatorv1alpha1.FlinkJobStatus) {
+	if len(oldStatus.ID) == 0 && len(newStatus.ID) > 0 {
+		handler.eventRecorder.Event(
+			flinkJob,
+			"Normal",
+			"JobSubmitted",
+			fmt.Sprintf("Job submitted with ID %v", newStatus.ID))
+	}
+	if oldStatus.State != newStatus.State {
+		var eventType = "Normal"
+		var message = fmt.Sprintf(
+			"Job status changed: %v -> %v", oldStatus.State, newStatus.State)
+		if len(oldStatus.State) == 0 {
+			message = fmt.Sprintf("Job status: %v", newStatus.State)
+		}
+		if len(newStatus.FailureReason) > 0 {
+			eventType = "Warning"
+			message += ", reason: " + newStatus.FailureReason
+		}
+		handler.eventRecorder.Event(flinkJob, eventType, "StatusUpdate", message)
+	}
+}
+
+func (handler *_FlinkJobHandler) createSubmitter(
+	observed *_ObservedFlinkJobState) error {
+	var log = handler.log
+	var submitter, err = getDesiredSubmitter(observed.flinkJob, observed.cluster)
+	if err != nil {
+		log.Error(err, "Failed to get the desired submitter")
+		return err
+	}
+	log.Info("Creating submitter", "resource", *submitter)
+	err = handler.k8sClient.Create(handler.context, submitter)
+	if err != nil {
+		log.Error(err, "Failed to create submitter")
+		return err
+	}
+	log.Info("Submitter created")
+	recordJobSubmission(submitter.Namespace, flinkoperatorv1alpha1.JobMode.Client)
+	return nil
+}
+
+// Cancels the running Flink job, then removes the finalizer so the FlinkJob
+// and its submitter can be deleted.
+func (handler *_FlinkJobHandler) finalize(
+	observed *_ObservedFlinkJobState) error {
+	var log = handler.log
+	var flinkJob = observed.flinkJob
+	if !hasFinalizer(flinkJob.ObjectMeta.Finalizers, cancelJobFinalizer) {
+		return nil
+	}
+
+	// Delete the submitter first, so it will not submit the job again.
+	if observed.submitter != nil && observed.submitter.DeletionTimestamp == nil {
+		log.Info("Deleting submitter")
+		var err = handler.k8sClient.Delete(
+			handler.context,
+			observed.submitter,
+			client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to delete submitter")
+			return err
+		}
+	}
+
+	// Retry later if the state of the job is unknown, rather than leaving the
+	// job running.
+	if observed.flinkAPIErr != nil {
+		return observed.flinkAPIErr
+	}
+	var jobActive = len(observed.flinkJobState) > 0 &&
+		!flinkclient.IsJobTerminated(observed.flinkJobState)
+	if jobActive {
+		log.Info("Cancelling job", "ID", observed.flinkJobID)
+		var err = handler.flinkClient.CancelJob(
+			handler.context,
+			getFlinkAPIBaseURL(observed.cluster),
+			observed.flinkJobID)
+		if err != nil && !flinkclient.IsNotFound(err) {
+			log.Error(err, "Failed to cancel job")
+			return err
+		}
+		handler.eventRecorder.Event(
+			flinkJob,
+			"Normal",
+			"JobCancelled",
+			fmt.Sprintf("Job %v cancelled", observed.flinkJobID))
+	}
+
+	log.Info("Removing finalizer")
+	flinkJob.ObjectMeta.Finalizers = removeFinalizer(
+		flinkJob.ObjectMeta.Finalizers, cancelJobFinalizer)
+	return handler.k8sClient.Update(handler.context, flinkJob)
+}
+
+// Checks whether jobs can be submitted to the cluster and queried through the
+// Flink REST API.
+func isClusterReadyForJob(cluster *flinkoperatorv1alpha1.FlinkCluster) bool {
+	return cluster != nil && cluster.Spec.JobSpec == nil &&
+		(cluster.Status.State == flinkoperatorv1alpha1.ClusterState.Running ||
+			cluster.Status.State == flinkoperatorv1alpha1.ClusterState.Reconciling)
+}
+
+func hasFinalizer(finalizers []string, finalizer string) bool {
+	for _, f := range finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFinalizer(finalizers []string, finalizer string) []string {
+	var remaining = []string{}
+	for _, f := range finalizers {
+		if f != finalizer {
+			remaining = append(remaining, f)
+		}
+	}
+	return remaining
+}
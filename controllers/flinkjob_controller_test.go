@@ -0,0 +1,264 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient/flinkclienttest"
+	"gotest.tools/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newTestFlinkJobHandler(
+	server *flinkclienttest.FakeServer,
+	objects ...runtime.Object) (*_FlinkJobHandler, client.Client) {
+	var scheme = runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	flinkoperatorv1alpha1.AddToScheme(scheme)
+	var k8sClient = fake.NewFakeClientWithScheme(scheme, objects...)
+	return &_FlinkJobHandler{
+		k8sClient:   k8sClient,
+		flinkClient: server.NewClient(log.NullLogger{}),
+		request: ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "myjob",
+			},
+		},
+		context:       context.Background(),
+		log:           log.NullLogger{},
+		eventRecorder: record.NewFakeRecorder(10),
+	}, k8sClient
+}
+
+func newTestRunningSessionCluster() *flinkoperatorv1alpha1.FlinkCluster {
+	var uiPort int32 = 8081
+	return &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			ImageSpec: flinkoperatorv1alpha1.ImageSpec{Name: "flink:1.8.1"},
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{UI: &uiPort},
+			},
+		},
+		Status: flinkoperatorv1alpha1.FlinkClusterStatus{
+			State: flinkoperatorv1alpha1.ClusterState.Running,
+		},
+	}
+}
+
+func newTestFlinkJob() *flinkoperatorv1alpha1.FlinkJob {
+	var restartPolicy = corev1.RestartPolicyNever
+	return &flinkoperatorv1alpha1.FlinkJob{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "myjob"},
+		Spec: flinkoperatorv1alpha1.FlinkJobSpec{
+			ClusterName: "mycluster",
+			Job: flinkoperatorv1alpha1.JobSpec{
+				JarFile:       "/opt/flink/job/job.jar",
+				Args:          []string{"--input", "it's a file"},
+				RestartPolicy: &restartPolicy,
+			},
+		},
+	}
+}
+
+// Marks the submitter as succeeded and creates its pod with the output of
+// `flink run`.
+func completeTestSubmitter(
+	t *testing.T, k8sClient client.Client, jobID string) {
+	var submitter = &batchv1.Job{}
+	var err = k8sClient.Get(
+		context.Background(),
+		types.NamespacedName{Namespace: "default", Name: "myjob-submitter"},
+		submitter)
+	assert.NilError(t, err)
+	submitter.Status.Succeeded = 1
+	err = k8sClient.Update(context.Background(), submitter)
+	assert.NilError(t, err)
+
+	var pod = &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "myjob-submitter-abcde",
+			Labels:    map[string]string{"job-name": "myjob-submitter"},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodSucceeded,
+			ContainerStatuses: []corev1.ContainerStatus{
+				corev1.ContainerStatus{
+					Name: "main",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							Message: "Starting execution of program\n" +
+								"Job has been submitted with JobID " + jobID + "\n",
+						},
+					},
+				},
+			},
+		},
+	}
+	err = k8sClient.Create(context.Background(), pod)
+	assert.NilError(t, err)
+}
+
+func TestGetDesiredSubmitter(t *testing.T) {
+	var submitter, err = getDesiredSubmitter(
+		newTestFlinkJob(), newTestRunningSessionCluster())
+	assert.NilError(t, err)
+
+	assert.Equal(t, submitter.ObjectMeta.Name, "myjob-submitter")
+	assert.Equal(t, submitter.ObjectMeta.OwnerReferences[0].Kind, "FlinkJob")
+	var container = submitter.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, container.Image, "flink:1.8.1")
+	assert.DeepEqual(t, container.Args[:2], []string{"/bin/sh", "-c"})
+	assert.Equal(
+		t,
+		container.Args[2],
+		"'./bin/flink' 'run' '--jobmanager' "+
+			"'mycluster-jobmanager:8081' '--detached' "+
+			"'/opt/flink/job/job.jar' '--input' 'it'\\''s a file'"+
+			" > /tmp/submit.log 2>&1\n"+
+			"rc=$?\n"+
+			"cat /tmp/submit.log\n"+
+			"tail -c 4096 /tmp/submit.log > /dev/termination-log\n"+
+			"exit $rc")
+}
+
+func TestFlinkJobSubmitted(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var handler, k8sClient = newTestFlinkJobHandler(
+		server, newTestRunningSessionCluster(), newTestFlinkJob())
+
+	// The finalizer is added first.
+	var _, err = handler.reconcile()
+	assert.NilError(t, err)
+	var flinkJob = &flinkoperatorv1alpha1.FlinkJob{}
+	err = k8sClient.Get(
+		context.Background(), handler.request.NamespacedName, flinkJob)
+	assert.NilError(t, err)
+	assert.DeepEqual(
+		t, flinkJob.ObjectMeta.Finalizers, []string{cancelJobFinalizer})
+
+	// Then the submitter is created.
+	result, err := handler.reconcile()
+	assert.NilError(t, err)
+	assert.Assert(t, result.RequeueAfter > 0)
+	err = k8sClient.Get(
+		context.Background(), handler.request.NamespacedName, flinkJob)
+	assert.NilError(t, err)
+	assert.Equal(
+		t, flinkJob.Status.State, flinkoperatorv1alpha1.JobState.Pending)
+
+	// The job ID is parsed from the output of the submitter.
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	completeTestSubmitter(t, k8sClient, jobID)
+	_, err = handler.reconcile()
+	assert.NilError(t, err)
+	err = k8sClient.Get(
+		context.Background(), handler.request.NamespacedName, flinkJob)
+	assert.NilError(t, err)
+	assert.Equal(t, flinkJob.Status.ID, jobID)
+	assert.Equal(t, flinkJob.Status.SubmitterName, "myjob-submitter")
+	assert.Equal(
+		t,
+		flinkJob.Status.SubmitterState,
+		flinkoperatorv1alpha1.JobState.Succeeded)
+	assert.Equal(t, flinkJob.Status.FlinkJobState, flinkclient.JobState.Running)
+	assert.Equal(
+		t, flinkJob.Status.State, flinkoperatorv1alpha1.JobState.Running)
+
+	// Polling stops once the job has terminated.
+	server.SetJobState(jobID, flinkclient.JobState.Finished)
+	result, err = handler.reconcile()
+	assert.NilError(t, err)
+	assert.Equal(t, result.RequeueAfter, time.Duration(0))
+	err = k8sClient.Get(
+		context.Background(), handler.request.NamespacedName, flinkJob)
+	assert.NilError(t, err)
+	assert.Equal(
+		t, flinkJob.Status.State, flinkoperatorv1alpha1.JobState.Succeeded)
+}
+
+func TestFlinkJobNotSessionCluster(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var cluster = newTestRunningSessionCluster()
+	cluster.Spec.JobSpec = &flinkoperatorv1alpha1.JobSpec{}
+	var flinkJob = newTestFlinkJob()
+	flinkJob.ObjectMeta.Finalizers = []string{cancelJobFinalizer}
+	var handler, k8sClient = newTestFlinkJobHandler(server, cluster, flinkJob)
+
+	var _, err = handler.reconcile()
+	assert.NilError(t, err)
+
+	err = k8sClient.Get(
+		context.Background(), handler.request.NamespacedName, flinkJob)
+	assert.NilError(t, err)
+	assert.Equal(
+		t, flinkJob.Status.State, flinkoperatorv1alpha1.JobState.Failed)
+	assert.Equal(
+		t,
+		flinkJob.Status.FailureReason,
+		"cluster mycluster is not a session cluster")
+	var submitters = &batchv1.JobList{}
+	err = k8sClient.List(context.Background(), submitters)
+	assert.NilError(t, err)
+	assert.Equal(t, len(submitters.Items), 0)
+}
+
+func TestFlinkJobDeletedCancelsJob(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var flinkJob = newTestFlinkJob()
+	var now = metav1.Now()
+	flinkJob.ObjectMeta.Finalizers = []string{cancelJobFinalizer}
+	flinkJob.ObjectMeta.DeletionTimestamp = &now
+	flinkJob.Status.ID = jobID
+	flinkJob.Status.State = flinkoperatorv1alpha1.JobState.Running
+	var handler, k8sClient = newTestFlinkJobHandler(
+		server, newTestRunningSessionCluster(), flinkJob)
+
+	var _, err = handler.reconcile()
+	assert.NilError(t, err)
+
+	assert.Equal(
+		t, server.GetJob(jobID).State, flinkclient.JobState.Canceled)
+	// Gets into a new object, the decoder does not clear the finalizers of the
+	// old one when the stored list is empty.
+	var storedJob = &flinkoperatorv1alpha1.FlinkJob{}
+	err = k8sClient.Get(
+		context.Background(), handler.request.NamespacedName, storedJob)
+	assert.NilError(t, err)
+	assert.Equal(t, len(storedJob.ObjectMeta.Finalizers), 0)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Converter which converts the FlinkJob spec to the desired Kubernetes job
+// which submits the Flink job to the session cluster.
+
+// The submitter submits the job in detached mode, its output is written to
+// the termination log of the container, from which the Flink job ID is parsed.
+// The termination message is limited to 4096 bytes, so only the tail is kept.
+const submitterScript = `%s > /tmp/submit.log 2>&1
+rc=$?
+cat /tmp/submit.log
+tail -c 4096 /tmp/submit.log > /dev/termination-log
+exit $rc`
+
+// The output of `flink run --detached` or the SQL client containing the job
+// ID.
+var submittedJobIDRegexp = regexp.MustCompile(
+	`(?:Job has been submitted with JobID|Job ID:) ([0-9a-f]{32})`)
+
+// Gets the desired Kubernetes job which submits the FlinkJob to the session
+// cluster.
+func getDesiredSubmitter(
+	flinkJob *flinkoperatorv1alpha1.FlinkJob,
+	cluster *flinkoperatorv1alpha1.FlinkCluster) (*batchv1.Job, error) {
+	var jobSpec = &flinkJob.Spec.Job
+	var imageSpec = cluster.Spec.ImageSpec
+	var clusterName = cluster.ObjectMeta.Name
+	var jobManagerAddress = fmt.Sprintf(
+		"%s:%d",
+		getJobManagerServiceName(clusterName),
+		*cluster.Spec.JobManagerSpec.Ports.UI)
+	var labels = map[string]string{
+		"cluster":  clusterName,
+		"app":      "flink",
+		"flinkjob": flinkJob.ObjectMeta.Name,
+	}
+
+	var flinkRunArgs, submitEnvVars = getSubmitArgs(
+		jobSpec, jobManagerAddress, jobSpec.Savepoint, true /* detached */)
+	var quotedArgs = []string{}
+	for _, arg := range flinkRunArgs {
+		quotedArgs = append(quotedArgs, shellQuote(arg))
+	}
+	var envVars = []corev1.EnvVar{}
+	envVars = append(envVars, cluster.Spec.EnvVars...)
+	envVars = append(envVars, submitEnvVars...)
+	var volumes = jobSpec.Volumes
+	var mounts = jobSpec.Mounts
+	var initContainers []corev1.Container
+	var fetcher, fetcherVolumes, artifactsMount = getArtifactFetcher(
+		jobSpec, jobArtifactsDir)
+	if fetcher != nil {
+		initContainers = []corev1.Container{*fetcher}
+		volumes = append(append([]corev1.Volume{}, volumes...), fetcherVolumes...)
+		mounts = append(
+			append([]corev1.VolumeMount{}, mounts...), *artifactsMount)
+	}
+
+	var submitter = &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: flinkJob.ObjectMeta.Namespace,
+			Name:      getSubmitterName(flinkJob.ObjectMeta.Name),
+			OwnerReferences: []metav1.OwnerReference{
+				toFlinkJobOwnerReference(flinkJob)},
+			Labels: labels,
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					InitContainers: initContainers,
+					Containers: []corev1.Container{
+						corev1.Container{
+							Name:            "main",
+							Image:           imageSpec.Name,
+							ImagePullPolicy: imageSpec.PullPolicy,
+							Args: []string{
+								"/bin/sh",
+								"-c",
+								fmt.Sprintf(
+									submitterScript, strings.Join(quotedArgs, " ")),
+							},
+							Env:          envVars,
+							VolumeMounts: mounts,
+						},
+					},
+					RestartPolicy:    *jobSpec.RestartPolicy,
+					Volumes:          volumes,
+					ImagePullSecrets: imageSpec.PullSecrets,
+				},
+			},
+		},
+	}
+	var err = applyPodTemplate(&submitter.Spec.Template, jobSpec.PodTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return submitter, nil
+}
+
+// Gets the ID of the submitted Flink job from the termination message of the
+// submitter pod, returns an empty string if it is not available.
+func getSubmittedJobID(pod *corev1.Pod) string {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		var terminated = containerStatus.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		var match = submittedJobIDRegexp.FindStringSubmatch(terminated.Message)
+		if len(match) == 2 {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// Quotes the string for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// Converts the FlinkJob as owner reference for its submitter.
+func toFlinkJobOwnerReference(
+	flinkJob *flinkoperatorv1alpha1.FlinkJob) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion:         flinkoperatorv1alpha1.GroupVersion.String(),
+		Kind:               "FlinkJob",
+		Name:               flinkJob.Name,
+		UID:                flinkJob.UID,
+		Controller:         &[]bool{true}[0],
+		BlockOwnerDeletion: &[]bool{false}[0],
+	}
+}
+
+// Gets submitter job name
+func getSubmitterName(flinkJobName string) string {
+	return flinkJobName + "-submitter"
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The interval of polling the state of pending or in progress savepoints.
+var savepointPollingInterval = 5 * time.Second
+
+// FlinkSavepointReconciler reconciles a FlinkSavepoint object
+type FlinkSavepointReconciler struct {
+	client.Client
+	Log logr.Logger
+	mgr ctrl.Manager
+}
+
+// +kubebuilder:rbac:groups=flinkoperator.k8s.io,resources=flinksavepoints,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=flinkoperator.k8s.io,resources=flinksavepoints/status,verbs=get;update;patch
+
+// Reconcile triggers the savepoint for a FlinkSavepoint custom resource and
+// tracks it until completion.
+func (reconciler *FlinkSavepointReconciler) Reconcile(
+	request ctrl.Request) (ctrl.Result, error) {
+	var log = reconciler.Log.WithValues(
+		"flinksavepoint", request.NamespacedName)
+	var handler = _SavepointHandler{
+		k8sClient:     reconciler,
+		flinkClient:   newFlinkClient(log),
+		request:       request,
+		context:       context.Background(),
+		log:           log,
+		eventRecorder: reconciler.mgr.GetEventRecorderFor("FlinkOperator"),
+	}
+	return handler.reconcile()
+}
+
+// SetupWithManager registers this reconciler with the controller manager and
+// starts watching FlinkSavepoint resources.
+func (reconciler *FlinkSavepointReconciler) SetupWithManager(
+	mgr ctrl.Manager) error {
+	reconciler.mgr = mgr
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&flinkoperatorv1alpha1.FlinkSavepoint{}).
+		Complete(reconciler)
+}
+
+// _SavepointHandler holds the context and state for a reconcile request of
+// a FlinkSavepoint.
+type _SavepointHandler struct {
+	k8sClient     client.Client
+	flinkClient   *flinkclient.FlinkClient
+	request       ctrl.Request
+	context       context.Context
+	log           logr.Logger
+	eventRecorder record.EventRecorder
+}
+
+func (handler *_SavepointHandler) reconcile() (ctrl.Result, error) {
+	var log = handler.log
+
+	var savepoint = &flinkoperatorv1alpha1.FlinkSavepoint{}
+	var err = handler.k8sClient.Get(
+		handler.context, handler.request.NamespacedName, savepoint)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get the savepoint resource")
+			return ctrl.Result{}, err
+		}
+		log.Info("The savepoint has been deleted")
+		return ctrl.Result{}, nil
+	}
+
+	switch savepoint.Status.State {
+	case flinkoperatorv1alpha1.SavepointState.Succeeded,
+		flinkoperatorv1alpha1.SavepointState.Failed:
+		log.Info("The savepoint has completed", "state", savepoint.Status.State)
+		return ctrl.Result{}, nil
+	case flinkoperatorv1alpha1.SavepointState.InProgress:
+		return handler.checkSavepoint(savepoint)
+	default:
+		return handler.triggerSavepoint(savepoint)
+	}
+}
+
+// Triggers the savepoint if the job of the cluster is running.
+func (handler *_SavepointHandler) triggerSavepoint(
+	savepoint *flinkoperatorv1alpha1.FlinkSavepoint) (ctrl.Result, error) {
+	var log = handler.log
+
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{}
+	var err = handler.k8sClient.Get(
+		handler.context,
+		types.NamespacedName{
+			Namespace: savepoint.ObjectMeta.Namespace,
+			Name:      savepoint.Spec.ClusterName,
+		},
+		cluster)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get the cluster")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, handler.fail(
+			savepoint,
+			fmt.Sprintf("cluster %v not found", savepoint.Spec.ClusterName))
+	}
+	if cluster.Spec.JobSpec == nil {
+		return ctrl.Result{}, handler.fail(
+			savepoint,
+			fmt.Sprintf("cluster %v is not a job cluster", cluster.ObjectMeta.Name))
+	}
+
+	var jobStatus = cluster.Status.Components.Job
+	if jobStatus != nil && isJobTerminated(jobStatus.State) {
+		return ctrl.Result{}, handler.fail(
+			savepoint,
+			fmt.Sprintf("the job has terminated with state %v", jobStatus.State))
+	}
+	if jobStatus == nil || len(jobStatus.ID) == 0 ||
+		jobStatus.State != flinkoperatorv1alpha1.JobState.Running {
+		log.Info("Waiting for the job to be running")
+		if savepoint.Status.State != flinkoperatorv1alpha1.SavepointState.Pending {
+			savepoint.Status.State = flinkoperatorv1alpha1.SavepointState.Pending
+			err = handler.updateStatus(savepoint)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: savepointPollingInterval}, nil
+	}
+
+	var savepointsDir = savepoint.Spec.SavepointsDir
+	if savepointsDir == nil {
+		savepointsDir = cluster.Spec.JobSpec.SavepointsDir
+	}
+	log.Info("Triggering savepoint", "jobID", jobStatus.ID)
+	triggerID, err := handler.flinkClient.TriggerSavepoint(
+		handler.context,
+		getFlinkAPIBaseURL(cluster),
+		jobStatus.ID,
+		savepointsDir,
+		false /* cancelJob */)
+	if err != nil {
+		log.Error(err, "Failed to trigger savepoint")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Savepoint triggered", "triggerID", triggerID)
+	savepoint.Status.State = flinkoperatorv1alpha1.SavepointState.InProgress
+	savepoint.Status.JobID = jobStatus.ID
+	savepoint.Status.TriggerID = triggerID
+	savepoint.Status.TriggerTime = time.Now().Format(time.RFC3339)
+	err = handler.updateStatus(savepoint)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	handler.eventRecorder.Event(
+		savepoint,
+		"Normal",
+		"SavepointTriggered",
+		fmt.Sprintf("Savepoint triggered for job %v", jobStatus.ID))
+	return ctrl.Result{RequeueAfter: savepointPollingInterval}, nil
+}
+
+// Checks the status of the savepoint in progress, records the result once it
+// completes.
+func (handler *_SavepointHandler) checkSavepoint(
+	savepoint *flinkoperatorv1alpha1.FlinkSavepoint) (ctrl.Result, error) {
+	var log = handler.log
+
+	var cluster = &flinkoperatorv1alpha1.FlinkCluster{}
+	var err = handler.k8sClient.Get(
+		handler.context,
+		types.NamespacedName{
+			Namespace: savepoint.ObjectMeta.Namespace,
+			Name:      savepoint.Spec.ClusterName,
+		},
+		cluster)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get the cluster")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, handler.fail(
+			savepoint,
+			fmt.Sprintf(
+				"cluster %v was deleted before the savepoint completed",
+				savepoint.Spec.ClusterName))
+	}
+
+	savepointStatus, err := handler.flinkClient.GetSavepointStatus(
+		handler.context,
+		getFlinkAPIBaseURL(cluster),
+		savepoint.Status.JobID,
+		savepoint.Status.TriggerID)
+	if err != nil {
+		if flinkclient.IsNotFound(err) {
+			// JobManager has lost track of the savepoint, e.g., it restarted.
+			return ctrl.Result{}, handler.fail(
+				savepoint, "the savepoint operation is no longer known to Flink")
+		}
+		log.Error(err, "Failed to get savepoint status")
+		return ctrl.Result{}, err
+	}
+	if !savepointStatus.IsCompleted() {
+		log.Info("Savepoint in progress", "status", savepointStatus.Status.ID)
+		return ctrl.Result{RequeueAfter: savepointPollingInterval}, nil
+	}
+	if !savepointStatus.IsSuccessful() {
+		return ctrl.Result{}, handler.fail(
+			savepoint, savepointStatus.FailureReason())
+	}
+
+	var location = savepointStatus.Operation.Location
+	log.Info("Savepoint completed", "location", location)
+	savepoint.Status.State = flinkoperatorv1alpha1.SavepointState.Succeeded
+	savepoint.Status.Location = location
+	savepoint.Status.Timestamp = time.Now().Format(time.RFC3339)
+	err = handler.updateStatus(savepoint)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	handler.eventRecorder.Event(
+		savepoint,
+		"Normal",
+		"SavepointSucceeded",
+		fmt.Sprintf("Savepoint completed at %v", location))
+	return ctrl.Result{}, nil
+}
+
+// Marks the savepoint as failed with the reason.
+func (handler *_SavepointHandler) fail(
+	savepoint *flinkoperatorv1alpha1.FlinkSavepoint, reason string) error {
+	handler.log.Info("Savepoint failed", "reason", reason)
+	savepoint.Status.State = flinkoperatorv1alpha1.SavepointState.Failed
+	savepoint.Status.FailureReason = reason
+	savepoint.Status.Timestamp = time.Now().Format(time.RFC3339)
+	var err = handler.updateStatus(savepoint)
+	if err != nil {
+		return err
+	}
+	handler.eventRecorder.Event(
+		savepoint,
+		"Warning",
+		"SavepointFailed",
+		fmt.Sprintf("Savepoint failed: %v", reason))
+	return nil
+}
+
+// Writes the status of the savepoint. The status is owned by the handler, so it
+// is written to the latest version of the resource on conflicts, e.g., the
+// trigger ID is not lost, which would trigger the savepoint again.
+func (handler *_SavepointHandler) updateStatus(
+	savepoint *flinkoperatorv1alpha1.FlinkSavepoint) error {
+	var err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var latest = &flinkoperatorv1alpha1.FlinkSavepoint{}
+		var err = handler.k8sClient.Get(
+			handler.context, handler.request.NamespacedName, latest)
+		if err != nil {
+			return err
+		}
+		savepoint.Status.DeepCopyInto(&latest.Status)
+		return handler.k8sClient.Status().Update(handler.context, latest)
+	})
+	if err != nil {
+		handler.log.Error(
+			err, "Failed to update savepoint status", "status", savepoint.Status)
+	}
+	return err
+}
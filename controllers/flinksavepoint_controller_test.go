@@ -0,0 +1,244 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient/flinkclienttest"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newTestSavepointHandler(
+	server *flinkclienttest.FakeServer,
+	objects ...runtime.Object) (*_SavepointHandler, client.Client) {
+	var scheme = runtime.NewScheme()
+	flinkoperatorv1alpha1.AddToScheme(scheme)
+	var k8sClient = fake.NewFakeClientWithScheme(scheme, objects...)
+	return &_SavepointHandler{
+		k8sClient:   k8sClient,
+		flinkClient: server.NewClient(log.NullLogger{}),
+		request: ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "mysavepoint",
+			},
+		},
+		context:       context.Background(),
+		log:           log.NullLogger{},
+		eventRecorder: record.NewFakeRecorder(10),
+	}, k8sClient
+}
+
+func newTestRunningJobCluster(jobID string) *flinkoperatorv1alpha1.FlinkCluster {
+	var uiPort int32 = 8081
+	var savepointsDir = "gs://my-bucket/savepoints/"
+	return &flinkoperatorv1alpha1.FlinkCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mycluster"},
+		Spec: flinkoperatorv1alpha1.FlinkClusterSpec{
+			JobManagerSpec: flinkoperatorv1alpha1.JobManagerSpec{
+				Ports: flinkoperatorv1alpha1.JobManagerPorts{UI: &uiPort},
+			},
+			JobSpec: &flinkoperatorv1alpha1.JobSpec{
+				SavepointsDir: &savepointsDir,
+			},
+		},
+		Status: flinkoperatorv1alpha1.FlinkClusterStatus{
+			State: flinkoperatorv1alpha1.ClusterState.Running,
+			Components: flinkoperatorv1alpha1.FlinkClusterComponentsStatus{
+				Job: &flinkoperatorv1alpha1.JobStatus{
+					Name:  "mycluster-job",
+					ID:    jobID,
+					State: flinkoperatorv1alpha1.JobState.Running,
+				},
+			},
+		},
+	}
+}
+
+func newTestSavepoint(clusterName string) *flinkoperatorv1alpha1.FlinkSavepoint {
+	return &flinkoperatorv1alpha1.FlinkSavepoint{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mysavepoint"},
+		Spec: flinkoperatorv1alpha1.FlinkSavepointSpec{
+			ClusterName: clusterName,
+		},
+	}
+}
+
+func TestSavepointSucceeded(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var handler, k8sClient = newTestSavepointHandler(
+		server, newTestRunningJobCluster(jobID), newTestSavepoint("mycluster"))
+
+	var result, err = handler.reconcile()
+	assert.NilError(t, err)
+	assert.Assert(t, result.RequeueAfter > 0)
+
+	var savepoint = &flinkoperatorv1alpha1.FlinkSavepoint{}
+	err = k8sClient.Get(
+		context.Background(), handler.request.NamespacedName, savepoint)
+	assert.NilError(t, err)
+	assert.Equal(
+		t,
+		savepoint.Status.State,
+		flinkoperatorv1alpha1.SavepointState.InProgress)
+	assert.Equal(t, savepoint.Status.JobID, jobID)
+	assert.Assert(t, len(savepoint.Status.TriggerID) > 0)
+
+	result, err = handler.reconcile()
+	assert.NilError(t, err)
+	assert.Equal(t, result.RequeueAfter, time.Duration(0))
+
+	err = k8sClient.Get(
+		context.Background(), handler.request.NamespacedName, savepoint)
+	assert.NilError(t, err)
+	assert.Equal(
+		t,
+		savepoint.Status.State,
+		flinkoperatorv1alpha1.SavepointState.Succeeded)
+	assert.Assert(t, strings.HasPrefix(
+		savepoint.Status.Location, "gs://my-bucket/savepoints/savepoint-"))
+	assert.Assert(t, len(savepoint.Status.Timestamp) > 0)
+}
+
+func TestSavepointFailed(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	server.SavepointFailure = true
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var handler, k8sClient = newTestSavepointHandler(
+		server, newTestRunningJobCluster(jobID), newTestSavepoint("mycluster"))
+
+	var _, err = handler.reconcile()
+	assert.NilError(t, err)
+	_, err = handler.reconcile()
+	assert.NilError(t, err)
+
+	var savepoint = &flinkoperatorv1alpha1.FlinkSavepoint{}
+	err = k8sClient.Get(
+		context.Background(), handler.request.NamespacedName, savepoint)
+	assert.NilError(t, err)
+	assert.Equal(
+		t, savepoint.Status.State, flinkoperatorv1alpha1.SavepointState.Failed)
+	assert.Equal(
+		t,
+		savepoint.Status.FailureReason,
+		"java.util.concurrent.CompletionException")
+}
+
+func TestSavepointClusterNotFound(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var handler, k8sClient = newTestSavepointHandler(
+		server, newTestSavepoint("nonexistent"))
+
+	var _, err = handler.reconcile()
+	assert.NilError(t, err)
+
+	var savepoint = &flinkoperatorv1alpha1.FlinkSavepoint{}
+	err = k8sClient.Get(
+		context.Background(), handler.request.NamespacedName, savepoint)
+	assert.NilError(t, err)
+	assert.Equal(
+		t, savepoint.Status.State, flinkoperatorv1alpha1.SavepointState.Failed)
+	assert.Equal(
+		t, savepoint.Status.FailureReason, "cluster nonexistent not found")
+	assert.Equal(t, len(server.Requests()), 0)
+}
+
+// A client of which the first status updates fail with a conflict, as if the
+// resource had been changed since it was read.
+type _ConflictingStatusClient struct {
+	client.Client
+	conflicts int
+}
+
+func (c *_ConflictingStatusClient) Status() client.StatusWriter {
+	return &_ConflictingStatusWriter{c.Client.Status(), c}
+}
+
+type _ConflictingStatusWriter struct {
+	client.StatusWriter
+	client *_ConflictingStatusClient
+}
+
+func (w *_ConflictingStatusWriter) Update(
+	ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if w.client.conflicts > 0 {
+		w.client.conflicts--
+		return errors.NewConflict(
+			schema.GroupResource{
+				Group:    "flinkoperator.k8s.io",
+				Resource: "flinksavepoints",
+			},
+			"mysavepoint",
+			fmt.Errorf("the object has been modified"))
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+// Tests the trigger ID is recorded on a conflict, so the savepoint is not
+// triggered again by the next reconcile request.
+func TestSavepointTriggerIDRecordedOnConflict(t *testing.T) {
+	var server = flinkclienttest.NewFakeServer()
+	defer server.Close()
+	var jobID = server.AddJob("job", flinkclient.JobState.Running)
+	var handler, k8sClient = newTestSavepointHandler(
+		server, newTestRunningJobCluster(jobID), newTestSavepoint("mycluster"))
+	handler.k8sClient = &_ConflictingStatusClient{Client: k8sClient, conflicts: 1}
+
+	var _, err = handler.reconcile()
+	assert.NilError(t, err)
+
+	var savepoint = &flinkoperatorv1alpha1.FlinkSavepoint{}
+	err = k8sClient.Get(
+		context.Background(), handler.request.NamespacedName, savepoint)
+	assert.NilError(t, err)
+	assert.Equal(
+		t,
+		savepoint.Status.State,
+		flinkoperatorv1alpha1.SavepointState.InProgress)
+	assert.Assert(t, len(savepoint.Status.TriggerID) > 0)
+
+	_, err = handler.reconcile()
+	assert.NilError(t, err)
+	var triggers = 0
+	for _, request := range server.Requests() {
+		if request == "POST /jobs/"+jobID+"/savepoints" {
+			triggers++
+		}
+	}
+	assert.Equal(t, triggers, 1)
+}
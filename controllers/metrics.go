@@ -0,0 +1,282 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/googlecloudplatform/flink-operator/controllers/flinkclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Prometheus metrics of the operator, served with the metrics of
+// controller-runtime on the address of the `--metrics-addr` flag.
+
+const metricsNamespace = "flinkoperator"
+
+// The phases of reconciling a FlinkCluster, see _FlinkClusterHandler.
+var reconcilePhase = struct {
+	Observe string
+	Derive  string
+	Update  string
+	Act     string
+}{
+	Observe: "observe",
+	Derive:  "derive",
+	Update:  "update",
+	Act:     "act",
+}
+
+var reconcilePhaseDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_phase_duration_seconds",
+		Help:      "Duration of each phase of reconciling a FlinkCluster.",
+	},
+	[]string{"phase"})
+
+var flinkAPIRequestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "flink_api_request_duration_seconds",
+		Help:      "Latency of the requests to the Flink REST API.",
+	},
+	[]string{"method", "endpoint"})
+
+var flinkAPIRequestErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "flink_api_request_errors_total",
+		Help: "The number of failed requests to the Flink REST API, code is " +
+			"\"unreachable\" if there is no response.",
+	},
+	[]string{"method", "endpoint", "code"})
+
+var jobSubmissions = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "job_submissions_total",
+		Help:      "The number of Flink jobs submitted by the operator.",
+	},
+	[]string{"namespace", "mode"})
+
+var jobRestarts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "job_restarts_total",
+		Help:      "The number of failed Flink jobs restarted by the operator.",
+	},
+	[]string{"namespace"})
+
+var jobRescales = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "job_rescales_total",
+		Help:      "The number of Flink jobs rescaled by the autoscaler.",
+	},
+	[]string{"namespace"})
+
+var jobTimeToRunning = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "job_time_to_running_seconds",
+		Help:      "Time from the submission of a Flink job until it is running.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 10),
+	},
+	[]string{"namespace"})
+
+var clustersDesc = prometheus.NewDesc(
+	metricsNamespace+"_clusters",
+	"The number of FlinkClusters by namespace and state.",
+	[]string{"namespace", "state"},
+	nil)
+
+var jobsDesc = prometheus.NewDesc(
+	metricsNamespace+"_jobs",
+	"The number of Flink jobs of FlinkClusters by namespace and state.",
+	[]string{"namespace", "state"},
+	nil)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcilePhaseDuration,
+		flinkAPIRequestDuration,
+		flinkAPIRequestErrors,
+		jobSubmissions,
+		jobRestarts,
+		jobRescales,
+		jobTimeToRunning)
+}
+
+// _ClusterStateCollector collects the number of FlinkClusters and their jobs
+// by state from the cache of the manager when the metrics are scraped, so the
+// counts are consistent with the recorded status of the clusters.
+type _ClusterStateCollector struct {
+	k8sClient client.Client
+	log       logr.Logger
+}
+
+// Describe implements prometheus.Collector.
+func (collector *_ClusterStateCollector) Describe(
+	ch chan<- *prometheus.Desc) {
+	ch <- clustersDesc
+	ch <- jobsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (collector *_ClusterStateCollector) Collect(ch chan<- prometheus.Metric) {
+	var clusters = &flinkoperatorv1alpha1.FlinkClusterList{}
+	var err = collector.k8sClient.List(context.Background(), clusters)
+	if err != nil {
+		collector.log.Error(err, "Failed to list clusters for metrics")
+		ch <- prometheus.NewInvalidMetric(clustersDesc, err)
+		ch <- prometheus.NewInvalidMetric(jobsDesc, err)
+		return
+	}
+
+	var clusterCounts = map[[2]string]int{}
+	var jobCounts = map[[2]string]int{}
+	for _, cluster := range clusters.Items {
+		clusterCounts[[2]string{cluster.Namespace, cluster.Status.State}]++
+		var jobStatus = cluster.Status.Components.Job
+		if jobStatus != nil {
+			jobCounts[[2]string{cluster.Namespace, jobStatus.State}]++
+		}
+	}
+	for labels, count := range clusterCounts {
+		ch <- prometheus.MustNewConstMetric(
+			clustersDesc, prometheus.GaugeValue, float64(count), labels[0], labels[1])
+	}
+	for labels, count := range jobCounts {
+		ch <- prometheus.MustNewConstMetric(
+			jobsDesc, prometheus.GaugeValue, float64(count), labels[0], labels[1])
+	}
+}
+
+// Records the duration of a reconcile phase which started at the time.
+func observeReconcilePhase(phase string, start time.Time) {
+	reconcilePhaseDuration.WithLabelValues(phase).Observe(
+		time.Since(start).Seconds())
+}
+
+// Records a job submitted by the operator.
+func recordJobSubmission(namespace string, mode string) {
+	jobSubmissions.WithLabelValues(namespace, mode).Inc()
+}
+
+// Records a failed job restarted by the operator.
+func recordJobRestart(namespace string) {
+	jobRestarts.WithLabelValues(namespace).Inc()
+}
+
+// Records a job rescaled by the autoscaler.
+func recordJobRescale(namespace string) {
+	jobRescales.WithLabelValues(namespace).Inc()
+}
+
+// Records the time it took the job to run once it turns Running. The job is
+// submitted when the submitter is created, or when JobManager starts it in
+// Application mode.
+func recordJobRunning(
+	observedState *_ObservedClusterState,
+	oldJobStatus *flinkoperatorv1alpha1.JobStatus,
+	newJobStatus *flinkoperatorv1alpha1.JobStatus) {
+	var running = flinkoperatorv1alpha1.JobState.Running
+	if newJobStatus == nil || newJobStatus.State != running ||
+		(oldJobStatus != nil && oldJobStatus.State == running) {
+		return
+	}
+	var submitTime time.Time
+	if isApplicationMode(observedState.cluster) {
+		if observedState.flinkJob != nil && observedState.flinkJob.StartTime > 0 {
+			submitTime = time.Unix(
+				0, observedState.flinkJob.StartTime*int64(time.Millisecond))
+		}
+	} else if observedState.job != nil {
+		submitTime = observedState.job.CreationTimestamp.Time
+	}
+	if submitTime.IsZero() {
+		return
+	}
+	jobTimeToRunning.WithLabelValues(observedState.cluster.Namespace).Observe(
+		time.Since(submitTime).Seconds())
+}
+
+// _FlinkAPITransport records the latency and the errors of the requests to
+// the Flink REST API.
+type _FlinkAPITransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (transport *_FlinkAPITransport) RoundTrip(
+	req *http.Request) (*http.Response, error) {
+	var endpoint = getFlinkAPIEndpoint(req.URL.Path)
+	var start = time.Now()
+	var resp, err = transport.next.RoundTrip(req)
+	flinkAPIRequestDuration.WithLabelValues(req.Method, endpoint).Observe(
+		time.Since(start).Seconds())
+	if err != nil {
+		flinkAPIRequestErrors.WithLabelValues(
+			req.Method, endpoint, "unreachable").Inc()
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		flinkAPIRequestErrors.WithLabelValues(
+			req.Method, endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+	}
+	return resp, err
+}
+
+// Gets the endpoint of the Flink REST API from the path of the request, with
+// the IDs replaced by the placeholders of the Flink docs to keep the
+// cardinality of the metrics low, e.g., "/jobs/:jobid/savepoints".
+func getFlinkAPIEndpoint(path string) string {
+	var segments = strings.Split(strings.Trim(path, "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		switch segments[i-1] {
+		case "jobs":
+			if segments[i] != "overview" {
+				segments[i] = ":jobid"
+			}
+		case "savepoints":
+			segments[i] = ":triggerid"
+		case "vertices":
+			segments[i] = ":vertexid"
+		case "jars":
+			if segments[i] != "upload" {
+				segments[i] = ":jarid"
+			}
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// Creates a Flink REST API client which records the metrics of its requests.
+func newFlinkClient(log logr.Logger) *flinkclient.FlinkClient {
+	var flinkClient = flinkclient.NewFlinkClient(log)
+	flinkClient.HTTPClient.Transport = &_FlinkAPITransport{
+		next: http.DefaultTransport,
+	}
+	return flinkClient
+}
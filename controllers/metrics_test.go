@@ -0,0 +1,99 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestGetFlinkAPIEndpoint(t *testing.T) {
+	var jobID = "ec8ae8d6e6e3c9e5d3fd1b1f3c1a7f0d"
+	var testCases = map[string]string{
+		"/jobs/overview":                         "/jobs/overview",
+		"/jobs/" + jobID:                         "/jobs/:jobid",
+		"/jobs/" + jobID + "/checkpoints":        "/jobs/:jobid/checkpoints",
+		"/jobs/" + jobID + "/savepoints":         "/jobs/:jobid/savepoints",
+		"/jobs/" + jobID + "/savepoints/trigger": "/jobs/:jobid/savepoints/:triggerid",
+		"/jars/upload":                           "/jars/upload",
+		"/jars/abc_job.jar/run":                  "/jars/:jarid/run",
+		"/taskmanagers":                          "/taskmanagers",
+	}
+	for path, endpoint := range testCases {
+		assert.Equal(t, getFlinkAPIEndpoint(path), endpoint)
+	}
+
+	var vertexID = "cbc357ccb763df2852fee8c4fc7d55f2"
+	assert.Equal(
+		t,
+		getFlinkAPIEndpoint(
+			"/jobs/"+jobID+"/vertices/"+vertexID+"/subtasks/metrics"),
+		"/jobs/:jobid/vertices/:vertexid/subtasks/metrics")
+}
+
+func TestClusterStateCollector(t *testing.T) {
+	var newCluster = func(
+		namespace string,
+		name string,
+		state string,
+		jobState string) *flinkoperatorv1alpha1.FlinkCluster {
+		var cluster = &flinkoperatorv1alpha1.FlinkCluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Status:     flinkoperatorv1alpha1.FlinkClusterStatus{State: state},
+		}
+		if len(jobState) > 0 {
+			cluster.Status.Components.Job =
+				&flinkoperatorv1alpha1.JobStatus{State: jobState}
+		}
+		return cluster
+	}
+	var scheme = runtime.NewScheme()
+	flinkoperatorv1alpha1.AddToScheme(scheme)
+	var k8sClient = fake.NewFakeClientWithScheme(
+		scheme,
+		newCluster("default", "session", "Running", ""),
+		newCluster("default", "job1", "Running", "Running"),
+		newCluster("default", "job2", "Reconciling", "Pending"),
+		newCluster("team-a", "job3", "Running", "Running"))
+	var collector = &_ClusterStateCollector{
+		k8sClient: k8sClient,
+		log:       log.NullLogger{},
+	}
+
+	var expected = `
+# HELP flinkoperator_clusters The number of FlinkClusters by namespace and state.
+# TYPE flinkoperator_clusters gauge
+flinkoperator_clusters{namespace="default",state="Reconciling"} 1
+flinkoperator_clusters{namespace="default",state="Running"} 2
+flinkoperator_clusters{namespace="team-a",state="Running"} 1
+# HELP flinkoperator_jobs The number of Flink jobs of FlinkClusters by namespace and state.
+# TYPE flinkoperator_jobs gauge
+flinkoperator_jobs{namespace="default",state="Pending"} 1
+flinkoperator_jobs{namespace="default",state="Running"} 1
+flinkoperator_jobs{namespace="team-a",state="Running"} 1
+`
+	assert.NilError(
+		t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	flinkoperatorv1alpha1 "github.com/googlecloudplatform/flink-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Converter which converts the monitoring spec of a FlinkCluster to the
+// PodMonitor or ServiceMonitor of the Prometheus Operator. The monitors are
+// unstructured objects, so the operator works without the CRDs of the
+// Prometheus Operator unless a monitor is specified.
+
+// The name of the port of the Flink Prometheus reporter.
+const metricsPortName = "metrics"
+
+// The class of the Flink Prometheus reporter.
+const prometheusReporterClass = "org.apache.flink.metrics.prometheus.PrometheusReporter"
+
+// Creates an empty monitor object of the kind.
+func newMonitor(kind string) *unstructured.Unstructured {
+	var monitor = &unstructured.Unstructured{}
+	monitor.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "monitoring.coreos.com",
+		Version: "v1",
+		Kind:    kind,
+	})
+	return monitor
+}
+
+// Gets the desired monitor of the kind, returns nil if the monitoring spec
+// does not ask for it. A PodMonitor selects the JobManager and TaskManager
+// pods, a ServiceMonitor selects the JobManager service only, as TaskManagers
+// have no service unless they are run as a StatefulSet, so the metrics of
+// TaskManagers are not scraped through it.
+func getDesiredMonitor(
+	flinkCluster *flinkoperatorv1alpha1.FlinkCluster,
+	kind string) (*unstructured.Unstructured, error) {
+	var monitoringSpec = flinkCluster.Spec.Monitoring
+	if monitoringSpec == nil || monitoringSpec.Monitor == nil ||
+		*monitoringSpec.Monitor != kind {
+		return nil, nil
+	}
+
+	var clusterName = flinkCluster.ObjectMeta.Name
+	// Only values of JSON types are allowed in unstructured objects.
+	var endpoint = map[string]interface{}{"port": metricsPortName}
+	if monitoringSpec.ScrapeInterval != nil {
+		endpoint["interval"] = *monitoringSpec.ScrapeInterval
+	}
+	var spec map[string]interface{}
+	switch kind {
+	case flinkoperatorv1alpha1.MonitorKind.PodMonitor:
+		spec = map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					"cluster": clusterName,
+					"app":     "flink",
+				},
+				// The pods of the job submitter have no metrics.
+				"matchExpressions": []interface{}{
+					map[string]interface{}{
+						"key":      "component",
+						"operator": "In",
+						"values":   []interface{}{"jobmanager", "taskmanager"},
+					},
+				},
+			},
+			"podMetricsEndpoints": []interface{}{endpoint},
+		}
+	case flinkoperatorv1alpha1.MonitorKind.ServiceMonitor:
+		spec = map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					"cluster":   clusterName,
+					"app":       "flink",
+					"component": "jobmanager",
+				},
+			},
+			"endpoints": []interface{}{endpoint},
+		}
+	default:
+		return nil, fmt.Errorf("unknown monitor kind: %v", kind)
+	}
+
+	var labels = map[string]string{"cluster": clusterName, "app": "flink"}
+	for key, value := range monitoringSpec.MonitorLabels {
+		labels[key] = value
+	}
+	var monitor = newMonitor(kind)
+	monitor.SetNamespace(flinkCluster.ObjectMeta.Namespace)
+	monitor.SetName(getMonitorName(clusterName))
+	monitor.SetOwnerReferences(
+		[]metav1.OwnerReference{toOwnerReference(flinkCluster)})
+	monitor.SetLabels(labels)
+	monitor.Object["spec"] = spec
+	return monitor, nil
+}
+
+func getMonitorName(clusterName string) string {
+	return clusterName + "-flink-metrics"
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2019 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// Converter which merges the pod template of a component spec onto the pod
+// template generated by the operator, the way `kubectl patch` merges a
+// strategic merge patch, e.g., containers are merged by their names. The
+// fields the operator relies on are restored after the merge.
+
+// Merges the pod template override onto the generated pod template in place.
+func applyPodTemplate(
+	podTemplate *corev1.PodTemplateSpec,
+	override *corev1.PodTemplateSpec) error {
+	if override == nil {
+		return nil
+	}
+
+	var original, err = json.Marshal(podTemplate)
+	if err != nil {
+		return err
+	}
+	patch, err := getPodTemplatePatch(override)
+	if err != nil {
+		return err
+	}
+	merged, err := strategicpatch.StrategicMergePatch(
+		original, patch, corev1.PodTemplateSpec{})
+	if err != nil {
+		return err
+	}
+	var result corev1.PodTemplateSpec
+	err = json.Unmarshal(merged, &result)
+	if err != nil {
+		return err
+	}
+	restoreOperatorFields(&result, podTemplate)
+	*podTemplate = result
+	return nil
+}
+
+// Converts the pod template override to a strategic merge patch. Null values
+// delete fields in a patch, so the null values the typed override marshals
+// to, e.g., `creationTimestamp`, are removed.
+func getPodTemplatePatch(override *corev1.PodTemplateSpec) ([]byte, error) {
+	var data, err = json.Marshal(override)
+	if err != nil {
+		return nil, err
+	}
+	var patch map[string]interface{}
+	err = json.Unmarshal(data, &patch)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(removeNullValues(patch))
+}
+
+func removeNullValues(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if child == nil {
+				delete(value, key)
+			} else {
+				value[key] = removeNullValues(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range value {
+			value[i] = removeNullValues(child)
+		}
+	}
+	return value
+}
+
+// Restores the fields of the generated pod template which the operator relies
+// on: the labels selected by the services and the controllers, the
+// annotations, the image, command and arguments of the main container, which
+// is the first container, and the env variables, volumes, mounts and init
+// containers it generated.
+func restoreOperatorFields(
+	podTemplate *corev1.PodTemplateSpec, generated *corev1.PodTemplateSpec) {
+	podTemplate.ObjectMeta.Labels = restoreStringMap(
+		podTemplate.ObjectMeta.Labels, generated.ObjectMeta.Labels)
+	podTemplate.ObjectMeta.Annotations = restoreStringMap(
+		podTemplate.ObjectMeta.Annotations, generated.ObjectMeta.Annotations)
+
+	var podSpec = &podTemplate.Spec
+	var generatedSpec = &generated.Spec
+	podSpec.InitContainers = restoreContainers(
+		podSpec.InitContainers, generatedSpec.InitContainers)
+	podSpec.Volumes = restoreVolumes(podSpec.Volumes, generatedSpec.Volumes)
+	if generatedSpec.RestartPolicy != "" {
+		podSpec.RestartPolicy = generatedSpec.RestartPolicy
+	}
+	if generatedSpec.ServiceAccountName != "" {
+		podSpec.ServiceAccountName = generatedSpec.ServiceAccountName
+	}
+
+	var generatedMain = &generatedSpec.Containers[0]
+	for i := range podSpec.Containers {
+		var container = &podSpec.Containers[i]
+		if container.Name != generatedMain.Name {
+			continue
+		}
+		container.Image = generatedMain.Image
+		container.Command = generatedMain.Command
+		container.Args = generatedMain.Args
+		container.Env = restoreEnvVars(container.Env, generatedMain.Env)
+		container.VolumeMounts = restoreVolumeMounts(
+			container.VolumeMounts, generatedMain.VolumeMounts)
+	}
+}
+
+func restoreStringMap(
+	values map[string]string, generated map[string]string) map[string]string {
+	if len(generated) == 0 {
+		return values
+	}
+	if values == nil {
+		values = map[string]string{}
+	}
+	for key, value := range generated {
+		values[key] = value
+	}
+	return values
+}
+
+func restoreContainers(
+	containers []corev1.Container,
+	generated []corev1.Container) []corev1.Container {
+	for _, generatedContainer := range generated {
+		var found = false
+		for i := range containers {
+			if containers[i].Name == generatedContainer.Name {
+				containers[i] = generatedContainer
+				found = true
+				break
+			}
+		}
+		if !found {
+			containers = append(containers, generatedContainer)
+		}
+	}
+	return containers
+}
+
+func restoreVolumes(
+	volumes []corev1.Volume, generated []corev1.Volume) []corev1.Volume {
+	for _, generatedVolume := range generated {
+		var found = false
+		for i := range volumes {
+			if volumes[i].Name == generatedVolume.Name {
+				volumes[i] = generatedVolume
+				found = true
+				break
+			}
+		}
+		if !found {
+			volumes = append(volumes, generatedVolume)
+		}
+	}
+	return volumes
+}
+
+func restoreEnvVars(
+	envVars []corev1.EnvVar, generated []corev1.EnvVar) []corev1.EnvVar {
+	for _, generatedEnvVar := range generated {
+		var found = false
+		for i := range envVars {
+			if envVars[i].Name == generatedEnvVar.Name {
+				envVars[i] = generatedEnvVar
+				found = true
+				break
+			}
+		}
+		if !found {
+			envVars = append(envVars, generatedEnvVar)
+		}
+	}
+	return envVars
+}
+
+func restoreVolumeMounts(
+	mounts []corev1.VolumeMount,
+	generated []corev1.VolumeMount) []corev1.VolumeMount {
+	for _, generatedMount := range generated {
+		var found = false
+		for i := range mounts {
+			if mounts[i].MountPath == generatedMount.MountPath {
+				mounts[i] = generatedMount
+				found = true
+				break
+			}
+		}
+		if !found {
+			mounts = append(mounts, generatedMount)
+		}
+	}
+	return mounts
+}
@@ -74,6 +74,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = (&controllers.FlinkSavepointReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("FlinkSavepoint"),
+	}).SetupWithManager(mgr)
+	if err != nil {
+		setupLog.Error(err, "Unable to create controller", "controller", "FlinkSavepoint")
+		os.Exit(1)
+	}
+
+	err = (&controllers.FlinkJobReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("FlinkJob"),
+	}).SetupWithManager(mgr)
+	if err != nil {
+		setupLog.Error(err, "Unable to create controller", "controller", "FlinkJob")
+		os.Exit(1)
+	}
+
 	// Set up webhooks for the custom resource.
 	// Disable it with `FLINK_OPERATOR_ENABLE_WEBHOOKS=false` when we run locally.
 	if os.Getenv("FLINK_OPERATOR_ENABLE_WEBHOOKS") != "false" {
@@ -82,6 +100,11 @@ func main() {
 			setupLog.Error(err, "Unable to setup webhooks", "webhook", "FlinkCluster")
 			os.Exit(1)
 		}
+		err = (&flinkoperatorv1alpha1.FlinkJob{}).SetupWebhookWithManager(mgr)
+		if err != nil {
+			setupLog.Error(err, "Unable to setup webhooks", "webhook", "FlinkJob")
+			os.Exit(1)
+		}
 	}
 
 	// +kubebuilder:scaffold:builder